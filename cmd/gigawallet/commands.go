@@ -2,13 +2,19 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 	"net/url"
 	"os"
+	"strings"
 
 	giga "github.com/dogecoinfoundation/gigawallet/pkg"
+	"github.com/dogecoinfoundation/gigawallet/pkg/chaintracker"
+	"github.com/dogecoinfoundation/gigawallet/pkg/dogecoin"
+	"github.com/dogecoinfoundation/gigawallet/pkg/store"
 )
 
 /*
@@ -39,6 +45,131 @@ func SetSyncHeight(blockHeight string, c giga.Config, s SubCommandArgs) error {
 	return postURL(url, "")
 }
 
+// SkippedBlocks lists the blocks that GigaWallet skipped because they
+// could not be decoded, or with "retry", asks GigaWallet to re-process the
+// skipped blocks that can now be decoded (e.g. after an upgrade) which
+// re-scans the blockchain from the lowest such block.
+func SkippedBlocks(action string, c giga.Config, s SubCommandArgs) error {
+	if action == "retry" {
+		url, err := adminAPIURL(c, s, "/admin/skippedblocks/retry")
+		if err != nil {
+			return err
+		}
+		fmt.Println("Calling", url)
+		return postURL(url, "")
+	}
+	url, err := adminAPIURL(c, s, "/admin/skippedblocks")
+	if err != nil {
+		return err
+	}
+	body, err := getURL(url)
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(body))
+	return nil
+}
+
+// Chain shows the sync status of GigaWallet's chain follower, or controls
+// it: "pause" and "resume" following the chain, "restart" the follower (if
+// stuck) or "rollback" to a block height and re-scan the chain from there.
+func Chain(action string, blockHeight string, c giga.Config, s SubCommandArgs) error {
+	path := ""
+	switch action {
+	case "", "status":
+		url, err := adminAPIURL(c, s, "/admin/chain")
+		if err != nil {
+			return err
+		}
+		body, err := getURL(url)
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(body))
+		return nil
+	case "pause", "resume", "restart":
+		path = "/admin/chain/" + action
+	case "rollback":
+		if blockHeight == "" {
+			return fmt.Errorf("provide a block height, ie: gigawallet chain rollback 12345")
+		}
+		path = "/admin/chain/rollback/" + blockHeight
+	default:
+		return fmt.Errorf("invalid chain command: %s (status, pause, resume, restart or rollback)", action)
+	}
+	url, err := adminAPIURL(c, s, path)
+	if err != nil {
+		return err
+	}
+	fmt.Println("Calling", url)
+	return postURL(url, "")
+}
+
+// SignOffline signs an UnsignedTxn (the "unsigned" field returned by
+// /account/:foreignID/pay for an account with offline_signing) with the
+// account's master key, and prints the JSON request body for
+// /account/:foreignID/submit-signed.
+//
+// This is intended to run on an offline machine that holds the master
+// key; it does not talk to dogecoind or a GigaWallet server.
+
+func SignOffline(unsignedFile string, c giga.Config, s SubCommandArgs) error {
+	data, err := os.ReadFile(unsignedFile)
+	if err != nil {
+		return fmt.Errorf("cannot read unsigned transaction: %v", err)
+	}
+	var unsigned giga.UnsignedTxn
+	err = json.Unmarshal(data, &unsigned)
+	if err != nil {
+		return fmt.Errorf("cannot decode unsigned transaction: %v", err)
+	}
+	key, err := os.ReadFile(s.SigningKeyFile)
+	if err != nil {
+		return fmt.Errorf("cannot read signing key: %v", err)
+	}
+	lib, err := dogecoin.NewL1Libdogecoin(c, nil)
+	if err != nil {
+		return err
+	}
+	signed, err := lib.SignTransaction(unsigned.TxnHex, unsigned.InputUTXOs(), giga.Privkey(strings.TrimSpace(string(key))))
+	if err != nil {
+		return err
+	}
+	_, err = unsigned.VerifySigned(signed)
+	if err != nil {
+		return err
+	}
+	o, _ := json.Marshal(map[string]any{"payment_id": unsigned.PaymentID, "tx": signed})
+	fmt.Println(string(o))
+	return nil
+}
+
+// ImportBlocks bootstraps a NEW GigaWallet database from a local copy of
+// Dogecoin Core's block files (the `blocks` directory containing blk*.dat)
+// which is much faster than the initial sync over RPC. It can be stopped
+// and run again; it resumes from the last imported block.
+//
+// WARNING:  Do not run this while a GigaWallet server is using the same
+// database. Start the server afterwards to follow the chain from there.
+
+func ImportBlocks(blocksDir string, c giga.Config) error {
+	db, err := store.NewSQLiteStore(c.Store.DBFile)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	bus := giga.NewMessageBus()
+	stop := make(chan context.Context, 1)
+	err = bus.Run(make(chan bool, 1), make(chan bool, 1), stop)
+	if err != nil {
+		return err
+	}
+	defer func() { stop <- context.Background() }()
+	state, err := chaintracker.ImportBlockFiles(c, db, bus, blocksDir)
+	fmt.Println("Imported up to block", state.BestBlockHash, state.BestBlockHeight)
+	return err
+}
+
 // work out the remote admin URL from args or config and return
 // a complete path with our best guess
 func adminAPIURL(c giga.Config, s SubCommandArgs, path string) (string, error) {
@@ -62,7 +193,25 @@ func adminAPIURL(c giga.Config, s SubCommandArgs, path string) (string, error) {
 		return "", err
 	}
 
-	return u.ResolveReference(p).String(), nil
+	// chain admin APIs act on the configured network (see --network)
+	ref := u.ResolveReference(p)
+	q := ref.Query()
+	q.Set("network", c.Gigawallet.Network)
+	ref.RawQuery = q.Encode()
+	return ref.String(), nil
+}
+
+// get a response from a remote GigaWallet admin API
+func getURL(url string) ([]byte, error) {
+	resp, err := http.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send HTTP request: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected response status code: %d", resp.StatusCode)
+	}
+	return io.ReadAll(resp.Body)
 }
 
 // post a command to a remote GigaWallet admin API
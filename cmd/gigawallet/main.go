@@ -41,6 +41,29 @@ func main() {
 		MQTT: giga.MQTTConfig{
 			Queues: make(map[string]giga.MQTTQueueConfig),
 		},
+		Consolidation: giga.ConsolidationConfig{
+			Enabled:       false,
+			UTXOThreshold: 100,
+			MaxInputs:     200,
+			MaxFeePerKB:   0.01,
+			Interval:      600,
+		},
+		Batching: giga.BatchingConfig{
+			Enabled:       false,
+			Interval:      30,
+			BatchSize:     100,
+			BatchInterval: 300,
+		},
+		Scheduling: giga.SchedulingConfig{
+			Enabled:       false,
+			Interval:      30,
+			RetryInterval: 600,
+			MaxAttempts:   144,
+		},
+		ChainFollower: giga.ChainFollowerConfig{
+			PrefetchWorkers:  4,
+			PrefetchMemoryMB: 64,
+		},
 		Loggers:   make(map[string]giga.LoggersConfig),
 		Dogecoind: make(map[string]giga.NodeConfig),
 		Core:      giga.NodeConfig{},
@@ -58,14 +81,50 @@ func main() {
 	// cli flag loading
 	applyFlags(&config, &subCommandArgs)
 
+	// Offline sub commands (no dogecoind or admin API needed)
+	if flag.Arg(0) == "sign" {
+		// Signs an unsigned transaction (from /pay for an account with
+		// offline_signing) with the account's master key, and prints
+		// the JSON body for /account/:foreignID/submit-signed
+		if flag.Arg(1) == "" || subCommandArgs.SigningKeyFile == "" {
+			fmt.Println("Provide an unsigned transaction file and key, ie: gigawallet sign --signing-key-file=master.key unsigned.json")
+			os.Exit(0)
+		}
+		err := SignOffline(flag.Arg(1), config, subCommandArgs)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+	if flag.Arg(0) == "importblocks" {
+		// Bootstraps the database from Dogecoin Core's block files
+		// (blocks/blk*.dat) for the configured network.
+		if flag.Arg(1) == "" {
+			fmt.Println("Provide Dogecoin Core's blocks directory, ie: gigawallet importblocks ~/.dogecoin/blocks")
+			os.Exit(0)
+		}
+		err := ImportBlocks(flag.Arg(1), config)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
 	// set config.Core to the network block specified in
 	// config.Gigawallet.Network
 	if len(config.Gigawallet.Network) < 1 {
 		panic("bad config: missing network")
 	}
-	config.Core = config.Dogecoind[config.Gigawallet.Network]
-	if len(config.Core.Host) < 1 {
-		panic(fmt.Sprintf("bad config: missing network: %s", config.Gigawallet.Network))
+	for _, network := range config.AllNetworks() {
+		if _, err := config.ForNetwork(network); err != nil {
+			panic(err)
+		}
+	}
+	config, err = config.ForNetwork(config.Gigawallet.Network)
+	if err != nil {
+		panic(err)
 	}
 
 	// Sub commands!
@@ -90,6 +149,25 @@ func main() {
 			os.Exit(1)
 		}
 		os.Exit(0)
+	case "skippedblocks":
+		// Lists blocks a running GigaWallet instance could not decode,
+		// or re-processes them: gigawallet skippedblocks retry
+		err := SkippedBlocks(flag.Arg(1), config, subCommandArgs)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	case "chain":
+		// Shows the sync status of a running GigaWallet instance, or
+		// controls its chain follower: gigawallet chain [status|pause|
+		// resume|restart|rollback <height>]
+		err := Chain(flag.Arg(1), flag.Arg(2), config, subCommandArgs)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		os.Exit(0)
 	default:
 		fmt.Println("Invalid subcommand:", flag.Arg(0))
 		os.Exit(1)
@@ -140,9 +218,11 @@ func applyFlags(config *giga.Config, subs *SubCommandArgs) {
 	flag.StringVar(&config.Store.DBFile, "store-db-file", config.Store.DBFile, "Store DB file")
 	// Extra arguments for various subcommands
 	flag.StringVar(&subs.RemoteAdminServer, "remote-admin-server", "", "http/s base URL for a remote GigaWallet server to command")
+	flag.StringVar(&subs.SigningKeyFile, "signing-key-file", "", "file containing the account master key, for the 'sign' subcommand")
 	flag.Parse()
 }
 
 type SubCommandArgs struct {
 	RemoteAdminServer string
+	SigningKeyFile    string
 }
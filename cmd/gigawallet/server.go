@@ -6,6 +6,7 @@ import (
 	"github.com/dogecoinfoundation/gigawallet/pkg/conductor"
 	"github.com/dogecoinfoundation/gigawallet/pkg/core"
 	"github.com/dogecoinfoundation/gigawallet/pkg/dogecoin"
+	"github.com/dogecoinfoundation/gigawallet/pkg/p2p"
 	"github.com/dogecoinfoundation/gigawallet/pkg/receivers"
 	"github.com/dogecoinfoundation/gigawallet/pkg/services"
 	"github.com/dogecoinfoundation/gigawallet/pkg/store"
@@ -26,12 +27,66 @@ func Server(conf giga.Config) {
 	// Set up all configured receivers
 	receivers.SetUpReceivers(c, bus, conf)
 
-	// Set up the L1 interface to Core
-	l1_core, err := core.NewDogecoinCoreRPC(conf)
+	// Start each network: the primary network (config.Gigawallet.Network)
+	// and any others running side by side (config.Gigawallet.Networks)
+	var apis []giga.API
+	for _, network := range conf.AllNetworks() {
+		netConf, err := conf.ForNetwork(network)
+		if err != nil {
+			panic(err)
+		}
+		// Events from each network are tagged with the network name.
+		api, store := startNetwork(c, bus.ForNetwork(network), netConf)
+		defer store.Close()
+		apis = append(apis, api)
+	}
+
+	// Start the Payment API
+	p, err := webapi.NewWebAPI(conf, apis[0], apis[1:]...)
 	if err != nil {
 		panic(err)
 	}
-	l1, err := dogecoin.NewL1Libdogecoin(conf, l1_core)
+	c.Service("Payment API", p)
+
+	<-c.Start()
+}
+
+// startNetwork sets up the L1, Store, Chain Tracker and internal services
+// for one network, and returns its API.
+func startNetwork(c *conductor.Conductor, bus giga.MessageBus, conf giga.Config) (giga.API, giga.Store) {
+	// Suffix for service names, so each network's services can be told apart
+	network := " (" + conf.Gigawallet.Network + ")"
+
+	// Set up the L1 interface to Core
+	var l1_core giga.L1
+	var err error
+	if len(conf.Core.Nodes) > 0 {
+		// Fail over between several Core nodes
+		var failover *core.L1Failover
+		failover, err = core.NewL1Failover(conf, bus)
+		if err != nil {
+			panic(err)
+		}
+		c.Service("Core Failover"+network, failover)
+		l1_core = failover
+	} else {
+		l1_core, err = core.NewDogecoinCoreRPC(conf)
+		if err != nil {
+			panic(err)
+		}
+	}
+	var l1_chain giga.L1 = l1_core
+	var l1_p2p *p2p.L1P2P
+	if len(conf.Core.P2PPeers) > 0 {
+		// Follow the chain from P2P peers instead (RPC for everything else)
+		l1_p2p, err = p2p.NewL1P2P(conf, l1_core)
+		if err != nil {
+			panic(err)
+		}
+		c.Service("P2P"+network, l1_p2p)
+		l1_chain = l1_p2p
+	}
+	l1, err := dogecoin.NewL1Libdogecoin(conf, l1_chain)
 	if err != nil {
 		panic(err)
 	}
@@ -41,33 +96,29 @@ func Server(conf giga.Config) {
 	if err != nil {
 		panic(err)
 	}
-	defer store.Close()
-
-	// Start internal services
-	services.StartServices(c, bus, conf, store)
 
 	// Start the Chain Tracker
-	chaser, follower, err := chaintracker.StartChainTracker(c, conf, l1, store)
+	chaser, follower, err := chaintracker.StartChainTracker(c, conf, l1, store, bus)
 	if err != nil {
 		panic(err)
 	}
 
-	// Start the Core listener service (ZMQ)
-	corez, err := core.NewCoreZMQReceiver(bus, conf)
-	if err != nil {
-		panic(err)
+	// New blocks from P2P peers, and/or the Core listener service (ZMQ)
+	if l1_p2p != nil {
+		l1_p2p.Subscribe(chaser)
+	}
+	if l1_p2p == nil || conf.Core.ZMQPort != 0 {
+		corez, err := core.NewCoreZMQReceiver(bus, conf)
+		if err != nil {
+			panic(err)
+		}
+		corez.Subscribe(chaser)
+		c.Service("ZMQ Listener"+network, corez)
 	}
-	corez.Subscribe(chaser)
-	c.Service("ZMQ Listener", corez)
 
 	api := giga.NewAPI(store, l1, bus, follower, conf)
 
-	// Start the Payment API
-	p, err := webapi.NewWebAPI(conf, api)
-	if err != nil {
-		panic(err)
-	}
-	c.Service("Payment API", p)
-
-	<-c.Start()
+	// Start internal services
+	services.StartServices(c, bus, conf, store, l1, api)
+	return api, store
 }
@@ -1,6 +1,10 @@
 package giga
 
-import "log"
+import (
+	"log"
+
+	"github.com/dogecoinfoundation/gigawallet/pkg/doge"
+)
 
 // The number of addresses HD Wallet discovery will scan beyond the last-used address.
 const HD_DISCOVERY_RANGE = 20
@@ -11,21 +15,59 @@ const HD_DISCOVERY_RANGE = 20
 	 - PayoutAddress is a dogecoin address to pay to
 	 - PayoutThreshold, if non-zero, auto-payout if balance is greater
 	 - PayoutFrequency, if set, payout at this schedule
+ -- Settings
+	 - CoinSelection, strategy used to choose UTXOs for payments (see CoinSelector)
+ -- Withdrawals
+	 - BatchSize, if non-zero, maximum queued withdrawals paid in one transaction
+	 - BatchInterval, if non-zero, seconds to collect withdrawals before paying a batch
+ -- Signing
+	 - OfflineSigning, if set, payments are unsigned transactions to sign offline
+ -- Approval
+	 - ApprovalsRequired, if non-zero, approvals needed for payments matching the policy
+	 - ApprovalThreshold, payments with a total above this require approval
+	 - ApprovedAddresses, if set, payments to any other address require approval
 */
 type Account struct {
-	Address          Address    // HD Wallet master public key as a dogecoin address (Account ID)
-	Privkey          Privkey    // HD Wallet master extended private key.
-	ForeignID        string     // unique identifier supplied by the organisation using Gigawallet.
-	NextInternalKey  uint32     // next internal HD Wallet address to use for txn change outputs.
-	NextExternalKey  uint32     // next external HD Wallet address to use for an invoice or pay-to address.
-	NextPoolInternal uint32     // next internal HD Wallet address to insert into account_address table.
-	NextPoolExternal uint32     // next external HD Wallet address to insert into account_address table.
-	PayoutAddress    Address    // Dogecoin address to receive funds periodically
-	PayoutThreshold  CoinAmount // Minimum amount to automatically pay to PayoutAddress
-	PayoutFrequency  string     // Minimum time between automatic payments to PayoutAddress
-	CurrentBalance   CoinAmount // current balance available to spend now (from BalanceKeeper)
-	IncomingBalance  CoinAmount // receiving coins waiting for confirmation (from BalanceKeeper)
-	OutgoingBalance  CoinAmount // spent coins waiting for confirmation (from BalanceKeeper)
+	Address           Address    // HD Wallet master public key as a dogecoin address (Account ID)
+	Privkey           Privkey    // HD Wallet master extended private key.
+	ForeignID         string     // unique identifier supplied by the organisation using Gigawallet.
+	NextInternalKey   uint32     // next internal HD Wallet address to use for txn change outputs.
+	NextExternalKey   uint32     // next external HD Wallet address to use for an invoice or pay-to address.
+	NextPoolInternal  uint32     // next internal HD Wallet address to insert into account_address table.
+	NextPoolExternal  uint32     // next external HD Wallet address to insert into account_address table.
+	PayoutAddress     Address    // Dogecoin address to receive funds periodically
+	PayoutThreshold   CoinAmount // Minimum amount to automatically pay to PayoutAddress
+	PayoutFrequency   string     // Minimum time between automatic payments to PayoutAddress
+	CoinSelection     string     // Coin Selection strategy for payments, e.g. "largest-first" (see CoinSelectDefault)
+	BatchSize         int        // Maximum withdrawals per batch transaction (zero: use config default)
+	BatchInterval     int        // Seconds to collect withdrawals before sending a batch (zero: use config default)
+	OfflineSigning    bool       // Payments are signed offline (see UnsignedTxn) instead of by GigaWallet
+	ApprovalsRequired int        // Distinct approvals needed to send a payment that requires approval (zero: no approval policy)
+	ApprovalThreshold CoinAmount // Payments with a total above this require approval
+	ApprovedAddresses []Address  // Payments to addresses not in this list require approval (empty: any address)
+	CurrentBalance    CoinAmount // current balance available to spend now (from BalanceKeeper)
+	IncomingBalance   CoinAmount // receiving coins waiting for confirmation (from BalanceKeeper)
+	OutgoingBalance   CoinAmount // spent coins waiting for confirmation (from BalanceKeeper)
+}
+
+// Chain returns the network the account's keys belong to, from the
+// version byte of the account address (falls back on a best guess)
+func (a Account) Chain() *doge.ChainParams {
+	chain := doge.ChainFromAddress(a.Address)
+	if chain == nil {
+		return doge.ChainFromWIFString(string(a.Address))
+	}
+	return chain
+}
+
+// AccountChain returns the chain of the keys and addresses GigaWallet makes
+// for accounts on `network`: libdogecoin only makes mainnet and testnet keys,
+// so regtest accounts have testnet addresses (see CreateAccount)
+func AccountChain(network *doge.ChainParams) *doge.ChainParams {
+	if network == &doge.DogeRegTestChain {
+		return &doge.DogeTestNetChain
+	}
+	return network
 }
 
 // AccountBalance holds the current account balances for an Account.
@@ -33,6 +75,7 @@ type AccountBalance struct {
 	IncomingBalance CoinAmount // pending coins being received (waiting for Txn to be confirmed)
 	CurrentBalance  CoinAmount // current balance available to spend now
 	OutgoingBalance CoinAmount // spent funds that are not yet confirmed (waiting for Txn to be confirmed)
+	Unreliable      bool       `json:",omitempty"` // blocks were skipped (see Store.ListSkippedBlocks): payments may be missing
 }
 
 // Generate and store HD Wallet addresses up to 20 beyond any currently-used addresses.
@@ -120,13 +163,21 @@ func (a *Account) NextChangeAddress(lib L1) (addr Address, index uint32, e error
 // GetPublicInfo gets those parts of the Account that are safe
 // to expose to the outside world (i.e. NOT private keys)
 func (a Account) GetPublicInfo() AccountPublic {
-	return AccountPublic{Address: a.Address, ForeignID: a.ForeignID, PayoutAddress: a.PayoutAddress, PayoutThreshold: a.PayoutThreshold, PayoutFrequency: a.PayoutFrequency}
+	return AccountPublic{Address: a.Address, ForeignID: a.ForeignID, PayoutAddress: a.PayoutAddress, PayoutThreshold: a.PayoutThreshold, PayoutFrequency: a.PayoutFrequency, CoinSelection: a.CoinSelection, BatchSize: a.BatchSize, BatchInterval: a.BatchInterval, OfflineSigning: a.OfflineSigning, ApprovalsRequired: a.ApprovalsRequired, ApprovalThreshold: a.ApprovalThreshold, ApprovedAddresses: a.ApprovedAddresses}
 }
 
 type AccountPublic struct {
-	Address         Address    `json:"id"`
-	ForeignID       string     `json:"foreign_id"`
-	PayoutAddress   Address    `json:"payout_address"`
-	PayoutThreshold CoinAmount `json:"payout_threshold"`
-	PayoutFrequency string     `json:"payout_frequency"`
+	Address           Address    `json:"id"`
+	ForeignID         string     `json:"foreign_id"`
+	PayoutAddress     Address    `json:"payout_address"`
+	PayoutThreshold   CoinAmount `json:"payout_threshold"`
+	PayoutFrequency   string     `json:"payout_frequency"`
+	CoinSelection     string     `json:"coin_selection"`
+	BatchSize         int        `json:"batch_size"`
+	BatchInterval     int        `json:"batch_interval"`
+	OfflineSigning    bool       `json:"offline_signing"`
+	ApprovalsRequired int        `json:"approvals_required"`
+	ApprovalThreshold CoinAmount `json:"approval_threshold"`
+	ApprovedAddresses []Address  `json:"approved_addresses"`
+	Network           string     `json:"network,omitempty"` // the network the account is bound to (see API.Network)
 }
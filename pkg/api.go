@@ -1,10 +1,12 @@
 package giga
 
 import (
+	"encoding/json"
 	"fmt"
 	"log"
 	"time"
 
+	"github.com/dogecoinfoundation/gigawallet/pkg/doge"
 	"github.com/shopspring/decimal"
 )
 
@@ -20,12 +22,51 @@ func NewAPI(store Store, l1 L1, bus MessageBus, follower ChainFollower, config C
 	return API{store, l1, bus, follower, config}
 }
 
+// Network returns the name of the network this API is for
+// (config.Gigawallet.Network, see Config.ForNetwork)
+func (a API) Network() string {
+	return a.config.Gigawallet.Network
+}
+
+// The network GigaWallet is configured for (config.Gigawallet.Network)
+func (a API) chain() *doge.ChainParams {
+	chain, err := doge.ChainFromName(a.config.Gigawallet.Network)
+	if err != nil {
+		return &doge.DogeMainNetChain
+	}
+	return chain
+}
+
+// Reject pay-to addresses that are not P2PKH or P2SH addresses on the
+// configured network (e.g. a testnet address on mainnet) or the account
+// chain (our own addresses on regtest) before making a transaction.
+// Missing addresses are reported when making the transaction.
+func (a API) validatePayTo(payTo []PayTo) error {
+	chain := a.chain()
+	accountChain := AccountChain(chain)
+	for _, pay := range payTo {
+		if pay.PayTo == "" || doge.ValidateP2PKH(pay.PayTo, chain) || doge.ValidateP2SH(pay.PayTo, chain) ||
+			doge.ValidateP2PKH(pay.PayTo, accountChain) {
+			continue
+		}
+		for _, other := range []*doge.ChainParams{&doge.DogeMainNetChain, &doge.DogeTestNetChain, &doge.DogeRegTestChain} {
+			if other != chain && other != accountChain && (doge.ValidateP2PKH(pay.PayTo, other) || doge.ValidateP2SH(pay.PayTo, other)) {
+				return NewErr(BadRequest, "Invalid transaction output: '%v' is an address on %v, but GigaWallet is on %v", pay.PayTo, other.ChainName, chain.ChainName)
+			}
+		}
+		return NewErr(BadRequest, "Invalid transaction output: '%v' is not a valid P2PKH or P2SH address on %v", pay.PayTo, chain.ChainName)
+	}
+	return nil
+}
+
 type InvoiceCreateRequest struct {
 	Items         []Item `json:"items"`
 	Confirmations int32  `json:"required_confirmations"` // specify -1 to mean not set
 }
 
-func (a API) CreateInvoice(request InvoiceCreateRequest, foreignID string) (Invoice, error) {
+// CreateInvoice creates a new Invoice for the account.
+// If idem has a Key, a retry of the same request returns the original Invoice.
+func (a API) CreateInvoice(request InvoiceCreateRequest, foreignID string, idem IdempotencyKey) (Invoice, error) {
 	dbtx, err := a.Store.Begin()
 	if err != nil {
 		a.bus.Send(SYS_ERR, fmt.Sprintf("CreateInvoice: Failed to begin txn: %s", err))
@@ -39,6 +80,13 @@ func (a API) CreateInvoice(request InvoiceCreateRequest, foreignID string) (Invo
 		return Invoice{}, err
 	}
 
+	// Replay the original response for a retried request.
+	var replay Invoice
+	found, err := replayIdempotent(dbtx, acc.Address, idem, &replay)
+	if found || err != nil {
+		return replay, err
+	}
+
 	// Create a new child address for this invoice from the account's HD key
 	invoiceID, keyIndex, err := acc.NextPayToAddress(a.L1)
 	if err != nil {
@@ -74,6 +122,11 @@ func (a API) CreateInvoice(request InvoiceCreateRequest, foreignID string) (Invo
 	if err != nil {
 		return Invoice{}, err
 	}
+	err = storeIdempotent(dbtx, acc.Address, idem, i)
+	if err != nil {
+		dbtx.Rollback()
+		return replay, replayAfterConflict(a.Store, acc.Address, idem, &replay, err)
+	}
 
 	err = dbtx.Commit()
 	if err != nil {
@@ -118,9 +171,25 @@ func (a API) ListInvoices(foreignID string, cursor int, limit int) (ListInvoices
 }
 
 type AccountCreateRequest struct {
-	PayoutAddress   Address    `json:"payout_address"`
-	PayoutThreshold CoinAmount `json:"payout_threshold"`
-	PayoutFrequency string     `json:"payout_frequency"`
+	PayoutAddress     Address    `json:"payout_address"`
+	PayoutThreshold   CoinAmount `json:"payout_threshold"`
+	PayoutFrequency   string     `json:"payout_frequency"`
+	CoinSelection     string     `json:"coin_selection"`
+	BatchSize         int        `json:"batch_size"`
+	BatchInterval     int        `json:"batch_interval"`
+	OfflineSigning    bool       `json:"offline_signing"`
+	ApprovalsRequired int        `json:"approvals_required"`
+	ApprovalThreshold CoinAmount `json:"approval_threshold"`
+	ApprovedAddresses []Address  `json:"approved_addresses"`
+	Network           string     `json:"network"` // optional, must match the API's Network
+}
+
+// publicInfo returns the public parts of the account, with the network
+// the account is bound to.
+func (a API) publicInfo(acc Account) AccountPublic {
+	pub := acc.GetPublicInfo()
+	pub.Network = a.Network()
+	return pub
 }
 
 func (a API) CreateAccount(request AccountCreateRequest, foreignID string, upsert bool) (AccountPublic, error) {
@@ -137,24 +206,45 @@ func (a API) CreateAccount(request AccountCreateRequest, foreignID string, upser
 		if err == nil {
 			// Account already exists.
 			if upsert {
-				return acc.GetPublicInfo(), nil
+				return a.publicInfo(acc), nil
 			}
 			return AccountPublic{}, NewErr(AlreadyExists, "account already exists: %v", err)
 		}
 
 		// Account does not exist yet.
-		isTestNet := a.config.Gigawallet.Network == "testnet"
+		if request.Network != "" && request.Network != a.Network() {
+			return AccountPublic{}, NewErr(BadRequest, "cannot create account on network '%s', this API is for '%s'", request.Network, a.Network())
+		}
+		_, err = NewCoinSelector(request.CoinSelection)
+		if err != nil {
+			return AccountPublic{}, err
+		}
+		if request.BatchSize < 0 || request.BatchInterval < 0 {
+			return AccountPublic{}, NewErr(BadRequest, "batch_size and batch_interval cannot be negative")
+		}
+		if request.ApprovalsRequired < 0 || request.ApprovalThreshold.IsNegative() {
+			return AccountPublic{}, NewErr(BadRequest, "approvals_required and approval_threshold cannot be negative")
+		}
+		// libdogecoin only makes mainnet and testnet keys (see AccountChain)
+		isTestNet := AccountChain(a.chain()) == &doge.DogeTestNetChain
 		addr, priv, err := a.L1.MakeAddress(isTestNet)
 		if err != nil {
 			return AccountPublic{}, NewErr(NotAvailable, "cannot create address: %v", err)
 		}
 		account := Account{
-			Address:         addr,
-			ForeignID:       foreignID,
-			PayoutAddress:   Address(request.PayoutAddress),
-			PayoutThreshold: request.PayoutThreshold,
-			PayoutFrequency: request.PayoutFrequency,
-			Privkey:         priv,
+			Address:           addr,
+			ForeignID:         foreignID,
+			PayoutAddress:     Address(request.PayoutAddress),
+			PayoutThreshold:   request.PayoutThreshold,
+			PayoutFrequency:   request.PayoutFrequency,
+			CoinSelection:     request.CoinSelection,
+			BatchSize:         request.BatchSize,
+			BatchInterval:     request.BatchInterval,
+			OfflineSigning:    request.OfflineSigning,
+			ApprovalsRequired: request.ApprovalsRequired,
+			ApprovalThreshold: request.ApprovalThreshold,
+			ApprovedAddresses: request.ApprovedAddresses,
+			Privkey:           priv,
 		}
 
 		// Generate and store addresses for transaction discovery on blockchain.
@@ -181,7 +271,7 @@ func (a API) CreateAccount(request AccountCreateRequest, foreignID string, upser
 			return AccountPublic{}, NewErr(NotAvailable, "cannot create account: %v", err)
 		}
 
-		pub := account.GetPublicInfo()
+		pub := a.publicInfo(account)
 		a.bus.Send(ACC_CREATED, pub)
 		return pub, nil
 	}
@@ -192,7 +282,7 @@ func (a API) GetAccount(foreignID string) (AccountPublic, error) {
 	if err != nil {
 		return AccountPublic{}, err
 	}
-	return acc.GetPublicInfo(), nil
+	return a.publicInfo(acc), nil
 }
 
 func (a API) CalculateBalance(foreignID string) (AccountBalance, error) {
@@ -204,6 +294,11 @@ func (a API) CalculateBalance(foreignID string) (AccountBalance, error) {
 	if err != nil {
 		return AccountBalance{}, err
 	}
+	skipped, err := a.Store.ListSkippedBlocks()
+	if err != nil {
+		return AccountBalance{}, err
+	}
+	bal.Unreliable = len(skipped) > 0
 	return bal, nil
 }
 
@@ -231,6 +326,39 @@ func (a API) UpdateAccountSettings(foreignID string, update map[string]interface
 			}
 		case "PayoutFrequency":
 			acc.PayoutFrequency = v.(string)
+		case "CoinSelection":
+			_, err = NewCoinSelector(v.(string))
+			if err != nil {
+				return AccountPublic{}, err
+			}
+			acc.CoinSelection = v.(string)
+		case "BatchSize":
+			if v.(int) < 0 {
+				return AccountPublic{}, NewErr(BadRequest, "BatchSize cannot be negative")
+			}
+			acc.BatchSize = v.(int)
+		case "BatchInterval":
+			if v.(int) < 0 {
+				return AccountPublic{}, NewErr(BadRequest, "BatchInterval cannot be negative")
+			}
+			acc.BatchInterval = v.(int)
+		case "OfflineSigning":
+			acc.OfflineSigning = v.(bool)
+		case "ApprovalsRequired":
+			if v.(int) < 0 {
+				return AccountPublic{}, NewErr(BadRequest, "ApprovalsRequired cannot be negative")
+			}
+			acc.ApprovalsRequired = v.(int)
+		case "ApprovalThreshold":
+			acc.ApprovalThreshold, err = decimal.NewFromString(v.(string))
+			if err != nil {
+				return AccountPublic{}, err
+			}
+			if acc.ApprovalThreshold.IsNegative() {
+				return AccountPublic{}, NewErr(BadRequest, "ApprovalThreshold cannot be negative")
+			}
+		case "ApprovedAddresses":
+			acc.ApprovedAddresses = v.([]Address)
 		default:
 			a.bus.Send(SYS_ERR, fmt.Sprintf("Invalid account setting: %s", k))
 		}
@@ -246,7 +374,7 @@ func (a API) UpdateAccountSettings(foreignID string, update map[string]interface
 		return AccountPublic{}, err
 	}
 
-	pub := acc.GetPublicInfo()
+	pub := a.publicInfo(acc)
 	a.bus.Send(ACC_UPDATED, pub)
 	return pub, nil
 }
@@ -257,121 +385,199 @@ type SendFundsResult struct {
 	Paid   CoinAmount `json:"paid"`  // amount paid to recipient, excluding fee
 	Fee    CoinAmount `json:"fee"`   // fee paid
 	TxData string     `json:"tx"`    // transaction data, hex-encoded
+	Memo   string     `json:"memo"`  // memo in the OP_RETURN output (if any)
+	// for an OfflineSigning account: the transaction to sign (TxData is unsigned)
+	Unsigned *UnsignedTxn `json:"unsigned,omitempty"`
+	// the Payment record (for a payment that requires approval: see ApprovePayment)
+	PaymentID      int64          `json:"payment_id,omitempty"`
+	ApprovalStatus ApprovalStatus `json:"approval_status,omitempty"`
 }
 
-func (a API) SendFundsToAddress(foreignID string, payTo []PayTo, explicitFee CoinAmount, maxFee CoinAmount, sendTx bool) (res SendFundsResult, err error) {
+// SendFundsToAddress pays out funds from the account, with an optional
+// memo (up to 80 bytes) embedded in an OP_RETURN output.
+// If idem has a Key, a retry of the same request returns the original result
+// without paying again.
+func (a API) SendFundsToAddress(foreignID string, payTo []PayTo, memo string, feeOpts FeeOptions, sendTx bool, idem IdempotencyKey) (res SendFundsResult, err error) {
+	err = validateFeeOptions(feeOpts)
+	if err != nil {
+		return
+	}
+	err = a.validatePayTo(payTo)
+	if err != nil {
+		return
+	}
 	account, err := a.Store.GetAccount(foreignID)
 	if err != nil {
 		return
 	}
-	if !maxFee.IsPositive() {
-		maxFee = TxnRecommendedMaxFee // default maximum fee
+	// Replay the original result for a retried request.
+	found, err := replayIdempotent(a.Store, account.Address, idem, &res)
+	if err != nil {
+		return
+	}
+	if found {
+		// The original request may have failed to submit the tx.
+		err = a.resubmitIdempotentPayment(account, res, sendTx)
+		if err != nil {
+			return SendFundsResult{}, err
+		}
+		return
 	}
 
 	// Create the Dogecoin Transaction
 	source := NewUTXOSource(a.Store, account.Address)
-	newTxn, changeUTXO, spentUTXOs, txid, err := CreateTxn(payTo, explicitFee, maxFee, account, source, a.L1)
+	newTxn, changeUTXO, spentUTXOs, txid, err := CreateTxn(payTo, []byte(memo), feeOpts, account, source, a.L1)
 	if err != nil {
 		return
 	}
 	total := newTxn.TotalOut // total paid to addresses (excludes fee)
 	fee := newTxn.FeeAmount  // fee paid by the transaction
 	txHex := newTxn.TxnHex
+	memo = string(newTxn.Memo) // as decoded from the transaction
 
 	log.Printf("New Tx: total %v fee %v change %v", newTxn.TotalOut, newTxn.FeeAmount, newTxn.ChangeAmount)
 
+	needsApproval := account.RequiresApproval(payTo, total)
+	if account.OfflineSigning {
+		// The transaction must be signed offline and sent with SubmitSignedTxn.
+		return a.reserveUnsignedPayment(account, payTo, memo, newTxn, spentUTXOs, changeUTXO, needsApproval, idem)
+	}
+	if needsApproval {
+		// The transaction is sent once the payment is approved (see ApprovePayment)
+		return a.reservePendingPayment(account, payTo, memo, newTxn, spentUTXOs, changeUTXO, txid, idem)
+	}
+
+	result := SendFundsResult{TxId: txid, Total: total.Add(fee), Paid: total, Fee: fee, TxData: txHex, Memo: memo}
+
 	// Create the Payment record up-front.
 	// Save changes to the Account (NextInternalKey) and address pool.
 	// Reserve the UTXOs for the payment.
-	dbtx, err := a.Store.Begin()
+	// Store the result for the Idempotency-Key with the Payment.
+	payment, err := a.reserveIdempotentPayment(account, payTo, memo, total, fee, spentUTXOs, changeUTXO, idem, result)
+	if err != nil {
+		return res, replayAfterConflict(a.Store, account.Address, idem, &res, err)
+	}
+	result.PaymentID = payment.ID
+
+	// BEYOND THIS POINT: if we fail to submit the tx, the UTXOs stay reserved
+	// by the Payment; a retry with the same Idempotency-Key re-submits the tx.
+
+	// Submit the transaction to core (if sendTx) and update the Payment with the txid.
+	err = SubmitPayment(a.Store, a.L1, payment.ID, txHex, txid, sendTx)
 	if err != nil {
 		return
 	}
-	err = account.UpdatePoolAddresses(dbtx, a.L1) // we used a Change address.
+
+	if sendTx {
+		msg := PaymentEvent{
+			PaymentID: payment.ID,
+			ForeignID: account.ForeignID,
+			AccountID: account.Address,
+			PayTo:     payTo,
+			Total:     total,
+			TxID:      txid,
+			Memo:      memo,
+		}
+		a.bus.Send(PAYMENT_SENT, msg)
+	}
+
+	return result, nil
+}
+
+// Confirmation targets (in blocks) quoted by QuoteFees by default.
+var DefaultQuoteTargets = []int{2, 6, 24}
+
+// Maximum confirmation target accepted by Core `estimatesmartfee`
+const MaxConfirmTarget = 1008
+
+type FeeQuote struct {
+	ConfirmTarget int        `json:"confirm_target"` // confirmation target (zero: explicit fee or fee_per_byte)
+	FeePerByte    CoinAmount `json:"fee_per_byte"`   // fee rate used for the quote
+	Inputs        int        `json:"inputs"`         // number of UTXOs selected to pay
+	Size          int        `json:"size"`           // estimated transaction size (bytes)
+	Fee           CoinAmount `json:"fee"`            // fee to pay
+	Total         CoinAmount `json:"total"`          // total amount spent, including fee
+	Paid          CoinAmount `json:"paid"`           // amount paid to recipients, excluding fee
+}
+
+type QuoteFeesResult struct {
+	Quotes []FeeQuote `json:"quotes"`
+}
+
+// QuoteFees is a dry-run of SendFundsToAddress: it creates the transaction
+// for each confirmation target (or once for an explicit fee or fee rate)
+// without reserving UTXOs or submitting the transaction.
+func (a API) QuoteFees(foreignID string, payTo []PayTo, memo string, feeOpts FeeOptions, targets []int) (res QuoteFeesResult, err error) {
+	err = validateFeeOptions(feeOpts)
 	if err != nil {
-		dbtx.Rollback()
 		return
 	}
-	err = dbtx.UpdateAccount(account) // for NextInternalKey (change address)
+	err = a.validatePayTo(payTo)
 	if err != nil {
-		dbtx.Rollback()
 		return
 	}
-	// Create the `payment` row with no txid or paid_height.
-	payment, err := dbtx.CreatePayment(account.Address, payTo, total, fee)
+	account, err := a.Store.GetAccount(foreignID)
 	if err != nil {
-		dbtx.Rollback()
 		return
 	}
-	// Reserve the UTXOs we're spending so they can't be double-spent.
-	for _, utxo := range spentUTXOs {
-		err = dbtx.MarkUTXOReserved(utxo.TxID, utxo.VOut, payment.ID)
-		if err != nil {
-			dbtx.Rollback()
-			return
+	options := []FeeOptions{feeOpts}
+	if !feeOpts.FixedFee.IsPositive() && !feeOpts.FeePerByte.IsPositive() && feeOpts.ConfirmTarget == 0 {
+		if len(targets) == 0 {
+			targets = DefaultQuoteTargets
+		}
+		options = nil
+		for _, target := range targets {
+			opts := feeOpts
+			opts.ConfirmTarget = target
+			err = validateFeeOptions(opts)
+			if err != nil {
+				return
+			}
+			options = append(options, opts)
 		}
 	}
-	// Create the 'change' UTXO now, so the change can be spent immediately.
-	if !changeUTXO.Value.IsZero() {
-		err = dbtx.CreateUTXO(changeUTXO)
+	source := NewUTXOSource(a.Store, account.Address)
+	for _, opts := range options {
+		newTxn, _, inputs, _, err := CreateTxn(payTo, []byte(memo), opts, account, source, a.L1)
 		if err != nil {
-			dbtx.Rollback()
-			return
+			return QuoteFeesResult{}, err
 		}
+		res.Quotes = append(res.Quotes, FeeQuote{
+			ConfirmTarget: opts.ConfirmTarget,
+			FeePerByte:    opts.feeRate(a.L1),
+			Inputs:        len(inputs),
+			Size:          int(newTxn.Size), // signed size (the tx is unsigned for OfflineSigning)
+			Fee:           newTxn.FeeAmount,
+			Total:         newTxn.TotalOut.Add(newTxn.FeeAmount),
+			Paid:          newTxn.TotalOut,
+		})
 	}
-	err = dbtx.Commit()
-	if err != nil {
-		return
-	}
-
-	// BEYOND THIS POINT: if we fail to submit the tx, user must void the payment
-	// manually which will clear the reserved lock on the UTXOs being spent.
+	return res, nil
+}
 
-	// Submit the transaction to core.
-	if sendTx {
-		// Submit tx to the network.
-		coreTxid, e := a.L1.Send(txHex)
-		if e != nil {
-			err = e
-			return
-		}
-		if coreTxid != txid {
-			log.Printf("[!] sendrawtransaction: Core Node did not return the precomputed txid: %s (expecting %s)", coreTxid, txid)
-		}
+func validateFeeOptions(feeOpts FeeOptions) error {
+	if feeOpts.FixedFee.IsNegative() || feeOpts.MaxFee.IsNegative() {
+		return NewErr(BadRequest, "explicit_fee and max_fee cannot be negative")
 	}
-
-	// Update the Payment with the txid,
-	// which changes it to "accepted" status (accepted by the network)
-	dbtx, err = a.Store.Begin()
-	if err != nil {
-		return
+	if feeOpts.FeePerByte.IsNegative() || (feeOpts.FeePerByte.IsPositive() && feeOpts.FeePerByte.LessThan(TxnFeePerByte)) {
+		return NewErr(BadRequest, "fee_per_byte cannot be less than the minimum fee rate (%vƉ per byte)", TxnFeePerByte)
 	}
-	err = dbtx.UpdatePaymentWithTxID(payment.ID, txid)
-	if err != nil {
-		dbtx.Rollback()
-		return
+	if feeOpts.ConfirmTarget < 0 || feeOpts.ConfirmTarget > MaxConfirmTarget {
+		return NewErr(BadRequest, "confirm_target must be between 1 and %v blocks (or 0 for the default)", MaxConfirmTarget)
 	}
-	err = dbtx.Commit()
-	if err != nil {
-		return
+	if feeOpts.FixedFee.IsPositive() && (feeOpts.FeePerByte.IsPositive() || feeOpts.ConfirmTarget > 0) {
+		return NewErr(BadRequest, "explicit_fee cannot be combined with fee_per_byte or confirm_target")
 	}
-
-	if sendTx {
-		msg := PaymentEvent{
-			PaymentID: payment.ID,
-			ForeignID: account.ForeignID,
-			AccountID: account.Address,
-			PayTo:     payTo,
-			Total:     total,
-			TxID:      txid,
-		}
-		a.bus.Send(PAYMENT_SENT, msg)
+	if feeOpts.FeePerByte.IsPositive() && feeOpts.ConfirmTarget > 0 {
+		return NewErr(BadRequest, "fee_per_byte cannot be combined with confirm_target")
 	}
-
-	return SendFundsResult{TxId: txid, Total: total.Add(fee), Paid: total, Fee: fee, TxData: txHex}, nil
+	return nil
 }
 
-func (a API) PayInvoiceFromAccount(invoiceID Address, foreignID string) (res SendFundsResult, err error) {
+// PayInvoiceFromAccount pays an invoice from another account managed by gigawallet.
+// If idem has a Key, a retry of the same request returns the original result
+// without paying again.
+func (a API) PayInvoiceFromAccount(invoiceID Address, foreignID string, idem IdempotencyKey) (res SendFundsResult, err error) {
 	invoice, err := a.Store.GetInvoice(invoiceID)
 	if err != nil {
 		return
@@ -380,6 +586,22 @@ func (a API) PayInvoiceFromAccount(invoiceID Address, foreignID string) (res Sen
 	if err != nil {
 		return
 	}
+	if account.OfflineSigning {
+		return res, NewErr(BadRequest, "account %v uses offline signing: use pay instead", foreignID)
+	}
+	// Replay the original result for a retried request.
+	found, err := replayIdempotent(a.Store, account.Address, idem, &res)
+	if err != nil {
+		return
+	}
+	if found {
+		// The original request may have failed to submit the tx.
+		err = a.resubmitIdempotentPayment(account, res, true)
+		if err != nil {
+			return SendFundsResult{}, err
+		}
+		return
+	}
 	invoiceAmount := invoice.CalcTotal()
 	if invoiceAmount.LessThan(TxnDustLimit) {
 		return SendFundsResult{}, fmt.Errorf("invoice amount is too small - transaction will be rejected: %s", invoiceAmount.String())
@@ -389,92 +611,742 @@ func (a API) PayInvoiceFromAccount(invoiceID Address, foreignID string) (res Sen
 	// Make a Doge Txn to pay `invoiceAmount` from `account` to `payTo`
 	payTo := []PayTo{{PayTo: payToAddress, Amount: invoiceAmount}}
 	source := NewUTXOSource(a.Store, account.Address)
-	newTxn, changeUTXO, spentUTXOs, txid, err := CreateTxn(payTo, ZeroCoins, TxnRecommendedMaxFee, account, source, a.L1)
+	newTxn, changeUTXO, spentUTXOs, txid, err := CreateTxn(payTo, nil, FeeOptions{}, account, source, a.L1)
 	if err != nil {
 		return
 	}
+	if account.RequiresApproval(payTo, invoiceAmount) {
+		// The transaction is sent once the payment is approved (see ApprovePayment)
+		return a.reservePendingPayment(account, payTo, "", newTxn, spentUTXOs, changeUTXO, txid, idem)
+	}
 	fee := newTxn.FeeAmount
 	txHex := newTxn.TxnHex
+	result := SendFundsResult{TxId: txid, Total: invoiceAmount.Add(fee), Paid: invoiceAmount, Fee: fee, TxData: txHex}
 
 	// Create the Payment record up-front.
 	// Save changes to the Account (NextInternalKey) and address pool.
 	// Reserve the UTXOs for the payment.
-	tx, err := a.Store.Begin()
+	// Store the result for the Idempotency-Key with the Payment.
+	payment, err := a.reserveIdempotentPayment(account, payTo, "", invoiceAmount, fee, spentUTXOs, changeUTXO, idem, result)
+	if err != nil {
+		return res, replayAfterConflict(a.Store, account.Address, idem, &res, err)
+	}
+	result.PaymentID = payment.ID
+
+	// Submit the transaction to core and update the Payment with the txid.
+	err = SubmitPayment(a.Store, a.L1, payment.ID, txHex, txid, true)
 	if err != nil {
 		return
 	}
-	err = account.UpdatePoolAddresses(tx, a.L1) // we have used an address.
+
+	msg := PaymentEvent{
+		PaymentID: payment.ID,
+		ForeignID: account.ForeignID,
+		AccountID: account.Address,
+		PayTo:     payTo,
+		Total:     invoiceAmount,
+		TxID:      txid,
+	}
+	a.bus.Send(PAYMENT_SENT, msg)
+	return result, nil
+}
+
+// Reserve a payout Payment for an unsigned transaction, storing the UnsignedTxn
+// on the Payment for SubmitSignedTxn. The change UTXO is created once the
+// signed transaction is submitted (when the txid is known)
+// If needsApproval, the payment must be approved before SubmitSignedTxn.
+func (a API) reserveUnsignedPayment(account Account, payTo []PayTo, memo string, newTxn NewTxn, spentUTXOs []UTXO, changeUTXO UTXO, needsApproval bool, idem IdempotencyKey) (res SendFundsResult, err error) {
+	dbtx, err := a.Store.Begin()
 	if err != nil {
-		tx.Rollback()
 		return
 	}
-	err = tx.UpdateAccount(account) // for NextInternalKey (change address)
+	defer dbtx.Rollback()
+	total := newTxn.TotalOut
+	fee := newTxn.FeeAmount
+	payment, err := ReservePaymentTx(dbtx, a.L1, account, PaymentTypePayout, payTo, memo, total, fee, spentUTXOs, UTXO{})
 	if err != nil {
-		tx.Rollback()
 		return
 	}
-	// Create the `payment` row with no txid or paid_height.
-	payment, err := tx.CreatePayment(account.Address, payTo, invoiceAmount, fee)
+	unsigned := NewUnsignedTxn(payment.ID, account.Address, newTxn.TxnHex, spentUTXOs, changeUTXO)
+	unsignedJson, err := json.Marshal(unsigned)
+	if err != nil {
+		return res, NewErr(UnknownError, "cannot encode unsigned transaction: %v", err)
+	}
+	err = dbtx.SetPaymentUnsignedTxn(payment.ID, string(unsignedJson))
 	if err != nil {
-		tx.Rollback()
 		return
 	}
-	// Reserve the UTXOs we're spending so they can't be double-spent.
-	for _, utxo := range spentUTXOs {
-		err = tx.MarkUTXOReserved(utxo.TxID, utxo.VOut, payment.ID)
+	result := SendFundsResult{Total: total.Add(fee), Paid: total, Fee: fee, TxData: newTxn.TxnHex, Memo: memo, PaymentID: payment.ID, Unsigned: &unsigned}
+	if needsApproval {
+		err = dbtx.UpdatePaymentApprovalStatus(payment.ID, ApprovalPending)
 		if err != nil {
-			tx.Rollback()
 			return
 		}
+		result.ApprovalStatus = ApprovalPending
 	}
-	// Create the 'change' UTXO now, so the change can be spent immediately.
-	if !changeUTXO.Value.IsZero() {
-		err = tx.CreateUTXO(changeUTXO)
-		if err != nil {
-			tx.Rollback()
-			return
-		}
+	err = storeIdempotent(dbtx, account.Address, idem, result)
+	if err != nil {
+		dbtx.Rollback()
+		return res, replayAfterConflict(a.Store, account.Address, idem, &res, err)
 	}
-	err = tx.Commit()
+	err = dbtx.Commit()
 	if err != nil {
-		return
+		return res, replayAfterConflict(a.Store, account.Address, idem, &res, err)
+	}
+	if needsApproval {
+		a.sendPendingApproval(account, payment.ID, payTo, total, "", memo)
 	}
+	a.bus.Send(SYS_MSG, fmt.Sprintf("Payment %v from %s is waiting for an offline-signed transaction", payment.ID, account.ForeignID))
+	return result, nil
+}
 
-	// Submit the transaction to core.
-	coreTxid, err := a.L1.Send(txHex)
+// Reserve a payout Payment that requires approval, storing the signed
+// transaction on the Payment as a PendingTxn for ApprovePayment.
+// The UTXOs are reserved while the payment is pending; the change UTXO is
+// created once the transaction is sent (so nothing can spend it before then)
+func (a API) reservePendingPayment(account Account, payTo []PayTo, memo string, newTxn NewTxn, spentUTXOs []UTXO, changeUTXO UTXO, txid string, idem IdempotencyKey) (res SendFundsResult, err error) {
+	dbtx, err := a.Store.Begin()
 	if err != nil {
 		return
 	}
-	if coreTxid != txid {
-		log.Printf("[!] sendrawtransaction: Core Node did not return the precomputed txid: %s (expecting %s)", coreTxid, txid)
+	defer dbtx.Rollback()
+	total := newTxn.TotalOut
+	fee := newTxn.FeeAmount
+	payment, err := ReservePaymentTx(dbtx, a.L1, account, PaymentTypePayout, payTo, memo, total, fee, spentUTXOs, UTXO{})
+	if err != nil {
+		return
+	}
+	err = SetPendingTxn(dbtx, payment.ID, newTxn, txid, changeUTXO)
+	if err != nil {
+		return
+	}
+	err = dbtx.UpdatePaymentApprovalStatus(payment.ID, ApprovalPending)
+	if err != nil {
+		return
 	}
+	result := SendFundsResult{TxId: txid, Total: total.Add(fee), Paid: total, Fee: fee, TxData: newTxn.TxnHex, Memo: memo, PaymentID: payment.ID, ApprovalStatus: ApprovalPending}
+	err = storeIdempotent(dbtx, account.Address, idem, result)
+	if err != nil {
+		dbtx.Rollback()
+		return res, replayAfterConflict(a.Store, account.Address, idem, &res, err)
+	}
+	err = dbtx.Commit()
+	if err != nil {
+		return res, replayAfterConflict(a.Store, account.Address, idem, &res, err)
+	}
+	a.sendPendingApproval(account, payment.ID, payTo, total, txid, memo)
+	return result, nil
+}
 
-	// Update the Payment with the txid,
-	// which changes it to "accepted" status (accepted by the network)
-	tx, err = a.Store.Begin()
+func (a API) sendPendingApproval(account Account, paymentID int64, payTo []PayTo, total CoinAmount, txid string, memo string) {
+	a.bus.Send(PAYMENT_PENDING_APPROVAL, PaymentEvent{
+		PaymentID: paymentID,
+		ForeignID: account.ForeignID,
+		AccountID: account.Address,
+		PayTo:     payTo,
+		Total:     total,
+		TxID:      txid,
+		Memo:      memo,
+	})
+}
+
+// SubmitSignedTxn submits an offline-signed transaction for a Payment
+// reserved by SendFundsToAddress for an OfflineSigning account. The signed
+// transaction must spend the same inputs and pay the same outputs as the
+// UnsignedTxn stored on the Payment.
+func (a API) SubmitSignedTxn(foreignID string, paymentID int64, signedHex string) (res SendFundsResult, err error) {
+	account, err := a.Store.GetAccount(foreignID)
+	if err != nil {
+		return
+	}
+	payment, err := a.Store.GetPayment(account.Address, paymentID)
 	if err != nil {
 		return
 	}
-	err = tx.UpdatePaymentWithTxID(payment.ID, txid)
+	if payment.UnsignedTxn == "" {
+		return res, NewErr(BadRequest, "payment %v is not waiting for an offline-signed transaction", paymentID)
+	}
+	if payment.PaidTxID != "" {
+		return res, NewErr(AlreadyExists, "payment %v has already been submitted: %v", paymentID, payment.PaidTxID)
+	}
+	switch payment.ApprovalStatus {
+	case ApprovalPending:
+		return res, NewErr(Conflict, "payment %v is waiting for approval", paymentID)
+	case ApprovalRejected:
+		return res, NewErr(BadRequest, "payment %v was rejected", paymentID)
+	}
+	var unsigned UnsignedTxn
+	err = json.Unmarshal([]byte(payment.UnsignedTxn), &unsigned)
+	if err != nil {
+		return res, NewErr(UnknownError, "cannot decode unsigned transaction for payment %v: %v", paymentID, err)
+	}
+	txid, err := unsigned.VerifySigned(signedHex)
 	if err != nil {
-		tx.Rollback()
 		return
 	}
-	err = tx.Commit()
+
+	// Submit the transaction to core and update the Payment with the txid.
+	err = SubmitPayment(a.Store, a.L1, payment.ID, signedHex, txid, true)
 	if err != nil {
 		return
 	}
 
-	msg := PaymentEvent{
+	// Create the 'change' UTXO now the txid is known.
+	if change, ok := unsigned.ChangeUTXO(txid); ok {
+		dbtx, err := a.Store.Begin()
+		if err != nil {
+			return res, err
+		}
+		err = dbtx.CreateUTXO(change)
+		if err != nil {
+			dbtx.Rollback()
+			return res, err
+		}
+		err = dbtx.Commit()
+		if err != nil {
+			return res, err
+		}
+	}
+
+	a.bus.Send(PAYMENT_SENT, PaymentEvent{
 		PaymentID: payment.ID,
 		ForeignID: account.ForeignID,
 		AccountID: account.Address,
-		PayTo:     payTo,
-		Total:     invoiceAmount,
+		PayTo:     payment.PayTo,
+		Total:     payment.Total,
 		TxID:      txid,
+		Memo:      payment.Memo,
+	})
+	return SendFundsResult{TxId: txid, Total: payment.Total.Add(payment.Fee), Paid: payment.Total, Fee: payment.Fee, TxData: signedHex, Memo: payment.Memo}, nil
+}
+
+// ApprovePayment records an approval of a pending Payment by `approver` (an
+// API identity). Once the account's ApprovalsRequired distinct approvers have
+// approved, the payment is sent (or, for an OfflineSigning account, can be
+// sent with SubmitSignedTxn)
+// It returns Conflict if the approver has already approved the payment.
+// Approving an approved payment that was not sent (e.g. the Core Node was
+// not available) re-submits its transaction.
+func (a API) ApprovePayment(foreignID string, paymentID int64, approver string) (Payment, error) {
+	account, payment, err := a.decidePayment(foreignID, paymentID, approver, true)
+	if err != nil {
+		return Payment{}, err
 	}
-	a.bus.Send(PAYMENT_SENT, msg)
-	return SendFundsResult{TxId: txid, Total: invoiceAmount.Add(fee), Paid: invoiceAmount, Fee: fee}, nil
+	if payment.ApprovalStatus != ApprovalApproved {
+		return payment, nil // waiting for more approvals.
+	}
+	if payment.PendingTxn == "" {
+		a.bus.Send(SYS_MSG, fmt.Sprintf("Payment %v from %s is approved and waiting for an offline-signed transaction", payment.ID, account.ForeignID))
+		return payment, nil
+	}
+	pending, err := payment.DecodePendingTxn()
+	if err != nil {
+		return Payment{}, err
+	}
+
+	// BEYOND THIS POINT: if we fail to submit the tx, the payment stays approved
+	// with no txid (and its UTXOs reserved) until it is approved again.
+
+	// Submit the transaction to core, update the Payment with the txid
+	// and create the 'change' UTXO.
+	err = SubmitPendingPayment(a.Store, a.L1, payment.ID, pending)
+	if err != nil {
+		return Payment{}, err
+	}
+	payment.PaidTxID = pending.TxID
+
+	a.bus.Send(PAYMENT_SENT, PaymentEvent{
+		PaymentID: payment.ID,
+		ForeignID: account.ForeignID,
+		AccountID: account.Address,
+		PayTo:     payment.PayTo,
+		Total:     payment.Total,
+		TxID:      pending.TxID,
+		Memo:      payment.Memo,
+	})
+	return payment, nil
+}
+
+// RejectPayment rejects a pending Payment on behalf of `approver` (an API
+// identity). A single rejection rejects the payment: its reserved UTXOs are
+// released so they can be spent by other payments.
+func (a API) RejectPayment(foreignID string, paymentID int64, approver string) (Payment, error) {
+	account, payment, err := a.decidePayment(foreignID, paymentID, approver, false)
+	if err != nil {
+		return Payment{}, err
+	}
+	a.bus.Send(PAYMENT_REJECTED, PaymentEvent{
+		PaymentID: payment.ID,
+		ForeignID: account.ForeignID,
+		AccountID: account.Address,
+		PayTo:     payment.PayTo,
+		Total:     payment.Total,
+		Memo:      payment.Memo,
+	})
+	return payment, nil
+}
+
+// Store an approve/reject decision for a pending Payment and update its
+// ApprovalStatus, in a single store transaction.
+func (a API) decidePayment(foreignID string, paymentID int64, approver string, approved bool) (Account, Payment, error) {
+	if approver == "" {
+		return Account{}, Payment{}, NewErr(Unauthorized, "an approver identity is required")
+	}
+	dbtx, err := a.Store.Begin()
+	if err != nil {
+		return Account{}, Payment{}, err
+	}
+	defer dbtx.Rollback()
+	account, err := dbtx.GetAccount(foreignID)
+	if err != nil {
+		return Account{}, Payment{}, err
+	}
+	payment, err := dbtx.GetPayment(account.Address, paymentID)
+	if err != nil {
+		return Account{}, Payment{}, err
+	}
+	switch payment.ApprovalStatus {
+	case ApprovalNone:
+		return Account{}, Payment{}, NewErr(BadRequest, "payment %v does not require approval", paymentID)
+	case ApprovalApproved:
+		if approved && payment.PaidTxID == "" && payment.PendingTxn != "" {
+			return account, payment, nil // not sent yet: ApprovePayment re-submits it.
+		}
+		return Account{}, Payment{}, NewErr(Conflict, "payment %v has already been approved", paymentID)
+	case ApprovalRejected:
+		return Account{}, Payment{}, NewErr(Conflict, "payment %v has already been rejected", paymentID)
+	}
+	err = dbtx.AddPaymentApproval(PaymentApproval{PaymentID: paymentID, Approver: approver, Approved: approved, Created: time.Now()})
+	if err != nil {
+		if IsAlreadyExistsError(err) {
+			return Account{}, Payment{}, NewErr(Conflict, "approver %v has already decided on payment %v", approver, paymentID)
+		}
+		return Account{}, Payment{}, err
+	}
+	if approved {
+		approvals, err := dbtx.ListPaymentApprovals(paymentID)
+		if err != nil {
+			return Account{}, Payment{}, err
+		}
+		count := 0
+		for _, ap := range approvals {
+			if ap.Approved {
+				count++
+			}
+		}
+		if count >= account.ApprovalsRequired {
+			payment.ApprovalStatus = ApprovalApproved
+		}
+	} else {
+		payment.ApprovalStatus = ApprovalRejected
+		err = dbtx.ReleasePaymentUTXOs(paymentID)
+		if err != nil {
+			return Account{}, Payment{}, err
+		}
+	}
+	if payment.ApprovalStatus != ApprovalPending {
+		err = dbtx.UpdatePaymentApprovalStatus(paymentID, payment.ApprovalStatus)
+		if err != nil {
+			return Account{}, Payment{}, err
+		}
+	}
+	err = dbtx.Commit()
+	if err != nil {
+		return Account{}, Payment{}, err
+	}
+	return account, payment, nil
+}
+
+// ListPaymentApprovals returns the approve/reject decisions for a Payment.
+func (a API) ListPaymentApprovals(foreignID string, paymentID int64) ([]PaymentApproval, error) {
+	account, err := a.Store.GetAccount(foreignID)
+	if err != nil {
+		return nil, err
+	}
+	_, err = a.Store.GetPayment(account.Address, paymentID)
+	if err != nil {
+		return nil, err
+	}
+	return a.Store.ListPaymentApprovals(paymentID)
+}
+
+type SweepResult struct {
+	TxId   string     `json:"txid"`   // hash of the sweep transaction
+	From   Address    `json:"from"`   // address of the swept private key
+	To     Address    `json:"to"`     // account receive address paid by the sweep
+	Inputs int        `json:"inputs"` // number of unspent outputs swept
+	Total  CoinAmount `json:"total"`  // total amount swept, including fee
+	Paid   CoinAmount `json:"paid"`   // amount paid into the account, excluding fee
+	Fee    CoinAmount `json:"fee"`    // fee paid
+	TxData string     `json:"tx"`     // transaction data, hex-encoded
+}
+
+// SweepPrivateKey sweeps all funds held by an external WIF-encoded private key
+// (e.g. a paper wallet) into the account's next receive address.
+// The key is only used to sign the sweep transaction: it is never stored.
+// The swept funds arrive in the account like any other payment (via ChainTracker)
+func (a API) SweepPrivateKey(foreignID string, ecPrivKeyWIF string, feeOpts FeeOptions) (res SweepResult, err error) {
+	err = validateFeeOptions(feeOpts)
+	if err != nil {
+		return
+	}
+	account, err := a.Store.GetAccount(foreignID)
+	if err != nil {
+		return
+	}
+
+	// The key must be for the network we're on.
+	chain := a.chain()
+	_, _, err = doge.DecodeECPrivKeyWIF(ecPrivKeyWIF, chain)
+	if err != nil {
+		return res, NewErr(BadRequest, "invalid private key for %v: %v", chain.ChainName, err)
+	}
+	if !doge.IsECPrivKeyWIFCompressed(ecPrivKeyWIF) {
+		return res, NewErr(BadRequest, "uncompressed private keys are not supported")
+	}
+	from, err := doge.GenerateP2PKHFromECPrivKeyWIF(ecPrivKeyWIF)
+	if err != nil {
+		return res, NewErr(BadRequest, "invalid private key: %v", err)
+	}
+
+	// Find the unspent outputs paid to the key.
+	found, err := a.L1.ScanAddressUTXOs(from)
+	if err != nil {
+		return res, NewErr(NotAvailable, "cannot scan for unspent outputs: %v", err)
+	}
+	utxos := make([]UTXO, 0, len(found))
+	for _, utxo := range found {
+		if utxo.ScriptType == doge.ScriptTypeP2PKH && utxo.ScriptAddress == from {
+			utxos = append(utxos, utxo)
+		}
+	}
+	if len(utxos) < 1 {
+		return res, NewErr(NotFound, "no unspent outputs found for %v", from)
+	}
+
+	// Pay to the next receive address in the account.
+	to, _, err := account.NextPayToAddress(a.L1)
+	if err != nil {
+		return
+	}
+	newTxn, txid, err := CreateSweepTxn(utxos, ecPrivKeyWIF, to, feeOpts, a.L1)
+	if err != nil {
+		return
+	}
+
+	// Reserve the receive address (unless another request used it meanwhile)
+	dbtx, err := a.Store.Begin()
+	if err != nil {
+		return
+	}
+	defer dbtx.Rollback()
+	account, err = dbtx.GetAccount(foreignID)
+	if err != nil {
+		return
+	}
+	reserved, _, err := account.NextPayToAddress(a.L1)
+	if err != nil {
+		return
+	}
+	if reserved != to {
+		return res, NewErr(DBConflict, "the receive address for account %v was used by another request: try again", foreignID)
+	}
+	err = account.UpdatePoolAddresses(dbtx, a.L1)
+	if err != nil {
+		return
+	}
+	err = dbtx.UpdateAccount(account)
+	if err != nil {
+		return
+	}
+	err = dbtx.Commit()
+	if err != nil {
+		return
+	}
+
+	// Submit the transaction to core.
+	coreTxid, err := a.L1.Send(newTxn.TxnHex)
+	if err != nil {
+		return
+	}
+	if coreTxid != txid {
+		log.Printf("[!] sendrawtransaction: Core Node did not return the precomputed txid: %s (expecting %s)", coreTxid, txid)
+	}
+	a.bus.Send(SYS_MSG, fmt.Sprintf("Swept %vƉ from %s into account %s: %s", newTxn.TotalOut, from, account.ForeignID, txid))
+	return SweepResult{TxId: txid, From: from, To: to, Inputs: len(utxos), Total: newTxn.TotalIn, Paid: newTxn.TotalOut, Fee: newTxn.FeeAmount, TxData: newTxn.TxnHex}, nil
+}
+
+// Reserve a payout Payment (see ReservePayment) and store the result for the
+// Idempotency-Key (if any) in the same store transaction.
+func (a API) reserveIdempotentPayment(account Account, payTo []PayTo, memo string, total CoinAmount, fee CoinAmount, spentUTXOs []UTXO, changeUTXO UTXO, idem IdempotencyKey, result SendFundsResult) (Payment, error) {
+	dbtx, err := a.Store.Begin()
+	if err != nil {
+		return Payment{}, err
+	}
+	payment, err := ReservePaymentTx(dbtx, a.L1, account, PaymentTypePayout, payTo, memo, total, fee, spentUTXOs, changeUTXO)
+	if err != nil {
+		dbtx.Rollback()
+		return Payment{}, err
+	}
+	result.PaymentID = payment.ID
+	err = storeIdempotent(dbtx, account.Address, idem, result)
+	if err != nil {
+		dbtx.Rollback()
+		return Payment{}, err
+	}
+	err = dbtx.Commit()
+	if err != nil {
+		return Payment{}, err
+	}
+	return payment, nil
+}
+
+// resubmitIdempotentPayment submits the tx for a replayed result if the
+// original request reserved the Payment but did not submit the tx (e.g. the
+// Core Node was not available) so a retry never reports an unsent payment.
+// Sending the same tx again cannot pay twice (it has the same txid)
+// Payments waiting for approval or offline signing are sent later.
+func (a API) resubmitIdempotentPayment(account Account, res SendFundsResult, sendTx bool) error {
+	if res.PaymentID == 0 || res.TxData == "" || res.Unsigned != nil || res.ApprovalStatus != ApprovalNone {
+		return nil
+	}
+	payment, err := a.Store.GetPayment(account.Address, res.PaymentID)
+	if err != nil {
+		return err
+	}
+	if payment.PaidTxID != "" {
+		return nil // already submitted.
+	}
+	log.Printf("Re-submitting payment %v for a retried request: %s", payment.ID, res.TxId)
+	err = SubmitPayment(a.Store, a.L1, payment.ID, res.TxData, res.TxId, sendTx)
+	if err != nil {
+		return err
+	}
+	if sendTx {
+		msg := PaymentEvent{
+			PaymentID: payment.ID,
+			ForeignID: account.ForeignID,
+			AccountID: account.Address,
+			PayTo:     payment.PayTo,
+			Total:     payment.Total,
+			TxID:      res.TxId,
+			Memo:      payment.Memo,
+		}
+		a.bus.Send(PAYMENT_SENT, msg)
+	}
+	return nil
+}
+
+// QueueWithdrawal accepts a pay-out request immediately, to be paid later
+// in a batch transaction with other withdrawals from the same account
+// (see services.PayoutBatcher)
+func (a API) QueueWithdrawal(foreignID string, payTo Address, amount CoinAmount) (Withdrawal, error) {
+	amount = amount.RoundFloor(NumKoinuDigits) // round to whole Koinu
+	if amount.LessThan(TxnDustLimit) {
+		return Withdrawal{}, NewErr(InvalidTxn, "Withdrawal Amount cannot be less than the Dogecoin Dust Limit (%vƉ): The request was to pay %vƉ to %v", TxnDustLimit, amount, payTo)
+	}
+	txn, err := a.Store.Begin()
+	if err != nil {
+		a.bus.Send(SYS_ERR, fmt.Sprintf("QueueWithdrawal: Failed to begin txn: %s", err))
+		return Withdrawal{}, err
+	}
+	defer txn.Rollback()
+
+	account, err := txn.GetAccount(foreignID)
+	if err != nil {
+		return Withdrawal{}, err
+	}
+	if account.OfflineSigning {
+		return Withdrawal{}, NewErr(BadRequest, "account %v uses offline signing: use pay instead", foreignID)
+	}
+	if account.RequiresApproval([]PayTo{{PayTo: payTo, Amount: amount}}, amount) {
+		return Withdrawal{}, NewErr(BadRequest, "withdrawal from account %v requires approval: use pay instead", foreignID)
+	}
+	// Reject invalid addresses now, rather than failing the whole batch later.
+	if payTo == "" {
+		return Withdrawal{}, NewErr(InvalidTxn, "Invalid withdrawal: missing 'to' address in the request.")
+	}
+	err = a.validatePayTo([]PayTo{{PayTo: payTo, Amount: amount}})
+	if err != nil {
+		return Withdrawal{}, err
+	}
+	_, err = scriptTypeForAddress(payTo, account)
+	if err != nil {
+		return Withdrawal{}, err
+	}
+	wd, err := txn.CreateWithdrawal(account.Address, payTo, amount)
+	if err != nil {
+		return Withdrawal{}, err
+	}
+	err = txn.Commit()
+	if err != nil {
+		a.bus.Send(SYS_ERR, fmt.Sprintf("QueueWithdrawal: Failed to commit: %s", foreignID))
+		return Withdrawal{}, err
+	}
+
+	a.bus.Send(WITHDRAWAL_QUEUED, WithdrawalEvent{
+		WithdrawalID: wd.ID,
+		AccountID:    account.Address,
+		ForeignID:    account.ForeignID,
+		PayTo:        wd.PayTo,
+		Amount:       wd.Amount,
+		Status:       wd.Status,
+	})
+	return wd, nil
+}
+
+func (a API) GetWithdrawal(foreignID string, id int64) (Withdrawal, error) {
+	account, err := a.Store.GetAccount(foreignID)
+	if err != nil {
+		return Withdrawal{}, err
+	}
+	return a.Store.GetWithdrawal(account.Address, id)
+}
+
+// SchedulePayment schedules a pay-out from the account at a future time
+// (payAt) or block height (payAtHeight) which is paid by the PaymentScheduler
+// service (see ScheduledPayment)
+// If lockTime is set, the transaction is created now with an nLockTime, and
+// the funds are reserved until it is sent.
+func (a API) SchedulePayment(foreignID string, payTo []PayTo, memo string, payAt time.Time, payAtHeight int64, lockTime bool) (ScheduledPayment, error) {
+	if !a.config.Scheduling.Enabled {
+		return ScheduledPayment{}, NewErr(NotAvailable, "the scheduled payment service is not enabled")
+	}
+	if payAt.IsZero() == (payAtHeight == 0) {
+		return ScheduledPayment{}, NewErr(BadRequest, "specify one of 'pay_at' (time) or 'pay_at_height' (block height)")
+	}
+	if payAtHeight < 0 || payAtHeight >= LockTimeThreshold {
+		return ScheduledPayment{}, NewErr(BadRequest, "invalid 'pay_at_height': %v", payAtHeight)
+	}
+	if len(payTo) < 1 {
+		return ScheduledPayment{}, NewErr(BadRequest, "missing 'pay' in the request")
+	}
+	if len(memo) > doge.MaxNullDataSize {
+		return ScheduledPayment{}, NewErr(BadRequest, "memo cannot be longer than %v bytes", doge.MaxNullDataSize)
+	}
+	account, err := a.Store.GetAccount(foreignID)
+	if err != nil {
+		return ScheduledPayment{}, err
+	}
+	if account.OfflineSigning {
+		return ScheduledPayment{}, NewErr(BadRequest, "account %v uses offline signing: use pay instead", foreignID)
+	}
+	// Reject invalid payments now, rather than failing when they are due.
+	err = a.validatePayTo(payTo)
+	if err != nil {
+		return ScheduledPayment{}, err
+	}
+	total := ZeroCoins
+	for _, pay := range payTo {
+		if pay.Amount.LessThan(TxnDustLimit) {
+			return ScheduledPayment{}, NewErr(InvalidTxn, "Payment Amount cannot be less than the Dogecoin Dust Limit (%vƉ): The request was to pay %vƉ to %v", TxnDustLimit, pay.Amount, pay.PayTo)
+		}
+		_, err = scriptTypeForAddress(pay.PayTo, account)
+		if err != nil {
+			return ScheduledPayment{}, err
+		}
+		total = total.Add(pay.Amount)
+	}
+	// The scheduler sends the payment unattended, so it cannot wait for approvals.
+	if account.RequiresApproval(payTo, total) {
+		return ScheduledPayment{}, NewErr(BadRequest, "payment from account %v requires approval: use pay instead", foreignID)
+	}
+	sp := ScheduledPayment{AccountID: account.Address, PayTo: payTo, Memo: memo, PayAt: payAt, PayAtHeight: payAtHeight, LockTime: lockTime}
+
+	// Create the nLockTime transaction now, and reserve the payment
+	// (the change UTXO is created when the transaction is sent)
+	var newTxn NewTxn
+	var changeUTXO UTXO
+	var spentUTXOs []UTXO
+	var txid string
+	if lockTime {
+		source := NewUTXOSource(a.Store, account.Address)
+		newTxn, changeUTXO, spentUTXOs, txid, err = CreateLockedTxn(payTo, []byte(memo), FeeOptions{}, sp.TxnLockTime(), account, source, a.L1)
+		if err != nil {
+			return ScheduledPayment{}, err
+		}
+	}
+
+	dbtx, err := a.Store.Begin()
+	if err != nil {
+		return ScheduledPayment{}, err
+	}
+	defer dbtx.Rollback()
+	if lockTime {
+		payment, err := ReservePaymentTx(dbtx, a.L1, account, PaymentTypePayout, payTo, string(newTxn.Memo), newTxn.TotalOut, newTxn.FeeAmount, spentUTXOs, UTXO{})
+		if err != nil {
+			return ScheduledPayment{}, err
+		}
+		err = SetPendingTxn(dbtx, payment.ID, newTxn, txid, changeUTXO)
+		if err != nil {
+			return ScheduledPayment{}, err
+		}
+		sp.PaymentID = payment.ID
+	}
+	sp, err = dbtx.CreateScheduledPayment(sp)
+	if err != nil {
+		return ScheduledPayment{}, err
+	}
+	err = dbtx.Commit()
+	if err != nil {
+		return ScheduledPayment{}, err
+	}
+	a.bus.Send(SCHEDULE_CREATED, NewScheduleEvent(account, sp))
+	return sp, nil
+}
+
+func (a API) GetScheduledPayment(foreignID string, id int64) (ScheduledPayment, error) {
+	account, err := a.Store.GetAccount(foreignID)
+	if err != nil {
+		return ScheduledPayment{}, err
+	}
+	return a.Store.GetScheduledPayment(account.Address, id)
+}
+
+// CancelScheduledPayment cancels a pending scheduled payment.
+// A payment with LockTime cannot be cancelled: its funds are committed
+// to the nLockTime transaction.
+func (a API) CancelScheduledPayment(foreignID string, id int64) (ScheduledPayment, error) {
+	dbtx, err := a.Store.Begin()
+	if err != nil {
+		return ScheduledPayment{}, err
+	}
+	defer dbtx.Rollback()
+	account, err := dbtx.GetAccount(foreignID)
+	if err != nil {
+		return ScheduledPayment{}, err
+	}
+	sp, err := dbtx.GetScheduledPayment(account.Address, id)
+	if err != nil {
+		return ScheduledPayment{}, err
+	}
+	if sp.Status != SchedulePending {
+		return ScheduledPayment{}, NewErr(Conflict, "scheduled payment %v is already %v", id, sp.Status)
+	}
+	if sp.LockTime {
+		return ScheduledPayment{}, NewErr(Conflict, "scheduled payment %v uses lock_time: the transaction cannot be cancelled", id)
+	}
+	sp.Status = ScheduleCancelled
+	err = dbtx.UpdateScheduledPayment(sp)
+	if err != nil {
+		return ScheduledPayment{}, err
+	}
+	err = dbtx.Commit()
+	if err != nil {
+		return ScheduledPayment{}, err
+	}
+	a.bus.Send(SCHEDULE_CANCELLED, NewScheduleEvent(account, sp))
+	return sp, nil
+}
+
+// List blocks the ChainFollower could not decode (not yet re-processed)
+func (a API) ListSkippedBlocks() ([]SkippedBlock, error) {
+	return a.Store.ListSkippedBlocks()
+}
+
+// Re-process skipped blocks that can now be decoded (see RetrySkippedBlocksCmd)
+func (a API) RetrySkippedBlocks() {
+	a.follower.SendCommand(RetrySkippedBlocksCmd{})
 }
 
 // Re-sync from a specific block height, or skip ahead (for now)
@@ -486,3 +1358,71 @@ func (a API) SetSyncHeight(height int64) error {
 	a.follower.SendCommand(ReSyncChainFollowerCmd{BlockHash: hash})
 	return nil
 }
+
+// ChainStatus is the sync status of the ChainFollower for one network.
+type ChainStatus struct {
+	Network         string         `json:"network"`
+	BestBlockHash   string         `json:"best_block_hash"`   // last block processed (effects included in DB)
+	BestBlockHeight int64          `json:"best_block_height"` // height of the last block processed
+	RootHash        string         `json:"root_hash"`         // hash of block at height 1 on the chain being sync'd
+	FirstHeight     int64          `json:"first_height"`      // block height when GigaWallet first started to sync
+	CoreHeight      int64          `json:"core_height"`       // block height of Core's best block (0: not available)
+	CoreError       string         `json:"core_error,omitempty"`
+	Behind          int64          `json:"behind"` // blocks behind Core's best block
+	Follower        FollowerStatus `json:"follower"`
+}
+
+// Get the sync status of the ChainFollower, and how far behind Core it is.
+func (a API) ChainStatus() (ChainStatus, error) {
+	state, err := a.Store.GetChainState()
+	if err != nil && !IsNotFoundError(err) {
+		return ChainStatus{}, err
+	}
+	status := ChainStatus{
+		Network:         a.Network(),
+		BestBlockHash:   state.BestBlockHash,
+		BestBlockHeight: state.BestBlockHeight,
+		RootHash:        state.RootHash,
+		FirstHeight:     state.FirstHeight,
+		Follower:        a.follower.Status(),
+	}
+	height, err := a.L1.GetBlockCount()
+	if err != nil {
+		status.CoreError = err.Error()
+	} else {
+		status.CoreHeight = height
+		if height > state.BestBlockHeight {
+			status.Behind = height - state.BestBlockHeight
+		}
+	}
+	return status, nil
+}
+
+// Pause following the chain (see PauseChainFollowerCmd)
+func (a API) PauseChainFollower() {
+	a.follower.SendCommand(PauseChainFollowerCmd{})
+}
+
+// Resume following the chain (see ResumeChainFollowerCmd)
+func (a API) ResumeChainFollower() {
+	a.follower.SendCommand(ResumeChainFollowerCmd{})
+}
+
+// Restart the ChainFollower in case it becomes stuck.
+func (a API) RestartChainFollower() {
+	a.follower.SendCommand(RestartChainFollowerCmd{})
+}
+
+// Roll back the chain-state to a block height below the last block processed,
+// and follow the chain forwards again from there (rather than re-scanning
+// from scratch, see SetSyncHeight)
+func (a API) RollbackChain(height int64) error {
+	state, err := a.Store.GetChainState()
+	if err != nil {
+		return err
+	}
+	if height < state.FirstHeight || height >= state.BestBlockHeight {
+		return NewErr(BadRequest, "cannot roll back to height %v: must be from %v to below %v (the last block processed)", height, state.FirstHeight, state.BestBlockHeight)
+	}
+	return a.SetSyncHeight(height)
+}
@@ -0,0 +1,53 @@
+package giga
+
+import (
+	"time"
+)
+
+// ApprovalStatus of a Payment that requires approval (see Account.ApprovalsRequired)
+type ApprovalStatus string
+
+const (
+	ApprovalNone     ApprovalStatus = ""         // payment did not require approval
+	ApprovalPending  ApprovalStatus = "pending"  // waiting for approvals (UTXOs are reserved)
+	ApprovalApproved ApprovalStatus = "approved" // approved, and sent (or ready to submit-signed)
+	ApprovalRejected ApprovalStatus = "rejected" // rejected (UTXOs are released)
+)
+
+// PaymentApproval is an approve or reject decision from an approver
+// (an API identity, see WebAPIConfig.Approvers) for a pending Payment.
+type PaymentApproval struct {
+	PaymentID int64     `json:"payment_id"`
+	Approver  string    `json:"approver"` // identity of the approver
+	Approved  bool      `json:"approved"` // false: rejected
+	Created   time.Time `json:"created"`
+}
+
+// RequiresApproval reports whether a payment of `total` to `payTo` requires
+// approval under the account's approval policy: either the total is above
+// ApprovalThreshold, or an address is not in ApprovedAddresses (if any)
+func (a Account) RequiresApproval(payTo []PayTo, total CoinAmount) bool {
+	if a.ApprovalsRequired < 1 {
+		return false
+	}
+	if total.GreaterThan(a.ApprovalThreshold) {
+		return true
+	}
+	if len(a.ApprovedAddresses) > 0 {
+		for _, pay := range payTo {
+			if !a.isApprovedAddress(pay.PayTo) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (a Account) isApprovedAddress(addr Address) bool {
+	for _, approved := range a.ApprovedAddresses {
+		if approved == addr {
+			return true
+		}
+	}
+	return false
+}
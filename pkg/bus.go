@@ -34,7 +34,8 @@ type MessageSubscriber interface {
 type Message struct {
 	EventType EventType   `json:"event_type"`
 	Message   interface{} `json:"message"`
-	ID        string      `json:"event_id"` // optional
+	ID        string      `json:"event_id"`          // optional
+	Network   string      `json:"network,omitempty"` // sent for this network (see ForNetwork)
 }
 
 type Subscription struct {
@@ -55,6 +56,18 @@ type MessageBus struct {
 
 	// Messages from Send(), destinated for MessageSubscribers
 	inbound chan Message
+
+	// Network to tag messages with (see ForNetwork)
+	network string
+}
+
+// ForNetwork returns a MessageBus that tags each message sent with
+// `network` (mainnet, testnet, regtest) when running several networks
+// side by side, so receivers can tell them apart. It shares the same
+// subscribers and must not be Run separately.
+func (b MessageBus) ForNetwork(network string) MessageBus {
+	b.network = network
+	return b
 }
 
 // Send a message to the bus with a specific EventType
@@ -63,9 +76,9 @@ type MessageBus struct {
 func (b MessageBus) Send(t EventType, msg interface{}, msgID ...string) error {
 
 	if len(msgID) == 0 {
-		b.inbound <- Message{t, msg, generateID()}
+		b.inbound <- Message{t, msg, generateID(), b.network}
 	} else {
-		b.inbound <- Message{t, msg, msgID[0]}
+		b.inbound <- Message{t, msg, msgID[0], b.network}
 	}
 	return nil
 }
@@ -104,7 +117,7 @@ func (b MessageBus) Run(started, stopped chan bool, stop chan context.Context) e
 							}
 						}
 						if !cont {
-							break
+							continue // try the next receiver.
 						}
 
 						// send the message to the receiver
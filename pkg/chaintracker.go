@@ -5,7 +5,17 @@ import "context"
 type TipChaserReceiver = chan NodeEvent
 
 type ChainFollower interface {
-	SendCommand(cmd any) // send any of the commands below.
+	SendCommand(cmd any)    // send any of the commands below.
+	Status() FollowerStatus // what the ChainFollower is doing now.
+}
+
+// FollowerStatus is the current activity of the ChainFollower (see ChainStatus)
+type FollowerStatus struct {
+	Paused     bool   `json:"paused"`               // not following the chain (see PauseChainFollowerCmd)
+	Syncing    bool   `json:"syncing"`              // catching up with the tip
+	BatchStart int64  `json:"batch_start"`          // first block height in the batch being processed (0: none)
+	BatchEnd   int64  `json:"batch_end"`            // last block height fetched in the current batch
+	LastError  string `json:"last_error,omitempty"` // last error the ChainFollower is retrying (until it reaches the tip)
 }
 
 /** Re-Sync the ChainFollower from a specific block hash on the blockchain.
@@ -16,6 +26,21 @@ type ReSyncChainFollowerCmd struct {
 	BlockHash string // Block hash to re-sync from.
 }
 
+/** Re-process blocks that were skipped because they could not be decoded
+ *  (see Store.ListSkippedBlocks) e.g. after upgrading GigaWallet. This will
+ *  roll back to the lowest skipped block that can now be decoded, and follow
+ *  the block-chain forwards again from there.
+ */
+type RetrySkippedBlocksCmd struct{}
+
+/** Pause following the block-chain (e.g. for maintenance) Other commands
+ *  are still executed, e.g. ReSync rolls back the chain-state while paused.
+ */
+type PauseChainFollowerCmd struct{}
+
+/** Resume following the block-chain after PauseChainFollowerCmd. */
+type ResumeChainFollowerCmd struct{}
+
 /** Restart the ChainFollower in case it becomes stuck. */
 type RestartChainFollowerCmd struct{}
 
@@ -29,3 +54,5 @@ type MockFollower struct {
 }
 
 func (m MockFollower) SendCommand(cmd any) {}
+
+func (m MockFollower) Status() FollowerStatus { return FollowerStatus{} }
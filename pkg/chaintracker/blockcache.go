@@ -0,0 +1,102 @@
+package chaintracker
+
+import (
+	"log"
+	"sync"
+
+	"github.com/dogecoinfoundation/gigawallet/pkg/doge"
+)
+
+const BLOCK_CACHE_SIZE = 10 // raw blocks kept from ZMQ rawblock notifications.
+
+/*
+ * blockCache holds the most recent raw blocks received from Core's ZMQ
+ * rawblock notifications (via TipChaser) keyed by block hash, along with
+ * the latest best block hash the TipChaser received, so the ChainFollower
+ * can apply new blocks at the tip without any RPC requests.
+ * All methods are safe to call on a nil blockCache.
+ */
+type blockCache struct {
+	lock   sync.Mutex
+	size   int
+	blocks map[string]cachedBlock // by block hash.
+	order  []string               // block hashes, oldest first.
+	tip    string                 // latest best block hash (from the TipChaser)
+}
+
+type cachedBlock struct {
+	prevHash string // hash of the previous block (from the header)
+	data     []byte // raw block (including header)
+}
+
+func newBlockCache(size int) *blockCache {
+	return &blockCache{size: size, blocks: make(map[string]cachedBlock)}
+}
+
+// Add a raw block (hex) received from Core, evicting the oldest block if full.
+// Ignored unless the block header hashes to `hash`.
+func (b *blockCache) add(hash string, blockHex string) {
+	if b == nil {
+		return
+	}
+	data, err := doge.HexDecode(blockHex)
+	if err != nil || len(data) < 80 || doge.BlockHashHex(data[:80]) != hash {
+		log.Println("BlockCache: ignoring invalid raw block:", hash)
+		return
+	}
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if _, found := b.blocks[hash]; found {
+		return
+	}
+	if len(b.order) >= b.size {
+		delete(b.blocks, b.order[0])
+		b.order = b.order[1:]
+	}
+	b.blocks[hash] = cachedBlock{prevHash: doge.HexEncodeReversed(data[4:36]), data: data}
+	b.order = append(b.order, hash)
+}
+
+// Get a raw block by hash.
+func (b *blockCache) get(hash string) (data []byte, found bool) {
+	if b == nil {
+		return nil, false
+	}
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	block, found := b.blocks[hash]
+	return block.data, found
+}
+
+// Record the best block hash received by the TipChaser (ZMQ or polling)
+func (b *blockCache) setTip(hash string) {
+	if b == nil {
+		return
+	}
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.tip = hash
+}
+
+// Find the cached blocks that lead from prevHash to the tip, in chain order.
+// Returns none unless the tip and every block after prevHash are cached.
+func (b *blockCache) pathToTip(prevHash string) []string {
+	if b == nil {
+		return nil
+	}
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	var path []string
+	for hash := b.tip; hash != prevHash; {
+		block, found := b.blocks[hash]
+		if !found || len(path) >= len(b.order) {
+			return nil
+		}
+		path = append(path, hash)
+		hash = block.prevHash
+	}
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+	return path
+}
@@ -0,0 +1,336 @@
+package chaintracker
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+
+	giga "github.com/dogecoinfoundation/gigawallet/pkg"
+	"github.com/dogecoinfoundation/gigawallet/pkg/doge"
+)
+
+const (
+	IMPORT_BLOCKS_PER_COMMIT = 500 // number of blocks per database commit (import)
+	IMPORT_COMMIT_ATTEMPTS   = 10  // attempts to commit each batch before giving up.
+)
+
+/*
+ * ImportBlockFiles bootstraps the chainstate from a local copy of Dogecoin
+ * Core's block files (blocks/blk*.dat) instead of fetching every block over RPC.
+ *
+ * Core appends blocks to these files as it receives them, which is not in
+ * height order (and includes stale forks) so we index every block header
+ * first, pick the chain with the most work from the genesis block, then
+ * apply the blocks in height order through the same path as the ChainFollower.
+ *
+ * The import resumes from the current ChainState (if any), and commits in
+ * batches, so it can be interrupted and run again. When it finishes, the
+ * ChainFollower resumes from the last imported block (and rolls back if Core
+ * has since reorganised away from it.) Do not run this while the server is
+ * running against the same database.
+ */
+func ImportBlockFiles(conf giga.Config, store giga.Store, bus giga.MessageBus, blocksDir string) (giga.ChainState, error) {
+	chain, err := doge.ChainFromName(conf.Gigawallet.Network)
+	if err != nil {
+		return giga.ChainState{}, err
+	}
+	files, err := filepath.Glob(filepath.Join(blocksDir, "blk*.dat"))
+	if err != nil {
+		return giga.ChainState{}, err
+	}
+	if len(files) < 1 {
+		return giga.ChainState{}, fmt.Errorf("no blk*.dat files in %v", blocksDir)
+	}
+	sort.Strings(files) // blk00000.dat, blk00001.dat, ...
+	log.Println("ImportBlockFiles: indexing", len(files), "block files")
+	index, err := indexBlockFiles(files, chain.MessageStart)
+	if err != nil {
+		return giga.ChainState{}, err
+	}
+	best, err := index.bestChain(chain.GenesisBlock)
+	if err != nil {
+		return giga.ChainState{}, err
+	}
+	log.Println("ImportBlockFiles: found", len(index.blocks), "blocks, best chain height", len(best)-1)
+
+	c, err := newChainFollower(conf, nil, store, bus, nil)
+	if err != nil {
+		return giga.ChainState{}, err
+	}
+	c.chain = chain
+	pos, err := c.importStartingPos(best, index)
+	if err != nil {
+		return giga.ChainState{}, err
+	}
+	reader := blockFileReader{files: files}
+	defer reader.close()
+
+	var txIDs []string
+	var changes []UTXOChange
+	var headers []giga.VerifiedHeader
+	for height := pos.BlockHeight + 1; height < int64(len(best)); height++ {
+		b := &index.blocks[best[height]]
+		hash := doge.HexEncodeReversed(b.hash[:])
+		data, err := reader.read(b)
+		if err != nil {
+			return c.importedState(pos), err
+		}
+		block, err := doge.DecodeBlock(data, hash, true)
+		if err != nil {
+			return c.importedState(pos), fmt.Errorf("block %v at height %v: %v", hash, height, err)
+		}
+		if c.verifyBlocks {
+			prev, prevPrev := c.previousVerifiedHeaders(height, headers)
+			hdr, err := c.verifyBlock(&block, giga.RpcBlockHeader{Hash: hash, Height: height}, prev, prevPrev)
+			if err != nil {
+				return c.importedState(pos), err
+			}
+			headers = append(headers, hdr)
+		}
+		changes, txIDs = c.processBlock(&block, hash, height, changes, txIDs)
+		if (height-pos.BlockHeight) >= IMPORT_BLOCKS_PER_COMMIT || height == int64(len(best))-1 {
+			pos, err = c.importCommit(changes, txIDs, headers, ChainPos{hash, height, "", pos.NextSeq})
+			if err != nil {
+				return c.importedState(pos), err
+			}
+			txIDs, changes, headers = nil, nil, nil
+		}
+	}
+	log.Println("ImportBlockFiles: imported up to block", pos.BlockHash, pos.BlockHeight)
+	return c.importedState(pos), nil
+}
+
+// importStartingPos resumes from the ChainState in the store, or creates
+// the ChainState at the genesis block.
+func (c *ChainFollower) importStartingPos(best []int32, index *blockIndex) (ChainPos, error) {
+	genesis := c.chain.GenesisBlock
+	state, err := c.store.GetChainState()
+	if err == nil && state.BestBlockHash != "" {
+		if state.RootHash != genesis {
+			return ChainPos{}, fmt.Errorf("chainstate in the database is for a different chain: %v", state.RootHash)
+		}
+		h := state.BestBlockHeight
+		if h >= int64(len(best)) || doge.HexEncodeReversed(index.blocks[best[h]].hash[:]) != state.BestBlockHash {
+			return ChainPos{}, fmt.Errorf("last processed block %v at height %v is not on the best chain in the block files", state.BestBlockHash, h)
+		}
+		log.Println("ImportBlockFiles: resuming from block", state.BestBlockHash, h)
+		return ChainPos{state.BestBlockHash, h, "", state.NextSeq}, nil
+	}
+	if err != nil && !giga.IsNotFoundError(err) {
+		return ChainPos{}, err
+	}
+	dbtx, err := c.store.Begin()
+	if err != nil {
+		return ChainPos{}, err
+	}
+	err = dbtx.UpdateChainState(giga.ChainState{
+		RootHash:        genesis,
+		FirstHeight:     0,
+		BestBlockHash:   genesis,
+		BestBlockHeight: 0,
+		NextSeq:         1,
+	}, true)
+	if err != nil {
+		dbtx.Rollback()
+		return ChainPos{}, err
+	}
+	err = dbtx.Commit()
+	if err != nil {
+		return ChainPos{}, err
+	}
+	return ChainPos{genesis, 0, "", 1}, nil
+}
+
+// importCommit applies a batch of changes, retrying on database errors.
+func (c *ChainFollower) importCommit(changes []UTXOChange, txIDs []string, headers []giga.VerifiedHeader, pos ChainPos) (ChainPos, error) {
+	var err error
+	for attempt := 0; attempt < IMPORT_COMMIT_ATTEMPTS; attempt++ {
+		var newPos ChainPos
+		newPos, err = c.attemptToApplyChanges(changes, txIDs, headers, nil, pos)
+		if err == nil {
+			return newPos, nil
+		}
+		c.sleepForRetry(err, 0)
+	}
+	return pos, err
+}
+
+func (c *ChainFollower) importedState(pos ChainPos) giga.ChainState {
+	return giga.ChainState{
+		RootHash:        c.chain.GenesisBlock,
+		BestBlockHash:   pos.BlockHash,
+		BestBlockHeight: pos.BlockHeight,
+		NextSeq:         pos.NextSeq,
+	}
+}
+
+// A block found in the block files.
+type blockFileEntry struct {
+	hash   [32]byte // internal byte order
+	prev   int32    // index of the previous block (-1 if not found)
+	file   int32    // index into files
+	offset int64    // offset of the block data (after magic and length)
+	size   uint32   // length of the block data
+	bits   uint32   // compact target (for chain work)
+}
+
+type blockIndex struct {
+	blocks []blockFileEntry
+	byHash map[[32]byte]int32
+}
+
+// indexBlockFiles reads the header of every block in the files.
+// Each block is framed by the network magic and a 32-bit length; the
+// remainder of a file can be zero-filled (pre-allocated by Core)
+func indexBlockFiles(files []string, magic [4]byte) (*blockIndex, error) {
+	index := &blockIndex{byHash: make(map[[32]byte]int32)}
+	prevs := make([][32]byte, 0)
+	for fileNo, name := range files {
+		f, err := os.Open(name)
+		if err != nil {
+			return nil, err
+		}
+		var offset int64
+		var frame [8 + 80]byte
+		for {
+			_, err = f.ReadAt(frame[:8], offset)
+			if err == io.EOF || (err == nil && binary.LittleEndian.Uint32(frame[0:4]) == 0) {
+				break // end of file, or zero-filled space.
+			}
+			if err != nil {
+				f.Close()
+				return nil, err
+			}
+			if !bytes.Equal(frame[0:4], magic[:]) {
+				f.Close()
+				return nil, fmt.Errorf("%v: wrong network magic at offset %v: %x", name, offset, frame[0:4])
+			}
+			size := binary.LittleEndian.Uint32(frame[4:8])
+			if size < 80 || size > doge.MaxVarIntSize {
+				f.Close()
+				return nil, fmt.Errorf("%v: invalid block size at offset %v: %v", name, offset, size)
+			}
+			_, err = f.ReadAt(frame[8:], offset+8)
+			if err != nil {
+				f.Close()
+				return nil, fmt.Errorf("%v: truncated block at offset %v: %v", name, offset, err)
+			}
+			header := frame[8:]
+			var hash, prev [32]byte
+			copy(hash[:], doge.DoubleSha256(header))
+			copy(prev[:], header[4:36])
+			if _, dup := index.byHash[hash]; !dup {
+				index.byHash[hash] = int32(len(index.blocks))
+				index.blocks = append(index.blocks, blockFileEntry{
+					hash:   hash,
+					file:   int32(fileNo),
+					offset: offset + 8,
+					size:   size,
+					bits:   binary.LittleEndian.Uint32(header[72:76]),
+				})
+				prevs = append(prevs, prev)
+			}
+			offset += 8 + int64(size)
+		}
+		f.Close()
+	}
+	for i := range index.blocks {
+		prev, found := index.byHash[prevs[i]]
+		if !found {
+			prev = -1
+		}
+		index.blocks[i].prev = prev
+	}
+	return index, nil
+}
+
+// bestChain returns the blocks (indexes) on the chain with the most work
+// from the genesis block, in height order (starting with genesis)
+func (index *blockIndex) bestChain(genesisHash string) ([]int32, error) {
+	genesis := int32(-1)
+	for i, b := range index.blocks {
+		if doge.HexEncodeReversed(b.hash[:]) == genesisHash {
+			genesis = int32(i)
+			break
+		}
+	}
+	if genesis < 0 {
+		return nil, fmt.Errorf("genesis block %v not found in the block files", genesisHash)
+	}
+	// Cumulative work for every block connected to genesis (-1: not connected)
+	work := make([]float64, len(index.blocks))
+	done := make([]bool, len(index.blocks))
+	work[genesis], done[genesis] = 0, true
+	var stack []int32
+	for i := range index.blocks {
+		// Walk back to a block we've already seen, then unwind.
+		for b := int32(i); !done[b]; b = index.blocks[b].prev {
+			stack = append(stack, b)
+			if index.blocks[b].prev < 0 {
+				break // orphan: does not connect to genesis.
+			}
+		}
+		for n := len(stack) - 1; n >= 0; n-- {
+			b := stack[n]
+			prev := index.blocks[b].prev
+			if prev < 0 || work[prev] < 0 {
+				work[b] = -1
+			} else {
+				work[b] = work[prev] + doge.BlockWork(index.blocks[b].bits)
+			}
+			done[b] = true
+		}
+		stack = stack[:0]
+	}
+	tip := genesis
+	for i := range index.blocks {
+		if work[i] > work[tip] {
+			tip = int32(i)
+		}
+	}
+	var chain []int32
+	for b := tip; b != genesis; b = index.blocks[b].prev {
+		chain = append(chain, b)
+	}
+	chain = append(chain, genesis)
+	for i, j := 0, len(chain)-1; i < j; i, j = i+1, j-1 {
+		chain[i], chain[j] = chain[j], chain[i]
+	}
+	return chain, nil
+}
+
+// blockFileReader reads blocks, keeping the current file open.
+type blockFileReader struct {
+	files  []string
+	fileNo int32
+	f      *os.File
+}
+
+func (r *blockFileReader) read(b *blockFileEntry) ([]byte, error) {
+	if r.f == nil || r.fileNo != b.file {
+		r.close()
+		f, err := os.Open(r.files[b.file])
+		if err != nil {
+			return nil, err
+		}
+		r.f, r.fileNo = f, b.file
+	}
+	data := make([]byte, b.size)
+	_, err := r.f.ReadAt(data, b.offset)
+	if err != nil {
+		return nil, fmt.Errorf("%v: reading block at offset %v: %v", r.files[b.file], b.offset, err)
+	}
+	return data, nil
+}
+
+func (r *blockFileReader) close() {
+	if r.f != nil {
+		r.f.Close()
+		r.f = nil
+	}
+}
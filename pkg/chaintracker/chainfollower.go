@@ -1,8 +1,11 @@
 package chaintracker
 
 import (
+	"bytes"
 	"context"
+	"fmt"
 	"log"
+	"sync"
 	"time"
 
 	giga "github.com/dogecoinfoundation/gigawallet/pkg"
@@ -10,23 +13,39 @@ import (
 )
 
 const (
-	RETRY_DELAY        = 5 * time.Second        // for RPC and Database errors.
-	WRONG_CHAIN_DELAY  = 5 * time.Minute        // for "Wrong Chain" error (essentially stop)
-	WAIT_INITIAL_BLOCK = 30 * time.Second       // for Initial Block Download
-	CONFLICT_DELAY     = 250 * time.Millisecond // for Database conflicts (concurrent transactions)
-	BLOCKS_PER_COMMIT  = 10                     // number of blocks per database commit.
+	RETRY_DELAY         = 5 * time.Second        // for RPC and Database errors.
+	WRONG_CHAIN_DELAY   = 5 * time.Minute        // for "Wrong Chain" error (essentially stop)
+	WAIT_INITIAL_BLOCK  = 30 * time.Second       // for Initial Block Download
+	CONFLICT_DELAY      = 250 * time.Millisecond // for Database conflicts (concurrent transactions)
+	VERIFY_FAILED_DELAY = 1 * time.Minute        // for blocks that fail verification or decoding (not skipped)
+	BLOCKS_PER_COMMIT   = 10                     // number of blocks per database commit.
+	SYNC_PROGRESS_DELAY = 30 * time.Second       // between NET_SYNC_PROGRESS events.
 )
 
 type ChainFollower struct {
 	l1               giga.L1
 	store            giga.Store
+	bus              giga.MessageBus
 	chain            *doge.ChainParams
+	network          *doge.ChainParams            // configured network (nil: any)
 	tx               giga.StoreTransaction        // non-nil during a transaction (for cleanup)
 	ReceiveBestBlock chan string                  // receive from TipChaser.
 	Commands         chan any                     // receive ReSyncChainFollowerCmd etc.
 	confirmations    int                          // required number of block confirmations.
+	rejections       int                          // blocks after a fork before a double-spend is flagged.
+	prefetchWorkers  int                          // concurrent block downloads (0: one block at a time)
+	prefetchMemory   int64                        // memory limit for prefetched blocks (bytes)
+	prefetch         *blockPrefetcher             // non-nil while prefetching blocks.
+	cache            *blockCache                  // raw blocks from ZMQ rawblock (see TipChaser)
+	verifyBlocks     bool                         // verify PoW and merkle roots (see verify.go)
+	haltOnSkip       bool                         // do not advance past a block that cannot be decoded.
+	retrySkipped     bool                         // pending RetrySkippedBlocks command.
+	syncing          bool                         // sent NET_SYNC_PROGRESS (send NET_SYNC_COMPLETE at the tip)
+	paused           bool                         // not following the chain (see PauseChainFollowerCmd)
 	stopping         bool                         // set to exit the main loop.
 	SetSync          *giga.ReSyncChainFollowerCmd // pending ReSync command.
+	statusLock       sync.Mutex                   // protects status.
+	status           giga.FollowerStatus          // current activity (see Status)
 }
 
 type ChainPos struct {
@@ -47,14 +66,23 @@ type ChainPos struct {
  * tip has changed since last time we checked (i.e. dirty flag); we don't
  * care about the actual block hash.
  */
-func newChainFollower(conf giga.Config, l1 giga.L1, store giga.Store) (*ChainFollower, error) {
+func newChainFollower(conf giga.Config, l1 giga.L1, store giga.Store, bus giga.MessageBus, cache *blockCache) (*ChainFollower, error) {
+	network, _ := doge.ChainFromName(conf.Gigawallet.Network)
 	result := &ChainFollower{
 		l1:               l1,
 		store:            store,
+		bus:              bus,
 		chain:            &doge.DogeRegTestChain,              // detected in fetchStartingPos()
 		ReceiveBestBlock: make(chan string, 1),                // signal that tip has changed.
 		Commands:         make(chan any, 10),                  // commands to the service.
 		confirmations:    conf.Gigawallet.ConfirmationsNeeded, // to confirm a txn (new UTXOs)
+		rejections:       conf.Gigawallet.RejectionsNeeded,    // to flag a double-spend after a fork
+		prefetchWorkers:  conf.ChainFollower.PrefetchWorkers,
+		prefetchMemory:   int64(conf.ChainFollower.PrefetchMemoryMB) << 20,
+		verifyBlocks:     conf.ChainFollower.VerifyBlocks,
+		network:          network,
+		haltOnSkip:       conf.ChainFollower.HaltOnSkippedBlock,
+		cache:            cache,
 	}
 	return result, nil
 }
@@ -63,6 +91,27 @@ func (c *ChainFollower) SendCommand(cmd any) {
 	c.Commands <- cmd
 }
 
+// Status returns what the ChainFollower is doing now (safe to call from any goroutine)
+func (c *ChainFollower) Status() giga.FollowerStatus {
+	c.statusLock.Lock()
+	defer c.statusLock.Unlock()
+	return c.status
+}
+
+func (c *ChainFollower) updateStatus(update func(status *giga.FollowerStatus)) {
+	c.statusLock.Lock()
+	defer c.statusLock.Unlock()
+	update(&c.status)
+}
+
+func (c *ChainFollower) setPaused(paused bool) {
+	if paused != c.paused {
+		log.Println("ChainFollower: paused:", paused)
+	}
+	c.paused = paused
+	c.updateStatus(func(status *giga.FollowerStatus) { status.Paused = paused })
+}
+
 func (c *ChainFollower) Run(started, stopped chan bool, stop chan context.Context) error {
 	go func() {
 		// Forward `stop` to the `Commands` channel.
@@ -92,6 +141,7 @@ func (c *ChainFollower) serviceMain() {
 			c.tx.Rollback()
 			c.tx = nil
 		}
+		c.stopPrefetch()
 	}()
 
 	// Fetch the last processed Best Block hash from the DB (restart point)
@@ -107,9 +157,15 @@ func (c *ChainFollower) serviceMain() {
 		c.SetSync = nil
 		pos = c.setSyncHeight(*cmd, pos)
 	}
+	if c.retrySkipped {
+		c.retrySkipped = false
+		pos = c.retrySkippedBlocks(pos)
+	}
 
 	// Walk forwards on the blockchain until we reach the tip.
-	pos = c.followChainToTip(pos)
+	if !c.paused {
+		pos = c.followChainToTip(pos)
+	}
 
 	// Main loop: catch up to the current Best Block (tip) each time it changes.
 	for {
@@ -127,6 +183,14 @@ func (c *ChainFollower) serviceMain() {
 			case giga.ReSyncChainFollowerCmd:
 				pos = c.setSyncHeight(cmt, pos)
 				// fall through to followChainToTip.
+			case giga.RetrySkippedBlocksCmd:
+				pos = c.retrySkippedBlocks(pos)
+				// fall through to followChainToTip.
+			case giga.PauseChainFollowerCmd:
+				c.setPaused(true)
+			case giga.ResumeChainFollowerCmd:
+				c.setPaused(false)
+				// fall through to followChainToTip.
 			default:
 				log.Println("ChainFollower: unknown command received!")
 				continue
@@ -134,6 +198,9 @@ func (c *ChainFollower) serviceMain() {
 		case <-c.ReceiveBestBlock:
 			log.Println("ChainFollower: received new block signal")
 		}
+		if c.paused {
+			continue // catch up when resumed.
+		}
 
 		// Walk forwards on the blockchain until we reach the tip.
 		pos = c.followChainToTip(pos)
@@ -154,6 +221,13 @@ func (c *ChainFollower) fetchStartingPos() ChainPos {
 			c.sleepForRetry(nil, WRONG_CHAIN_DELAY)
 			continue
 		}
+		if c.network != nil && chain != c.network {
+			log.Println("ChainFollower: WRONG NETWORK!")
+			log.Println("ChainFollower: Core Node is on:", chain.ChainName)
+			log.Println("ChainFollower: GigaWallet is configured for:", c.network.ChainName)
+			c.sleepForRetry(nil, WRONG_CHAIN_DELAY)
+			continue
+		}
 		c.chain = chain
 		// Wait for Core to be fully synced, otherwise fetchBlockCount will give
 		// us an early block and we'll follow the whole chain.
@@ -234,11 +308,18 @@ func (c *ChainFollower) setSyncHeight(cmd giga.ReSyncChainFollowerCmd, pos Chain
 	}
 	// This is correct in both cases: if the new block is after current,
 	// nothing will match the rollback queries, it will just update ChainState.
-	pos = c.rollBackChainState(cmd.BlockHash, pos)
+	pos = c.rollBackChainState(cmd.BlockHash, pos, false)
 	return pos
 }
 
 func (c *ChainFollower) followChainToTip(pos ChainPos) ChainPos {
+	// New blocks received from Core's ZMQ (rawblock) that follow on from our
+	// last-processed block are applied without fetching them over RPC.
+	if newPos, applied := c.applyCachedBlocks(pos); applied {
+		c.updateStatus(func(status *giga.FollowerStatus) { status.LastError = "" })
+		c.bus.Send(giga.NET_NEW_BLOCK, giga.NetBlockEvent{Hash: newPos.BlockHash, Height: newPos.BlockHeight})
+		return newPos
+	}
 	// Make forward progress following the chain, rolling back if we encounter a fork.
 	// Check if the last-processed block is still on-chain,
 	// and fetch the 'nextblockhash' (if any) from Core's chainstate.
@@ -255,20 +336,59 @@ func (c *ChainFollower) followChainToTip(pos ChainPos) ChainPos {
 	} else {
 		// The last block we processed is no longer on-chain, so roll back
 		// that block and prior blocks until we find a block that is on-chain.
-		pos = c.rollBackChainState(lastBlock.PreviousBlockHash, pos)
+		pos = c.rollBackChainState(lastBlock.PreviousBlockHash, pos, true)
 	}
 	// Walk forwards on the blockchain until we reach the tip.
 	// If this encounters a fork along the way, it will interally call rollBackChainState
 	// and then resume from the block it returns (as necessary, until it reaches the tip).
+	startHash := pos.BlockHash
+	var lastProgress time.Time
 	for pos.NextBlockHash != "" {
 		pos = c.transactionalRollForward(pos)
+		if pos.NextBlockHash != "" && time.Since(lastProgress) >= SYNC_PROGRESS_DELAY {
+			// Still behind the tip: report progress.
+			c.sendSyncEvent(giga.NET_SYNC_PROGRESS, pos, c.fetchBlockCount())
+			c.syncing = true
+			c.updateStatus(func(status *giga.FollowerStatus) { status.Syncing = true })
+			lastProgress = time.Now()
+		}
 		c.checkShutdown() // loops must check for shutdown.
 	}
 	// We have reached the tip of the blockchain.
 	log.Println("ChainFollower: reached the tip of the blockchain:", pos.BlockHash)
+	c.updateStatus(func(status *giga.FollowerStatus) {
+		status.Syncing = false
+		status.LastError = ""
+	})
+	if c.syncing {
+		c.sendSyncEvent(giga.NET_SYNC_COMPLETE, pos, pos.BlockHeight)
+		c.syncing = false
+	}
+	if pos.BlockHash != startHash {
+		c.bus.Send(giga.NET_NEW_BLOCK, giga.NetBlockEvent{Hash: pos.BlockHash, Height: pos.BlockHeight})
+	}
 	return pos
 }
 
+func (c *ChainFollower) sendSyncEvent(event giga.EVENT_NET, pos ChainPos, tipHeight int64) {
+	behind := tipHeight - pos.BlockHeight
+	if behind < 0 {
+		behind = 0
+	}
+	percent := 100.0
+	if tipHeight > 0 && behind > 0 {
+		percent = float64(pos.BlockHeight) * 100 / float64(tipHeight)
+	}
+	log.Println("ChainFollower:", event, pos.BlockHeight, "of", tipHeight)
+	c.bus.Send(event, giga.NetSyncEvent{
+		Hash:      pos.BlockHash,
+		Height:    pos.BlockHeight,
+		TipHeight: tipHeight,
+		Behind:    behind,
+		Percent:   percent,
+	})
+}
+
 func (c *ChainFollower) transactionalRollForward(pos ChainPos) ChainPos {
 	// 1. Follow the chain forwards up to BATCH_SIZE blocks.
 	// If we encounter a fork, stop and take note of the fork-point as well.
@@ -279,14 +399,56 @@ func (c *ChainFollower) transactionalRollForward(pos ChainPos) ChainPos {
 	var blockCount int = 0
 	var txIDs []string
 	var changes []UTXOChange
+	var headers []giga.VerifiedHeader
+	var skipped []giga.SkippedBlock
+	var haltErr error
+	defer c.updateStatus(func(status *giga.FollowerStatus) {
+		status.BatchStart, status.BatchEnd = 0, 0 // batch done.
+	})
 	for pos.NextBlockHash != "" {
 		//log.Println("ChainFollower: fetching block:", pos.NextBlockHash)
-		block := c.fetchBlockHeader(pos.NextBlockHash)
+		block, decoded, decodeErr := c.fetchNextBlock(pos)
 		if block.Confirmations != -1 {
+			if decodeErr != nil && c.haltOnSkip {
+				// Refuse to advance past a block that cannot be decoded.
+				haltErr = fmt.Errorf("block %v at height %v: cannot decode block: %v", block.Hash, block.Height, decodeErr)
+				break
+			}
+			if c.verifyBlocks {
+				// Refuse to advance past a block that fails verification.
+				if decodeErr != nil {
+					haltErr = fmt.Errorf("block %v at height %v: cannot decode block to verify it", block.Hash, block.Height)
+					break
+				}
+				prev, prevPrev := c.previousVerifiedHeaders(block.Height, headers)
+				hdr, err := c.verifyBlock(&decoded, block, prev, prevPrev)
+				if err != nil {
+					haltErr = err
+					break
+				}
+				headers = append(headers, hdr)
+			}
 			// Still on-chain, so update chainstate from block transactions.
-			changes, txIDs = c.processBlock(block.Hash, block.Height, changes, txIDs)
+			if decodeErr == nil {
+				changes, txIDs = c.processBlock(&decoded, block.Hash, block.Height, changes, txIDs)
+			} else {
+				// Record the block so it can be re-processed (see retrySkippedBlocks)
+				log.Printf("[!] ChainFollower: ERROR DECODING BLOCK - SKIPPED - SHOULD FIX AND RE-PROCESS: %v %v: %v", block.Hash, block.Height, decodeErr)
+				skipped = append(skipped, giga.SkippedBlock{
+					Height:  block.Height,
+					Hash:    block.Hash,
+					Error:   decodeErr.Error(),
+					Skipped: time.Now(),
+				})
+			}
 			// Progress has been made.
 			pos = ChainPos{block.Hash, block.Height, block.NextBlockHash, pos.NextSeq}
+			c.updateStatus(func(status *giga.FollowerStatus) {
+				if blockCount == 0 {
+					status.BatchStart = block.Height
+				}
+				status.BatchEnd = block.Height
+			})
 			blockCount++
 			if blockCount > BLOCKS_PER_COMMIT {
 				// Commit our progress every BATCH_SIZE blocks.
@@ -308,7 +470,7 @@ func (c *ChainFollower) transactionalRollForward(pos ChainPos) ChainPos {
 		// However, eventually we bail and retry the whole process (in case something else is wrong)
 		attempts := 10
 		for {
-			newPos, err := c.attemptToApplyChanges(changes, txIDs, pos)
+			newPos, err := c.attemptToApplyChanges(changes, txIDs, headers, skipped, pos)
 			if err == nil {
 				pos = newPos // update on success.
 				break        // success.
@@ -325,7 +487,15 @@ func (c *ChainFollower) transactionalRollForward(pos ChainPos) ChainPos {
 	}
 	// 3. If a fork-point was found above, roll back chainstate to that point.
 	if rollbackFrom != "" {
-		pos = c.rollBackChainState(rollbackFrom, pos)
+		pos = c.rollBackChainState(rollbackFrom, pos, true)
+	}
+	// 4. If a block failed verification (or decoding, with HaltOnSkippedBlock) report it
+	// and wait before trying again (Core may reorg away from it, or the block may be
+	// re-fetched intact)
+	if haltErr != nil {
+		log.Println("[!] ChainFollower: BLOCK FAILED - NOT ADVANCING:", haltErr)
+		c.bus.Send(giga.SYS_ERR, fmt.Sprintf("ChainFollower: not advancing: %v", haltErr))
+		c.sleepForRetry(haltErr, VERIFY_FAILED_DELAY)
 	}
 	return pos
 }
@@ -355,7 +525,7 @@ func (m *AccountMap) Add(id string) {
 	}
 }
 
-func (c *ChainFollower) attemptToApplyChanges(changes []UTXOChange, txIDs []string, pos ChainPos) (ChainPos, error) {
+func (c *ChainFollower) attemptToApplyChanges(changes []UTXOChange, txIDs []string, headers []giga.VerifiedHeader, skipped []giga.SkippedBlock, pos ChainPos) (ChainPos, error) {
 	accounts := NewAccountMap(pos.NextSeq)
 	dbtx := c.beginStoreTxn()
 	err := c.applyUTXOChanges(dbtx, changes, accounts)
@@ -412,6 +582,17 @@ func (c *ChainFollower) attemptToApplyChanges(changes []UTXOChange, txIDs []stri
 		return pos, err // retry.
 	}
 	accounts.AddIds(confirmAccounts)
+	// Flag double-spends: confirmed UTXOs and Payments that were rolled back by a reorg,
+	// and have not been re-mined within `rejections` blocks of the fork-point.
+	var doubleSpends []giga.DoubleSpendEvent
+	if c.rejections > 0 {
+		doubleSpends, err = c.markDoubleSpends(dbtx, pos.BlockHeight, accounts)
+		if err != nil {
+			// Unable to complete block processing - roll back.
+			dbtx.Rollback()
+			return pos, err // retry.
+		}
+	}
 	// Write the new sequence numbers on all affected accounts.
 	// This is used by (multiple) Services to keep track of new account changes.
 	err = dbtx.IncChainSeqForAccounts(accounts.Accounts)
@@ -421,6 +602,24 @@ func (c *ChainFollower) attemptToApplyChanges(changes []UTXOChange, txIDs []stri
 		dbtx.Rollback()
 		return pos, err // retry.
 	}
+	// Record the verified block headers (empty unless VerifyBlocks)
+	if len(headers) > 0 {
+		err = dbtx.StoreVerifiedHeaders(headers)
+		if err != nil {
+			log.Println("ChainFollower: StoreVerifiedHeaders:", err)
+			dbtx.Rollback()
+			return pos, err // retry.
+		}
+	}
+	// Record blocks that could not be decoded (see retrySkippedBlocks)
+	for _, b := range skipped {
+		err = dbtx.StoreSkippedBlock(b)
+		if err != nil {
+			log.Println("ChainFollower: StoreSkippedBlock:", err)
+			dbtx.Rollback()
+			return pos, err // retry.
+		}
+	}
 	// Report affected accounts in the log (useful for now)
 	for acct, seq := range accounts.Accounts {
 		log.Printf("ChainFollower: account was affected: %s (%v)", acct, seq)
@@ -445,10 +644,50 @@ func (c *ChainFollower) attemptToApplyChanges(changes []UTXOChange, txIDs []stri
 		return pos, err // retry.
 	}
 	pos.NextSeq = accounts.NextSeq // after commit.
+	for _, b := range skipped {
+		c.bus.Send(giga.SYS_ERR, fmt.Sprintf("ChainFollower: skipped block %v at height %v (balances are unreliable until it is re-processed): %v", b.Hash, b.Height, b.Error))
+	}
+	for _, ds := range doubleSpends {
+		if ds.PaymentID != 0 {
+			c.bus.Send(giga.PAYMENT_DOUBLE_SPEND, ds)
+		} else {
+			c.bus.Send(giga.INV_DOUBLE_SPEND, ds)
+		}
+	}
 	return pos, nil
 }
 
-func (c *ChainFollower) rollBackChainState(fromHash string, oldPos ChainPos) ChainPos {
+func (c *ChainFollower) markDoubleSpends(dbtx giga.StoreTransaction, blockHeight int64, accounts *AccountMap) ([]giga.DoubleSpendEvent, error) {
+	found, err := dbtx.MarkDoubleSpends(c.rejections, blockHeight)
+	if err != nil {
+		log.Println("ChainFollower: MarkDoubleSpends:", err)
+		return nil, err
+	}
+	var events []giga.DoubleSpendEvent
+	for _, ds := range found {
+		acc, err := dbtx.GetAccountByID(ds.AccountID)
+		if err != nil {
+			log.Println("ChainFollower: MarkDoubleSpends: GetAccountByID:", err, ds.AccountID)
+			return nil, err
+		}
+		log.Println("ChainFollower: DOUBLE SPEND: invoice", ds.InvoiceID, "payment", ds.PaymentID, "txids", ds.TxIDs)
+		accounts.Add(string(ds.AccountID))
+		events = append(events, giga.DoubleSpendEvent{
+			InvoiceID:  ds.InvoiceID,
+			PaymentID:  ds.PaymentID,
+			AccountID:  ds.AccountID,
+			ForeignID:  acc.ForeignID,
+			TxIDs:      ds.TxIDs,
+			ForkHeight: ds.ForkHeight,
+			Depth:      blockHeight - ds.ForkHeight,
+		})
+	}
+	return events, nil
+}
+
+// Roll back to the last on-chain block at or before `fromHash`.
+// If `reorg` is set (i.e. not a ReSync command) this sends a NET_REORG event.
+func (c *ChainFollower) rollBackChainState(fromHash string, oldPos ChainPos, reorg bool) ChainPos {
 	log.Println("ChainFollower: rolling back from:", fromHash)
 	// Walk backwards along the chain (in Core) to find an on-chain block.
 	for {
@@ -462,26 +701,48 @@ func (c *ChainFollower) rollBackChainState(fromHash string, oldPos ChainPos) Cha
 		} else {
 			// Found an on-chain block: roll back all chainstate above this block-height.
 			pos := ChainPos{block.Hash, block.Height, block.NextBlockHash, oldPos.NextSeq}
-			pos.NextSeq = c.rollBackChainStateToPos(pos)
+			pos.NextSeq = c.rollBackChainStateToPos(pos, oldPos, reorg)
 			// Caller needs this block hash and next block hash (if any)
 			return pos
 		}
 	}
 }
 
-func (c *ChainFollower) rollBackChainStateToPos(pos ChainPos) int64 {
+func (c *ChainFollower) rollBackChainStateToPos(pos ChainPos, oldPos ChainPos, reorg bool) int64 {
 	log.Println("ChainFollower: rolling back chainstate to height:", pos.BlockHeight)
+	c.stopPrefetch() // prefetched blocks are on the old chain.
+	// Only a reorg can double-spend the rolled back transactions: other
+	// rollbacks replay the same blocks (see MarkDoubleSpends)
+	var reorgFrom int64
+	if reorg {
+		reorgFrom = oldPos.BlockHeight
+	}
+	// If our old tip is still on-chain, the replayed blocks will mark the same
+	// Payments on-chain again: keep them, so BalanceKeeper doesn't report them
+	// unconfirmed in the meantime (e.g. RetrySkippedBlocks)
+	keepPayments := !reorg && c.fetchBlockHeader(oldPos.BlockHash).Confirmations != -1
 	// wrap the following in a transaction with retry.
 	for {
 		dbtx := c.beginStoreTxn()
 		// Roll back chainstate above the specified block height.
-		newSeq, err := dbtx.RevertChangesAboveHeight(pos.BlockHeight, pos.NextSeq)
+		newSeq, err := dbtx.RevertChangesAboveHeight(pos.BlockHeight, pos.NextSeq, reorgFrom, keepPayments)
 		if err != nil {
 			dbtx.Rollback()
 			log.Println("ChainFollower: RevertUTXOsAboveHeight:", err)
 			c.sleepForRetry(err, 0)
 			continue // retry.
 		}
+		// Find the confirmed transactions that were rolled back (for NET_REORG)
+		var reorgTxIDs []string
+		if reorg {
+			reorgTxIDs, err = dbtx.ListReorgedTxIDs(pos.BlockHeight)
+			if err != nil {
+				dbtx.Rollback()
+				log.Println("ChainFollower: ListReorgedTxIDs:", err)
+				c.sleepForRetry(err, 0)
+				continue // retry.
+			}
+		}
 		// Update Best Block in the database (checkpoint for restart)
 		err = dbtx.UpdateChainState(giga.ChainState{
 			BestBlockHash:   pos.BlockHash,
@@ -501,6 +762,18 @@ func (c *ChainFollower) rollBackChainStateToPos(pos ChainPos) int64 {
 			c.sleepForRetry(err, 0)
 			continue // retry.
 		}
+		if reorg && oldPos.BlockHeight > pos.BlockHeight {
+			depth := oldPos.BlockHeight - pos.BlockHeight
+			log.Println("ChainFollower: REORG: rolled back", depth, "blocks,", len(reorgTxIDs), "confirmed transactions")
+			c.bus.Send(giga.NET_REORG, giga.NetReorgEvent{
+				FromHash:   oldPos.BlockHash,
+				FromHeight: oldPos.BlockHeight,
+				ForkHash:   pos.BlockHash,
+				ForkHeight: pos.BlockHeight,
+				Depth:      depth,
+				TxIDs:      reorgTxIDs,
+			})
+		}
 		return newSeq // success.
 	}
 }
@@ -575,15 +848,157 @@ func (c *ChainFollower) applyUTXOChanges(dbtx giga.StoreTransaction, changes []U
 	return nil
 }
 
-func (c *ChainFollower) processBlock(blockHash string, blockHeight int64, changes []UTXOChange, txIDs []string) ([]UTXOChange, []string) {
-	blockData := c.fetchBlockData(blockHash)
-	block, err := c.decodeBlock(blockData, blockHash, blockHeight)
+// Fetch the header for pos.NextBlockHash and, if it is still on-chain, the decoded block.
+// Uses the blockPrefetcher if enabled. Returns an error if the block cannot be decoded.
+func (c *ChainFollower) fetchNextBlock(pos ChainPos) (header giga.RpcBlockHeader, block doge.Block, err error) {
+	if c.prefetchWorkers < 1 {
+		header = c.fetchBlockHeader(pos.NextBlockHash)
+		if header.Confirmations == -1 {
+			return header, block, nil
+		}
+		blockData := c.fetchBlockData(header.Hash)
+		block, err = c.decodeBlock(blockData, header.Hash, header.Height)
+		return header, block, err
+	}
+	pb := c.nextPrefetched(pos)
+	if pb.header.Confirmations == -1 {
+		return pb.header, block, nil
+	}
+	block, err = pb.block, pb.err
+	if err != nil {
+		// try the fallback method (fetches the raw header)
+		block, err = c.decodeBlock(pb.data, pb.header.Hash, pb.header.Height)
+	}
+	return pb.header, block, err
+}
+
+// applyCachedBlocks applies blocks in the blockCache (from ZMQ rawblock) that lead
+// from the last block processed to the tip the TipChaser received, one block per
+// commit, without any RPC requests. Returns false if there are none, or one cannot
+// be applied here (e.g. cannot be decoded) to fetch blocks from Core instead.
+// Like other ZMQ data these blocks are not authenticated; they are verified against
+// their header (and proof-of-work with VerifyBlocks) before use. A cached block from
+// a stale fork is not applied unless Core reported it as the tip, in which case it
+// was the best chain at the time (and is rolled back like any other reorg)
+func (c *ChainFollower) applyCachedBlocks(pos ChainPos) (ChainPos, bool) {
+	applied := false
+	for _, hash := range c.cache.pathToTip(pos.BlockHash) {
+		height := pos.BlockHeight + 1
+		data, found := c.cache.get(hash)
+		if !found {
+			return pos, applied // evicted meanwhile.
+		}
+		block, err := doge.DecodeBlock(data, hash, true)
+		if err != nil {
+			log.Println("ChainFollower: cannot decode block from ZMQ (will fetch it):", hash, err)
+			return pos, applied
+		}
+		root, mutated, err := doge.BlockMerkleRoot(&block)
+		if err != nil || mutated || !bytes.Equal(root, block.Header.MerkleRoot) {
+			log.Println("ChainFollower: block from ZMQ does not match its merkle root (will fetch it):", hash)
+			return pos, applied
+		}
+		var headers []giga.VerifiedHeader
+		if c.verifyBlocks {
+			prev, prevPrev := c.previousVerifiedHeaders(height, nil)
+			hdr, err := c.verifyBlock(&block, giga.RpcBlockHeader{Hash: hash, Height: height}, prev, prevPrev)
+			if err != nil {
+				log.Println("ChainFollower: block from ZMQ failed verification (will fetch it):", err)
+				return pos, applied
+			}
+			headers = append(headers, hdr)
+		}
+		changes, txIDs := c.processBlock(&block, hash, height, nil, nil)
+		newPos, err := c.attemptToApplyChanges(changes, txIDs, headers, nil, ChainPos{hash, height, "", pos.NextSeq})
+		if err != nil {
+			log.Println("ChainFollower: cannot apply block from ZMQ (will fetch it):", err)
+			return pos, applied
+		}
+		log.Println("ChainFollower: applied block from ZMQ:", hash, height)
+		pos = newPos
+		applied = true
+		c.checkShutdown() // loops must check for shutdown.
+	}
+	return pos, applied
+}
+
+// retrySkippedBlocks rolls back to the block before the lowest skipped block that
+// can now be decoded (e.g. after an upgrade) so it is re-processed followed by all
+// later blocks, applying their changes in block order.
+func (c *ChainFollower) retrySkippedBlocks(pos ChainPos) ChainPos {
+	skipped, err := c.store.ListSkippedBlocks()
 	if err != nil {
-		log.Printf("[!] ChainFollower: ERROR DECODING BLOCK - SKIPPED - SHOULD FIX AND RE-PROCESS: %v %v: %v", blockHash, blockHeight, err)
-		return changes, txIDs // Skip this block but continue processing
+		log.Println("ChainFollower: RetrySkippedBlocks: ListSkippedBlocks:", err)
+		c.bus.Send(giga.SYS_ERR, fmt.Sprintf("ChainFollower: cannot retry skipped blocks: %v", err))
+		return pos
 	}
-	// c.verifyDecodedBlock(&block, blockHash)
+	for _, b := range skipped {
+		hdr := c.fetchBlockHeader(b.Hash)
+		if hdr.Confirmations == -1 {
+			continue // no longer on-chain (removed when we roll back past it)
+		}
+		_, err := c.decodeBlock(c.fetchBlockData(b.Hash), b.Hash, b.Height)
+		if err != nil {
+			log.Println("ChainFollower: RetrySkippedBlocks: still cannot decode block:", b.Hash, b.Height, err)
+			continue
+		}
+		log.Println("ChainFollower: RetrySkippedBlocks: re-processing from block", b.Hash, b.Height)
+		return c.rollBackChainState(hdr.PreviousBlockHash, pos, false)
+	}
+	log.Println("ChainFollower: RetrySkippedBlocks: no skipped blocks can be decoded")
+	return pos
+}
+
+// Take the next block from the blockPrefetcher, (re)starting it at pos.NextBlockHash
+// if necessary. Waits for the block to be downloaded (checking for shutdown.)
+func (c *ChainFollower) nextPrefetched(pos ChainPos) *prefetchedBlock {
+	for {
+		if c.prefetch != nil && c.prefetch.expect != pos.NextBlockHash {
+			c.stopPrefetch() // not following on from the last block we took.
+		}
+		if c.prefetch == nil {
+			c.prefetch = newBlockPrefetcher(c.l1, pos.NextBlockHash, c.prefetchWorkers, c.prefetchMemory)
+		}
+		p := c.prefetch
+		var pb *prefetchedBlock
+		open := true
+		for pb == nil && open {
+			select {
+			case pb, open = <-p.queue:
+			case <-time.After(PREFETCH_POLL):
+				c.checkShutdown()
+			}
+		}
+		if !open {
+			// reached the tip before this block was mined: restart from here.
+			c.stopPrefetch()
+			continue
+		}
+		for waiting := true; waiting; {
+			select {
+			case <-pb.done:
+				waiting = false
+			case <-time.After(PREFETCH_POLL):
+				c.checkShutdown()
+			}
+		}
+		p.expect = pb.header.NextBlockHash
+		p.release(pb.size)
+		return pb
+	}
+}
+
+func (c *ChainFollower) stopPrefetch() {
+	if c.prefetch != nil {
+		c.prefetch.stop()
+		c.prefetch = nil
+	}
+}
+
+func (c *ChainFollower) processBlock(block *doge.Block, blockHash string, blockHeight int64, changes []UTXOChange, txIDs []string) ([]UTXOChange, []string) {
+	// c.verifyDecodedBlock(block, blockHash)
 	log.Println("ChainFollower: processing block", blockHash, len(block.Tx), blockHeight)
+	accountChain := giga.AccountChain(c.chain)
 	// Insert entirely-new UTXOs that don't exist in the database.
 	for _, tx := range block.Tx {
 		txIDs = append(txIDs, tx.TxID)
@@ -608,7 +1023,8 @@ func (c *ChainFollower) processBlock(blockHash string, blockHeight int64, change
 				continue
 			}
 			// Gigawallet only handles P2PKH (HD Wallet) Addresses.
-			scriptType, address := doge.ClassifyScript(vout.Script, &doge.DogeMainNetChain)
+			// Classify as account addresses (testnet addresses on regtest)
+			scriptType, address := doge.ClassifyScript(vout.Script, accountChain)
 			if scriptType == doge.ScriptTypeP2PKH {
 				// Create a UTXO associated with the wallet that owns the address.
 				changes = append(changes, UTXOChange{
@@ -684,6 +1100,9 @@ func (c *ChainFollower) fetchChainState() giga.ChainState {
 }
 
 func (c *ChainFollower) fetchBlockData(blockHash string) []byte {
+	if data, found := c.cache.get(blockHash); found {
+		return data // from ZMQ rawblock.
+	}
 	for {
 		hex, err := c.l1.GetBlockHex(blockHash)
 		if err != nil {
@@ -755,21 +1174,12 @@ func (c *ChainFollower) sleepForRetry(err error, delay time.Duration) {
 			delay = CONFLICT_DELAY
 		}
 	}
+	if err != nil {
+		c.updateStatus(func(status *giga.FollowerStatus) { status.LastError = err.Error() })
+	}
 	select {
 	case cmd := <-c.Commands:
-		log.Println("ChainFollower: received command")
-		switch cm := cmd.(type) {
-		case giga.StopChainFollowerCmd:
-			c.stopping = true
-			panic("stopped") // caught in `Run` method.
-		case giga.RestartChainFollowerCmd:
-			panic("stopped") // caught in `Run` method.
-		case giga.ReSyncChainFollowerCmd:
-			c.SetSync = &cm
-			panic("restart") // caught in `Run` method.
-		default:
-			log.Println("ChainFollower: unknown command received (ignored)")
-		}
+		c.interrupt(cmd)
 	case <-time.After(delay):
 		return
 	}
@@ -778,24 +1188,38 @@ func (c *ChainFollower) sleepForRetry(err error, delay time.Duration) {
 func (c *ChainFollower) checkShutdown() {
 	select {
 	case cmd := <-c.Commands:
-		log.Println("ChainFollower: received command")
-		switch cm := cmd.(type) {
-		case giga.StopChainFollowerCmd:
-			c.stopping = true
-			panic("stopped") // caught in `Run` method.
-		case giga.RestartChainFollowerCmd:
-			panic("stopped") // caught in `Run` method.
-		case giga.ReSyncChainFollowerCmd:
-			c.SetSync = &cm
-			panic("restart") // caught in `Run` method.
-		default:
-			log.Println("ChainFollower: unknown command received (ignored)")
-		}
+		c.interrupt(cmd)
 	default:
 		return
 	}
 }
 
+// Handle a command received while following the chain: restart the
+// service to execute it (see serviceMain)
+func (c *ChainFollower) interrupt(cmd any) {
+	log.Println("ChainFollower: received command")
+	switch cm := cmd.(type) {
+	case giga.StopChainFollowerCmd:
+		c.stopping = true
+		panic("stopped") // caught in `Run` method.
+	case giga.RestartChainFollowerCmd:
+		panic("stopped") // caught in `Run` method.
+	case giga.ReSyncChainFollowerCmd:
+		c.SetSync = &cm
+		panic("restart") // caught in `Run` method.
+	case giga.RetrySkippedBlocksCmd:
+		c.retrySkipped = true
+		panic("restart") // caught in `Run` method.
+	case giga.PauseChainFollowerCmd:
+		c.setPaused(true)
+		panic("restart") // caught in `Run` method.
+	case giga.ResumeChainFollowerCmd:
+		// already following the chain.
+	default:
+		log.Println("ChainFollower: unknown command received (ignored)")
+	}
+}
+
 // OLD code to fetch decoded transactions from Core RPC.
 // Now used to verify decoded blocks and scripts from doge.DecodeBlock and doge.ClassifyScript.
 
@@ -875,7 +1299,7 @@ func (c *ChainFollower) verifyDecodedBlock(b *doge.Block, blockHash string) {
 			if hex != vout.ScriptPubKey.Hex {
 				log.Fatalf("Wrong script hex: %v vs %v in tx %v vin %v", hex, vout.ScriptPubKey.Hex, txn_id, i)
 			}
-			sType, address := doge.ClassifyScript(bOut.Script, &doge.DogeMainNetChain)
+			sType, address := doge.ClassifyScript(bOut.Script, c.chain)
 			scriptType := giga.DecodeCoreRPCScriptType(vout.ScriptPubKey.Type)
 			if sType != scriptType {
 				log.Fatalf("Wrong script type: %v vs %v in tx %v vin %v", sType, scriptType, txn_id, i)
@@ -1,10 +1,21 @@
 package chaintracker
 
 import (
+	"bytes"
+	"context"
+	"encoding/binary"
 	"encoding/hex"
+	"os"
+	"path/filepath"
+	"sync"
 	"testing"
+	"time"
 
+	giga "github.com/dogecoinfoundation/gigawallet/pkg"
+	"github.com/dogecoinfoundation/gigawallet/pkg/doge"
 	"github.com/dogecoinfoundation/gigawallet/pkg/dogecoin"
+	"github.com/dogecoinfoundation/gigawallet/pkg/store"
+	"github.com/shopspring/decimal"
 )
 
 // https://en.bitcoin.it/wiki/Merged_mining_specification
@@ -47,6 +58,666 @@ func TestAuxPoWBadHeader(t *testing.T) {
 	}
 }
 
+// A chain of blocks for the blockPrefetcher (every block is the good AuxPoW block)
+type chainMock struct {
+	dogecoin.L1Mock
+	headers map[string]giga.RpcBlockHeader
+}
+
+func (m chainMock) GetBlockHeader(blockHash string) (giga.RpcBlockHeader, error) {
+	return m.headers[blockHash], nil
+}
+
+func (m chainMock) GetBlockHex(blockHash string) (string, error) {
+	return AuxPoW_Test_Good, nil
+}
+
+func TestBlockPrefetcher(t *testing.T) {
+	hashes := []string{"b1", "b2", "b3", "b4", "b5", "b6"}
+	headers := make(map[string]giga.RpcBlockHeader)
+	for i, hash := range hashes {
+		hdr := giga.RpcBlockHeader{Hash: hash, Height: int64(i + 1), Confirmations: 1}
+		if i+1 < len(hashes) {
+			hdr.NextBlockHash = hashes[i+1]
+		}
+		headers[hash] = hdr
+	}
+	// the last block is no longer on-chain (a fork)
+	fork := headers["b6"]
+	fork.Confirmations = -1
+	headers["b6"] = fork
+
+	// 1 byte memory limit: only one block is fetched ahead at a time.
+	p := newBlockPrefetcher(chainMock{headers: headers}, "b1", 3, 1)
+	defer p.stop()
+	for _, hash := range hashes {
+		pb, open := <-p.queue
+		if !open {
+			t.Fatalf("TestBlockPrefetcher: queue closed before block %v", hash)
+		}
+		<-pb.done
+		if pb.header.Hash != hash {
+			t.Fatalf("TestBlockPrefetcher: wrong block order: %v vs %v", pb.header.Hash, hash)
+		}
+		if hash == "b6" {
+			if pb.header.Confirmations != -1 || pb.size != 0 {
+				t.Errorf("TestBlockPrefetcher: expecting the fork header only: %v", pb.header)
+			}
+			continue
+		}
+		if pb.err != nil || len(pb.block.Tx) != 1 || pb.size == 0 {
+			t.Errorf("TestBlockPrefetcher: block %v not decoded: %v", hash, pb.err)
+		}
+		p.release(pb.size)
+	}
+	if _, open := <-p.queue; open {
+		t.Errorf("TestBlockPrefetcher: expecting the queue to close after the fork")
+	}
+}
+
+// mineRegTestBlock creates a regtest block with one coinbase tx (varied by `tag`)
+// finding a nonce that meets the (easy) regtest proof-of-work target.
+func mineRegTestBlock(prev []byte, tag byte) (doge.Block, giga.RpcBlockHeader, []byte) {
+	return mineRegTestBlockWith(prev, []byte{tag})
+}
+
+// mineRegTestBlockWith creates a regtest block with a coinbase tx (varied by `tag`)
+// followed by `txs` (fewer than 252), like mineRegTestBlock.
+func mineRegTestBlockWith(prev []byte, tag []byte, txs ...[]byte) (doge.Block, giga.RpcBlockHeader, []byte) {
+	coinbase := doge.EncodeTx(doge.BlockTx{
+		Version: 1,
+		VIn:     []doge.BlockTxIn{{TxID: make([]byte, 32), VOut: doge.CoinbaseVOut, Script: append(append([]byte{}, tag...), 0x51), Sequence: 0xffffffff}},
+		VOut:    []doge.BlockTxOut{{Value: 10000 * 100_000_000, Script: []byte{0x51}}},
+	})
+	txs = append([][]byte{coinbase}, txs...)
+	hashes := make([][]byte, 0, len(txs))
+	for _, tx := range txs {
+		hashes = append(hashes, doge.DoubleSha256(tx))
+	}
+	root, _ := doge.MerkleRoot(hashes)
+	hdr := doge.BlockHeader{Version: 0x00620004, PrevBlock: prev, MerkleRoot: root, Bits: 0x207fffff}
+	for doge.CheckProofOfWork(doge.ScryptHash(doge.EncodeBlockHeader(hdr)), hdr.Bits, &doge.DogeRegTestChain) != nil {
+		hdr.Nonce++
+	}
+	raw := doge.EncodeBlockHeader(hdr)
+	hash := doge.BlockHashHex(raw)
+	data := append(raw, byte(len(txs)))
+	for _, tx := range txs {
+		data = append(data, tx...)
+	}
+	block, err := doge.DecodeBlock(data, hash, true)
+	if err != nil {
+		panic(err)
+	}
+	return block, giga.RpcBlockHeader{Hash: hash, Height: 1, Confirmations: 1}, data
+}
+
+func TestVerifyBlock(t *testing.T) {
+	follower := ChainFollower{chain: &doge.DogeRegTestChain}
+	genesis := hx2b(doge.DogeRegTestChain.GenesisBlock)
+	for i, j := 0, len(genesis)-1; i < j; i, j = i+1, j-1 {
+		genesis[i], genesis[j] = genesis[j], genesis[i] // internal byte order.
+	}
+	block, header, _ := mineRegTestBlock(genesis, 1)
+	prev := &giga.VerifiedHeader{Height: 0, Hash: doge.DogeRegTestChain.GenesisBlock}
+	hdr, err := follower.verifyBlock(&block, header, prev, nil)
+	if err != nil {
+		t.Fatalf("TestVerifyBlock: %v", err)
+	}
+	if hdr.Height != 1 || hdr.Hash != header.Hash || len(hdr.Header) != 160 {
+		t.Errorf("TestVerifyBlock: wrong verified header: %+v", hdr)
+	}
+	// Anchor: no previous verified header.
+	if _, err = follower.verifyBlock(&block, header, nil, nil); err != nil {
+		t.Errorf("TestVerifyBlock: anchor block: %v", err)
+	}
+	// Does not follow the previous verified header.
+	if _, err = follower.verifyBlock(&block, header, &hdr, nil); err == nil {
+		t.Errorf("TestVerifyBlock: expecting error for unlinked block")
+	}
+	// Difficulty must match the previous block (regtest does not retarget)
+	genesisHdr := RegTest_Genesis[:160]
+	prevPrev := &giga.VerifiedHeader{Height: -1, Hash: "00", Header: genesisHdr}
+	withHdr := &giga.VerifiedHeader{Height: 0, Hash: prev.Hash, Header: genesisHdr}
+	if _, err = follower.verifyBlock(&block, header, withHdr, prevPrev); err != nil {
+		t.Errorf("TestVerifyBlock: difficulty: %v", err)
+	}
+	harder := doge.DecodeBlockHeader(hx2b(genesisHdr))
+	harder.Bits = 0x1e0ffff0
+	withHdr.Header = doge.HexEncode(doge.EncodeBlockHeader(harder))
+	if _, err = follower.verifyBlock(&block, header, withHdr, prevPrev); err == nil {
+		t.Errorf("TestVerifyBlock: expecting error for wrong difficulty")
+	}
+	// Core returned a different hash for the block.
+	if _, err = follower.verifyBlock(&block, giga.RpcBlockHeader{Hash: "00", Height: 1}, prev, nil); err == nil {
+		t.Errorf("TestVerifyBlock: expecting error for wrong block hash")
+	}
+	// Transactions do not match the merkle root.
+	block.Tx = append(block.Tx, block.Tx[0])
+	if _, err = follower.verifyBlock(&block, header, prev, nil); err == nil {
+		t.Errorf("TestVerifyBlock: expecting error for mutated transactions")
+	}
+	// Not enough proof-of-work for mainnet.
+	follower.chain = &doge.DogeMainNetChain
+	block.Tx = block.Tx[:1]
+	if _, err = follower.verifyBlock(&block, header, prev, nil); err == nil {
+		t.Errorf("TestVerifyBlock: expecting proof-of-work error")
+	}
+}
+
+// The regtest genesis block (same coinbase as mainnet)
+const RegTest_Genesis = "010000000000000000000000000000000000000000000000000000000000000000000000696ad20e2dd4365c7459b4a4a5af743d5e92c6da3229e6532cd605f6533f2a5bdae5494dffff7f20020000000101000000010000000000000000000000000000000000000000000000000000000000000000ffffffff1004ffff001d0104084e696e746f6e646fffffffff010058850c020000004341040184710fa689ad5023690c80f3a49c8f13f8d45b8c857fbcbc8bc4a8e4d3eb4b10f4d4604fa08dce601aaf0f470216fe1b51850b4acf21b179c45070ac7b03a9ac00000000"
+
+func TestImportBlockFiles(t *testing.T) {
+	// genesis -> b1 -> b2 -> b3 with a stale fork genesis -> b1 -> f2
+	genesis := hx2b(RegTest_Genesis)
+	_, _, d1 := mineRegTestBlock(doge.DoubleSha256(genesis[:80]), 1)
+	_, _, d2 := mineRegTestBlock(doge.DoubleSha256(d1[:80]), 2)
+	_, _, f2 := mineRegTestBlock(doge.DoubleSha256(d1[:80]), 3)
+	_, _, d3 := mineRegTestBlock(doge.DoubleSha256(d2[:80]), 4)
+	frame := func(blocks ...[]byte) (buf []byte) {
+		for _, b := range blocks {
+			size := make([]byte, 4)
+			binary.LittleEndian.PutUint32(size, uint32(len(b)))
+			buf = append(append(append(buf, doge.DogeRegTestChain.MessageStart[:]...), size...), b...)
+		}
+		return
+	}
+	// Blocks are out of order across files, with zero-filled space at the end.
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "blk00000.dat"), append(frame(genesis, d1, f2, d3), make([]byte, 64)...), 0644)
+	os.WriteFile(filepath.Join(dir, "blk00001.dat"), frame(d2), 0644)
+
+	conf := giga.TestConfig()
+	conf.Gigawallet.Network = "regtest"
+	conf.ChainFollower.VerifyBlocks = true
+	s, err := store.NewSQLiteStore(":memory:")
+	if err != nil {
+		t.Fatalf("TestImportBlockFiles: NewSQLiteStore: %v", err)
+	}
+	defer s.Close()
+	state, err := ImportBlockFiles(conf, s, giga.NewMessageBus(), dir)
+	if err != nil {
+		t.Fatalf("TestImportBlockFiles: %v", err)
+	}
+	hash3 := doge.BlockHashHex(d3[:80])
+	if state.BestBlockHeight != 3 || state.BestBlockHash != hash3 {
+		t.Errorf("TestImportBlockFiles: wrong best block: %+v", state)
+	}
+	saved, err := s.GetChainState()
+	if err != nil || saved.BestBlockHash != hash3 || saved.RootHash != doge.DogeRegTestChain.GenesisBlock || saved.FirstHeight != 0 {
+		t.Errorf("TestImportBlockFiles: wrong chainstate: %+v %v", saved, err)
+	}
+	hdr, err := s.GetVerifiedHeader(2)
+	if err != nil || hdr.Hash != doge.BlockHashHex(d2[:80]) {
+		t.Errorf("TestImportBlockFiles: wrong verified header: %+v %v", hdr, err)
+	}
+	// Importing again resumes from the chainstate (nothing to do)
+	state, err = ImportBlockFiles(conf, s, giga.NewMessageBus(), dir)
+	if err != nil || state.BestBlockHash != hash3 {
+		t.Errorf("TestImportBlockFiles: resume: %+v %v", state, err)
+	}
+}
+
+func TestApplyCachedBlocks(t *testing.T) {
+	// genesis -> b1 -> b2 -> b3 where b2, b3 and a stale fork block (after b1)
+	// arrived from ZMQ rawblock, and b3 is the tip.
+	chain := newTestChain(&doge.DogeRegTestChain)
+	hash1 := chain.mine(chain.at(0))
+	hash2 := chain.mine(hash1)
+	hash3 := chain.mine(hash2)
+	stale := chain.mine(hash1)
+
+	cache := newBlockCache(3)
+	cache.add(hash2, chain.blockHex(hash3)) // wrong hash: ignored.
+	cache.add(hash3, chain.blockHex(hash3))
+	cache.add(hash2, chain.blockHex(hash2))
+	cache.add(stale, chain.blockHex(stale)) // received last, but not the tip.
+	cache.setTip(hash3)
+	if data, found := cache.get(hash2); !found || len(data) != len(chain.blocks[hash2]) {
+		t.Fatalf("TestApplyCachedBlocks: block not cached")
+	}
+
+	conf := giga.TestConfig()
+	conf.Gigawallet.Network = "regtest"
+	conf.ChainFollower.VerifyBlocks = true
+	s, err := store.NewSQLiteStore(":memory:")
+	if err != nil {
+		t.Fatalf("TestApplyCachedBlocks: NewSQLiteStore: %v", err)
+	}
+	defer s.Close()
+	follower, err := newChainFollower(conf, nil, s, giga.NewMessageBus(), cache) // no RPC.
+	if err != nil {
+		t.Fatalf("TestApplyCachedBlocks: %v", err)
+	}
+	follower.chain = &doge.DogeRegTestChain
+	pos, applied := follower.applyCachedBlocks(ChainPos{BlockHash: hash1, BlockHeight: 1, NextSeq: 1})
+	if !applied || pos.BlockHash != hash3 || pos.BlockHeight != 3 {
+		t.Fatalf("TestApplyCachedBlocks: wrong position: %+v %v", pos, applied)
+	}
+	saved, err := s.GetChainState()
+	if err != nil || saved.BestBlockHash != hash3 || saved.BestBlockHeight != 3 {
+		t.Errorf("TestApplyCachedBlocks: wrong chainstate: %+v %v", saved, err)
+	}
+	// Nothing follows on from the new tip.
+	if _, applied = follower.applyCachedBlocks(pos); applied {
+		t.Errorf("TestApplyCachedBlocks: expecting no more cached blocks")
+	}
+	// Core switched to another fork after b1 (not cached): fetch it instead.
+	cache.setTip(chain.mine(hash1))
+	if pos, applied = follower.applyCachedBlocks(ChainPos{BlockHash: hash1, BlockHeight: 1, NextSeq: 1}); applied {
+		t.Errorf("TestApplyCachedBlocks: expecting no blocks applied without the tip: %+v", pos)
+	}
+	// The oldest block is evicted when the cache is full.
+	cache.add(hash1, chain.blockHex(hash1))
+	if _, found := cache.get(hash3); found {
+		t.Errorf("TestApplyCachedBlocks: expecting the oldest block to be evicted")
+	}
+}
+
+func TestCachedBlockHandoff(t *testing.T) {
+	// TipChaser caches raw blocks from ZMQ and notifies the running ChainFollower,
+	// which applies them without fetching them over RPC.
+	r := newFollowerRig(t, "testnet")
+	inv := r.newInvoice(t, "Handoff")
+	tip := r.chain.mineN(r.chain.at(0), 3)
+	r.chain.setTip(tip)
+	r.sync(t)
+	cache := newBlockCache(BLOCK_CACHE_SIZE)
+	r.follower.cache = cache
+	tc, err := newTipChaser(giga.TestConfig(), r.chain, r.follower.bus, cache)
+	if err != nil {
+		t.Fatalf("newTipChaser: %v", err)
+	}
+	tc.Subscribe(r.follower.ReceiveBestBlock, false)
+	started, stopped, stop := make(chan bool, 1), make(chan bool, 1), make(chan context.Context, 1)
+	tc.Run(started, stopped, stop)
+	<-started
+	t.Cleanup(func() {
+		stop <- context.Background()
+		<-stopped
+	})
+	r.run(t)
+	r.events.wait(t, giga.NET_NEW_BLOCK)
+
+	// A new block paying the invoice arrives from ZMQ.
+	fetched := r.chain.fetched
+	tip = r.chain.mine(tip, payToAddressTx(t, inv.ID, 1))
+	r.chain.setTip(tip)
+	tc.ReceiveFromCore <- giga.NodeEvent{Type: giga.Block, ID: tip, Data: r.chain.blockHex(tip)}
+	msgs := r.events.wait(t, giga.NET_NEW_BLOCK)
+	if e := msgs[len(msgs)-1].Message.(giga.NetBlockEvent); e.Hash != tip || e.Height != 4 {
+		t.Fatalf("TestCachedBlockHandoff: wrong NET_NEW_BLOCK: %+v", e)
+	}
+	if r.chain.fetched != fetched {
+		t.Errorf("TestCachedBlockHandoff: expecting the block from the cache, fetched %v blocks", r.chain.fetched-fetched)
+	}
+
+	// A block from a stale fork was cached, but Core reports another tip
+	// (without the raw block): the follower fetches the best chain instead.
+	fork := r.chain.mine(tip)
+	cache.add(fork, r.chain.blockHex(fork))
+	tip = r.chain.mine(tip)
+	r.chain.setTip(tip)
+	tc.ReceiveFromCore <- giga.NodeEvent{Type: giga.Block, ID: tip}
+	msgs = r.events.wait(t, giga.NET_NEW_BLOCK)
+	if e := msgs[len(msgs)-1].Message.(giga.NetBlockEvent); e.Hash != tip || e.Height != 5 {
+		t.Fatalf("TestCachedBlockHandoff: expecting the best chain, got NET_NEW_BLOCK: %+v", e)
+	}
+	if len(r.events.take(giga.NET_REORG)) != 0 {
+		t.Errorf("TestCachedBlockHandoff: unexpected NET_REORG")
+	}
+	// The payment in the cached block is confirmed by the next block.
+	if r.invoiceUTXOs(t, inv) != 1 {
+		t.Errorf("TestCachedBlockHandoff: invoice not paid from the cached block")
+	}
+}
+
+// A fake Core node for ChainFollower tests: a tree of blocks made with
+// mineRegTestBlockWith (proof-of-work is not checked unless VerifyBlocks)
+// where setTip selects the best chain. Block 0 is the genesis block of
+// `chain` (header only) which selects the network.
+type testChain struct {
+	dogecoin.L1Mock
+	lock    sync.Mutex
+	chain   *doge.ChainParams
+	blocks  map[string][]byte              // raw blocks by hash
+	headers map[string]giga.RpcBlockHeader // block headers by hash
+	best    []string                       // best chain: block hash by height
+	mined   int                            // varies the coinbase in each block
+	fetched int                            // number of GetBlockHex requests
+}
+
+func newTestChain(chain *doge.ChainParams) *testChain {
+	genesis := chain.GenesisBlock
+	c := &testChain{
+		chain:   chain,
+		blocks:  make(map[string][]byte),
+		headers: map[string]giga.RpcBlockHeader{genesis: {Hash: genesis, Confirmations: 1}},
+		best:    []string{genesis},
+	}
+	return c
+}
+
+// Mine a block containing `txs` on top of block `prev`, returns its hash.
+// Call setTip to make it part of the best chain.
+func (c *testChain) mine(prev string, txs ...[]byte) string {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	parent := c.headers[prev]
+	c.mined++
+	prevBytes, _ := doge.HexDecode(prev)
+	for i, j := 0, len(prevBytes)-1; i < j; i, j = i+1, j-1 {
+		prevBytes[i], prevBytes[j] = prevBytes[j], prevBytes[i]
+	}
+	_, hdr, data := mineRegTestBlockWith(prevBytes, []byte{byte(c.mined), byte(c.mined >> 8)}, txs...)
+	hdr.Height = parent.Height + 1
+	hdr.PreviousBlockHash = prev
+	hdr.Confirmations = -1
+	c.headers[hdr.Hash] = hdr
+	c.blocks[hdr.Hash] = data
+	return hdr.Hash
+}
+
+// Mine `n` blocks on top of block `prev` (paying nothing), returns the last hash.
+func (c *testChain) mineN(prev string, n int) string {
+	for i := 0; i < n; i++ {
+		prev = c.mine(prev)
+	}
+	return prev
+}
+
+// Make the chain ending at block `tip` the best chain.
+func (c *testChain) setTip(tip string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for hash, hdr := range c.headers {
+		hdr.Confirmations, hdr.NextBlockHash = -1, ""
+		c.headers[hash] = hdr
+	}
+	height := c.headers[tip].Height
+	c.best = make([]string, height+1)
+	next := ""
+	for hash := tip; hash != ""; hash = c.headers[hash].PreviousBlockHash {
+		hdr := c.headers[hash]
+		hdr.Confirmations = height - hdr.Height + 1
+		hdr.NextBlockHash = next
+		c.headers[hash] = hdr
+		c.best[hdr.Height] = hash
+		next = hash
+	}
+}
+
+// Hash of the block at `height` on the best chain.
+func (c *testChain) at(height int64) string {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.best[height]
+}
+
+func (c *testChain) GetBlockHeader(blockHash string) (giga.RpcBlockHeader, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	hdr, found := c.headers[blockHash]
+	if !found {
+		return hdr, giga.NewErr(giga.NotFound, "block not found: %v", blockHash)
+	}
+	return hdr, nil
+}
+
+// Raw block (hex) as sent in a ZMQ rawblock notification.
+func (c *testChain) blockHex(hash string) string {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return hex.EncodeToString(c.blocks[hash])
+}
+
+func (c *testChain) GetBlockHex(blockHash string) (string, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.fetched++
+	data, found := c.blocks[blockHash]
+	if !found {
+		return "", giga.NewErr(giga.NotFound, "block not found: %v", blockHash)
+	}
+	return hex.EncodeToString(data), nil
+}
+
+func (c *testChain) GetBlockHash(height int64) (string, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if height < 0 || height >= int64(len(c.best)) {
+		return "", giga.NewErr(giga.NotFound, "block height out of range: %v", height)
+	}
+	return c.best[height], nil
+}
+
+func (c *testChain) GetBestBlockHash() (string, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.best[len(c.best)-1], nil
+}
+
+func (c *testChain) GetBlockCount() (int64, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return int64(len(c.best) - 1), nil
+}
+
+func (c *testChain) GetBlockchainInfo() (giga.RpcBlockchainInfo, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return giga.RpcBlockchainInfo{Chain: c.chain.ChainName, Blocks: int64(len(c.best) - 1), BestBlockHash: c.best[len(c.best)-1]}, nil
+}
+
+// A transaction paying 10 DOGE to a P2PKH address (from a made-up input varied by `tag`)
+func payToAddressTx(t *testing.T, addr giga.Address, tag byte) []byte {
+	payload, err := doge.Base58DecodeCheck(string(addr))
+	if err != nil {
+		t.Fatalf("payToAddressTx: %v", err)
+	}
+	script := append(append([]byte{doge.OP_DUP, doge.OP_HASH160, 20}, payload[1:]...), doge.OP_EQUALVERIFY, doge.OP_CHECKSIG)
+	return doge.EncodeTx(doge.BlockTx{
+		Version: 1,
+		VIn:     []doge.BlockTxIn{{TxID: bytes.Repeat([]byte{tag}, 32), VOut: 0, Script: []byte{0x51}, Sequence: 0xffffffff}},
+		VOut:    []doge.BlockTxOut{{Value: 10 * 100_000_000, Script: script}},
+	})
+}
+
+// Collects the messages sent on a MessageBus.
+type busRecorder struct {
+	rec chan giga.Message
+}
+
+func (r busRecorder) GetChan() chan giga.Message {
+	return r.rec
+}
+
+func newBusRecorder(t *testing.T) (giga.MessageBus, busRecorder) {
+	bus := giga.NewMessageBus()
+	r := busRecorder{rec: make(chan giga.Message, 1000)}
+	bus.Register(r, giga.EVENT_ALL("ALL"))
+	started, stopped, stop := make(chan bool, 1), make(chan bool, 1), make(chan context.Context, 1)
+	bus.Run(started, stopped, stop)
+	<-started
+	t.Cleanup(func() {
+		stop <- context.Background()
+		<-stopped
+	})
+	return bus, r
+}
+
+// Take the messages received so far (waiting briefly for delivery) that
+// match any of `types`, discarding the rest.
+func (r busRecorder) take(types ...giga.EventType) (msgs []giga.Message) {
+	for {
+		select {
+		case msg := <-r.rec:
+			for _, t := range types {
+				if msg.EventType == t {
+					msgs = append(msgs, msg)
+				}
+			}
+		case <-time.After(50 * time.Millisecond):
+			return
+		}
+	}
+}
+
+// Wait for a message of type `event`, returns the messages received up to that message.
+func (r busRecorder) wait(t *testing.T, event giga.EventType) (msgs []giga.Message) {
+	for {
+		select {
+		case msg := <-r.rec:
+			msgs = append(msgs, msg)
+			if msg.EventType == event {
+				return msgs
+			}
+		case <-time.After(10 * time.Second):
+			t.Fatalf("timed out waiting for %v", event)
+		}
+	}
+}
+
+type followerRig struct {
+	follower *ChainFollower
+	chain    *testChain
+	store    giga.Store
+	api      giga.API
+	events   busRecorder
+}
+
+// A ChainFollower on the testChain with an in-memory store, and an API
+// with the same store to create accounts and invoices.
+func newFollowerRig(t *testing.T, network string) followerRig {
+	conf := giga.TestConfig()
+	conf.Gigawallet.Network = network
+	conf.Gigawallet.ConfirmationsNeeded = 1
+	conf.Gigawallet.RejectionsNeeded = 2
+	conf.ChainFollower.PrefetchWorkers = 0
+	params, err := doge.ChainFromName(network)
+	if err != nil {
+		t.Fatalf("newFollowerRig: %v", err)
+	}
+	s, err := store.NewSQLiteStore(":memory:")
+	if err != nil {
+		t.Fatalf("newFollowerRig: NewSQLiteStore: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	chain := newTestChain(params)
+	lib, err := dogecoin.NewL1Libdogecoin(conf, chain)
+	if err != nil {
+		t.Fatalf("newFollowerRig: NewL1Libdogecoin: %v", err)
+	}
+	bus, events := newBusRecorder(t)
+	follower, err := newChainFollower(conf, chain, s, bus, nil)
+	if err != nil {
+		t.Fatalf("newFollowerRig: %v", err)
+	}
+	api := giga.NewAPI(s, lib, bus, follower, conf)
+	return followerRig{follower: follower, chain: chain, store: s, api: api, events: events}
+}
+
+// Create an account and an invoice, returns the invoice.
+func (r followerRig) newInvoice(t *testing.T, foreignID string) giga.Invoice {
+	_, err := r.api.CreateAccount(giga.AccountCreateRequest{}, foreignID, false)
+	if err != nil {
+		t.Fatalf("CreateAccount: %v", err)
+	}
+	inv, err := r.api.CreateInvoice(giga.InvoiceCreateRequest{Items: []giga.Item{{Type: "item", Name: "doge", Value: decimal.NewFromInt(10), Quantity: 1}}, Confirmations: -1}, foreignID, giga.IdempotencyKey{})
+	if err != nil {
+		t.Fatalf("CreateInvoice: %v", err)
+	}
+	return inv
+}
+
+func (r followerRig) getInvoice(t *testing.T, id giga.Address) giga.Invoice {
+	inv, err := r.store.GetInvoice(id)
+	if err != nil {
+		t.Fatalf("GetInvoice: %v", err)
+	}
+	return inv
+}
+
+// Number of UTXOs paid to an invoice address.
+func (r followerRig) invoiceUTXOs(t *testing.T, inv giga.Invoice) int {
+	utxos, err := r.store.GetAllUnreservedUTXOs(inv.Account)
+	if err != nil {
+		t.Fatalf("GetAllUnreservedUTXOs: %v", err)
+	}
+	n := 0
+	for _, u := range utxos {
+		if u.ScriptAddress == inv.ID {
+			n++
+		}
+	}
+	return n
+}
+
+// Run the ChainFollower service (stopped when the test ends)
+func (r followerRig) run(t *testing.T) {
+	started, stopped, stop := make(chan bool, 1), make(chan bool, 1), make(chan context.Context, 1)
+	r.follower.Run(started, stopped, stop)
+	<-started
+	t.Cleanup(func() {
+		stop <- context.Background()
+		<-stopped
+	})
+}
+
+// Sync from genesis to the tip of the testChain (the ChainFollower is not running)
+func (r followerRig) sync(t *testing.T) ChainPos {
+	pos := r.follower.fetchStartingPos()
+	return r.follower.followChainToTip(pos)
+}
+
+func TestReorgDoubleSpends(t *testing.T) {
+	// Old chain: genesis -> 1..25 with payments to two invoices in blocks 20 and 21.
+	// New chain: forks at block 2 -> 3..30 and only re-mines the first payment (at 24)
+	r := newFollowerRig(t, "testnet")
+	inv1 := r.newInvoice(t, "Reorg1")
+	inv2 := r.newInvoice(t, "Reorg2")
+	pay1, pay2 := payToAddressTx(t, inv1.ID, 1), payToAddressTx(t, inv2.ID, 2)
+	fork := r.chain.mineN(r.chain.headers[r.chain.at(0)].Hash, 2)
+	old := r.chain.mine(r.chain.mineN(fork, 17), pay1)
+	old = r.chain.mineN(r.chain.mine(old, pay2), 4)
+	r.chain.setTip(old)
+	pos := r.sync(t)
+	if pos.BlockHeight != 25 || r.invoiceUTXOs(t, inv1) != 1 || r.invoiceUTXOs(t, inv2) != 1 {
+		t.Fatalf("TestReorgDoubleSpends: invoices not paid: %+v", pos)
+	}
+
+	// Reorg deeper than RejectionsNeeded, replayed in several batches.
+	tip := r.chain.mineN(r.chain.mine(r.chain.mineN(fork, 21), pay1), 6)
+	r.chain.setTip(tip)
+	r.events.take()
+	pos = r.follower.followChainToTip(pos)
+	if pos.BlockHeight != 30 || pos.BlockHash != tip {
+		t.Fatalf("TestReorgDoubleSpends: wrong position after reorg: %+v", pos)
+	}
+	// Only the payment that was not re-mined is a double-spend, once the
+	// new chain is past the old tip.
+	msgs := r.events.take(giga.INV_DOUBLE_SPEND)
+	if len(msgs) != 1 {
+		t.Fatalf("TestReorgDoubleSpends: expecting one INV_DOUBLE_SPEND, got %v", msgs)
+	}
+	ds := msgs[0].Message.(giga.DoubleSpendEvent)
+	if ds.InvoiceID != inv2.ID || ds.ForkHeight != 2 || ds.Depth != 28 {
+		t.Errorf("TestReorgDoubleSpends: wrong double-spend: %+v", ds)
+	}
+	if r.getInvoice(t, inv1.ID).DoubleSpendHeight != 0 || r.getInvoice(t, inv2.ID).DoubleSpendHeight != 30 {
+		t.Errorf("TestReorgDoubleSpends: wrong invoice flags")
+	}
+
+	// The flag is cleared if the payment is re-mined later.
+	tip = r.chain.mine(tip, pay2)
+	r.chain.setTip(tip)
+	pos = r.follower.followChainToTip(pos)
+	if pos.BlockHeight != 31 || r.getInvoice(t, inv2.ID).DoubleSpendHeight != 0 {
+		t.Errorf("TestReorgDoubleSpends: expecting the flag to be cleared: %+v", r.getInvoice(t, inv2.ID))
+	}
+}
+
 func hx2b(str string) (bytes []byte) {
 	bytes, err := hex.DecodeString(str)
 	if err != nil {
@@ -54,3 +725,183 @@ func hx2b(str string) (bytes []byte) {
 	}
 	return
 }
+
+func TestRetrySkippedBlocksNoDoubleSpends(t *testing.T) {
+	// Chain: genesis -> 1..25 with a payment to the invoice in block 20,
+	// where block 3 was skipped (could not be decoded)
+	// The account also sent the payment (as a Payment)
+	r := newFollowerRig(t, "testnet")
+	inv := r.newInvoice(t, "Skipped")
+	payTx := payToAddressTx(t, inv.ID, 1)
+	tx, err := r.store.Begin()
+	if err != nil {
+		t.Fatalf("Begin: %v", err)
+	}
+	payment, err := tx.CreatePayment(inv.Account, giga.PaymentTypePayout, []giga.PayTo{{PayTo: inv.ID, Amount: decimal.NewFromInt(10)}}, "", decimal.NewFromInt(10), decimal.Zero)
+	if err != nil {
+		t.Fatalf("CreatePayment: %v", err)
+	}
+	err = tx.UpdatePaymentWithTxID(payment.ID, doge.TxHashHex(payTx))
+	if err != nil {
+		t.Fatalf("UpdatePaymentWithTxID: %v", err)
+	}
+	if err = tx.Commit(); err != nil {
+		t.Fatalf("Commit: %v", err)
+	}
+	tip := r.chain.mineN(r.chain.mine(r.chain.mineN(r.chain.at(0), 19), payTx), 5)
+	r.chain.setTip(tip)
+	pos := r.sync(t)
+	expectPaid := func(when string) {
+		t.Helper()
+		p, err := r.store.GetPayment(inv.Account, payment.ID)
+		if err != nil {
+			t.Fatalf("GetPayment: %v", err)
+		}
+		if p.PaidHeight == 0 || p.ConfirmedHeight == 0 {
+			t.Fatalf("TestRetrySkippedBlocksNoDoubleSpends: payment not on-chain %s: %+v", when, p)
+		}
+	}
+	expectPaid("after sync")
+	tx, err = r.store.Begin()
+	if err != nil {
+		t.Fatalf("Begin: %v", err)
+	}
+	err = tx.StoreSkippedBlock(giga.SkippedBlock{Height: 3, Hash: r.chain.at(3), Error: "test", Skipped: time.Now()})
+	if err != nil {
+		t.Fatalf("StoreSkippedBlock: %v", err)
+	}
+	if err = tx.Commit(); err != nil {
+		t.Fatalf("Commit: %v", err)
+	}
+
+	// Retrying rolls back to block 2 and replays the same blocks: not a reorg.
+	r.events.take()
+	pos = r.follower.retrySkippedBlocks(pos)
+	// The Payment stays on-chain (no PAYMENT_UNCONFIRMED) until its block is replayed.
+	expectPaid("after rollback")
+	pos = r.follower.followChainToTip(pos)
+	if pos.BlockHeight != 25 || pos.BlockHash != tip {
+		t.Fatalf("TestRetrySkippedBlocksNoDoubleSpends: wrong position after retry: %+v", pos)
+	}
+	expectPaid("after retry")
+	if msgs := r.events.take(giga.INV_DOUBLE_SPEND, giga.NET_REORG); len(msgs) != 0 {
+		t.Errorf("TestRetrySkippedBlocksNoDoubleSpends: unexpected events: %v", msgs)
+	}
+	if r.getInvoice(t, inv.ID).DoubleSpendHeight != 0 || r.invoiceUTXOs(t, inv) != 1 {
+		t.Errorf("TestRetrySkippedBlocksNoDoubleSpends: invoice not paid or flagged: %+v", r.getInvoice(t, inv.ID))
+	}
+	if skipped, err := r.store.ListSkippedBlocks(); err != nil || len(skipped) != 0 {
+		t.Errorf("TestRetrySkippedBlocksNoDoubleSpends: expecting no skipped blocks: %v %v", skipped, err)
+	}
+}
+
+func TestRollbackChainNoDoubleSpends(t *testing.T) {
+	// Chain: genesis -> 1..25 with a payment to the invoice in block 20.
+	r := newFollowerRig(t, "testnet")
+	inv := r.newInvoice(t, "Rollback")
+	tip := r.chain.mineN(r.chain.mine(r.chain.mineN(r.chain.at(0), 19), payToAddressTx(t, inv.ID, 1)), 5)
+	r.chain.setTip(tip)
+	r.run(t)
+	msgs := r.events.wait(t, giga.NET_NEW_BLOCK)
+	if ev := msgs[len(msgs)-1].Message.(giga.NetBlockEvent); ev.Height != 25 {
+		t.Fatalf("TestRollbackChainNoDoubleSpends: wrong height after sync: %+v", ev)
+	}
+
+	// RollbackChain replays the same blocks (re-sync): not a reorg.
+	err := r.api.RollbackChain(2)
+	if err != nil {
+		t.Fatalf("RollbackChain: %v", err)
+	}
+	msgs = r.events.wait(t, giga.NET_NEW_BLOCK)
+	if ev := msgs[len(msgs)-1].Message.(giga.NetBlockEvent); ev.Height != 25 || ev.Hash != tip {
+		t.Fatalf("TestRollbackChainNoDoubleSpends: wrong position after re-sync: %+v", ev)
+	}
+	for _, msg := range msgs {
+		if msg.EventType == giga.INV_DOUBLE_SPEND || msg.EventType == giga.NET_REORG {
+			t.Errorf("TestRollbackChainNoDoubleSpends: unexpected event: %v", msg)
+		}
+	}
+	if r.getInvoice(t, inv.ID).DoubleSpendHeight != 0 || r.invoiceUTXOs(t, inv) != 1 {
+		t.Errorf("TestRollbackChainNoDoubleSpends: invoice not paid or flagged: %+v", r.getInvoice(t, inv.ID))
+	}
+}
+
+func TestFollowerNetEvents(t *testing.T) {
+	// Chain: genesis -> 1..25 with a payment to the invoice in block 20.
+	r := newFollowerRig(t, "testnet")
+	inv := r.newInvoice(t, "NetEvents")
+	pay := payToAddressTx(t, inv.ID, 1)
+	fork := r.chain.mineN(r.chain.at(0), 18)
+	old := r.chain.mineN(r.chain.mine(r.chain.mine(fork), pay), 5)
+	r.chain.setTip(old)
+	r.events.take()
+	pos := r.sync(t)
+
+	// Syncing from genesis: progress after the first batch, then complete.
+	msgs := r.events.take(giga.NET_SYNC_PROGRESS, giga.NET_SYNC_COMPLETE, giga.NET_NEW_BLOCK)
+	if len(msgs) != 3 || msgs[0].EventType != giga.NET_SYNC_PROGRESS || msgs[1].EventType != giga.NET_SYNC_COMPLETE || msgs[2].EventType != giga.NET_NEW_BLOCK {
+		t.Fatalf("TestFollowerNetEvents: wrong sync events: %v", msgs)
+	}
+	progress := msgs[0].Message.(giga.NetSyncEvent)
+	if progress.Height != 11 || progress.Hash != r.chain.at(11) || progress.TipHeight != 25 || progress.Behind != 14 || progress.Percent != 44 {
+		t.Errorf("TestFollowerNetEvents: wrong NET_SYNC_PROGRESS: %+v", progress)
+	}
+	complete := msgs[1].Message.(giga.NetSyncEvent)
+	if complete.Height != 25 || complete.Hash != old || complete.TipHeight != 25 || complete.Behind != 0 || complete.Percent != 100 {
+		t.Errorf("TestFollowerNetEvents: wrong NET_SYNC_COMPLETE: %+v", complete)
+	}
+	if block := msgs[2].Message.(giga.NetBlockEvent); block.Height != 25 || block.Hash != old {
+		t.Errorf("TestFollowerNetEvents: wrong NET_NEW_BLOCK: %+v", block)
+	}
+
+	// Reorg from block 18, which rolls back the confirmed payment.
+	tip := r.chain.mineN(fork, 9)
+	r.chain.setTip(tip)
+	pos = r.follower.followChainToTip(pos)
+	msgs = r.events.take(giga.NET_REORG, giga.NET_NEW_BLOCK)
+	if len(msgs) != 2 || msgs[0].EventType != giga.NET_REORG || msgs[1].EventType != giga.NET_NEW_BLOCK {
+		t.Fatalf("TestFollowerNetEvents: wrong reorg events: %v", msgs)
+	}
+	reorg := msgs[0].Message.(giga.NetReorgEvent)
+	if reorg.FromHash != old || reorg.FromHeight != 25 || reorg.ForkHash != fork || reorg.ForkHeight != 18 || reorg.Depth != 7 ||
+		len(reorg.TxIDs) != 1 || reorg.TxIDs[0] != doge.TxHashHex(pay) {
+		t.Errorf("TestFollowerNetEvents: wrong NET_REORG: %+v", reorg)
+	}
+	if block := msgs[1].Message.(giga.NetBlockEvent); block.Height != 27 || block.Hash != tip || pos.BlockHash != tip {
+		t.Errorf("TestFollowerNetEvents: wrong NET_NEW_BLOCK after reorg: %+v", block)
+	}
+}
+
+func TestProcessBlockNetworks(t *testing.T) {
+	// Regtest accounts have testnet addresses (see giga.AccountChain)
+	for _, network := range []string{"testnet", "regtest"} {
+		r := newFollowerRig(t, network)
+		inv := r.newInvoice(t, "Net-"+network)
+		if !doge.ValidateP2PKH(inv.ID, &doge.DogeTestNetChain) {
+			t.Fatalf("TestProcessBlockNetworks: %v: expecting a testnet invoice address: %v", network, inv.ID)
+		}
+		pay := payToAddressTx(t, inv.ID, 1)
+		r.chain.setTip(r.chain.mineN(r.chain.mine(r.chain.mineN(r.chain.at(0), 2), pay), 2))
+		pos := r.sync(t)
+
+		// processBlock finds the payment to the invoice address.
+		block, _, _ := mineRegTestBlockWith(make([]byte, 32), []byte{1}, pay)
+		changes, _ := r.follower.processBlock(&block, pos.BlockHash, pos.BlockHeight, nil, nil)
+		found := false
+		for _, change := range changes {
+			found = found || (change.ScriptAddress == inv.ID && change.TxID == doge.TxHashHex(pay))
+		}
+		if !found {
+			t.Errorf("TestProcessBlockNetworks: %v: no UTXO for the invoice: %+v", network, changes)
+		}
+		// ... and the ChainFollower stored it for the invoice.
+		if r.invoiceUTXOs(t, inv) != 1 {
+			t.Errorf("TestProcessBlockNetworks: %v: invoice not paid", network)
+		}
+		// The account's own addresses are valid pay-to addresses.
+		_, err := r.api.QueueWithdrawal("Net-"+network, inv.ID, decimal.NewFromInt(5))
+		if err != nil {
+			t.Errorf("TestProcessBlockNetworks: %v: QueueWithdrawal to own address: %v", network, err)
+		}
+	}
+}
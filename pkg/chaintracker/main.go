@@ -5,21 +5,27 @@ import (
 	"github.com/dogecoinfoundation/gigawallet/pkg/conductor"
 )
 
-func StartChainTracker(c *conductor.Conductor, conf giga.Config, l1 giga.L1, store giga.Store) (giga.TipChaserReceiver, giga.ChainFollower, error) {
+func StartChainTracker(c *conductor.Conductor, conf giga.Config, l1 giga.L1, store giga.Store, bus giga.MessageBus) (giga.TipChaserReceiver, giga.ChainFollower, error) {
+	// Raw blocks from Core's ZMQ rawblock notifications (see NodeConfig.ZMQRawBlock)
+	cache := newBlockCache(BLOCK_CACHE_SIZE)
+
+	// e.g. "TipChaser (testnet)" when several networks run side by side
+	network := " (" + conf.Gigawallet.Network + ")"
+
 	// Start the TipChaser service
-	tc, err := newTipChaser(conf, l1)
+	tc, err := newTipChaser(conf, l1, bus, cache)
 	if err != nil {
 		return nil, nil, err
 	}
-	c.Service("TipChaser", tc)
+	c.Service("TipChaser"+network, tc)
 
 	// Start the ChainFollower service
-	cf, err := newChainFollower(conf, l1, store)
+	cf, err := newChainFollower(conf, l1, store, bus, cache)
 	if err != nil {
 		return nil, nil, err
 	}
 	tc.Subscribe(cf.ReceiveBestBlock, false) // non-blocking.
-	c.Service("ChainFollower", cf)
+	c.Service("ChainFollower"+network, cf)
 
 	return tc.ReceiveFromCore, cf, nil
 }
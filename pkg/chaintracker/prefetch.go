@@ -0,0 +1,196 @@
+package chaintracker
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	giga "github.com/dogecoinfoundation/gigawallet/pkg"
+	"github.com/dogecoinfoundation/gigawallet/pkg/doge"
+)
+
+const (
+	PREFETCH_AHEAD_PER_WORKER = 4               // queued blocks per worker (in addition to the memory limit)
+	PREFETCH_POLL             = 1 * time.Second // check for shutdown while waiting for a block
+)
+
+// A block downloaded and decoded ahead of time by the blockPrefetcher.
+type prefetchedBlock struct {
+	header giga.RpcBlockHeader
+	block  doge.Block
+	data   []byte        // raw block data, kept only if decoding failed (see decodeBlock)
+	err    error         // decoding error
+	size   int64         // approximate memory used by the block (see memory limit)
+	done   chan struct{} // closed when the block has been fetched and decoded
+}
+
+/*
+ * blockPrefetcher downloads and decodes upcoming blocks while the
+ * ChainFollower applies and commits the current batch.
+ *
+ * It walks forwards from a starting block (following 'nextblockhash')
+ * and hands each block to a pool of workers; blocks are delivered to the
+ * ChainFollower in chain order. It stops at the tip, or after delivering
+ * a header that is no longer on-chain (so the ChainFollower can roll back
+ * exactly as it does without prefetch.)
+ *
+ * Memory is bounded by `limit` bytes of blocks not yet taken by the
+ * ChainFollower (this can be exceeded by up to one block per worker.)
+ *
+ * Workers never call the ChainFollower's fetch functions, because those
+ * receive Commands and panic to stop the service (see sleepForRetry)
+ */
+type blockPrefetcher struct {
+	l1      giga.L1
+	queue   chan *prefetchedBlock // in chain order; closed when the walk ends.
+	jobs    chan *prefetchedBlock // blocks to fetch (workers)
+	quit    chan struct{}         // closed to stop all goroutines.
+	expect  string                // hash of the next block the ChainFollower will receive.
+	mu      sync.Mutex            // protects used, stopped.
+	cond    *sync.Cond            // signalled when used decreases, or stopped.
+	used    int64                 // bytes of fetched blocks not yet taken.
+	limit   int64                 // memory limit in bytes.
+	stopped bool
+}
+
+func newBlockPrefetcher(l1 giga.L1, startHash string, workers int, limit int64) *blockPrefetcher {
+	p := &blockPrefetcher{
+		l1:     l1,
+		queue:  make(chan *prefetchedBlock, workers*PREFETCH_AHEAD_PER_WORKER),
+		jobs:   make(chan *prefetchedBlock, workers),
+		quit:   make(chan struct{}),
+		expect: startHash,
+		limit:  limit,
+	}
+	p.cond = sync.NewCond(&p.mu)
+	for i := 0; i < workers; i++ {
+		go p.worker()
+	}
+	go p.walkHeaders(startHash)
+	return p
+}
+
+// Stop all goroutines; blocks in the queue are discarded.
+func (p *blockPrefetcher) stop() {
+	p.mu.Lock()
+	if !p.stopped {
+		p.stopped = true
+		close(p.quit)
+		p.cond.Broadcast()
+	}
+	p.mu.Unlock()
+}
+
+// Release the memory used by a block taken from the queue.
+func (p *blockPrefetcher) release(size int64) {
+	p.mu.Lock()
+	p.used -= size
+	p.cond.Broadcast()
+	p.mu.Unlock()
+}
+
+func (p *blockPrefetcher) walkHeaders(hash string) {
+	defer close(p.queue)
+	defer close(p.jobs)
+	for hash != "" {
+		if !p.waitForMemory() {
+			return // stopped.
+		}
+		header, ok := p.fetchHeader(hash)
+		if !ok {
+			return // stopped.
+		}
+		pb := &prefetchedBlock{header: header, done: make(chan struct{})}
+		if header.Confirmations == -1 {
+			// No longer on-chain: deliver the header so the ChainFollower rolls back.
+			close(pb.done)
+			p.send(p.queue, pb)
+			return
+		}
+		if !p.send(p.queue, pb) || !p.send(p.jobs, pb) {
+			return // stopped.
+		}
+		hash = header.NextBlockHash
+	}
+}
+
+func (p *blockPrefetcher) worker() {
+	for pb := range p.jobs {
+		data, ok := p.fetchData(pb.header.Hash)
+		if !ok {
+			return // stopped.
+		}
+		pb.block, pb.err = doge.DecodeBlock(data, pb.header.Hash, true)
+		if pb.err != nil {
+			pb.data = data // for the fallback method in decodeBlock.
+		}
+		pb.size = int64(len(data)) * 2 // raw data and decoded block (approx.)
+		p.mu.Lock()
+		p.used += pb.size
+		p.mu.Unlock()
+		close(pb.done)
+	}
+}
+
+func (p *blockPrefetcher) waitForMemory() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for p.used >= p.limit && !p.stopped {
+		p.cond.Wait()
+	}
+	return !p.stopped
+}
+
+func (p *blockPrefetcher) send(ch chan *prefetchedBlock, pb *prefetchedBlock) bool {
+	select {
+	case ch <- pb:
+		return true
+	case <-p.quit:
+		return false
+	}
+}
+
+func (p *blockPrefetcher) fetchHeader(blockHash string) (giga.RpcBlockHeader, bool) {
+	for {
+		header, err := p.l1.GetBlockHeader(blockHash)
+		if err == nil {
+			return header, true
+		}
+		log.Println("ChainFollower: prefetch: error retrieving block header (will retry):", err)
+		if !p.sleepForRetry() {
+			return header, false
+		}
+	}
+}
+
+func (p *blockPrefetcher) fetchData(blockHash string) ([]byte, bool) {
+	for {
+		select {
+		case <-p.quit:
+			return nil, false
+		default:
+		}
+		hex, err := p.l1.GetBlockHex(blockHash)
+		if err == nil {
+			bytes, err := doge.HexDecode(hex)
+			if err == nil {
+				return bytes, true
+			}
+			log.Println("ChainFollower: prefetch: invalid block hex (will retry):", err)
+		} else {
+			log.Println("ChainFollower: prefetch: error retrieving block (will retry):", err)
+		}
+		if !p.sleepForRetry() {
+			return nil, false
+		}
+	}
+}
+
+func (p *blockPrefetcher) sleepForRetry() bool {
+	select {
+	case <-p.quit:
+		return false
+	case <-time.After(RETRY_DELAY):
+		return true
+	}
+}
@@ -2,6 +2,7 @@ package chaintracker
 
 import (
 	"context"
+	"fmt"
 	"log"
 	"time"
 
@@ -18,23 +19,33 @@ type TipSubscription struct {
 }
 
 type TipChaser struct {
-	// bus             giga.MessageBus
+	bus             giga.MessageBus
 	l1              giga.L1
 	ReceiveFromCore chan giga.NodeEvent
 	listeners       []TipSubscription
+	cache           *blockCache // raw blocks for the ChainFollower (ZMQ rawblock)
+	node            string      // Core node for NET_NODE events ("" if core.L1Failover sends them)
+	nodeDown        bool        // sent NET_NODE_DOWN
 }
 
 /*
  * TipChaser tracks the current Best Block (tip) of the blockchain.
  * It notifies listeners each time the Best Block hash changes.
  * It receives NodeEvent ('Block') from CoreReceiver ZMQ listener.
+ * Raw blocks in the NodeEvent Data (ZMQ rawblock) are cached for the ChainFollower.
  * If it doesn't receive ZMQ notifications for a while, it will poll the node instead.
+ * Sends NET_NODE_DOWN if polling fails, and NET_NODE_UP when it succeeds again.
  */
-func newTipChaser(conf giga.Config, l1 giga.L1) (*TipChaser, error) {
+func newTipChaser(conf giga.Config, l1 giga.L1, bus giga.MessageBus, cache *blockCache) (*TipChaser, error) {
 	result := &TipChaser{
+		bus:             bus,
 		l1:              l1,
+		cache:           cache,
 		ReceiveFromCore: make(chan giga.NodeEvent, 1000),
 	}
+	if len(conf.Core.Nodes) == 0 {
+		result.node = fmt.Sprintf("%s:%d", conf.Core.RPCHost, conf.Core.RPCPort)
+	}
 	return result, nil
 }
 
@@ -55,6 +66,11 @@ func (c *TipChaser) Run(started, stopped chan bool, stop chan context.Context) e
 				switch e.Type {
 				case giga.Block:
 					blockid := e.ID
+					if e.Data != "" {
+						c.cache.add(blockid, e.Data)
+					}
+					c.cache.setTip(blockid)
+					c.setNodeUp(true, blockid, nil)
 					if blockid != lastid {
 						lastid = blockid
 						c.sendEvent(blockid)
@@ -62,12 +78,13 @@ func (c *TipChaser) Run(started, stopped chan bool, stop chan context.Context) e
 				}
 			case <-time.After(expectedBlockInterval):
 				log.Println("TipChaser: falling back to getbestblockhash")
-				// c.bus.Send(giga.SYS_ERR, "TipChaser: falling back to getbestblockhash")
 				blockid, err := c.l1.GetBestBlockHash()
 				if err != nil {
 					log.Println("TipChaser: core RPC request failed: getbestblockhash")
-					// c.bus.Send(giga.SYS_ERR, "TipChaser: core RPC request failed: getbestblockhash")
+					c.setNodeUp(false, "", err)
 				} else {
+					c.cache.setTip(blockid)
+					c.setNodeUp(true, blockid, nil)
 					if blockid != lastid {
 						lastid = blockid
 						c.sendEvent(blockid)
@@ -80,6 +97,23 @@ func (c *TipChaser) Run(started, stopped chan bool, stop chan context.Context) e
 	return nil
 }
 
+// setNodeUp sends NET_NODE_DOWN or NET_NODE_UP when the node's status changes.
+func (c *TipChaser) setNodeUp(up bool, bestHash string, err error) {
+	if c.node == "" || up != c.nodeDown {
+		return // no change.
+	}
+	c.nodeDown = !up
+	e := giga.NetNodeEvent{Node: c.node, Up: up, BestHash: bestHash}
+	if up {
+		log.Println("TipChaser: core node is up:", c.node)
+		c.bus.Send(giga.NET_NODE_UP, e)
+	} else {
+		e.Error = err.Error()
+		log.Println("TipChaser: core node is down:", c.node, err)
+		c.bus.Send(giga.NET_NODE_DOWN, e)
+	}
+}
+
 func (c *TipChaser) sendEvent(e string) {
 	log.Println("TipChaser: discovered new best block:", e)
 	for _, ch := range c.listeners {
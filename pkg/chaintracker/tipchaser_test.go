@@ -0,0 +1,75 @@
+package chaintracker
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"testing"
+	"time"
+
+	giga "github.com/dogecoinfoundation/gigawallet/pkg"
+	"github.com/dogecoinfoundation/gigawallet/pkg/doge"
+)
+
+func TestTipChaser(t *testing.T) {
+	conf := giga.TestConfig()
+	conf.Core.RPCHost, conf.Core.RPCPort = "core", 22555
+	bus, events := newBusRecorder(t)
+	cache := newBlockCache(BLOCK_CACHE_SIZE)
+	tc, err := newTipChaser(conf, nil, bus, cache)
+	if err != nil {
+		t.Fatalf("newTipChaser: %v", err)
+	}
+	tips := make(chan string, 10)
+	tc.Subscribe(tips, false)
+
+	// Polling Core failed: the node is down (once)
+	tc.setNodeUp(false, "", errors.New("connection refused"))
+	tc.setNodeUp(false, "", errors.New("connection refused"))
+	msgs := events.take(giga.NET_NODE_DOWN, giga.NET_NODE_UP)
+	if len(msgs) != 1 || msgs[0].EventType != giga.NET_NODE_DOWN {
+		t.Fatalf("TestTipChaser: expecting NET_NODE_DOWN, got %v", msgs)
+	}
+	if e := msgs[0].Message.(giga.NetNodeEvent); e.Node != "core:22555" || e.Up || e.Error != "connection refused" {
+		t.Errorf("TestTipChaser: wrong NET_NODE_DOWN: %+v", e)
+	}
+
+	started, stopped, stop := make(chan bool, 1), make(chan bool, 1), make(chan context.Context, 1)
+	tc.Run(started, stopped, stop)
+	<-started
+	defer func() {
+		stop <- context.Background()
+		<-stopped
+	}()
+
+	// A ZMQ rawblock notification: cached, listeners notified once, node up.
+	_, hdr, data := mineRegTestBlock(make([]byte, 32), 1)
+	tc.ReceiveFromCore <- giga.NodeEvent{Type: giga.Block, ID: hdr.Hash, Data: hex.EncodeToString(data)}
+	tc.ReceiveFromCore <- giga.NodeEvent{Type: giga.Block, ID: hdr.Hash}
+	select {
+	case tip := <-tips:
+		if tip != hdr.Hash {
+			t.Errorf("TestTipChaser: wrong tip: %v", tip)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("TestTipChaser: no tip notification")
+	}
+	msgs = events.take(giga.NET_NODE_DOWN, giga.NET_NODE_UP)
+	if len(msgs) != 1 || msgs[0].EventType != giga.NET_NODE_UP {
+		t.Fatalf("TestTipChaser: expecting NET_NODE_UP, got %v", msgs)
+	}
+	if e := msgs[0].Message.(giga.NetNodeEvent); e.Node != "core:22555" || !e.Up || e.BestHash != hdr.Hash {
+		t.Errorf("TestTipChaser: wrong NET_NODE_UP: %+v", e)
+	}
+	select {
+	case tip := <-tips:
+		t.Errorf("TestTipChaser: unexpected tip notification: %v", tip)
+	default:
+	}
+	if _, found := cache.get(hdr.Hash); !found {
+		t.Errorf("TestTipChaser: expecting the raw block in the cache")
+	}
+	if path := cache.pathToTip(doge.HexEncodeReversed(make([]byte, 32))); len(path) != 1 || path[0] != hdr.Hash {
+		t.Errorf("TestTipChaser: expecting the raw block as the tip: %v", path)
+	}
+}
@@ -0,0 +1,119 @@
+package chaintracker
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+
+	giga "github.com/dogecoinfoundation/gigawallet/pkg"
+	"github.com/dogecoinfoundation/gigawallet/pkg/doge"
+)
+
+/*
+ * Headers-first validation of blocks supplied by Core (VerifyBlocks config)
+ *
+ * Each block is checked before its UTXO changes are applied: the header must
+ * hash to the block hash Core gave us, carry valid proof-of-work (including
+ * AuxPoW for merge-mined blocks), link to the previous verified header, have
+ * the difficulty required by DigiShield retargeting (from the two previous
+ * verified headers), and commit to the block's transactions via the merkle root.
+ *
+ * Verified headers are stored in the same DB transaction as the chainstate,
+ * and removed on rollback, so the header chain always matches the chainstate.
+ * The first block verified (e.g. after enabling VerifyBlocks) anchors the chain;
+ * difficulty is checked once there are two verified headers before a block.
+ */
+
+// verifyBlock checks a decoded block against its header from Core and the
+// two previous verified headers (nil if there aren't any)
+func (c *ChainFollower) verifyBlock(block *doge.Block, header giga.RpcBlockHeader, prev *giga.VerifiedHeader, prevPrev *giga.VerifiedHeader) (giga.VerifiedHeader, error) {
+	raw := doge.EncodeBlockHeader(block.Header)
+	hash := doge.BlockHashHex(raw)
+	if hash != header.Hash {
+		return giga.VerifiedHeader{}, fmt.Errorf("block %v at height %v: header hashes to %v", header.Hash, header.Height, hash)
+	}
+	err := doge.CheckBlockProofOfWork(block.Header, raw, block.AuxPoW, c.chain)
+	if err != nil {
+		return giga.VerifiedHeader{}, fmt.Errorf("block %v at height %v: %v", header.Hash, header.Height, err)
+	}
+	if prev != nil {
+		prevHash := doge.HexEncodeReversed(block.Header.PrevBlock)
+		if prev.Height != header.Height-1 || prev.Hash != prevHash {
+			return giga.VerifiedHeader{}, fmt.Errorf("block %v at height %v: does not follow verified block %v at height %v", header.Hash, header.Height, prev.Hash, prev.Height)
+		}
+		err = c.checkDifficulty(block.Header, header, prev, prevPrev)
+		if err != nil {
+			return giga.VerifiedHeader{}, err
+		}
+	}
+	root, mutated, err := doge.BlockMerkleRoot(block)
+	if err != nil {
+		return giga.VerifiedHeader{}, fmt.Errorf("block %v at height %v: %v", header.Hash, header.Height, err)
+	}
+	if mutated || !bytes.Equal(root, block.Header.MerkleRoot) {
+		return giga.VerifiedHeader{}, fmt.Errorf("block %v at height %v: merkle root does not match transactions", header.Hash, header.Height)
+	}
+	return giga.VerifiedHeader{Height: header.Height, Hash: header.Hash, Header: doge.HexEncode(raw)}, nil
+}
+
+// checkDifficulty checks the block's Bits against DigiShield retargeting
+// (as the P2P header chain does) given the two previous verified headers.
+func (c *ChainFollower) checkDifficulty(hdr doge.BlockHeader, header giga.RpcBlockHeader, prev *giga.VerifiedHeader, prevPrev *giga.VerifiedHeader) error {
+	retarget := c.chain.PowNoRetargeting || (c.chain.DigiShieldHeight != 0 && header.Height > c.chain.DigiShieldHeight)
+	if !retarget || prevPrev == nil {
+		return nil // before DigiShield, or not enough verified headers.
+	}
+	prevHdr, err := decodeVerifiedHeader(prev)
+	if err != nil {
+		return err
+	}
+	prevPrevHdr, err := decodeVerifiedHeader(prevPrev)
+	if err != nil {
+		return err
+	}
+	bits := doge.DigiShieldWorkRequired(header.Height, hdr.Timestamp, prevHdr, prevPrevHdr, c.chain)
+	if hdr.Bits != bits {
+		return fmt.Errorf("block %v at height %v: wrong difficulty: %08x (expecting %08x)", header.Hash, header.Height, hdr.Bits, bits)
+	}
+	return nil
+}
+
+func decodeVerifiedHeader(vh *giga.VerifiedHeader) (doge.BlockHeader, error) {
+	raw, err := doge.HexDecode(vh.Header)
+	if err != nil || len(raw) != 80 {
+		return doge.BlockHeader{}, fmt.Errorf("verified header %v at height %v: invalid header: %v", vh.Hash, vh.Height, vh.Header)
+	}
+	return doge.DecodeBlockHeader(raw), nil
+}
+
+// previousVerifiedHeaders returns the two verified headers before `height`
+// (see previousVerifiedHeader) either of which can be nil.
+func (c *ChainFollower) previousVerifiedHeaders(height int64, batch []giga.VerifiedHeader) (prev *giga.VerifiedHeader, prevPrev *giga.VerifiedHeader) {
+	prev = c.previousVerifiedHeader(height, batch)
+	if prev != nil {
+		if len(batch) > 0 {
+			batch = batch[:len(batch)-1]
+		}
+		prevPrev = c.previousVerifiedHeader(height-1, batch)
+	}
+	return prev, prevPrev
+}
+
+// previousVerifiedHeader returns the verified header before `height`: the last
+// one verified in this batch, otherwise from the store (nil if not found)
+func (c *ChainFollower) previousVerifiedHeader(height int64, batch []giga.VerifiedHeader) *giga.VerifiedHeader {
+	if len(batch) > 0 {
+		return &batch[len(batch)-1]
+	}
+	for {
+		hdr, err := c.store.GetVerifiedHeader(height - 1)
+		if err == nil {
+			return &hdr
+		}
+		if giga.IsNotFoundError(err) {
+			return nil // this block anchors the header chain.
+		}
+		log.Println("ChainFollower: error retrieving verified header (will retry):", err)
+		c.sleepForRetry(err, 0)
+	}
+}
@@ -0,0 +1,264 @@
+package giga
+
+import "sort"
+
+// Coin Selection strategies, chosen per-account (Account.CoinSelection)
+const (
+	CoinSelectDefault        = ""                 // spend UTXOs in the order the UTXOSource returns them
+	CoinSelectLargestFirst   = "largest-first"    // spend the largest UTXOs first (fewest inputs)
+	CoinSelectOldestFirst    = "oldest-first"     // spend the oldest UTXOs first (by spendable height)
+	CoinSelectBranchAndBound = "branch-and-bound" // search for a combination that needs no change output
+	CoinSelectPrivacy        = "privacy"          // spend whole addresses, linking as few addresses as possible
+)
+
+// Maximum number of combinations BranchAndBoundSelector will try
+// before falling back to LargestFirstSelector (same as Core)
+const BNB_MAX_TRIES = 100000
+
+// SelectionTarget returns the total value the selected UTXOs must cover
+// when `numInputs` UTXOs are selected (the fee grows with each input.)
+type SelectionTarget func(numInputs int) CoinAmount
+
+// CoinSelector chooses which UTXOs to spend in a new transaction.
+type CoinSelector interface {
+	// SelectUTXOs returns UTXOs from the source (excluding those already taken)
+	// whose total value covers target(len(selected)); it must not modify `taken`,
+	// the caller adds the selected UTXOs to `taken`.
+	// Returns InsufficientFunds if the source cannot cover the target.
+	SelectUTXOs(source UTXOSource, taken UTXOSet, target SelectionTarget) ([]UTXO, error)
+}
+
+// NewCoinSelector returns the CoinSelector for a Coin Selection strategy name.
+func NewCoinSelector(strategy string) (CoinSelector, error) {
+	switch strategy {
+	case CoinSelectDefault:
+		return SourceOrderSelector{}, nil
+	case CoinSelectLargestFirst:
+		return LargestFirstSelector{}, nil
+	case CoinSelectOldestFirst:
+		return OldestFirstSelector{}, nil
+	case CoinSelectBranchAndBound:
+		return BranchAndBoundSelector{}, nil
+	case CoinSelectPrivacy:
+		return PrivacySelector{}, nil
+	default:
+		return nil, NewErr(BadRequest, "unknown coin selection strategy: '%s' (expecting one of: %s, %s, %s, %s)", strategy,
+			CoinSelectLargestFirst, CoinSelectOldestFirst, CoinSelectBranchAndBound, CoinSelectPrivacy)
+	}
+}
+
+// SourceOrderSelector spends UTXOs in the order the UTXOSource returns them.
+type SourceOrderSelector struct{}
+
+func (SourceOrderSelector) SelectUTXOs(source UTXOSource, taken UTXOSet, target SelectionTarget) ([]UTXO, error) {
+	selected := []UTXO{}
+	total := ZeroCoins
+	excluded := takenOrSelected{taken, NewUTXOSet()}
+	for total.LessThan(target(len(selected))) {
+		utxo, err := source.NextUnspentUTXO(excluded)
+		if err != nil {
+			return nil, err
+		}
+		excluded.selected.Add(utxo.TxID, utxo.VOut)
+		selected = append(selected, utxo)
+		total = total.Add(utxo.Value)
+	}
+	return selected, nil
+}
+
+// LargestFirstSelector spends the largest UTXOs first,
+// which minimises the number of inputs (and so the fee.)
+type LargestFirstSelector struct{}
+
+func (LargestFirstSelector) SelectUTXOs(source UTXOSource, taken UTXOSet, target SelectionTarget) ([]UTXO, error) {
+	available, err := source.AllUnspentUTXOs(taken)
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(available, func(i, j int) bool {
+		return available[i].Value.GreaterThan(available[j].Value)
+	})
+	return selectInOrder(available, target)
+}
+
+// OldestFirstSelector spends the oldest UTXOs first (lowest spendable height)
+// which avoids leaving old, small UTXOs in the account forever.
+// Unconfirmed UTXOs (e.g. change) are spent last.
+type OldestFirstSelector struct{}
+
+func (OldestFirstSelector) SelectUTXOs(source UTXOSource, taken UTXOSet, target SelectionTarget) ([]UTXO, error) {
+	available, err := source.AllUnspentUTXOs(taken)
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(available, func(i, j int) bool {
+		hi, hj := available[i].BlockHeight, available[j].BlockHeight
+		if hi == 0 || hj == 0 {
+			return hj == 0 && hi != 0 // zero (unconfirmed) sorts last
+		}
+		return hi < hj
+	})
+	return selectInOrder(available, target)
+}
+
+// BranchAndBoundSelector searches for a combination of UTXOs that covers
+// the target with an excess below the Dust Limit, so the transaction needs
+// no change output (the excess is paid to the fee.) If no such combination
+// is found within BNB_MAX_TRIES, it falls back to LargestFirstSelector.
+type BranchAndBoundSelector struct{}
+
+func (BranchAndBoundSelector) SelectUTXOs(source UTXOSource, taken UTXOSet, target SelectionTarget) ([]UTXO, error) {
+	available, err := source.AllUnspentUTXOs(taken)
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(available, func(i, j int) bool {
+		return available[i].Value.GreaterThan(available[j].Value)
+	})
+	// Search in whole Koinu to avoid decimal arithmetic in the inner loop.
+	values := make([]int64, len(available))
+	remaining := int64(0)
+	for i, utxo := range available {
+		values[i] = toKoinu(utxo.Value)
+		remaining += values[i]
+	}
+	targets := make([]int64, len(available)+1)
+	for n := range targets {
+		targets[n] = toKoinu(target(n))
+	}
+	var best []int
+	var bestExcess int64
+	chosen := []int{}
+	tries := 0
+	var search func(depth int, current int64, remaining int64)
+	search = func(depth int, current int64, remaining int64) {
+		tries++
+		if tries > BNB_MAX_TRIES || (best != nil && bestExcess == 0) {
+			return
+		}
+		need := targets[len(chosen)]
+		if current >= need {
+			// Adding more inputs only increases the excess.
+			excess := current - need
+			if excess < TxnDustLimit_64 && (best == nil || excess < bestExcess) {
+				best = append([]int{}, chosen...)
+				bestExcess = excess
+			}
+			return
+		}
+		if depth >= len(values) || current+remaining < need {
+			return // cannot reach the target on this branch.
+		}
+		// Try including this UTXO, then try without it.
+		chosen = append(chosen, depth)
+		search(depth+1, current+values[depth], remaining-values[depth])
+		chosen = chosen[:len(chosen)-1]
+		search(depth+1, current, remaining-values[depth])
+	}
+	search(0, 0, remaining)
+	if best == nil {
+		return selectInOrder(available, target)
+	}
+	selected := make([]UTXO, 0, len(best))
+	for _, i := range best {
+		selected = append(selected, available[i])
+	}
+	return selected, nil
+}
+
+// PrivacySelector avoids linking addresses together on-chain: it spends
+// all UTXOs paid to the same address together (so the address is not
+// seen again later) and links as few distinct addresses as possible.
+type PrivacySelector struct{}
+
+func (PrivacySelector) SelectUTXOs(source UTXOSource, taken UTXOSet, target SelectionTarget) ([]UTXO, error) {
+	available, err := source.AllUnspentUTXOs(taken)
+	if err != nil {
+		return nil, err
+	}
+	// Group UTXOs by the address they were paid to.
+	type group struct {
+		utxos []UTXO
+		total CoinAmount
+	}
+	groups := []*group{}
+	byAddress := map[Address]*group{}
+	for _, utxo := range available {
+		g, found := byAddress[utxo.ScriptAddress]
+		if !found {
+			g = &group{total: ZeroCoins}
+			byAddress[utxo.ScriptAddress] = g
+			groups = append(groups, g)
+		}
+		g.utxos = append(g.utxos, utxo)
+		g.total = g.total.Add(utxo.Value)
+	}
+	// Prefer the smallest single address that covers the target.
+	var single *group
+	for _, g := range groups {
+		if g.total.GreaterThanOrEqual(target(len(g.utxos))) {
+			if single == nil || g.total.LessThan(single.total) {
+				single = g
+			}
+		}
+	}
+	if single != nil {
+		return single.utxos, nil
+	}
+	// Otherwise spend whole addresses, largest first.
+	sort.SliceStable(groups, func(i, j int) bool {
+		return groups[i].total.GreaterThan(groups[j].total)
+	})
+	selected := []UTXO{}
+	total := ZeroCoins
+	for _, g := range groups {
+		if total.GreaterThanOrEqual(target(len(selected))) {
+			break
+		}
+		for _, utxo := range g.utxos {
+			selected = append(selected, utxo)
+			total = total.Add(utxo.Value)
+		}
+	}
+	if total.LessThan(target(len(selected))) {
+		return nil, NewErr(InsufficientFunds, "not enough funds in account")
+	}
+	return selected, nil
+}
+
+// takenOrSelected excludes UTXOs already taken and those selected so far,
+// without adding the selected UTXOs to `taken` (see CoinSelector)
+type takenOrSelected struct {
+	taken    UTXOSet
+	selected UTXOMapSet
+}
+
+func (s takenOrSelected) Add(txID string, vOut int) {
+	s.selected.Add(txID, vOut)
+}
+
+func (s takenOrSelected) Includes(txID string, vOut int) bool {
+	return s.taken.Includes(txID, vOut) || s.selected.Includes(txID, vOut)
+}
+
+// Select UTXOs in the order given until they cover the target.
+func selectInOrder(available []UTXO, target SelectionTarget) ([]UTXO, error) {
+	selected := []UTXO{}
+	total := ZeroCoins
+	for _, utxo := range available {
+		if total.GreaterThanOrEqual(target(len(selected))) {
+			break
+		}
+		selected = append(selected, utxo)
+		total = total.Add(utxo.Value)
+	}
+	if total.LessThan(target(len(selected))) {
+		return nil, NewErr(InsufficientFunds, "not enough funds in account")
+	}
+	return selected, nil
+}
+
+// Convert a CoinAmount to whole Koinu (rounded up, so targets are always met)
+func toKoinu(amount CoinAmount) int64 {
+	return amount.Shift(NumKoinuDigits).Ceil().IntPart()
+}
@@ -1,5 +1,11 @@
 package giga
 
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
 type Config struct {
 	Gigawallet GigawalletConfig
 	WebAPI     WebAPIConfig
@@ -8,6 +14,16 @@ type Config struct {
 	Callbacks  map[string]CallbackConfig
 	MQTT       MQTTConfig
 
+	// UTXO consolidation service (see services.UTXOConsolidator)
+	Consolidation ConsolidationConfig
+
+	// Batched payout service (see services.PayoutBatcher)
+	Batching BatchingConfig
+
+	// Scheduled payment service (see services.PaymentScheduler)
+	Scheduling    SchedulingConfig
+	ChainFollower ChainFollowerConfig
+
 	// Map of available networks, config.Core will be set to
 	// the one specified by config.Gigawallet.Network
 	Dogecoind map[string]NodeConfig
@@ -38,6 +54,12 @@ type GigawalletConfig struct {
 	// key for which Dogecoind struct to use, ie: mainnet, testnet
 	Network string
 
+	// Additional Dogecoind keys to run side by side with Network in
+	// the same process, ie: ["testnet"] Each network has its own chain
+	// tracker, L1 and store (see NodeConfig.DBFile) and accounts are
+	// bound to a network when created (see AccountCreateRequest.Network)
+	Networks []string
+
 	// Default number of confirmations needed to mark an invoice
 	// as paid, this can be overridden per invoice using the create
 	// invoice API, default 6
@@ -46,6 +68,8 @@ type GigawalletConfig struct {
 	// Default number of confirmations after a fork before an invoice
 	// is marked as a double-spend and warnings are thrown. This only
 	// occurs if a confirmation has already been issued. Default 6
+	// (i.e. confirmed transactions rolled back by a reorg that are not
+	// re-mined within this many blocks: INV_DOUBLE_SPEND, 0 disables)
 	RejectionsNeeded int
 }
 
@@ -56,6 +80,74 @@ type NodeConfig struct {
 	RPCPort int
 	RPCPass string
 	RPCUser string
+
+	// Subscribe to ZMQ rawblock (Core: -zmqpubrawblock) instead of hashblock
+	// notifications, so new blocks at the tip are applied without fetching
+	// them over RPC (RPC is used when a block is missed) default false
+	ZMQRawBlock bool
+
+	// Dogecoin P2P peers (host or host:port) to follow the chain from,
+	// instead of fetching blocks over RPC; RPC is still used for other
+	// requests, e.g. sending transactions (see p2p.L1P2P)
+	P2PPeers []string
+
+	// Optional block to start the P2P header chain from, instead of
+	// the Genesis block (avoids downloading and keeping every header
+	// in memory) must be a main-chain block below the ChainFollower's
+	// current block.
+	P2PStartHeight int64
+	P2PStartHash   string
+
+	// Additional Core nodes for this network (RPCHost, RPCPort, RPCUser,
+	// RPCPass) used for failover: requests go to the healthiest node and
+	// transactions are sent to all nodes (see core.L1Failover)
+	Nodes []NodeConfig
+
+	// Store DB file for this network when running several networks
+	// (see GigawalletConfig.Networks) default: Store.DBFile for the
+	// primary Network, Store.DBFile with the network name appended
+	// for the others, ie: gigawallet-testnet.db (required for Postgres)
+	DBFile string
+}
+
+// AllNetworks returns Gigawallet.Network followed by any additional
+// Gigawallet.Networks, without duplicates.
+func (c Config) AllNetworks() []string {
+	networks := []string{c.Gigawallet.Network}
+	for _, n := range c.Gigawallet.Networks {
+		dup := false
+		for _, have := range networks {
+			dup = dup || have == n
+		}
+		if !dup {
+			networks = append(networks, n)
+		}
+	}
+	return networks
+}
+
+// ForNetwork returns a copy of the config for one of the configured
+// networks, with Gigawallet.Network, Core and Store.DBFile set from the
+// Dogecoind entry for that network.
+func (c Config) ForNetwork(network string) (Config, error) {
+	core, found := c.Dogecoind[network]
+	if !found || (len(core.Host) < 1 && len(core.P2PPeers) < 1) {
+		return Config{}, fmt.Errorf("bad config: missing network: %s", network)
+	}
+	if core.DBFile == "" {
+		core.DBFile = c.Store.DBFile
+		if network != c.Gigawallet.Network && strings.Contains(c.Store.DBFile, "://") {
+			return Config{}, fmt.Errorf("bad config: set dogecoind.%s.dbfile to a separate database for this network", network)
+		}
+		if network != c.Gigawallet.Network && c.Store.DBFile != ":memory:" {
+			ext := filepath.Ext(c.Store.DBFile)
+			core.DBFile = strings.TrimSuffix(c.Store.DBFile, ext) + "-" + network + ext
+		}
+	}
+	c.Gigawallet.Network = network
+	c.Core = core
+	c.Store.DBFile = core.DBFile
+	return c, nil
 }
 
 type WebAPIConfig struct {
@@ -64,6 +156,9 @@ type WebAPIConfig struct {
 	AdminBind        string // optional interface IP address
 	AdminBearerToken string // optional bearer token for authenticating admin API requests
 
+	// Payment approvers: approver identity -> bearer token (see Account.ApprovalsRequired)
+	Approvers map[string]string
+
 	// Public API
 	PubPort       string
 	PubBind       string // optional interface IP address
@@ -74,6 +169,83 @@ type StoreConfig struct {
 	DBFile string
 }
 
+type ConsolidationConfig struct {
+	// Enable the UTXO consolidation service, default false
+	Enabled bool
+
+	// Consolidate an account when it holds more than this many
+	// unspent UTXOs, default 100
+	UTXOThreshold int
+
+	// Maximum number of UTXOs (smallest first) to merge in a
+	// single consolidation transaction, default 200
+	MaxInputs int
+
+	// Only consolidate while the fee estimate from Core is at or
+	// below this fee in DOGE per KB (low-fee window), default 0.01
+	MaxFeePerKB float64
+
+	// Seconds between checks for accounts to consolidate, default 600
+	Interval int
+}
+
+type BatchingConfig struct {
+	// Enable the batched payout service, which pays queued
+	// withdrawals (see /account/:foreignID/withdraw) default false
+	Enabled bool
+
+	// Seconds between checks for pending withdrawals, default 30
+	Interval int
+
+	// Default maximum number of withdrawals combined into one
+	// transaction, this can be overridden per account, default 100
+	BatchSize int
+
+	// Default seconds to collect withdrawals before sending a batch
+	// (a full batch is sent immediately) this can be overridden per
+	// account, default 300
+	BatchInterval int
+}
+
+type SchedulingConfig struct {
+	// Enable the scheduled payment service, which pays scheduled
+	// payments (see /account/:foreignID/schedule) default false
+	Enabled bool
+
+	// Seconds between checks for scheduled payments that are due, default 30
+	Interval int
+
+	// Seconds to wait before retrying a payment that could not be
+	// paid (e.g. not enough funds yet) default 600
+	RetryInterval int
+
+	// Attempts to pay before a scheduled payment fails, zero for
+	// no limit, default 144 (one day at the default RetryInterval)
+	MaxAttempts int
+}
+
+type ChainFollowerConfig struct {
+	// Number of workers that download and decode upcoming blocks
+	// while the ChainFollower commits the current batch, default 4
+	// (zero fetches blocks one at a time)
+	PrefetchWorkers int
+
+	// Memory limit for blocks downloaded ahead of the ChainFollower,
+	// in megabytes, default 64
+	PrefetchMemoryMB int
+
+	// Verify proof-of-work (including AuxPoW) and merkle roots of blocks
+	// from Core before applying them, keeping a header chain in the store.
+	// The ChainFollower will not advance past a block that fails (SYS_ERR)
+	VerifyBlocks bool
+
+	// Stop following the chain at a block that cannot be decoded (retrying
+	// periodically) instead of skipping it. Skipped blocks are recorded and
+	// account balances are marked unreliable until they have been re-processed
+	// (see /admin/skippedblocks) default false
+	HaltOnSkippedBlock bool
+}
+
 type LoggersConfig struct {
 	Path  string
 	Types []string
@@ -122,6 +294,29 @@ func TestConfig() Config {
 		MQTT: MQTTConfig{
 			Queues: make(map[string]MQTTQueueConfig),
 		},
+		Consolidation: ConsolidationConfig{
+			Enabled:       false,
+			UTXOThreshold: 100,
+			MaxInputs:     200,
+			MaxFeePerKB:   0.01,
+			Interval:      600,
+		},
+		Batching: BatchingConfig{
+			Enabled:       false,
+			Interval:      30,
+			BatchSize:     100,
+			BatchInterval: 300,
+		},
+		Scheduling: SchedulingConfig{
+			Enabled:       false,
+			Interval:      30,
+			RetryInterval: 600,
+			MaxAttempts:   144,
+		},
+		ChainFollower: ChainFollowerConfig{
+			PrefetchWorkers:  4,
+			PrefetchMemoryMB: 64,
+		},
 		Loggers:   make(map[string]LoggersConfig),
 		Dogecoind: make(map[string]NodeConfig),
 		Core:      NodeConfig{},
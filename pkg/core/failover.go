@@ -0,0 +1,365 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	giga "github.com/dogecoinfoundation/gigawallet/pkg"
+)
+
+const (
+	HEALTH_CHECK_INTERVAL = 15 * time.Second
+	NODE_DOWN_FAILURES    = 3   // consecutive failed requests before a node is marked down.
+	LATENCY_SMOOTHING     = 0.2 // weight of each new sample in the average latency.
+)
+
+// interface guard ensures L1Failover implements giga.L1
+var _ giga.L1 = &L1Failover{}
+
+/*
+ * L1Failover spreads requests over several Core nodes on the same network.
+ *
+ * A health check polls every node (getblockchaininfo) to track its height,
+ * best block and latency. Requests go to the healthiest node: nodes that
+ * are up, then fewest recent errors, highest block, lowest latency. If a
+ * request fails, the next node is tried, so the ChainFollower keeps going
+ * while any node is responding (an error from Core, e.g. a block that a
+ * lagging node doesn't have yet, is also tried on the next node but does not
+ * count against the node) Transactions are sent to every node.
+ *
+ * Status changes are sent on the bus: NET_NODE_DOWN, NET_NODE_UP, and
+ * NET_NODE_DISAGREE when nodes at the same height have different best blocks
+ * for two health checks in a row (they briefly disagree while a new block
+ * propagates)
+ */
+type L1Failover struct {
+	bus      giga.MessageBus
+	nodes    []*failoverNode
+	mu       sync.Mutex      // protects node status, disagree, reported.
+	disagree map[string]bool // disagreements seen at the last health check
+	reported map[string]bool // disagreements reported (avoid repeats)
+}
+
+type failoverNode struct {
+	name     string
+	l1       giga.L1
+	up       bool
+	height   int64
+	bestHash string
+	latency  float64 // milliseconds (moving average)
+	errors   int64   // failed requests since start
+	failing  int     // consecutive failed requests
+	lastErr  string
+}
+
+// NewL1Failover returns a giga.L1 that uses config.Core and each of config.Core.Nodes
+func NewL1Failover(config giga.Config, bus giga.MessageBus) (*L1Failover, error) {
+	nodes := append([]giga.NodeConfig{config.Core}, config.Core.Nodes...)
+	names := make([]string, 0, len(nodes))
+	l1s := make([]giga.L1, 0, len(nodes))
+	for _, node := range nodes {
+		if node.RPCHost == "" {
+			return nil, errors.New("NewL1Failover: missing RPCHost in Core node config")
+		}
+		names = append(names, fmt.Sprintf("%s:%d", node.RPCHost, node.RPCPort))
+		l1s = append(l1s, newCoreRPC(node))
+	}
+	return newL1Failover(names, l1s, bus), nil
+}
+
+func newL1Failover(names []string, l1s []giga.L1, bus giga.MessageBus) *L1Failover {
+	l := &L1Failover{bus: bus, disagree: make(map[string]bool), reported: make(map[string]bool)}
+	for i, name := range names {
+		// Nodes start up until a health check or requests fail.
+		l.nodes = append(l.nodes, &failoverNode{name: name, l1: l1s[i], up: true})
+	}
+	return l
+}
+
+func (l *L1Failover) Run(started, stopped chan bool, stop chan context.Context) error {
+	go func() {
+		started <- true
+		l.checkHealth()
+		for {
+			select {
+			case <-stop:
+				close(stopped)
+				return
+			case <-time.After(HEALTH_CHECK_INTERVAL):
+				l.checkHealth()
+			}
+		}
+	}()
+	return nil
+}
+
+// Status returns the current status of each node.
+func (l *L1Failover) Status() []giga.NetNodeEvent {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	result := make([]giga.NetNodeEvent, 0, len(l.nodes))
+	for _, n := range l.nodes {
+		result = append(result, n.status())
+	}
+	return result
+}
+
+func (n *failoverNode) status() giga.NetNodeEvent {
+	return giga.NetNodeEvent{
+		Node:      n.name,
+		Up:        n.up,
+		Height:    n.height,
+		BestHash:  n.bestHash,
+		LatencyMS: n.latency,
+		Errors:    n.errors,
+		Error:     n.lastErr,
+	}
+}
+
+// checkHealth polls every node concurrently, then looks for disagreements.
+func (l *L1Failover) checkHealth() {
+	var wg sync.WaitGroup
+	for _, n := range l.nodes {
+		wg.Add(1)
+		go func(n *failoverNode) {
+			defer wg.Done()
+			start := time.Now()
+			info, err := n.l1.GetBlockchainInfo()
+			l.record(n, start, err)
+			if err == nil {
+				l.mu.Lock()
+				n.height, n.bestHash = info.Blocks, info.BestBlockHash
+				if !n.up {
+					n.up = true
+					log.Println("L1Failover: node is up:", n.name)
+					l.bus.Send(giga.NET_NODE_UP, n.status())
+				}
+				l.mu.Unlock()
+			} else if l.markDown(n) {
+				log.Println("L1Failover: node is down:", n.name, err)
+			}
+		}(n)
+	}
+	wg.Wait()
+	l.checkDisagreement()
+}
+
+// checkDisagreement reports nodes at the same height with different best blocks,
+// if they also disagreed at the last health check.
+func (l *L1Failover) checkDisagreement() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	byHeight := make(map[int64]map[string]string)
+	for _, n := range l.nodes {
+		if n.up && n.bestHash != "" {
+			if byHeight[n.height] == nil {
+				byHeight[n.height] = make(map[string]string)
+			}
+			byHeight[n.height][n.name] = n.bestHash
+		}
+	}
+	found := make(map[string]bool)
+	for height, hashes := range byHeight {
+		seen := ""
+		for _, hash := range hashes {
+			if seen != "" && hash != seen {
+				key := fmt.Sprint(height, hashes)
+				found[key] = true
+				if l.disagree[key] && !l.reported[key] {
+					l.reported[key] = true
+					log.Println("L1Failover: nodes disagree on the best block at height", height, hashes)
+					l.bus.Send(giga.NET_NODE_DISAGREE, giga.NetNodeDisagreeEvent{Height: height, Hashes: hashes})
+				}
+				break
+			}
+			seen = hash
+		}
+	}
+	for key := range l.reported {
+		if !found[key] {
+			delete(l.reported, key) // resolved.
+		}
+	}
+	l.disagree = found
+}
+
+// record updates a node's latency or error counts after a request.
+// Errors returned by Core (CoreError) do not count against the node.
+func (l *L1Failover) record(n *failoverNode, start time.Time, err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var coreErr CoreError
+	if err != nil && !errors.As(err, &coreErr) {
+		n.errors++
+		n.failing++
+		n.lastErr = err.Error()
+		return
+	}
+	ms := float64(time.Since(start).Microseconds()) / 1000
+	if n.latency == 0 {
+		n.latency = ms
+	} else {
+		n.latency += (ms - n.latency) * LATENCY_SMOOTHING
+	}
+	n.failing = 0
+	n.lastErr = ""
+}
+
+// markDown marks a node down (if it was up) and sends NET_NODE_DOWN.
+func (l *L1Failover) markDown(n *failoverNode) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if !n.up {
+		return false
+	}
+	n.up = false
+	l.bus.Send(giga.NET_NODE_DOWN, n.status())
+	return true
+}
+
+// order returns the nodes, healthiest first.
+func (l *L1Failover) order() []*failoverNode {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	nodes := append([]*failoverNode(nil), l.nodes...)
+	sort.SliceStable(nodes, func(i, j int) bool {
+		a, b := nodes[i], nodes[j]
+		if a.up != b.up {
+			return a.up
+		}
+		if a.failing != b.failing {
+			return a.failing < b.failing
+		}
+		if a.height != b.height {
+			return a.height > b.height
+		}
+		return a.latency < b.latency
+	})
+	return nodes
+}
+
+// failover makes a request to the healthiest node, trying the
+// next node if it fails. Returns the last error if all nodes fail.
+func failover[T any](l *L1Failover, fn func(giga.L1) (T, error)) (result T, err error) {
+	for _, n := range l.order() {
+		start := time.Now()
+		result, err = fn(n.l1)
+		l.record(n, start, err)
+		if err == nil {
+			return result, nil
+		}
+		l.mu.Lock()
+		failing := n.failing
+		l.mu.Unlock()
+		if failing >= NODE_DOWN_FAILURES && l.markDown(n) {
+			log.Println("L1Failover: node is down:", n.name, err)
+		}
+	}
+	return result, err
+}
+
+func (l *L1Failover) MakeAddress(isTestNet bool) (giga.Address, giga.Privkey, error) {
+	return "", "", fmt.Errorf("not implemented")
+}
+
+func (l *L1Failover) MakeChildAddress(privkey giga.Privkey, addressIndex uint32, isInternal bool) (giga.Address, error) {
+	return "", fmt.Errorf("not implemented")
+}
+
+func (l *L1Failover) MakeTransaction(inputs []giga.UTXO, outputs []giga.NewTxOut, fee giga.CoinAmount, change giga.Address, private_key giga.Privkey) (giga.NewTxn, error) {
+	return giga.NewTxn{}, fmt.Errorf("not implemented")
+}
+
+func (l *L1Failover) SignTransaction(txnHex string, inputs []giga.UTXO, private_key giga.Privkey) (string, error) {
+	return "", fmt.Errorf("not implemented")
+}
+
+func (l *L1Failover) SignTransactionWithKey(txnHex string, inputs []giga.UTXO, ec_privkey_wif string) (string, error) {
+	return "", fmt.Errorf("not implemented")
+}
+
+func (l *L1Failover) DecodeTransaction(txnHex string) (giga.RawTxn, error) {
+	return failover(l, func(n giga.L1) (giga.RawTxn, error) { return n.DecodeTransaction(txnHex) })
+}
+
+func (l *L1Failover) GetBlock(blockHash string) (giga.RpcBlock, error) {
+	return failover(l, func(n giga.L1) (giga.RpcBlock, error) { return n.GetBlock(blockHash) })
+}
+
+func (l *L1Failover) GetBlockHex(blockHash string) (string, error) {
+	return failover(l, func(n giga.L1) (string, error) { return n.GetBlockHex(blockHash) })
+}
+
+func (l *L1Failover) GetBlockHeader(blockHash string) (giga.RpcBlockHeader, error) {
+	return failover(l, func(n giga.L1) (giga.RpcBlockHeader, error) { return n.GetBlockHeader(blockHash) })
+}
+
+func (l *L1Failover) GetRawBlockHeader(blockHash string) ([]byte, error) {
+	return failover(l, func(n giga.L1) ([]byte, error) { return n.GetRawBlockHeader(blockHash) })
+}
+
+func (l *L1Failover) GetBlockHash(height int64) (string, error) {
+	return failover(l, func(n giga.L1) (string, error) { return n.GetBlockHash(height) })
+}
+
+func (l *L1Failover) GetBestBlockHash() (string, error) {
+	return failover(l, func(n giga.L1) (string, error) { return n.GetBestBlockHash() })
+}
+
+func (l *L1Failover) GetBlockCount() (int64, error) {
+	return failover(l, func(n giga.L1) (int64, error) { return n.GetBlockCount() })
+}
+
+func (l *L1Failover) GetBlockchainInfo() (giga.RpcBlockchainInfo, error) {
+	return failover(l, func(n giga.L1) (giga.RpcBlockchainInfo, error) { return n.GetBlockchainInfo() })
+}
+
+func (l *L1Failover) GetTransaction(txnHash string) (giga.RawTxn, error) {
+	return failover(l, func(n giga.L1) (giga.RawTxn, error) { return n.GetTransaction(txnHash) })
+}
+
+func (l *L1Failover) ScanAddressUTXOs(address giga.Address) ([]giga.UTXO, error) {
+	return failover(l, func(n giga.L1) ([]giga.UTXO, error) { return n.ScanAddressUTXOs(address) })
+}
+
+func (l *L1Failover) EstimateFee(confirmTarget int) (giga.CoinAmount, error) {
+	return failover(l, func(n giga.L1) (giga.CoinAmount, error) { return n.EstimateFee(confirmTarget) })
+}
+
+// Send broadcasts the transaction to every node (concurrently) and
+// succeeds if any node accepts it.
+func (l *L1Failover) Send(txnHex string) (txid string, err error) {
+	type reply struct {
+		txid string
+		err  error
+	}
+	replies := make(chan reply, len(l.nodes))
+	for _, n := range l.nodes {
+		go func(n *failoverNode) {
+			start := time.Now()
+			id, err := n.l1.Send(txnHex)
+			l.record(n, start, err)
+			if err != nil {
+				log.Println("L1Failover: Send failed on node:", n.name, err)
+			}
+			replies <- reply{id, err}
+		}(n)
+	}
+	for range l.nodes {
+		r := <-replies
+		if r.err == nil && txid == "" {
+			txid = r.txid
+		} else if r.err != nil {
+			err = r.err
+		}
+	}
+	if txid != "" {
+		return txid, nil
+	}
+	return "", err
+}
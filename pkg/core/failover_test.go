@@ -0,0 +1,153 @@
+// These tests do not need libzmq: CGO_ENABLED=0 go test ./pkg/core
+
+package core
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	giga "github.com/dogecoinfoundation/gigawallet/pkg"
+)
+
+// fakeNode implements the parts of giga.L1 used by the tests.
+type fakeNode struct {
+	giga.L1
+	down   bool
+	height int64
+	best   string
+	sent   []string
+}
+
+var errNodeDown = errors.New("connection refused")
+
+func (f *fakeNode) GetBlockchainInfo() (giga.RpcBlockchainInfo, error) {
+	if f.down {
+		return giga.RpcBlockchainInfo{}, errNodeDown
+	}
+	return giga.RpcBlockchainInfo{Blocks: f.height, BestBlockHash: f.best}, nil
+}
+
+func (f *fakeNode) GetBestBlockHash() (string, error) {
+	if f.down {
+		return "", errNodeDown
+	}
+	return f.best, nil
+}
+
+func (f *fakeNode) GetBlockHash(height int64) (string, error) {
+	if f.down {
+		return "", errNodeDown
+	}
+	return "", CoreError{Message: `{"code":-8,"message":"Block height out of range"}`}
+}
+
+func (f *fakeNode) Send(txnHex string) (string, error) {
+	if f.down {
+		return "", errNodeDown
+	}
+	f.sent = append(f.sent, txnHex)
+	return "txid", nil
+}
+
+type eventCollector struct {
+	ch chan giga.Message
+}
+
+func (e eventCollector) GetChan() chan giga.Message { return e.ch }
+
+func (e eventCollector) next(t *testing.T) giga.Message {
+	select {
+	case msg := <-e.ch:
+		return msg
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for event")
+		return giga.Message{}
+	}
+}
+
+func (e eventCollector) none(t *testing.T) {
+	select {
+	case msg := <-e.ch:
+		t.Fatalf("expected no event, got %v %v", msg.EventType, msg.Message)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func startBus(t *testing.T) (giga.MessageBus, eventCollector) {
+	bus := giga.NewMessageBus()
+	events := eventCollector{ch: make(chan giga.Message, 100)}
+	bus.Register(events, giga.EVENT_NET("NET"))
+	started, stopped, stop := make(chan bool, 1), make(chan bool, 1), make(chan context.Context, 1)
+	bus.Run(started, stopped, stop)
+	<-started
+	t.Cleanup(func() {
+		stop <- context.Background()
+		<-stopped
+	})
+	return bus, events
+}
+
+func TestFailover(t *testing.T) {
+	bus, events := startBus(t)
+	a := &fakeNode{height: 10, best: "aa"}
+	b := &fakeNode{height: 10, best: "aa"}
+	l := newL1Failover([]string{"a", "b"}, []giga.L1{a, b}, bus)
+
+	// Fails over to the second node, which is then preferred.
+	a.down = true
+	hash, err := l.GetBestBlockHash()
+	if err != nil || hash != "aa" {
+		t.Fatalf("GetBestBlockHash: %v %v", hash, err)
+	}
+	if l.order()[0].name != "b" {
+		t.Fatalf("expected node b to be preferred")
+	}
+
+	// Health check marks the first node down.
+	l.checkHealth()
+	msg := events.next(t)
+	if msg.EventType != giga.NET_NODE_DOWN || msg.Message.(giga.NetNodeEvent).Node != "a" {
+		t.Fatalf("expected NET_NODE_DOWN for node a, got %v %v", msg.EventType, msg.Message)
+	}
+
+	// Errors from Core do not count against a node.
+	if _, err := l.GetBlockHash(100); err == nil {
+		t.Fatalf("expected an error from GetBlockHash")
+	}
+	if !l.Status()[1].Up || l.Status()[1].Errors != 0 {
+		t.Fatalf("Core errors counted against node b: %+v", l.Status()[1])
+	}
+
+	// Transactions are sent to every node that is responding.
+	txid, err := l.Send("0100")
+	if err != nil || txid != "txid" || len(b.sent) != 1 {
+		t.Fatalf("Send: %v %v %v", txid, err, b.sent)
+	}
+
+	// Health check brings the node back; a brief disagreement is not reported.
+	a.down = false
+	a.best = "bb"
+	l.checkHealth()
+	msg = events.next(t)
+	if msg.EventType != giga.NET_NODE_UP || msg.Message.(giga.NetNodeEvent).Node != "a" {
+		t.Fatalf("expected NET_NODE_UP for node a, got %v %v", msg.EventType, msg.Message)
+	}
+	b.best = "bb"
+	l.checkHealth()
+	events.none(t)
+
+	// Disagreement that persists to the next health check is reported once.
+	b.height, b.best = 11, "cc"
+	a.height, a.best = 11, "dd"
+	l.checkHealth()
+	events.none(t)
+	l.checkHealth()
+	msg = events.next(t)
+	if msg.EventType != giga.NET_NODE_DISAGREE || msg.Message.(giga.NetNodeDisagreeEvent).Height != 11 {
+		t.Fatalf("expected NET_NODE_DISAGREE at height 11, got %v %v", msg.EventType, msg.Message)
+	}
+	l.checkHealth()
+	events.none(t)
+}
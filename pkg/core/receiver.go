@@ -3,25 +3,43 @@ package core
 import (
 	"context"
 	"encoding/hex"
+	"errors"
 	"fmt"
-	"syscall"
+	"log"
 	"time"
 
 	giga "github.com/dogecoinfoundation/gigawallet/pkg"
-	"github.com/pebbe/zmq4"
+	"github.com/dogecoinfoundation/gigawallet/pkg/doge"
+)
+
+const (
+	ZMQ_TIMEOUT     = 5 * time.Minute // without notifications before NET_ZMQ_TIMEOUT.
+	RECONNECT_DELAY = 5 * time.Second // between attempts to reconnect after an error.
 )
 
 // interface guard ensures ZMQEmitter implements giga.NodeEmitter
 var _ giga.NodeEmitter = &CoreZMQReceiver{}
 
+// zmqSocket is the part of a ZMQ SUB socket used by CoreZMQReceiver (see dialZMQ)
+type zmqSocket interface {
+	RecvMessage() ([][]byte, error) // errZMQTimeout if no message for a while.
+	Close() error
+}
+
+var errZMQTimeout = errors.New("ZMQ: receive timed out")
+
 // CoreZMQReceiver receives ZMQ messages from Dogecoin Core.
 // CAUTION: the protocol is not authenticated!
 // CAUTION: subscribers MUST validate the received data since it may be out of date, incomplete or even invalid (fake)
 type CoreZMQReceiver struct {
-	bus         giga.MessageBus
-	sock        *zmq4.Socket
-	listeners   []chan<- giga.NodeEvent
-	nodeAddress string
+	bus            giga.MessageBus
+	sock           zmqSocket
+	dial           func(address string, topic string) (zmqSocket, error)
+	timeout        time.Duration // without notifications before NET_ZMQ_TIMEOUT
+	reconnectDelay time.Duration // between attempts to reconnect (RECONNECT_DELAY)
+	listeners      []chan<- giga.NodeEvent
+	nodeAddress    string
+	topic          string // "hashblock" or "rawblock" (see NodeConfig.ZMQRawBlock)
 }
 
 func (e *CoreZMQReceiver) Subscribe(ch chan<- giga.NodeEvent) {
@@ -29,84 +47,91 @@ func (e *CoreZMQReceiver) Subscribe(ch chan<- giga.NodeEvent) {
 }
 
 func NewCoreZMQReceiver(bus giga.MessageBus, config giga.Config) (*CoreZMQReceiver, error) {
+	topic := "hashblock"
+	if config.Core.ZMQRawBlock {
+		topic = "rawblock" // NodeEvent.Data is the raw block (hex)
+	}
 	return &CoreZMQReceiver{
-		bus:         bus,
-		listeners:   make([]chan<- giga.NodeEvent, 0, 10),
-		nodeAddress: fmt.Sprintf("tcp://%s:%d", config.Core.Host, config.Core.ZMQPort),
+		bus:            bus,
+		dial:           dialZMQ,
+		timeout:        ZMQ_TIMEOUT,
+		reconnectDelay: RECONNECT_DELAY,
+		listeners:      make([]chan<- giga.NodeEvent, 0, 10),
+		nodeAddress:    fmt.Sprintf("tcp://%s:%d", config.Core.Host, config.Core.ZMQPort),
+		topic:          topic,
 	}, nil
 }
 
 func (z *CoreZMQReceiver) Run(started, stopped chan bool, stop chan context.Context) error {
-	sock, err := zmq4.NewSocket(zmq4.SUB)
-	if err != nil {
-		return err
-	}
-	sock.SetRcvtimeo(2 * time.Second)
-	z.sock = sock
 	z.bus.Send(giga.SYS_STARTUP, fmt.Sprintf("ZMQ: connecting to: %s", z.nodeAddress))
-	err = sock.Connect(z.nodeAddress)
-	if err != nil {
-		return err
-	}
-	err = subscribeAll(sock, "hashblock") // "hashtx", "rawtx"
+	err := z.connect()
 	if err != nil {
 		return err
 	}
 	go func() {
 		started <- true
+		lastMsg := time.Now()
+		timedOut := false
 
 		for {
 			// Handle shutdown
 			select {
 			case <-stop:
-				sock.Close()
+				z.sock.Close()
 				close(stopped)
 				return
 			default:
 				// fall through to zmq recv
 			}
 
-			msg, err := z.sock.RecvMessageBytes(0)
+			msg, err := z.sock.RecvMessage()
 			if err != nil {
-				switch err := err.(type) {
-				case zmq4.Errno:
-					if err == zmq4.Errno(syscall.ETIMEDOUT) {
-						// handle timeouts by looping again
-						z.bus.Send(giga.SYS_ERR, "ZMQ: connection timeout")
-						continue
-					} else if err == zmq4.Errno(syscall.EAGAIN) {
-						continue
-					} else {
-						// handle other ZeroMQ error codes
-						z.bus.Send(giga.SYS_ERR, fmt.Sprintf("ZMQ err: %s", err))
-						continue
+				if err == errZMQTimeout {
+					// handle timeouts by looping again
+					silence := time.Since(lastMsg)
+					if silence >= z.timeout && !timedOut {
+						timedOut = true
+						log.Println("ZMQ: no notifications from Core for", silence.Round(time.Second))
+						z.bus.Send(giga.NET_ZMQ_TIMEOUT, giga.NetZMQTimeoutEvent{
+							Address: z.nodeAddress,
+							Seconds: int64(silence.Seconds()),
+						})
 					}
-				default:
-					// handle other Go errors
-					panic(fmt.Sprintf("zmq error: %v\n", err))
+					continue
 				}
+				// handle other errors by reconnecting with a new socket
+				z.bus.Send(giga.SYS_ERR, fmt.Sprintf("ZMQ err: %s (reconnecting)", err))
+				if !z.reconnect(stop) {
+					close(stopped)
+					return
+				}
+				continue
+			}
+			lastMsg = time.Now()
+			if timedOut {
+				timedOut = false
+				log.Println("ZMQ: receiving notifications from Core again")
+			}
+			if len(msg) < 2 {
+				log.Println("ZMQ: ignoring malformed message")
+				continue
 			}
 			tag := string(msg[0])
 			switch tag {
-			case "hashtx":
-				id := toHex(msg[1])
-				msg, err = z.sock.RecvMessageBytes(0)
-				if err != nil {
-					panic(fmt.Sprintf("zmq error: (hashtx %s): %v\n", id, err.Error()))
-				}
-				if string(msg[0]) != "rawtx" {
-					panic(fmt.Sprintf("zmq error: expected rawtx after hashtx %s", id))
-				}
-				rawtx := toHex(msg[1])
-				// fmt.Printf("ZMQ=> TX id=%s rawtx=%s\n", id, rawtx)
-				fmt.Printf("ZMQ=> TX id=%s\n", id)
-				z.notify(giga.TX, id, rawtx)
 			case "hashblock":
 				id := toHex(msg[1])
-				fmt.Printf("ZMQ=> BLOCK id=%s\n", id)
+				log.Println("ZMQ=> BLOCK id:", id)
 				z.notify(giga.Block, id, "")
+			case "rawblock":
+				if len(msg[1]) < 80 {
+					log.Println("ZMQ: ignoring truncated rawblock")
+					continue
+				}
+				id := doge.BlockHashHex(msg[1][:80])
+				log.Println("ZMQ=> BLOCK id:", id, "(raw)")
+				z.notify(giga.Block, id, toHex(msg[1]))
 			default:
-				fmt.Printf("ZMQ=> %s ??\n", tag)
+				log.Println("ZMQ: ignoring unknown message:", tag)
 			}
 		}
 
@@ -114,6 +139,35 @@ func (z *CoreZMQReceiver) Run(started, stopped chan bool, stop chan context.Cont
 	return nil
 }
 
+// connect creates a new socket and subscribes to block notifications.
+func (z *CoreZMQReceiver) connect() error {
+	sock, err := z.dial(z.nodeAddress, z.topic)
+	if err != nil {
+		return err
+	}
+	z.sock = sock
+	return nil
+}
+
+// reconnect closes the socket and connects again, retrying every
+// reconnectDelay until it succeeds. Returns false if stopped.
+func (z *CoreZMQReceiver) reconnect(stop chan context.Context) bool {
+	z.sock.Close()
+	for {
+		select {
+		case <-stop:
+			return false
+		case <-time.After(z.reconnectDelay):
+		}
+		err := z.connect()
+		if err == nil {
+			log.Println("ZMQ: reconnected to:", z.nodeAddress)
+			return true
+		}
+		log.Println("ZMQ: cannot reconnect (will retry):", err)
+	}
+}
+
 func (z *CoreZMQReceiver) notify(tag giga.NodeEventType, id string, data string) {
 	e := giga.NodeEvent{
 		Type: tag, ID: id, Data: data,
@@ -126,13 +180,3 @@ func (z *CoreZMQReceiver) notify(tag giga.NodeEventType, id string, data string)
 func toHex(b []byte) string {
 	return hex.EncodeToString(b)
 }
-
-func subscribeAll(sock *zmq4.Socket, topics ...string) error {
-	for _, topic := range topics {
-		err := sock.SetSubscribe(topic)
-		if err != nil {
-			return err
-		}
-	}
-	return nil
-}
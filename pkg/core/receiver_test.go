@@ -0,0 +1,158 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	giga "github.com/dogecoinfoundation/gigawallet/pkg"
+)
+
+// fakeZMQ is a zmqSocket that receives messages sent to `msgs`
+type fakeZMQ struct {
+	msgs   chan [][]byte
+	errs   chan error
+	mu     sync.Mutex
+	closed bool
+}
+
+func newFakeZMQ() *fakeZMQ {
+	return &fakeZMQ{msgs: make(chan [][]byte, 10), errs: make(chan error, 10)}
+}
+
+func (f *fakeZMQ) RecvMessage() ([][]byte, error) {
+	select {
+	case msg := <-f.msgs:
+		return msg, nil
+	case err := <-f.errs:
+		return nil, err
+	case <-time.After(10 * time.Millisecond):
+		return nil, errZMQTimeout
+	}
+}
+
+func (f *fakeZMQ) Close() error {
+	f.mu.Lock()
+	f.closed = true
+	f.mu.Unlock()
+	return nil
+}
+
+func (f *fakeZMQ) isClosed() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.closed
+}
+
+// A CoreZMQReceiver that connects to the sockets in `socks`, in order
+// (failing to connect where the socket is nil)
+func newReceiver(t *testing.T, bus giga.MessageBus, socks ...*fakeZMQ) *CoreZMQReceiver {
+	conf := giga.TestConfig()
+	conf.Core.Host, conf.Core.ZMQPort = "core", 28332
+	z, err := NewCoreZMQReceiver(bus, conf)
+	if err != nil {
+		t.Fatalf("NewCoreZMQReceiver: %v", err)
+	}
+	var mu sync.Mutex
+	z.dial = func(address string, topic string) (zmqSocket, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if address != "tcp://core:28332" || topic != "hashblock" || len(socks) == 0 {
+			t.Errorf("dial: unexpected: %v %v", address, topic)
+		}
+		sock := socks[0]
+		socks = socks[1:]
+		if sock == nil {
+			return nil, errors.New("connection refused")
+		}
+		return sock, nil
+	}
+	z.timeout = 100 * time.Millisecond
+	z.reconnectDelay = 10 * time.Millisecond
+	return z
+}
+
+// Run a CoreZMQReceiver that connects to the sockets in `socks`, in order.
+func startReceiver(t *testing.T, bus giga.MessageBus, socks ...*fakeZMQ) (*CoreZMQReceiver, chan giga.NodeEvent) {
+	z := newReceiver(t, bus, socks...)
+	notes := make(chan giga.NodeEvent, 10)
+	z.Subscribe(notes)
+	started, stopped, stop := make(chan bool, 1), make(chan bool), make(chan context.Context, 1)
+	err := z.Run(started, stopped, stop)
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	<-started
+	t.Cleanup(func() {
+		stop <- context.Background()
+		<-stopped
+	})
+	return z, notes
+}
+
+func TestZMQTimeout(t *testing.T) {
+	bus, events := startBus(t)
+	sock := newFakeZMQ()
+	_, notes := startReceiver(t, bus, sock)
+
+	// No notifications for a while: NET_ZMQ_TIMEOUT (once)
+	msg := events.next(t)
+	if msg.EventType != giga.NET_ZMQ_TIMEOUT {
+		t.Fatalf("expected NET_ZMQ_TIMEOUT, got %v %v", msg.EventType, msg.Message)
+	}
+	if e := msg.Message.(giga.NetZMQTimeoutEvent); e.Address != "tcp://core:28332" {
+		t.Errorf("wrong NET_ZMQ_TIMEOUT: %+v", e)
+	}
+	events.none(t)
+	events.none(t)
+
+	// A block notification resets the timeout.
+	sock.msgs <- [][]byte{[]byte("hashblock"), {0xab, 0xcd}}
+	select {
+	case e := <-notes:
+		if e.Type != giga.Block || e.ID != "abcd" {
+			t.Errorf("wrong NodeEvent: %+v", e)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("no NodeEvent for hashblock")
+	}
+	msg = events.next(t)
+	if msg.EventType != giga.NET_ZMQ_TIMEOUT {
+		t.Fatalf("expected NET_ZMQ_TIMEOUT, got %v %v", msg.EventType, msg.Message)
+	}
+}
+
+func TestZMQConnect(t *testing.T) {
+	bus, _ := startBus(t)
+	z := newReceiver(t, bus, nil)
+	started, stopped, stop := make(chan bool, 1), make(chan bool), make(chan context.Context, 1)
+	if err := z.Run(started, stopped, stop); err == nil {
+		t.Fatalf("Run: expected an error when Core is not listening")
+	}
+}
+
+func TestZMQReconnect(t *testing.T) {
+	bus, _ := startBus(t)
+	first, second := newFakeZMQ(), newFakeZMQ()
+	_, notes := startReceiver(t, bus, first, nil, second)
+
+	// A socket error: closes the socket, retries until it reconnects.
+	first.errs <- errors.New("connection reset")
+	second.msgs <- [][]byte{[]byte("hashblock"), {0xab, 0xcd}}
+	select {
+	case e := <-notes:
+		if e.Type != giga.Block || e.ID != "abcd" {
+			t.Errorf("wrong NodeEvent: %+v", e)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("no NodeEvent after reconnecting")
+	}
+	if !first.isClosed() {
+		t.Errorf("expected the first socket to be closed")
+	}
+	if second.isClosed() {
+		t.Errorf("expected the second socket to be open")
+	}
+}
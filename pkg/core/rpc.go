@@ -21,10 +21,12 @@ var _ giga.L1 = &L1CoreRPC{}
 
 // NewDogecoinCoreRPC returns a giga.L1 implementor that uses dogecoin-core's RPC
 func NewDogecoinCoreRPC(config giga.Config) (*L1CoreRPC, error) {
-	addr := fmt.Sprintf("http://%s:%d", config.Core.RPCHost, config.Core.RPCPort)
-	user := config.Core.RPCUser
-	pass := config.Core.RPCPass
-	return &L1CoreRPC{url: addr, user: user, pass: pass}, nil
+	return newCoreRPC(config.Core), nil
+}
+
+func newCoreRPC(node giga.NodeConfig) *L1CoreRPC {
+	addr := fmt.Sprintf("http://%s:%d", node.RPCHost, node.RPCPort)
+	return &L1CoreRPC{url: addr, user: node.RPCUser, pass: node.RPCPass}
 }
 
 type L1CoreRPC struct {
@@ -74,6 +76,11 @@ func (l *L1CoreRPC) request(method string, params []any, result any) error {
 	}
 	// check for error response
 	if res.StatusCode != 200 {
+		// Core also returns an error status for RPC errors (with a JSON body)
+		var rpcres rpcResponse
+		if json.Unmarshal(res_bytes, &rpcres) == nil && rpcres.Error != nil {
+			return newCoreError(rpcres.Error)
+		}
 		return fmt.Errorf("json-rpc error status: %v", res.StatusCode)
 	}
 	// cannot use json.NewDecoder: "The decoder introduces its own buffering
@@ -87,12 +94,7 @@ func (l *L1CoreRPC) request(method string, params []any, result any) error {
 		return fmt.Errorf("json-rpc wrong ID returned: %v vs %v", rpcres.Id, body.Id)
 	}
 	if rpcres.Error != nil {
-		enc, err := json.Marshal(rpcres.Error)
-		if err == nil {
-			return fmt.Errorf("json-rpc: error from Core Node: %v", string(enc))
-		} else {
-			return fmt.Errorf("json-rpc: error from Core Node: %v", rpcres.Error)
-		}
+		return newCoreError(rpcres.Error)
 	}
 	if rpcres.Result == nil {
 		return fmt.Errorf("json-rpc no result or error was returned")
@@ -104,6 +106,24 @@ func (l *L1CoreRPC) request(method string, params []any, result any) error {
 	return nil
 }
 
+// CoreError is an error returned by the Core Node for a request,
+// as opposed to a transport error (i.e. the node is responding)
+type CoreError struct {
+	Message string
+}
+
+func (e CoreError) Error() string {
+	return "json-rpc: error from Core Node: " + e.Message
+}
+
+func newCoreError(rpcErr any) error {
+	enc, err := json.Marshal(rpcErr)
+	if err == nil {
+		return CoreError{Message: string(enc)}
+	}
+	return CoreError{Message: fmt.Sprint(rpcErr)}
+}
+
 func (l *L1CoreRPC) MakeAddress(isTestNet bool) (giga.Address, giga.Privkey, error) {
 	return "", "", fmt.Errorf("not implemented")
 }
@@ -116,6 +136,14 @@ func (l *L1CoreRPC) MakeTransaction(inputs []giga.UTXO, outputs []giga.NewTxOut,
 	return giga.NewTxn{}, fmt.Errorf("not implemented")
 }
 
+func (l *L1CoreRPC) SignTransaction(txnHex string, inputs []giga.UTXO, private_key giga.Privkey) (string, error) {
+	return "", fmt.Errorf("not implemented")
+}
+
+func (l *L1CoreRPC) SignTransactionWithKey(txnHex string, inputs []giga.UTXO, ec_privkey_wif string) (string, error) {
+	return "", fmt.Errorf("not implemented")
+}
+
 func (l *L1CoreRPC) DecodeTransaction(txn_hex string) (txn giga.RawTxn, err error) {
 	err = l.request("decoderawtransaction", []any{txn_hex}, &txn)
 	return
@@ -175,6 +203,54 @@ func (l *L1CoreRPC) GetTransaction(txnHash string) (txn giga.RawTxn, err error)
 	return
 }
 
+type scanTxOutSetResult struct {
+	Success  bool               `json:"success"`
+	Unspents []scanTxOutSetUTXO `json:"unspents"`
+}
+type scanTxOutSetUTXO struct {
+	TxID         string          `json:"txid"`
+	VOut         int             `json:"vout"`
+	ScriptPubKey string          `json:"scriptPubKey"`
+	Amount       decimal.Decimal `json:"amount"`
+	Height       int64           `json:"height"`
+}
+
+// ScanAddressUTXOs finds the unspent outputs paid to `address` in the
+// UTXO set (requires a Core Node with `scantxoutset`; this can take a
+// few minutes, since it scans the whole UTXO set.)
+func (l *L1CoreRPC) ScanAddressUTXOs(address giga.Address) ([]giga.UTXO, error) {
+	var res scanTxOutSetResult
+	err := l.request("scantxoutset", []any{"start", []any{fmt.Sprintf("addr(%s)", address)}}, &res)
+	if err != nil {
+		return nil, fmt.Errorf("scantxoutset: %v", err)
+	}
+	if !res.Success {
+		return nil, fmt.Errorf("scantxoutset: scan did not complete")
+	}
+	chain := doge.ChainFromAddress(address)
+	if chain == nil {
+		chain = doge.ChainFromWIFString(string(address))
+	}
+	utxos := make([]giga.UTXO, 0, len(res.Unspents))
+	for _, out := range res.Unspents {
+		script, err := doge.HexDecode(out.ScriptPubKey)
+		if err != nil {
+			return nil, fmt.Errorf("scantxoutset: invalid scriptPubKey: %v", err)
+		}
+		scriptType, scriptAddress := doge.ClassifyScript(script, chain)
+		utxos = append(utxos, giga.UTXO{
+			TxID:          out.TxID,
+			VOut:          out.VOut,
+			Value:         out.Amount,
+			ScriptHex:     out.ScriptPubKey,
+			ScriptType:    scriptType,
+			ScriptAddress: scriptAddress,
+			BlockHeight:   out.Height,
+		})
+	}
+	return utxos, nil
+}
+
 func (l *L1CoreRPC) Send(txnHex string) (txid string, err error) {
 	log.Printf("SEND Tx: %v", txnHex)
 	txn, err := doge.HexDecode(txnHex)
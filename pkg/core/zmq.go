@@ -0,0 +1,43 @@
+//go:build cgo
+
+package core
+
+import (
+	"syscall"
+	"time"
+
+	"github.com/pebbe/zmq4"
+)
+
+// dialZMQ creates a new ZMQ SUB socket connected to `address`
+// and subscribed to `topic` (libzmq, via cgo)
+func dialZMQ(address string, topic string) (zmqSocket, error) {
+	sock, err := zmq4.NewSocket(zmq4.SUB)
+	if err != nil {
+		return nil, err
+	}
+	sock.SetRcvtimeo(2 * time.Second)
+	err = sock.Connect(address)
+	if err != nil {
+		sock.Close()
+		return nil, err
+	}
+	err = sock.SetSubscribe(topic)
+	if err != nil {
+		sock.Close()
+		return nil, err
+	}
+	return zmq4Socket{sock}, nil
+}
+
+type zmq4Socket struct {
+	*zmq4.Socket
+}
+
+func (s zmq4Socket) RecvMessage() ([][]byte, error) {
+	msg, err := s.RecvMessageBytes(0)
+	if err == zmq4.Errno(syscall.ETIMEDOUT) || err == zmq4.Errno(syscall.EAGAIN) {
+		return nil, errZMQTimeout
+	}
+	return msg, err
+}
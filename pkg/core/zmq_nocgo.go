@@ -0,0 +1,11 @@
+//go:build !cgo
+
+package core
+
+import "errors"
+
+// Without cgo there is no libzmq: the rest of this package
+// (RPC, L1Failover) still builds and can be tested.
+func dialZMQ(address string, topic string) (zmqSocket, error) {
+	return nil, errors.New("ZMQ: not available (built without cgo)")
+}
@@ -59,3 +59,16 @@ func ValidateP2SH(address Address, chain *ChainParams) bool {
 	}
 	return key[0] == chain.p2sh_address_prefix
 }
+
+// ChainFromAddress returns the Dogecoin chain for a P2PKH or P2SH address,
+// from its version byte. Unlike ChainFromWIFString, this tells regtest P2PKH
+// addresses apart from testnet (P2SH addresses are the same on both, so this
+// returns DogeTestNetChain) Returns nil if the address is not valid on any chain.
+func ChainFromAddress(address Address) *ChainParams {
+	for _, chain := range []*ChainParams{&DogeMainNetChain, &DogeTestNetChain, &DogeRegTestChain} {
+		if ValidateP2PKH(address, chain) || ValidateP2SH(address, chain) {
+			return chain
+		}
+	}
+	return nil
+}
@@ -16,3 +16,19 @@ func TestAddress(t *testing.T) {
 		t.Fatalf("PubKeyToP2PKH is wrong: %s", p2pkh)
 	}
 }
+
+func TestChainFromAddress(t *testing.T) {
+	hash := Hash160(hx2b("0250863ad64a87ae8a2fe83c1af1a8403cb53f53e486d8511dad8a04887e5b2352"))
+	for _, chain := range []*ChainParams{&DogeMainNetChain, &DogeTestNetChain, &DogeRegTestChain} {
+		addr := Hash160toAddress(hash, chain.p2pkh_address_prefix)
+		if got := ChainFromAddress(addr); got != chain {
+			t.Fatalf("ChainFromAddress(%v): expected %v, got %v", addr, chain.ChainName, got)
+		}
+	}
+	if ChainFromAddress(Hash160toAddress(hash, BitcoinMainChain.p2pkh_address_prefix)) != nil {
+		t.Fatalf("ChainFromAddress: expected nil for a Bitcoin address")
+	}
+	if ChainFromAddress("xyz") != nil {
+		t.Fatalf("ChainFromAddress: expected nil for an invalid address")
+	}
+}
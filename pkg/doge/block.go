@@ -112,6 +112,45 @@ func readHeader(s *Stream) (b BlockHeader) {
 	return
 }
 
+// ReadBlockHeader reads a block header followed by its AuxPoW (if any)
+// as found at the start of a block, or in a P2P `headers` message.
+// Also returns the 80-byte header, which hashes to the block hash.
+func ReadBlockHeader(s *Stream, blockid string) (hdr BlockHeader, auxPow *MerkleTx, raw []byte, err error) {
+	start := s.pos
+	hdr = readHeader(s)
+	if !s.Valid() {
+		return hdr, nil, nil, fmt.Errorf("error reading header: overran end of data: %v of %v", s.pos, s.len)
+	}
+	raw = s.buf[start:s.pos]
+	if hdr.IsAuxPoW() {
+		auxPow, err = readMerkleTx(s, "AuxPoW "+blockid)
+		if err != nil {
+			return hdr, nil, raw, fmt.Errorf("error reading AuxPoW: %v", err)
+		}
+	}
+	return
+}
+
+// BlockHashHex returns the block hash (hex) of an 80-byte block header.
+func BlockHashHex(header []byte) string {
+	return TxHashHex(header)
+}
+
+// DecodeBlockHeader decodes an 80-byte block header (without AuxPoW)
+func DecodeBlockHeader(header []byte) BlockHeader {
+	return readHeader(NewStream(header))
+}
+
+// EncodeBlockHeader serializes the 80-byte block header (without AuxPoW)
+func EncodeBlockHeader(hdr BlockHeader) []byte {
+	buf := appendUint32le(nil, hdr.Version)
+	buf = append(buf, hdr.PrevBlock...)
+	buf = append(buf, hdr.MerkleRoot...)
+	buf = appendUint32le(buf, hdr.Timestamp)
+	buf = appendUint32le(buf, hdr.Bits)
+	return appendUint32le(buf, hdr.Nonce)
+}
+
 func readMerkleTx(s *Stream, blockid string) (*MerkleTx, error) {
 	var m MerkleTx
 	coinbaseTx, err := readTx(s, blockid)
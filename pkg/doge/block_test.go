@@ -69,6 +69,32 @@ func TestAuxPoW(t *testing.T) {
 	}
 }
 
+func TestReadBlockHeader(t *testing.T) {
+	data := hx2b(Block_Test)
+	s := NewStream(data)
+	hdr, auxPow, raw, err := ReadBlockHeader(s, "test block")
+	if err != nil {
+		t.Fatalf("TestReadBlockHeader: decode error: %v", err)
+	}
+	if auxPow == nil {
+		t.Errorf("TestReadBlockHeader: expecting AuxPoW")
+	}
+	hash := BlockHashHex(raw)
+	if hash != "fb5f5b5b7d70e660c2c67bca8d3328afae32ae8bb4c8d6cbc42d96ff876b0859" {
+		t.Errorf("TestReadBlockHeader: wrong block hash: %v", hash)
+	}
+	if !reflect.DeepEqual(EncodeBlockHeader(hdr), data[:80]) {
+		t.Errorf("TestReadBlockHeader: EncodeBlockHeader does not match the header")
+	}
+	b, err := DecodeBlock(data, "test block", true)
+	if err != nil {
+		t.Fatalf("TestReadBlockHeader: decode error: %v", err)
+	}
+	if !reflect.DeepEqual(*auxPow, *b.AuxPoW) {
+		t.Errorf("TestReadBlockHeader: AuxPoW does not match DecodeBlock")
+	}
+}
+
 func collectOutVals(b *Block) (outVals []int64) {
 	for _, tx := range b.Tx {
 		for _, out := range tx.VOut {
@@ -12,6 +12,13 @@ type ChainParams struct {
 	bip32_pubkey_prefix      uint32
 	Bip32_WIF_PrivKey_Prefix string
 	Bip32_WIF_PubKey_Prefix  string
+	MessageStart             [4]byte // P2P network magic (pchMessageStart in Core)
+	DefaultPort              int     // P2P port
+	PowLimitBits             uint32  // easiest allowed target (powLimit in Core)
+	StrictChainID            bool    // AuxPoW: enforce our chain ID (fStrictChainId in Core)
+	DigiShieldHeight         int64   // every block retargets after this height (0: never)
+	MinDifficultyHeight      int64   // min-difficulty blocks allowed after this height (0: never)
+	PowNoRetargeting         bool    // difficulty never changes (fPowNoRetargeting in Core)
 }
 
 var DogeMainNetChain ChainParams = ChainParams{
@@ -24,6 +31,11 @@ var DogeMainNetChain ChainParams = ChainParams{
 	bip32_pubkey_prefix:      0x02facafd, // dgub
 	Bip32_WIF_PrivKey_Prefix: "dgpv",
 	Bip32_WIF_PubKey_Prefix:  "dgub",
+	MessageStart:             [4]byte{0xc0, 0xc0, 0xc0, 0xc0},
+	DefaultPort:              22556,
+	PowLimitBits:             0x1e0fffff,
+	StrictChainID:            true,
+	DigiShieldHeight:         145000,
 }
 
 var DogeTestNetChain ChainParams = ChainParams{
@@ -36,6 +48,12 @@ var DogeTestNetChain ChainParams = ChainParams{
 	bip32_pubkey_prefix:      0x043587cf, // tpub
 	Bip32_WIF_PrivKey_Prefix: "tprv",
 	Bip32_WIF_PubKey_Prefix:  "tpub",
+	MessageStart:             [4]byte{0xfc, 0xc1, 0xb7, 0xdc},
+	DefaultPort:              44556,
+	PowLimitBits:             0x1e0fffff,
+	StrictChainID:            false,
+	DigiShieldHeight:         145000,
+	MinDifficultyHeight:      157500,
 }
 
 var DogeRegTestChain ChainParams = ChainParams{
@@ -48,6 +66,11 @@ var DogeRegTestChain ChainParams = ChainParams{
 	bip32_pubkey_prefix:      0x043587cf, // tpub
 	Bip32_WIF_PrivKey_Prefix: "tprv",
 	Bip32_WIF_PubKey_Prefix:  "tpub",
+	MessageStart:             [4]byte{0xfa, 0xbf, 0xb5, 0xda},
+	DefaultPort:              18444,
+	PowLimitBits:             0x207fffff,
+	StrictChainID:            true,
+	PowNoRetargeting:         true,
 }
 
 // Used in tests only.
@@ -61,6 +84,10 @@ var BitcoinMainChain ChainParams = ChainParams{
 	bip32_pubkey_prefix:      0x0488B21E, //
 	Bip32_WIF_PrivKey_Prefix: "xxxx",     // TODO
 	Bip32_WIF_PubKey_Prefix:  "xxxx",     // TODO
+	MessageStart:             [4]byte{0xf9, 0xbe, 0xb4, 0xd9},
+	DefaultPort:              8333,
+	PowLimitBits:             0x1d00ffff,
+	StrictChainID:            false,
 }
 
 func ChainFromTestNetFlag(isTestNet bool) *ChainParams {
@@ -155,6 +182,20 @@ func ChainFromBip32Version(version uint32, allowNonDoge bool) *ChainParams {
 	return &DogeTestNetChain // fallback
 }
 
+// ChainFromName returns the Dogecoin chain for a network name,
+// i.e. "mainnet", "testnet" or "regtest" (or the ChainName)
+func ChainFromName(name string) (*ChainParams, error) {
+	switch name {
+	case "mainnet", "main", DogeMainNetChain.ChainName:
+		return &DogeMainNetChain, nil
+	case "testnet", "test", DogeTestNetChain.ChainName:
+		return &DogeTestNetChain, nil
+	case "regtest", DogeRegTestChain.ChainName:
+		return &DogeRegTestChain, nil
+	}
+	return nil, errors.New("ChainFromName: unrecognised chain: " + name)
+}
+
 func ChainFromGenesisHash(hash string) (*ChainParams, error) {
 	if hash == DogeMainNetChain.GenesisBlock {
 		return &DogeMainNetChain, nil
@@ -0,0 +1,284 @@
+package doge
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+
+	"github.com/btcsuite/golangcrypto/scrypt"
+)
+
+const (
+	AuxPoWChainID        = 0x0062 // nAuxpowChainId from Dogecoin Core
+	MaxChainMerkleBranch = 30     // maximum vChainMerkleBranch size in CAuxPow::check
+	PowTargetSpacing     = 60     // seconds between blocks (nPowTargetSpacing in Core)
+	DigiShieldTimespan   = 60     // retarget timespan after DigiShield (nPowTargetTimespan in Core)
+)
+
+var MergedMiningHeader = []byte{0xfa, 0xbe, 'm', 'm'} // pchMergedMiningHeader in Core
+
+// ScryptHash returns the proof-of-work hash of an 80-byte block header
+// (scrypt, as in Litecoin) in internal byte order.
+func ScryptHash(header []byte) []byte {
+	hash, err := scrypt.Key(header, header, 1024, 1, 1, 32)
+	if err != nil {
+		panic("ScryptHash: " + err.Error()) // only fails for invalid parameters.
+	}
+	return hash
+}
+
+// CompactToBig converts a compact target (`bits`) to a big.Int
+// Returns nil for negative or overflowing targets (SetCompact in Core)
+func CompactToBig(bits uint32) *big.Int {
+	exponent := uint(bits >> 24)
+	mantissa := int64(bits & 0x007fffff)
+	if mantissa != 0 && ((bits&0x00800000) != 0 || exponent > 34 ||
+		(mantissa > 0xff && exponent > 33) || (mantissa > 0xffff && exponent > 32)) {
+		return nil
+	}
+	target := big.NewInt(mantissa)
+	if exponent <= 3 {
+		return target.Rsh(target, 8*(3-exponent))
+	}
+	return target.Lsh(target, 8*(exponent-3))
+}
+
+// BigToCompact converts a target to compact form (`bits`) as GetCompact in Core.
+func BigToCompact(target *big.Int) uint32 {
+	size := uint32((target.BitLen() + 7) / 8)
+	var compact uint32
+	if size <= 3 {
+		compact = uint32(target.Uint64() << (8 * (3 - size)))
+	} else {
+		compact = uint32(new(big.Int).Rsh(target, uint(8*(size-3))).Uint64())
+	}
+	// The 0x00800000 bit is the sign bit: use a larger exponent instead.
+	if compact&0x00800000 != 0 {
+		compact >>= 8
+		size++
+	}
+	return compact | size<<24
+}
+
+// DigiShieldWorkRequired returns the target (`bits`) required for a block
+// at `height` with timestamp `time`, where `prev` and `prevPrev` are the
+// two previous headers. Only valid after chain.DigiShieldHeight, when every
+// block retargets (GetNextWorkRequired and CalculateDogecoinNextWorkRequired
+// in Dogecoin Core)
+func DigiShieldWorkRequired(height int64, time uint32, prev BlockHeader, prevPrev BlockHeader, chain *ChainParams) uint32 {
+	if chain.PowNoRetargeting {
+		return prev.Bits
+	}
+	if chain.MinDifficultyHeight != 0 && height > chain.MinDifficultyHeight &&
+		int64(time) > int64(prev.Timestamp)+PowTargetSpacing*2 {
+		return chain.PowLimitBits // no block for a while (testnet)
+	}
+	// Amplitude filter, then limit the adjustment step.
+	timespan := int64(prev.Timestamp) - int64(prevPrev.Timestamp)
+	timespan = DigiShieldTimespan + (timespan-DigiShieldTimespan)/8
+	if timespan < DigiShieldTimespan-DigiShieldTimespan/4 {
+		timespan = DigiShieldTimespan - DigiShieldTimespan/4
+	}
+	if timespan > DigiShieldTimespan+DigiShieldTimespan/2 {
+		timespan = DigiShieldTimespan + DigiShieldTimespan/2
+	}
+	target := CompactToBig(prev.Bits)
+	if target == nil {
+		return chain.PowLimitBits // invalid, rejected by CheckProofOfWork.
+	}
+	target.Mul(target, big.NewInt(timespan))
+	target.Div(target, big.NewInt(DigiShieldTimespan))
+	if limit := CompactToBig(chain.PowLimitBits); target.Cmp(limit) > 0 {
+		target = limit
+	}
+	return BigToCompact(target)
+}
+
+// BlockWork estimates the work for a header's compact target (`bits`),
+// i.e. 2^256 / target, in the same way as GetBlockProof in Core.
+func BlockWork(bits uint32) float64 {
+	exponent := int(bits >> 24)
+	mantissa := bits & 0x007fffff
+	if mantissa == 0 || (bits&0x00800000) != 0 {
+		return 0 // invalid (zero or negative) target.
+	}
+	return math.Ldexp(1/float64(mantissa), 256-8*(exponent-3))
+}
+
+// hashToBig interprets a hash in internal byte order as a 256-bit number.
+func hashToBig(hash []byte) *big.Int {
+	b := bytes.Clone(hash)
+	reverseInPlace(b)
+	return new(big.Int).SetBytes(b)
+}
+
+// CheckProofOfWork checks that a proof-of-work hash (internal byte order)
+// meets the target `bits`, and the target is within the chain's PoW limit.
+func CheckProofOfWork(powHash []byte, bits uint32, chain *ChainParams) error {
+	target := CompactToBig(bits)
+	if target == nil || target.Sign() <= 0 || target.Cmp(CompactToBig(chain.PowLimitBits)) > 0 {
+		return fmt.Errorf("proof-of-work: invalid target: %08x", bits)
+	}
+	if hashToBig(powHash).Cmp(target) > 0 {
+		return fmt.Errorf("proof-of-work: hash does not meet target: %08x", bits)
+	}
+	return nil
+}
+
+// CheckBlockProofOfWork checks the proof-of-work for a block header,
+// including merge-mined (AuxPoW) headers: mirrors CheckAuxPowProofOfWork
+// in Dogecoin Core. `header` is the 80-byte header, `auxPow` is the AuxPoW
+// decoded with the block (or nil)
+func CheckBlockProofOfWork(hdr BlockHeader, header []byte, auxPow *MerkleTx, chain *ChainParams) error {
+	chainID := hdr.Version >> 16
+	if !hdr.IsLegacy() && chain.StrictChainID && chainID != AuxPoWChainID {
+		return fmt.Errorf("proof-of-work: block does not have our chain ID: %x", chainID)
+	}
+	if auxPow == nil {
+		if hdr.IsAuxPoW() {
+			return errors.New("proof-of-work: no AuxPoW on block with AuxPoW version")
+		}
+		return CheckProofOfWork(ScryptHash(header), hdr.Bits, chain)
+	}
+	if !hdr.IsAuxPoW() {
+		return errors.New("proof-of-work: AuxPoW on block with non-AuxPoW version")
+	}
+	err := CheckAuxPoW(auxPow, DoubleSha256(header), chainID, chain)
+	if err != nil {
+		return err
+	}
+	return CheckProofOfWork(ScryptHash(EncodeBlockHeader(auxPow.ParentBlock)), hdr.Bits, chain)
+}
+
+// IsLegacy is true for blocks from before merge-mining (IsLegacy in Dogecoin Core)
+func (b *BlockHeader) IsLegacy() bool {
+	return b.Version == 1 || (b.Version == 2 && (b.Version>>16) == 0)
+}
+
+// CheckAuxPoW checks that the AuxPoW parent block commits to `blockHash`
+// (internal byte order) as a port of CAuxPow::check in Dogecoin Core.
+// It does not check the parent block's proof-of-work.
+func CheckAuxPoW(aux *MerkleTx, blockHash []byte, chainID uint32, chain *ChainParams) error {
+	if aux.CoinbaseBranch.SideMask != 0 {
+		return errors.New("AuxPoW: not a generate (coinbase) transaction")
+	}
+	if chain.StrictChainID && (aux.ParentBlock.Version>>16) == chainID {
+		return errors.New("AuxPoW: parent block has our chain ID")
+	}
+	merkleHeight := len(aux.BlockchainBranch.Hash)
+	if merkleHeight > MaxChainMerkleBranch {
+		return errors.New("AuxPoW: chain merkle branch too long")
+	}
+	// The chain merkle root must be in the parent coinbase.
+	rootHash := CheckMerkleBranch(blockHash, aux.BlockchainBranch)
+	reverseInPlace(rootHash) // as it appears in the script.
+	// The coinbase must be in the parent block's merkle tree.
+	coinbaseHash := DoubleSha256(EncodeTx(aux.CoinbaseTx))
+	if !bytes.Equal(CheckMerkleBranch(coinbaseHash, aux.CoinbaseBranch), aux.ParentBlock.MerkleRoot) {
+		return errors.New("AuxPoW: merkle root incorrect")
+	}
+	if len(aux.CoinbaseTx.VIn) < 1 {
+		return errors.New("AuxPoW: coinbase has no inputs")
+	}
+	script := aux.CoinbaseTx.VIn[0].Script
+	pc := bytes.Index(script, rootHash)
+	if pc < 0 {
+		return errors.New("AuxPoW: missing chain merkle root in parent coinbase")
+	}
+	head := bytes.Index(script, MergedMiningHeader)
+	if head >= 0 {
+		// Only one chain merkle root: a single merged mining header just before it.
+		if bytes.Contains(script[head+1:], MergedMiningHeader) {
+			return errors.New("AuxPoW: multiple merged mining headers in coinbase")
+		}
+		if head+len(MergedMiningHeader) != pc {
+			return errors.New("AuxPoW: merged mining header is not just before chain merkle root")
+		}
+	} else if pc > 20 {
+		// For backward compatibility: the root must start early in the coinbase.
+		return errors.New("AuxPoW: chain merkle root must start in the first 20 bytes of the parent coinbase")
+	}
+	// The chain merkle tree size and nonce follow the root.
+	rest := NewStream(script[pc+len(rootHash):])
+	size := rest.Uint32le()
+	nonce := rest.Uint32le()
+	if !rest.Valid() {
+		return errors.New("AuxPoW: missing chain merkle tree size and nonce in parent coinbase")
+	}
+	if size != 1<<merkleHeight {
+		return errors.New("AuxPoW: merkle branch size does not match parent coinbase")
+	}
+	if aux.BlockchainBranch.SideMask != auxPoWExpectedIndex(nonce, chainID, merkleHeight) {
+		return errors.New("AuxPoW: wrong index")
+	}
+	return nil
+}
+
+// getExpectedIndex in Dogecoin Core
+func auxPoWExpectedIndex(nonce uint32, chainID uint32, height int) uint32 {
+	rand := nonce
+	rand = rand*1103515245 + 12345
+	rand += chainID
+	rand = rand*1103515245 + 12345
+	return rand % (1 << height)
+}
+
+// CheckMerkleBranch computes the merkle root from a hash and its branch
+// (all in internal byte order) as in CAuxPow::CheckMerkleBranch
+func CheckMerkleBranch(hash []byte, branch MerkleBranch) []byte {
+	index := branch.SideMask
+	hash = bytes.Clone(hash)
+	for _, h := range branch.Hash {
+		if index&1 != 0 {
+			hash = DoubleSha256(append(bytes.Clone(h), hash...))
+		} else {
+			hash = DoubleSha256(append(hash, h...))
+		}
+		index >>= 1
+	}
+	return hash
+}
+
+// MerkleRoot computes the merkle root of a block's transaction hashes
+// (internal byte order) as in BlockMerkleRoot in Core. `mutated` is true
+// if the tree has duplicate hashes that give the same root (CVE-2012-2459)
+func MerkleRoot(hashes [][]byte) (root []byte, mutated bool) {
+	if len(hashes) == 0 {
+		return make([]byte, 32), false
+	}
+	level := make([][]byte, len(hashes))
+	copy(level, hashes)
+	for len(level) > 1 {
+		for i := 0; i+1 < len(level); i += 2 {
+			if bytes.Equal(level[i], level[i+1]) {
+				mutated = true
+			}
+		}
+		if len(level)%2 != 0 {
+			level = append(level, level[len(level)-1])
+		}
+		next := make([][]byte, 0, len(level)/2)
+		for i := 0; i < len(level); i += 2 {
+			next = append(next, DoubleSha256(append(bytes.Clone(level[i]), level[i+1]...)))
+		}
+		level = next
+	}
+	return level[0], mutated
+}
+
+// BlockMerkleRoot computes the merkle root of a decoded block's transactions.
+func BlockMerkleRoot(b *Block) (root []byte, mutated bool, err error) {
+	hashes := make([][]byte, 0, len(b.Tx))
+	for _, tx := range b.Tx {
+		hash, err := HexDecode(tx.TxID)
+		if err != nil || len(hash) != 32 {
+			return nil, false, fmt.Errorf("invalid txid: %v", tx.TxID)
+		}
+		reverseInPlace(hash)
+		hashes = append(hashes, hash)
+	}
+	root, mutated = MerkleRoot(hashes)
+	return root, mutated, nil
+}
@@ -0,0 +1,132 @@
+package doge
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestBlockProofOfWork(t *testing.T) {
+	data := hx2b(Block_Test)
+	b, err := DecodeBlock(data, "test block", true)
+	if err != nil {
+		t.Fatalf("TestBlockProofOfWork: decode error: %v", err)
+	}
+	err = CheckBlockProofOfWork(b.Header, data[:80], b.AuxPoW, &DogeMainNetChain)
+	if err != nil {
+		t.Errorf("TestBlockProofOfWork: %v", err)
+	}
+	root, mutated, err := BlockMerkleRoot(&b)
+	if err != nil || mutated || HexEncode(root) != HexEncode(b.Header.MerkleRoot) {
+		t.Errorf("TestBlockProofOfWork: wrong merkle root: %x (mutated %v) %v", root, mutated, err)
+	}
+
+	// A different header is not committed to by the AuxPoW.
+	hdr := b.Header
+	hdr.Timestamp++
+	err = CheckBlockProofOfWork(hdr, EncodeBlockHeader(hdr), b.AuxPoW, &DogeMainNetChain)
+	if err == nil {
+		t.Errorf("TestBlockProofOfWork: expecting AuxPoW error for modified header")
+	}
+	// Not our chain ID.
+	hdr = b.Header
+	hdr.Version = 0x00630104
+	err = CheckBlockProofOfWork(hdr, EncodeBlockHeader(hdr), b.AuxPoW, &DogeMainNetChain)
+	if err == nil {
+		t.Errorf("TestBlockProofOfWork: expecting chain ID error")
+	}
+	// Without AuxPoW, the header itself does not meet the target.
+	hdr = b.Header
+	hdr.Version &^= VersionAuxPoW
+	err = CheckBlockProofOfWork(hdr, EncodeBlockHeader(hdr), nil, &DogeMainNetChain)
+	if err == nil {
+		t.Errorf("TestBlockProofOfWork: expecting proof-of-work error")
+	}
+	// Missing a transaction.
+	b.Tx = b.Tx[1:]
+	root, _, _ = BlockMerkleRoot(&b)
+	if HexEncode(root) == HexEncode(b.Header.MerkleRoot) {
+		t.Errorf("TestBlockProofOfWork: expecting wrong merkle root")
+	}
+}
+
+func TestMerkleRoot(t *testing.T) {
+	a, b, c := DoubleSha256([]byte("a")), DoubleSha256([]byte("b")), DoubleSha256([]byte("c"))
+	root, mutated := MerkleRoot([][]byte{a, b, c})
+	if mutated {
+		t.Errorf("TestMerkleRoot: not mutated")
+	}
+	// The last hash is duplicated: same root, but mutated (CVE-2012-2459)
+	root2, mutated := MerkleRoot([][]byte{a, b, c, c})
+	if !mutated || HexEncode(root) != HexEncode(root2) {
+		t.Errorf("TestMerkleRoot: expecting mutated tree with the same root")
+	}
+	root, _ = MerkleRoot([][]byte{a})
+	if HexEncode(root) != HexEncode(a) {
+		t.Errorf("TestMerkleRoot: single hash is its own root")
+	}
+}
+
+func TestCompactToBig(t *testing.T) {
+	if CompactToBig(0x1d00ffff).Text(16) != "ffff0000000000000000000000000000000000000000000000000000" {
+		t.Errorf("TestCompactToBig: wrong target: %v", CompactToBig(0x1d00ffff).Text(16))
+	}
+	if CompactToBig(0x01003456).Sign() != 0 || CompactToBig(0x04923456) != nil {
+		t.Errorf("TestCompactToBig: wrong result for small or negative targets")
+	}
+}
+
+func TestBigToCompact(t *testing.T) {
+	for _, bits := range []uint32{0x1d00ffff, 0x1e0fffff, 0x207fffff, 0x1b0404cb, 0x03123456} {
+		if BigToCompact(CompactToBig(bits)) != bits {
+			t.Errorf("TestBigToCompact: %08x round-trips to %08x", bits, BigToCompact(CompactToBig(bits)))
+		}
+	}
+	// The sign bit is avoided with a larger exponent.
+	if BigToCompact(big.NewInt(0x80)) != 0x02008000 {
+		t.Errorf("TestBigToCompact: wrong compact for 0x80: %08x", BigToCompact(big.NewInt(0x80)))
+	}
+}
+
+func TestDigiShieldWorkRequired(t *testing.T) {
+	prevPrev := BlockHeader{Timestamp: 1700000000, Bits: 0x1b0404cb}
+	prev := BlockHeader{Timestamp: 1700000060, Bits: 0x1b0404cb}
+	expect := func(timespan int64) uint32 {
+		target := CompactToBig(prev.Bits)
+		target.Mul(target, big.NewInt(timespan))
+		return BigToCompact(target.Div(target, big.NewInt(DigiShieldTimespan)))
+	}
+	// On time: no change.
+	if bits := DigiShieldWorkRequired(200000, prev.Timestamp+60, prev, prevPrev, &DogeMainNetChain); bits != prev.Bits {
+		t.Errorf("TestDigiShieldWorkRequired: expecting no change, got %08x", bits)
+	}
+	// Fast block: harder by 1/8 of the difference.
+	prev.Timestamp = prevPrev.Timestamp
+	if bits := DigiShieldWorkRequired(200000, prev.Timestamp+60, prev, prevPrev, &DogeMainNetChain); bits != expect(53) {
+		t.Errorf("TestDigiShieldWorkRequired: expecting %08x, got %08x", expect(53), bits)
+	}
+	// Slow block: easier, limited to 1.5x
+	prev.Timestamp = prevPrev.Timestamp + 3600
+	if bits := DigiShieldWorkRequired(200000, prev.Timestamp+60, prev, prevPrev, &DogeMainNetChain); bits != expect(90) {
+		t.Errorf("TestDigiShieldWorkRequired: expecting %08x, got %08x", expect(90), bits)
+	}
+	// Never easier than the PoW limit.
+	prev.Bits = DogeMainNetChain.PowLimitBits
+	if bits := DigiShieldWorkRequired(200000, prev.Timestamp+60, prev, prevPrev, &DogeMainNetChain); bits != DogeMainNetChain.PowLimitBits {
+		t.Errorf("TestDigiShieldWorkRequired: expecting the PoW limit, got %08x", bits)
+	}
+	// Testnet allows a min-difficulty block after 2 minutes without a block.
+	prev = BlockHeader{Timestamp: 1700000060, Bits: 0x1b0404cb}
+	if bits := DigiShieldWorkRequired(157501, prev.Timestamp+121, prev, prevPrev, &DogeTestNetChain); bits != DogeTestNetChain.PowLimitBits {
+		t.Errorf("TestDigiShieldWorkRequired: expecting a min-difficulty block, got %08x", bits)
+	}
+	if bits := DigiShieldWorkRequired(157501, prev.Timestamp+121, prev, prevPrev, &DogeMainNetChain); bits != prev.Bits {
+		t.Errorf("TestDigiShieldWorkRequired: no min-difficulty blocks on mainnet, got %08x", bits)
+	}
+	if bits := DigiShieldWorkRequired(157500, prev.Timestamp+121, prev, prevPrev, &DogeTestNetChain); bits != prev.Bits {
+		t.Errorf("TestDigiShieldWorkRequired: no min-difficulty blocks before 157501, got %08x", bits)
+	}
+	// Regtest never retargets.
+	if bits := DigiShieldWorkRequired(10, prev.Timestamp+3600, prev, prevPrev, &DogeRegTestChain); bits != prev.Bits {
+		t.Errorf("TestDigiShieldWorkRequired: expecting no retarget on regtest, got %08x", bits)
+	}
+}
@@ -73,3 +73,51 @@ func ClassifyScript(script []byte, chain *ChainParams) (ScriptType, Address) {
 func isOpN1(op byte) bool {
 	return op >= OP_1 && op <= OP_16
 }
+
+// Maximum data in a standard OP_RETURN output (MAX_OP_RETURN_RELAY in Core
+// is 83 bytes, including OP_RETURN and the push opcodes)
+const MaxNullDataSize = 80
+
+// NullDataScript creates an OP_RETURN <data> script (TX_NULL_DATA)
+func NullDataScript(data []byte) []byte {
+	script := []byte{OP_RETURN}
+	if len(data) <= 75 {
+		script = append(script, byte(len(data)))
+	} else {
+		script = append(script, OP_PUSHDATA1, byte(len(data)))
+	}
+	return append(script, data...)
+}
+
+// ExtractNullData returns the data pushed by an OP_RETURN script
+// (all pushes concatenated) or false if the script is not null-data.
+func ExtractNullData(script []byte) ([]byte, bool) {
+	L := len(script)
+	if L < 1 || script[0] != OP_RETURN {
+		return nil, false
+	}
+	data := []byte{}
+	ofs := 1
+	for ofs < L {
+		op := script[ofs]
+		size := 0
+		ofs += 1
+		if op >= 0x01 && op <= 0x4b {
+			size = int(op)
+		} else if op == OP_PUSHDATA1 && ofs+1 <= L {
+			size = int(script[ofs])
+			ofs += 1
+		} else if op == OP_PUSHDATA2 && ofs+2 <= L {
+			size = int(script[ofs]) | int(script[ofs+1])<<8
+			ofs += 2
+		} else if op != OP_0 {
+			return nil, false // not a push opcode.
+		}
+		if ofs+size > L {
+			return nil, false // truncated push.
+		}
+		data = append(data, script[ofs:ofs+size]...)
+		ofs += size
+	}
+	return data, true
+}
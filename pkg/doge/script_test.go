@@ -62,3 +62,33 @@ func TestClassifyScript(t *testing.T) {
 		t.Errorf("Shouldn't generate Base58 Addresses: %v", ms_found)
 	}
 }
+
+func TestNullDataScript(t *testing.T) {
+	for _, size := range []int{0, 20, 75, 76, MaxNullDataSize} {
+		data := make([]byte, size)
+		for i := range data {
+			data[i] = byte(i)
+		}
+		script := NullDataScript(data)
+		if typ, _ := ClassifyScript(script, &DogeMainNetChain); typ != ScriptTypeNullData {
+			t.Errorf("Wrong script type for %v bytes: %v vs %v", size, typ, ScriptTypeNullData)
+		}
+		found, ok := ExtractNullData(script)
+		if !ok || HexEncode(found) != HexEncode(data) {
+			t.Errorf("Wrong data for %v bytes: %x vs %x", size, found, data)
+		}
+	}
+	// existing NullData script (single push)
+	found, ok := ExtractNullData(hx2b("6a1454f6fb64f14b756d118a96a57a2f9ebf4b4708fe"))
+	if !ok || HexEncode(found) != "54f6fb64f14b756d118a96a57a2f9ebf4b4708fe" {
+		t.Errorf("Wrong data: %x", found)
+	}
+	// not NullData
+	if _, ok := ExtractNullData(hx2b("76a91454f6fb64f14b756d118a96a57a2f9ebf4b4708fe88ac")); ok {
+		t.Errorf("Shouldn't extract data from P2PKH script")
+	}
+	// truncated push
+	if _, ok := ExtractNullData(hx2b("6a1454f6fb")); ok {
+		t.Errorf("Shouldn't extract data from a truncated push")
+	}
+}
@@ -0,0 +1,45 @@
+package doge
+
+import (
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/decred/dcrd/dcrec/secp256k1/v4/ecdsa"
+)
+
+const SIGHASH_ALL = 1
+
+// SignatureHash computes the (legacy, non-witness) signature hash for input
+// `n` of `tx` with SIGHASH_ALL, where `scriptCode` is the locking script of
+// the output being spent (without any OP_CODESEPARATOR)
+func SignatureHash(tx BlockTx, n int, scriptCode []byte) []byte {
+	vin := make([]BlockTxIn, len(tx.VIn))
+	for i, in := range tx.VIn {
+		in.Script = nil
+		if i == n {
+			in.Script = scriptCode
+		}
+		vin[i] = in
+	}
+	tx.VIn = vin
+	buf := EncodeTx(tx)
+	buf = appendUint32le(buf, SIGHASH_ALL)
+	return DoubleSha256(buf)
+}
+
+// SignHash signs a signature hash with RFC6979 deterministic nonces and low-S
+// (as libsecp256k1 does) returning the DER-encoded signature, without the
+// sighash type byte.
+func SignHash(hash []byte, key ECPrivKey) []byte {
+	pk := secp256k1.PrivKeyFromBytes(key)
+	sig := ecdsa.Sign(pk, hash).Serialize()
+	pk.Zero() // clear key for security.
+	return sig
+}
+
+// P2PKHUnlockingScript makes the unlocking script for a P2PKH input from a
+// DER signature and the public key: <sig+SIGHASH_ALL> <pubkey>
+func P2PKHUnlockingScript(sig []byte, pubKey []byte) []byte {
+	script := []byte{byte(len(sig) + 1)}
+	script = append(script, sig...)
+	script = append(script, SIGHASH_ALL, byte(len(pubKey)))
+	return append(script, pubKey...)
+}
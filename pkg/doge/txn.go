@@ -6,6 +6,53 @@ func TxHashHex(tx []byte) string {
 	return HexEncode(hash)
 }
 
+// EncodeTx serializes a transaction in the standard (non-witness) format,
+// i.e. the reverse of DecodeTx. Witness data is not encoded.
+func EncodeTx(tx BlockTx) []byte {
+	buf := appendUint32le(nil, tx.Version)
+	buf = appendVarUint(buf, uint64(len(tx.VIn)))
+	for _, in := range tx.VIn {
+		buf = append(buf, in.TxID...)
+		buf = appendUint32le(buf, in.VOut)
+		buf = appendVarUint(buf, uint64(len(in.Script)))
+		buf = append(buf, in.Script...)
+		buf = appendUint32le(buf, in.Sequence)
+	}
+	buf = appendVarUint(buf, uint64(len(tx.VOut)))
+	for _, out := range tx.VOut {
+		buf = appendUint64le(buf, uint64(out.Value))
+		buf = appendVarUint(buf, uint64(len(out.Script)))
+		buf = append(buf, out.Script...)
+	}
+	return appendUint32le(buf, tx.LockTime)
+}
+
+func appendUint16le(buf []byte, val uint16) []byte {
+	return append(buf, byte(val), byte(val>>8))
+}
+
+func appendUint32le(buf []byte, val uint32) []byte {
+	return append(buf, byte(val), byte(val>>8), byte(val>>16), byte(val>>24))
+}
+
+func appendUint64le(buf []byte, val uint64) []byte {
+	return appendUint32le(appendUint32le(buf, uint32(val)), uint32(val>>32))
+}
+
+// appendVarUint appends a variable-length unsigned integer (CompactSize in Core)
+func appendVarUint(buf []byte, val uint64) []byte {
+	if val < 253 {
+		return append(buf, byte(val))
+	}
+	if val <= 0xffff {
+		return appendUint16le(append(buf, 253), uint16(val))
+	}
+	if val <= 0xffffffff {
+		return appendUint32le(append(buf, 254), uint32(val))
+	}
+	return appendUint64le(append(buf, 255), val)
+}
+
 func reverseInPlace(a []byte) {
 	// https://github.com/golang/go/wiki/SliceTricks#reversing
 	for left, right := 0, len(a)-1; left < right; left, right = left+1, right-1 {
@@ -13,3 +13,14 @@ func TestTxHash(t *testing.T) {
 		t.Fatalf("TxHashHex: wrong tx hash: %s vs %s", hash, pizza_hash)
 	}
 }
+
+func TestEncodeTx(t *testing.T) {
+	tx, err := DecodeTx(hx2b(pizza_tx), pizza_hash)
+	if err != nil {
+		t.Fatalf("DecodeTx: %v", err)
+	}
+	enc := HexEncode(EncodeTx(tx))
+	if enc != pizza_tx {
+		t.Fatalf("EncodeTx: wrong encoding: %s vs %s", enc, pizza_tx)
+	}
+}
@@ -51,3 +51,15 @@ func DecodeECPrivKeyWIF(str string, chain *ChainParams) (ec_priv_key ECPrivKey,
 	clear(data[:]) // clear key for security.
 	return pk[:], chain, nil
 }
+
+// Reports whether a WIF-encoded EC private key is for a compressed pubkey
+// (see EncodeECPrivKeyWIF) as opposed to an uncompressed pubkey.
+func IsECPrivKeyWIFCompressed(str string) bool {
+	data, err := Base58DecodeCheck(str)
+	if err != nil {
+		return false
+	}
+	compressed := len(data) == 1+ECPrivKeyLen+1 && data[1+ECPrivKeyLen] == 0x01
+	clear(data[:]) // clear key for security.
+	return compressed
+}
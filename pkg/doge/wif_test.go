@@ -37,6 +37,9 @@ func wifCT(t *testing.T, pkey string, wif string) {
 	if !bytes.Equal(key_c, pkey_c) {
 		t.Fatalf("DecodeECPrivKeyWIF: decoded bytes differ: %v vs %v", key_c, pkey)
 	}
+	if !IsECPrivKeyWIFCompressed(wif_c) {
+		t.Fatalf("IsECPrivKeyWIFCompressed: expected compressed: %s", wif_c)
+	}
 }
 
 func wifUT(t *testing.T, pkey string, wif string) {
@@ -55,4 +58,7 @@ func wifUT(t *testing.T, pkey string, wif string) {
 	if !bytes.Equal(key_u, pkey_u) {
 		t.Fatalf("DecodeECPrivKeyWIF: decoded bytes differ: %v vs %v", key_u, pkey)
 	}
+	if IsECPrivKeyWIFCompressed(wif_u) {
+		t.Fatalf("IsECPrivKeyWIFCompressed: expected uncompressed: %s", wif_u)
+	}
 }
@@ -19,6 +19,8 @@ type L1 interface {
 	MakeAddress(isTestNet bool) (Address, Privkey, error)
 	MakeChildAddress(privkey Privkey, addressIndex uint32, isInternal bool) (Address, error)
 	MakeTransaction(inputs []UTXO, outputs []NewTxOut, fee CoinAmount, change Address, private_key Privkey) (NewTxn, error)
+	SignTransaction(txnHex string, inputs []UTXO, private_key Privkey) (string, error)
+	SignTransactionWithKey(txnHex string, inputs []UTXO, ec_privkey_wif string) (string, error)
 	DecodeTransaction(txnHex string) (RawTxn, error)
 	GetBlock(blockHash string) (RpcBlock, error)
 	GetBlockHex(blockHash string) (string, error)
@@ -29,6 +31,7 @@ type L1 interface {
 	GetBlockCount() (int64, error)
 	GetBlockchainInfo() (RpcBlockchainInfo, error)
 	GetTransaction(txnHash string) (RawTxn, error)
+	ScanAddressUTXOs(address Address) ([]UTXO, error)
 	Send(txnHex string) (txid string, err error)
 	EstimateFee(confirmTarget int) (feePerKB CoinAmount, err error)
 	//SignMessage([]byte, Privkey) (string, error)
@@ -49,7 +52,7 @@ var TxnRecommendedMinFee = OneCoin.Div(decimal.NewFromInt(100)) // 0.01 DOGE (RE
 var TxnRecommendedMaxFee = OneCoin                              // 1 DOGE
 var TxnFeePerKB = OneCoin.Div(decimal.NewFromInt(100))          // 0.01 DOGE
 var TxnFeePerByte = TxnFeePerKB.Div(decimal.NewFromInt(1000))   // since Core version 1.14.5
-var TxnDustLimit = OneCoin.Div(decimal.NewFromInt(100))         // 0.01 DOGE
+var TxnDustLimit = OneCoin.Div(decimal.NewFromInt(100))         // 0.01 DOGE (same for all output script types)
 
 // A new transaction (hex) from libdogecoin.
 type NewTxn struct {
@@ -58,13 +61,16 @@ type NewTxn struct {
 	TotalOut     CoinAmount // Sum of all outputs (NewTxOuts) i.e. total amount paid (excludes fee)
 	FeeAmount    CoinAmount // Fee paid by the transaction
 	ChangeAmount CoinAmount // Change returned to wallet (excess input)
+	Memo         []byte     // OP_RETURN data decoded from the transaction (if any)
+	Size         int64      // Size of the signed transaction the fee pays for (estimated if unsigned, see CreateTxn)
 }
 
 // NewTxOut is an output from a new Txn, i.e. creates a new UTXO.
 type NewTxOut struct {
-	ScriptType    ScriptType // 'p2pkh' etc, see ScriptType constants
+	ScriptType    ScriptType // 'p2pkh', 'p2sh' or 'nulldata', see ScriptType constants
 	Amount        CoinAmount // Amount of Dogecoin to pay to the PayTo address
-	ScriptAddress Address    // Dogecoin P2PKH Address to receive the funds
+	ScriptAddress Address    // Dogecoin P2PKH or P2SH Address to receive the funds
+	Data          []byte     // OP_RETURN data for a 'nulldata' output (Amount is zero)
 }
 
 // Decode the 'Type' from Core RPC to our ScriptType enum.
@@ -92,9 +92,20 @@ func (l L1Libdogecoin) MakeTransaction(inputs []giga.UTXO, outputs []giga.NewTxO
 		}
 	}
 
-	// add transaction outputs: P2PKH paid to ScriptAddress.
+	// add transaction outputs: P2PKH or P2SH paid to ScriptAddress.
+	// libdogecoin builds the locking script from the address version byte.
+	// OP_RETURN (null data) outputs are not supported by libdogecoin,
+	// so these are inserted into the transaction after finalizing.
 	var anyOutputAddress string
+	hasNullData := false
 	for _, out := range outputs {
+		if out.ScriptType == doge.ScriptTypeNullData {
+			hasNullData = true
+			continue
+		}
+		if out.ScriptType != doge.ScriptTypeP2PKH && out.ScriptType != doge.ScriptTypeP2SH {
+			return giga.NewTxn{}, giga.NewErr(giga.InvalidTxn, "cannot add transaction output: unsupported script type: %v", out)
+		}
 		log.Printf("add_output: %v %v\n", string(out.ScriptAddress), out.Amount.String())
 		if libdogecoin.W_add_output(tx, string(out.ScriptAddress), out.Amount.String()) != 1 {
 			return giga.NewTxn{}, giga.NewErr(giga.InvalidTxn, "cannot add transaction output: %v", out)
@@ -110,10 +121,61 @@ func (l L1Libdogecoin) MakeTransaction(inputs []giga.UTXO, outputs []giga.NewTxO
 	if tx_hex == "" {
 		return giga.NewTxn{}, giga.NewErr(giga.InvalidTxn, "cannot finalize_transaction")
 	}
+	if hasNullData {
+		var err error
+		tx_hex, err = insertNullDataOutputs(tx_hex, outputs)
+		if err != nil {
+			return giga.NewTxn{}, err
+		}
+	}
 
 	// FIXME: safer to extract this from the transaction output added by libdogecoin (if any)
 	change_amt := totalIn.Sub(totalOut).Sub(fee)
 
+	// Sign the transaction, unless it is for offline signing (no private key)
+	if private_key != "" {
+		var err error
+		tx_hex, err = l.signTransaction(tx_hex, inputs, private_key)
+		if err != nil {
+			return giga.NewTxn{}, err
+		}
+	}
+
+	return giga.NewTxn{TxnHex: tx_hex, TotalIn: totalIn, TotalOut: totalOut, FeeAmount: fee, ChangeAmount: change_amt}, nil
+}
+
+// SignTransaction signs an unsigned transaction, e.g. one made by MakeTransaction
+// for offline signing, with the HD wallet keys for the `inputs` (in order)
+func (l L1Libdogecoin) SignTransaction(txnHex string, inputs []giga.UTXO, private_key giga.Privkey) (string, error) {
+	libdogecoin.W_context_start()
+	defer libdogecoin.W_context_stop()
+	return l.signTransaction(txnHex, inputs, private_key)
+}
+
+// SignTransactionWithKey signs a transaction, made by MakeTransaction without
+// a private key, where every input is paid to the single EC key `ec_privkey_wif`
+// (e.g. a paper wallet) rather than an HD wallet key.
+func (l L1Libdogecoin) SignTransactionWithKey(tx_hex string, inputs []giga.UTXO, ec_privkey_wif string) (string, error) {
+	libdogecoin.W_context_start()
+	defer libdogecoin.W_context_stop()
+
+	p2pkh_address, err := doge.GenerateP2PKHFromECPrivKeyWIF(ec_privkey_wif)
+	if err != nil {
+		return "", giga.NewErr(giga.InvalidTxn, "cannot decode private key: %v", err)
+	}
+	for n, utxo := range inputs {
+		if utxo.ScriptAddress != p2pkh_address {
+			return "", giga.NewErr(giga.InvalidTxn, "Private Key doesn't match UTXO ScriptAddress: %v", utxo)
+		}
+		tx_hex, err = signInput(n, tx_hex, utxo, ec_privkey_wif)
+		if err != nil {
+			return "", err
+		}
+	}
+	return tx_hex, nil
+}
+
+func (l L1Libdogecoin) signTransaction(tx_hex string, inputs []giga.UTXO, private_key giga.Privkey) (string, error) {
 	// Sign the transaction: we need to sign each input UTXO separately,
 	// because each one is generated from our HD Wallet with a different P2PKH Address.
 	chain := doge.ChainFromWIFString(string(private_key))
@@ -122,50 +184,83 @@ func (l L1Libdogecoin) MakeTransaction(inputs []giga.UTXO, outputs []giga.NewTxO
 		// The PK should be the key for the ScriptAddress we extracted from the UTXO.
 		hd_node_pk := libdogecoin.W_get_derived_hd_address(string(private_key), 0, utxo.IsInternal, utxo.KeyIndex, true)
 		if !strings.HasPrefix(hd_node_pk, chain.Bip32_WIF_PrivKey_Prefix) {
-			return giga.NewTxn{}, giga.NewErr(giga.InvalidTxn, "cannot get_derived_hd_address priv: %s %v", hd_node_pk, utxo)
+			return "", giga.NewErr(giga.InvalidTxn, "cannot get_derived_hd_address priv: %s %v", hd_node_pk, utxo)
 		}
 		hd_node_pub := libdogecoin.W_get_derived_hd_address(string(private_key), 0, utxo.IsInternal, utxo.KeyIndex, false)
 		if !strings.HasPrefix(hd_node_pub, chain.Bip32_WIF_PubKey_Prefix) {
-			return giga.NewTxn{}, giga.NewErr(giga.InvalidTxn, "cannot get_derived_hd_address pub: %s %v", hd_node_pub, utxo)
+			return "", giga.NewErr(giga.InvalidTxn, "cannot get_derived_hd_address pub: %s %v", hd_node_pub, utxo)
 		}
 
 		// Verify we have the right PrivKey for the UTXO ScriptAddress.
 		hd_p2pkh_priv := libdogecoin.W_generate_derived_hd_pub_key(hd_node_pk)
 		if hd_p2pkh_priv != string(utxo.ScriptAddress) {
-			return giga.NewTxn{}, giga.NewErr(giga.InvalidTxn, "HD Private Key doesn't match UTXO ScriptAddress: %v", utxo)
+			return "", giga.NewErr(giga.InvalidTxn, "HD Private Key doesn't match UTXO ScriptAddress: %v", utxo)
 		}
 
 		// Verify we have the right PubKey for the UTXO ScriptAddress.
 		hd_p2pkh_pub := libdogecoin.W_generate_derived_hd_pub_key(hd_node_pub)
 		if hd_p2pkh_pub != string(utxo.ScriptAddress) {
-			return giga.NewTxn{}, giga.NewErr(giga.InvalidTxn, "HD Pub Key doesn't match UTXO ScriptAddress: %v", utxo)
+			return "", giga.NewErr(giga.InvalidTxn, "HD Pub Key doesn't match UTXO ScriptAddress: %v", utxo)
 		}
 
 		// Extract the WIF-encoded EC Key from the HD Child PrivKey.
 		ec_privkey_wif, err := doge.ExtractECPrivKeyFromBip32(hd_node_pk)
 		if err != nil {
-			return giga.NewTxn{}, err
+			return "", err
 		}
 		// Generate the corresponding P2PKH Address for the HD Child PrivKey.
 		p2pkh_address, err := doge.GenerateP2PKHFromECPrivKeyWIF(ec_privkey_wif)
 		if err != nil {
-			return giga.NewTxn{}, err
+			return "", err
 		}
 		if p2pkh_address != utxo.ScriptAddress {
-			return giga.NewTxn{}, giga.NewErr(giga.InvalidTxn, "HD Private Key doesn't match UTXO ScriptAddress: %v", utxo)
+			return "", giga.NewErr(giga.InvalidTxn, "HD Private Key doesn't match UTXO ScriptAddress: %v", utxo)
 		}
 
 		// sign the Nth transaction input (i.e. generate the unlocking script)
-		// [input_index, incoming_raw_tx string, script_hex string, sig_hash_type int, privkey string]
-		// "the pubkey script in hexadecimal format (scripthex)"
-		// e.g. ""
-		tx_hex = libdogecoin.W_sign_raw_transaction(n, tx_hex, utxo.ScriptHex, SIGHASH_ALL, ec_privkey_wif)
-		if tx_hex == "" {
-			return giga.NewTxn{}, giga.NewErr(giga.InvalidTxn, "cannot sign_raw_transaction: %v", utxo)
+		tx_hex, err = signInput(n, tx_hex, utxo, ec_privkey_wif)
+		if err != nil {
+			return "", err
 		}
 	}
 
-	return giga.NewTxn{TxnHex: tx_hex, TotalIn: totalIn, TotalOut: totalOut, FeeAmount: fee, ChangeAmount: change_amt}, nil
+	return tx_hex, nil
+}
+
+// signInput signs the Nth transaction input (a P2PKH UTXO) with the EC key.
+// libdogecoin aborts the process if the DER signature is shorter than 70 bytes
+// (about 1% of signatures: when R or S has leading zero bytes) so the signature
+// is made here first, and the unlocking script is inserted here if it is short.
+// Signatures are deterministic (RFC6979) so libdogecoin makes the same signature.
+func signInput(n int, tx_hex string, utxo giga.UTXO, ec_privkey_wif string) (string, error) {
+	txBytes, err := doge.HexDecode(tx_hex)
+	if err != nil {
+		return "", giga.NewErr(giga.InvalidTxn, "cannot decode transaction: %v", err)
+	}
+	tx, err := doge.DecodeTx(txBytes, "")
+	if err != nil || n >= len(tx.VIn) {
+		return "", giga.NewErr(giga.InvalidTxn, "cannot decode transaction: %v", err)
+	}
+	scriptCode, err := doge.HexDecode(utxo.ScriptHex)
+	if err != nil {
+		return "", giga.NewErr(giga.InvalidTxn, "cannot decode UTXO script: %v", utxo)
+	}
+	ec_privkey, _, err := doge.DecodeECPrivKeyWIF(ec_privkey_wif, nil)
+	if err != nil {
+		return "", giga.NewErr(giga.InvalidTxn, "cannot decode private key: %v", err)
+	}
+	sig := doge.SignHash(doge.SignatureHash(tx, n, scriptCode), ec_privkey)
+	if len(sig) < 70 {
+		tx.VIn[n].Script = doge.P2PKHUnlockingScript(sig, doge.ECPubKeyFromECPrivKey(ec_privkey))
+		return doge.HexEncode(doge.EncodeTx(tx)), nil
+	}
+	// [input_index, incoming_raw_tx string, script_hex string, sig_hash_type int, privkey string]
+	// "the pubkey script in hexadecimal format (scripthex)"
+	tx_hex = libdogecoin.W_sign_raw_transaction(n, tx_hex, utxo.ScriptHex, SIGHASH_ALL, ec_privkey_wif)
+	if tx_hex == "" {
+		return "", giga.NewErr(giga.InvalidTxn, "cannot sign_raw_transaction: %v", utxo)
+	}
+	return tx_hex, nil
 }
 
 func (l L1Libdogecoin) DecodeTransaction(txnHex string) (giga.RawTxn, error) {
@@ -238,6 +333,13 @@ func (l L1Libdogecoin) GetTransaction(txnHash string) (txn giga.RawTxn, err erro
 	return giga.RawTxn{}, fmt.Errorf("not implemented")
 }
 
+func (l L1Libdogecoin) ScanAddressUTXOs(address giga.Address) ([]giga.UTXO, error) {
+	if l.fallback != nil {
+		return l.fallback.ScanAddressUTXOs(address)
+	}
+	return nil, fmt.Errorf("not implemented")
+}
+
 func (l L1Libdogecoin) Send(txnHex string) (txid string, err error) {
 	if l.fallback != nil {
 		return l.fallback.Send(txnHex)
@@ -251,3 +353,36 @@ func (l L1Libdogecoin) EstimateFee(confirmTarget int) (feePerKB giga.CoinAmount,
 	}
 	return giga.ZeroCoins, fmt.Errorf("not implemented")
 }
+
+// Insert OP_RETURN outputs into an unsigned transaction, at the same
+// position as in `outputs` (the change output, if any, remains last.)
+func insertNullDataOutputs(tx_hex string, outputs []giga.NewTxOut) (string, error) {
+	txBytes, err := doge.HexDecode(tx_hex)
+	if err != nil {
+		return "", giga.NewErr(giga.InvalidTxn, "cannot decode finalized transaction: %v", err)
+	}
+	tx, err := doge.DecodeTx(txBytes, "")
+	if err != nil {
+		return "", giga.NewErr(giga.InvalidTxn, "cannot decode finalized transaction: %v", err)
+	}
+	vout := make([]doge.BlockTxOut, 0, len(tx.VOut)+len(outputs))
+	next := 0 // next output added by libdogecoin.
+	for _, out := range outputs {
+		if out.ScriptType == doge.ScriptTypeNullData {
+			if len(out.Data) > doge.MaxNullDataSize {
+				return "", giga.NewErr(giga.InvalidTxn, "cannot add transaction output: OP_RETURN data exceeds %v bytes", doge.MaxNullDataSize)
+			}
+			vout = append(vout, doge.BlockTxOut{Value: 0, Script: doge.NullDataScript(out.Data)})
+		} else {
+			if next >= len(tx.VOut) {
+				return "", giga.NewErr(giga.InvalidTxn, "finalized transaction is missing an output: %v", out)
+			}
+			vout = append(vout, tx.VOut[next])
+			next++
+		}
+	}
+	// append the change output (if any) after the specified outputs.
+	vout = append(vout, tx.VOut[next:]...)
+	tx.VOut = vout
+	return doge.HexEncode(doge.EncodeTx(tx)), nil
+}
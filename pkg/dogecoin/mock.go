@@ -32,6 +32,14 @@ func (l L1Mock) MakeTransaction(inputs []giga.UTXO, outputs []giga.NewTxOut, fee
 	return giga.NewTxn{}, fmt.Errorf("not implemented")
 }
 
+func (l L1Mock) SignTransaction(txnHex string, inputs []giga.UTXO, private_key giga.Privkey) (string, error) {
+	return "", fmt.Errorf("not implemented")
+}
+
+func (l L1Mock) SignTransactionWithKey(txnHex string, inputs []giga.UTXO, ec_privkey_wif string) (string, error) {
+	return "", fmt.Errorf("not implemented")
+}
+
 func (l L1Mock) DecodeTransaction(txnHex string) (giga.RawTxn, error) {
 	return giga.RawTxn{}, fmt.Errorf("not implemented")
 }
@@ -76,6 +84,10 @@ func (l L1Mock) GetTransaction(txnHash string) (txn giga.RawTxn, err error) {
 	return giga.RawTxn{}, nil
 }
 
+func (l L1Mock) ScanAddressUTXOs(address giga.Address) ([]giga.UTXO, error) {
+	return nil, fmt.Errorf("not implemented")
+}
+
 func (l L1Mock) Send(txnHex string) (txid string, err error) {
 	return "FEED000000000000000000000000000000000000000000000000000000000000", nil
 }
@@ -16,6 +16,7 @@ const (
 	InvalidTxn        ErrorCode = "invalid-txn"
 	InsufficientFunds ErrorCode = "insufficient-funds"
 	DBConflict        ErrorCode = "db-conflict"
+	Conflict          ErrorCode = "conflict"
 	UnknownError      ErrorCode = "unknown-error"
 )
 
@@ -1,5 +1,7 @@
 package giga
 
+import "time"
+
 // Gigawallet event types
 
 // bus.Send(INV_PAYMENT_REFUNDED, payment)
@@ -15,7 +17,10 @@ var EVENT_TYPES []EventType = []EventType{EVENT_ALL("ALL"),
 	EVENT_SYS("SYS"),
 	EVENT_NET("NET"),
 	EVENT_ACC("ACC"),
-	EVENT_INV("INV")}
+	EVENT_INV("INV"),
+	EVENT_PAYMENT("PAYMENT"),
+	EVENT_WITHDRAWAL("WITHDRAWAL"),
+	EVENT_SCHEDULE("SCHEDULE")}
 
 // Special category, do not use directly, represents *
 type EVENT_ALL string
@@ -44,6 +49,62 @@ func (e EVENT_NET) Type() string {
 	return "NET"
 }
 
+const (
+	NET_NEW_BLOCK     EVENT_NET = "NET_NEW_BLOCK"     // the ChainFollower processed up to a new tip block
+	NET_SYNC_PROGRESS EVENT_NET = "NET_SYNC_PROGRESS" // the ChainFollower is catching up to the tip
+	NET_SYNC_COMPLETE EVENT_NET = "NET_SYNC_COMPLETE" // the ChainFollower has caught up to the tip
+	NET_REORG         EVENT_NET = "NET_REORG"         // the ChainFollower rolled back blocks for a new chain
+	NET_NODE_DOWN     EVENT_NET = "NET_NODE_DOWN"     // a Core node stopped responding
+	NET_NODE_UP       EVENT_NET = "NET_NODE_UP"       // a Core node is responding again
+	NET_NODE_DISAGREE EVENT_NET = "NET_NODE_DISAGREE" // Core nodes have different blocks at the same height
+	NET_ZMQ_TIMEOUT   EVENT_NET = "NET_ZMQ_TIMEOUT"   // no ZMQ notifications from Core for a while
+)
+
+type NetBlockEvent struct {
+	Hash   string `json:"hash"`   // block hash
+	Height int64  `json:"height"` // block height
+}
+
+// ChainFollower progress towards the tip (Best Block) of Core's chain.
+type NetSyncEvent struct {
+	Hash      string  `json:"hash"`       // last block processed
+	Height    int64   `json:"height"`     // height of that block
+	TipHeight int64   `json:"tip_height"` // height of the tip on the Core node
+	Behind    int64   `json:"behind"`     // number of blocks left to process
+	Percent   float64 `json:"percent"`    // Height as a percentage of TipHeight
+}
+
+type NetZMQTimeoutEvent struct {
+	Address string `json:"address"` // ZMQ address of the Core node
+	Seconds int64  `json:"seconds"` // seconds since the last notification
+}
+
+// Status of a Core node (see core.L1Failover and chaintracker.TipChaser)
+type NetNodeEvent struct {
+	Node      string  `json:"node"`       // node name (RPC host:port)
+	Up        bool    `json:"up"`         // responding to health checks
+	Height    int64   `json:"height"`     // best block height
+	BestHash  string  `json:"best_hash"`  // best block hash
+	LatencyMS float64 `json:"latency_ms"` // average response time
+	Errors    int64   `json:"errors"`     // failed requests since start
+	Error     string  `json:"error"`      // last error (or "")
+}
+
+// Core nodes that report different best blocks at the same height.
+type NetNodeDisagreeEvent struct {
+	Height int64             `json:"height"` // block height
+	Hashes map[string]string `json:"hashes"` // node name -> best block hash
+}
+
+type NetReorgEvent struct {
+	FromHash   string   `json:"from_hash"`   // last block processed before the reorg
+	FromHeight int64    `json:"from_height"` // height of that block
+	ForkHash   string   `json:"fork_hash"`   // last block in common with the new chain
+	ForkHeight int64    `json:"fork_height"` // height of the fork-point
+	Depth      int64    `json:"depth"`       // number of blocks rolled back
+	TxIDs      []string `json:"txids"`       // confirmed transactions that were rolled back
+}
+
 // Account Events
 type EVENT_ACC string
 
@@ -73,10 +134,13 @@ func (e EVENT_PAYMENT) Type() string {
 }
 
 const (
-	PAYMENT_SENT        EVENT_PAYMENT = "PAYMENT_SENT"
-	PAYMENT_ON_CHAIN    EVENT_PAYMENT = "PAYMENT_ON_CHAIN"
-	PAYMENT_CONFIRMED   EVENT_PAYMENT = "PAYMENT_CONFIRMED"
-	PAYMENT_UNCONFIRMED EVENT_PAYMENT = "PAYMENT_UNCONFIRMED"
+	PAYMENT_SENT             EVENT_PAYMENT = "PAYMENT_SENT"
+	PAYMENT_ON_CHAIN         EVENT_PAYMENT = "PAYMENT_ON_CHAIN"
+	PAYMENT_CONFIRMED        EVENT_PAYMENT = "PAYMENT_CONFIRMED"
+	PAYMENT_UNCONFIRMED      EVENT_PAYMENT = "PAYMENT_UNCONFIRMED"
+	PAYMENT_PENDING_APPROVAL EVENT_PAYMENT = "PAYMENT_PENDING_APPROVAL"
+	PAYMENT_REJECTED         EVENT_PAYMENT = "PAYMENT_REJECTED"
+	PAYMENT_DOUBLE_SPEND     EVENT_PAYMENT = "PAYMENT_DOUBLE_SPEND"
 )
 
 type PaymentEvent struct {
@@ -86,6 +150,61 @@ type PaymentEvent struct {
 	PayTo     []PayTo    `json:"pay_to"`
 	Total     CoinAmount `json:"total"`
 	TxID      string     `json:"txid"`
+	Memo      string     `json:"memo"`
+}
+
+// Withdrawal Events
+type EVENT_WITHDRAWAL string
+
+func (e EVENT_WITHDRAWAL) Type() string {
+	return "WITHDRAWAL"
+}
+
+const (
+	WITHDRAWAL_QUEUED EVENT_WITHDRAWAL = "WITHDRAWAL_QUEUED"
+	WITHDRAWAL_SENT   EVENT_WITHDRAWAL = "WITHDRAWAL_SENT"
+	WITHDRAWAL_FAILED EVENT_WITHDRAWAL = "WITHDRAWAL_FAILED"
+)
+
+type WithdrawalEvent struct {
+	WithdrawalID int64            `json:"withdrawal_id"`
+	AccountID    Address          `json:"account_id"`
+	ForeignID    string           `json:"foreign_id"`
+	PayTo        Address          `json:"to"`
+	Amount       CoinAmount       `json:"amount"`
+	Status       WithdrawalStatus `json:"status"`
+	PaymentID    int64            `json:"payment_id"`
+	TxID         string           `json:"txid"`
+	Error        string           `json:"error"`
+}
+
+// Scheduled Payment Events
+type EVENT_SCHEDULE string
+
+func (e EVENT_SCHEDULE) Type() string {
+	return "SCHEDULE"
+}
+
+const (
+	SCHEDULE_CREATED   EVENT_SCHEDULE = "SCHEDULE_CREATED"
+	SCHEDULE_SENT      EVENT_SCHEDULE = "SCHEDULE_SENT"
+	SCHEDULE_RETRY     EVENT_SCHEDULE = "SCHEDULE_RETRY" // not enough funds (yet): will retry
+	SCHEDULE_FAILED    EVENT_SCHEDULE = "SCHEDULE_FAILED"
+	SCHEDULE_CANCELLED EVENT_SCHEDULE = "SCHEDULE_CANCELLED"
+)
+
+type ScheduleEvent struct {
+	ScheduleID  int64          `json:"schedule_id"`
+	AccountID   Address        `json:"account_id"`
+	ForeignID   string         `json:"foreign_id"`
+	PayTo       []PayTo        `json:"pay"`
+	PayAt       time.Time      `json:"pay_at"`
+	PayAtHeight int64          `json:"pay_at_height"`
+	Status      ScheduleStatus `json:"status"`
+	Attempts    int            `json:"attempts"`
+	PaymentID   int64          `json:"payment_id"`
+	TxID        string         `json:"txid"`
+	Error       string         `json:"error"`
 }
 
 // Invoice Events
@@ -106,8 +225,21 @@ const (
 	INV_OVER_PAYMENT_CONFIRMED  EVENT_INV = "INV_OVER_PAYMENT_CONFIRMED"
 	INV_PAYMENT_UNCONFIRMED     EVENT_INV = "INV_PAYMENT_UNCONFIRMED"
 	INV_PAYMENT_REFUNDED        EVENT_INV = "INV_PAYMENT_REFUNDED"
+	INV_DOUBLE_SPEND            EVENT_INV = "INV_DOUBLE_SPEND"
 )
 
+// Sent for an Invoice or Payment (PAYMENT_DOUBLE_SPEND) when confirmed transactions were
+// rolled back by a reorg, and not re-mined within RejectionsNeeded blocks.
+type DoubleSpendEvent struct {
+	InvoiceID  Address  `json:"invoice_id,omitempty"`
+	PaymentID  int64    `json:"payment_id,omitempty"`
+	AccountID  Address  `json:"account_id"`
+	ForeignID  string   `json:"foreign_id"`
+	TxIDs      []string `json:"txids"`       // transactions that were not re-mined
+	ForkHeight int64    `json:"fork_height"` // height of the fork-point
+	Depth      int64    `json:"depth"`       // blocks on the new chain since the fork-point
+}
+
 type InvPaymentEvent struct {
 	InvoiceID      Address    `json:"invoice_id"`
 	AccountID      Address    `json:"account_id"`
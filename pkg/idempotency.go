@@ -0,0 +1,102 @@
+package giga
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+)
+
+// Maximum length of a client-supplied Idempotency-Key.
+const MaxIdempotencyKeyLength = 255
+
+// IdempotencyKey makes a create request (Payment or Invoice) safe to retry.
+// The response is stored with the key in the same store transaction that
+// creates the Payment or Invoice, and a replay returns the original response.
+type IdempotencyKey struct {
+	Key         string // client-supplied key (empty: request is not idempotent)
+	RequestHash string // hash of the request, to detect re-use of a key with a different request
+}
+
+// NewIdempotencyKey hashes the parts of a request (e.g. method, path and body)
+// to make an IdempotencyKey for the client-supplied key.
+func NewIdempotencyKey(key string, request ...[]byte) IdempotencyKey {
+	h := sha256.New()
+	for _, part := range request {
+		h.Write(part)
+		h.Write([]byte{0}) // separator
+	}
+	return IdempotencyKey{Key: key, RequestHash: hex.EncodeToString(h.Sum(nil))}
+}
+
+// IdempotentResponse is the stored response for an IdempotencyKey.
+type IdempotentResponse struct {
+	AccountID   Address   // an Account.Address (keys are scoped per account)
+	Key         string    // client-supplied key
+	RequestHash string    // hash of the original request
+	Response    []byte    // original response (JSON)
+	Created     time.Time // when the original request was processed
+}
+
+type idempotentResponseGetter interface {
+	GetIdempotentResponse(account Address, key string) (IdempotentResponse, error)
+}
+
+// replayIdempotent decodes the stored response for the key into `result`.
+// It returns false if there is no key, or the key has not been used yet.
+// It returns giga.Conflict if the key was used for a different request.
+func replayIdempotent(store idempotentResponseGetter, account Address, idem IdempotencyKey, result any) (bool, error) {
+	if idem.Key == "" {
+		return false, nil
+	}
+	res, err := store.GetIdempotentResponse(account, idem.Key)
+	if err != nil {
+		if IsNotFoundError(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	if res.RequestHash != idem.RequestHash {
+		return false, NewErr(Conflict, "Idempotency-Key has already been used for a different request: %s", idem.Key)
+	}
+	err = json.Unmarshal(res.Response, result)
+	if err != nil {
+		return false, NewErr(UnknownError, "cannot decode stored response for Idempotency-Key: %s: %v", idem.Key, err)
+	}
+	return true, nil
+}
+
+// storeIdempotent stores the response for the key (if any) in the caller's
+// store transaction, so it is committed with the Payment or Invoice.
+func storeIdempotent(dbtx StoreTransaction, account Address, idem IdempotencyKey, result any) error {
+	if idem.Key == "" {
+		return nil
+	}
+	response, err := json.Marshal(result)
+	if err != nil {
+		return NewErr(UnknownError, "cannot encode response for Idempotency-Key: %s: %v", idem.Key, err)
+	}
+	return dbtx.StoreIdempotentResponse(IdempotentResponse{
+		AccountID:   account,
+		Key:         idem.Key,
+		RequestHash: idem.RequestHash,
+		Response:    response,
+		Created:     time.Now(),
+	})
+}
+
+// replayAfterConflict handles a failed commit of a new response: if a concurrent
+// request with the same key committed first, replay its response instead.
+func replayAfterConflict(store Store, account Address, idem IdempotencyKey, result any, err error) error {
+	if idem.Key == "" || !IsAlreadyExistsError(err) {
+		return err
+	}
+	found, rerr := replayIdempotent(store, account, idem, result)
+	if rerr != nil {
+		return rerr
+	}
+	if !found {
+		return err
+	}
+	return nil
+}
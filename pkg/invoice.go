@@ -25,6 +25,7 @@ type Invoice struct {
 	PaidAmount         CoinAmount `json:"total_confirmed"` // total of all confirmed UTXOs
 	LastIncomingAmount CoinAmount `json:"-"`               // last incoming total used to send an event
 	LastPaidAmount     CoinAmount `json:"-"`               // last confirmed total used to send an event
+	DoubleSpendHeight  int64      `json:"-"`               // block-height when a paying transaction was flagged as double-spent
 	// Additional derived fields (included in PublicInvoice)
 	PayTo          Address `json:"pay_to_address"`
 	PartDetected   bool    `json:"part_payment_detected"`       // Calculated
@@ -32,6 +33,7 @@ type Invoice struct {
 	TotalConfirmed bool    `json:"total_payment_confirmed"`     // Calculated
 	Unconfirmed    bool    `json:"payment_unconfirmed"`         // Calculated
 	Estimate       int     `json:"estimate_seconds_to_confirm"` // Calculated
+	DoubleSpent    bool    `json:"double_spent"`                // Calculated
 }
 
 // CalcTotal sums up the Items listed on the Invoice.
@@ -116,6 +118,7 @@ func (i *Invoice) AddPublic() {
 	i.TotalConfirmed = (i.PaidHeight > 1)
 	i.Unconfirmed = false // XXX meant to indicate if a rollback has occured
 	i.Estimate = 0        // XXX meant to estimate time until confirmation
+	i.DoubleSpent = (i.DoubleSpendHeight != 0)
 }
 
 func (i *Invoice) ToPublic() PublicInvoice {
@@ -132,6 +135,7 @@ func (i *Invoice) ToPublic() PublicInvoice {
 		TotalConfirmed: false,
 		Unconfirmed:    false, // XXX meant to indicate if a rollback has occured
 		Estimate:       0,     // XXX meant to estimate time until confirmation
+		DoubleSpent:    i.DoubleSpendHeight != 0,
 	}
 
 	if i.LastIncomingAmount.IsPositive() {
@@ -163,4 +167,5 @@ type PublicInvoice struct {
 	TotalConfirmed bool       `json:"total_payment_confirmed"`     // Calculated
 	Unconfirmed    bool       `json:"payment_unconfirmed"`         // Calculated
 	Estimate       int        `json:"estimate_seconds_to_confirm"` // Calculated
+	DoubleSpent    bool       `json:"double_spent"`                // Calculated
 }
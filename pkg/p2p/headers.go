@@ -0,0 +1,150 @@
+package p2p
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/dogecoinfoundation/gigawallet/pkg/doge"
+)
+
+// A block header in the headerChain.
+type headerNode struct {
+	hash   Hash
+	raw    [80]byte // zero for the base block (header not downloaded)
+	height int64
+	work   float64 // cumulative work, in hashes (approx.)
+	prev   *headerNode
+	header doge.BlockHeader // decoded raw (zero for the base block)
+}
+
+var errOrphanHeader = errors.New("header does not connect to the header chain")
+
+/*
+ * headerChain is an in-memory index of block headers received from peers.
+ *
+ * It starts at a base block (Genesis, or a configured start block) and
+ * tracks the most-work chain, i.e. the same chain Core considers best.
+ *
+ * Each header must carry valid proof-of-work (including AuxPoW for
+ * merge-mined blocks) and, after DigiShield, the difficulty Core expects
+ * from the two previous headers, so a peer cannot feed us a cheap chain.
+ * Difficulty is not checked before DigiShield (block 145000, buried under
+ * far more work) or for the first two headers after the base block.
+ * Transactions are not checked here (see ChainFollowerConfig.VerifyBlocks)
+ */
+type headerChain struct {
+	chain *doge.ChainParams
+	nodes map[Hash]*headerNode
+	main  []*headerNode // best chain, indexed by height-base
+	base  int64         // height of main[0]
+}
+
+func newHeaderChain(chain *doge.ChainParams, baseHash Hash, baseHeight int64) *headerChain {
+	base := &headerNode{hash: baseHash, height: baseHeight}
+	return &headerChain{
+		chain: chain,
+		nodes: map[Hash]*headerNode{baseHash: base},
+		main:  []*headerNode{base},
+		base:  baseHeight,
+	}
+}
+
+func (c *headerChain) tip() *headerNode {
+	return c.main[len(c.main)-1]
+}
+
+func (c *headerChain) get(hash Hash) *headerNode {
+	return c.nodes[hash]
+}
+
+func (c *headerChain) atHeight(height int64) *headerNode {
+	if height < c.base || height-c.base >= int64(len(c.main)) {
+		return nil
+	}
+	return c.main[height-c.base]
+}
+
+func (c *headerChain) onMain(n *headerNode) bool {
+	return c.atHeight(n.height) == n
+}
+
+// add a header to the chain; returns the node (nil if already known) and
+// true if the best chain changed. Returns an error if the header does not
+// connect (errOrphanHeader) or is invalid.
+func (c *headerChain) add(h wireHeader) (*headerNode, bool, error) {
+	if _, found := c.nodes[h.Hash]; found {
+		return nil, false, nil
+	}
+	var prevHash Hash
+	copy(prevHash[:], h.Header.PrevBlock)
+	prev := c.nodes[prevHash]
+	if prev == nil {
+		return nil, false, errOrphanHeader
+	}
+	height := prev.height + 1
+	err := doge.CheckBlockProofOfWork(h.Header, h.Raw, h.AuxPoW, c.chain)
+	if err != nil {
+		return nil, false, fmt.Errorf("header %v at height %v: %v", h.Hash, height, err)
+	}
+	retarget := c.chain.PowNoRetargeting || (c.chain.DigiShieldHeight != 0 && height > c.chain.DigiShieldHeight)
+	if retarget && prev.prev != nil && prev.prev.height > c.base {
+		bits := doge.DigiShieldWorkRequired(height, h.Header.Timestamp, prev.header, prev.prev.header, c.chain)
+		if h.Header.Bits != bits {
+			return nil, false, fmt.Errorf("header %v at height %v: wrong difficulty: %08x (expecting %08x)", h.Hash, height, h.Header.Bits, bits)
+		}
+	}
+	n := &headerNode{
+		hash:   h.Hash,
+		height: height,
+		work:   prev.work + doge.BlockWork(h.Header.Bits),
+		prev:   prev,
+		header: h.Header,
+	}
+	copy(n.raw[:], h.Raw)
+	c.nodes[h.Hash] = n
+	if n.work > c.tip().work {
+		c.setTip(n)
+		return n, true, nil
+	}
+	return n, false, nil
+}
+
+// setTip makes `n` the tip of the best chain (re-organising if necessary)
+func (c *headerChain) setTip(n *headerNode) {
+	var path []*headerNode
+	for !c.onMain(n) {
+		path = append(path, n)
+		n = n.prev
+	}
+	c.main = c.main[:n.height-c.base+1]
+	for i := len(path) - 1; i >= 0; i-- {
+		c.main = append(c.main, path[i])
+	}
+}
+
+// locator returns a block locator (for getheaders) starting at `n`:
+// ten recent blocks, then exponentially further back, ending at base.
+func (c *headerChain) locator(n *headerNode) []Hash {
+	var loc []Hash
+	step := int64(1)
+	for {
+		loc = append(loc, n.hash)
+		if n.height <= c.base {
+			return loc
+		}
+		height := n.height - step
+		if height < c.base {
+			height = c.base
+		}
+		if c.onMain(n) {
+			n = c.atHeight(height)
+		} else {
+			for n.height > height {
+				n = n.prev
+			}
+		}
+		if len(loc) >= 10 {
+			step *= 2
+		}
+	}
+}
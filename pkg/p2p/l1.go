@@ -0,0 +1,383 @@
+package p2p
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"net"
+	"strconv"
+	"sync"
+
+	giga "github.com/dogecoinfoundation/gigawallet/pkg"
+	"github.com/dogecoinfoundation/gigawallet/pkg/doge"
+)
+
+// interface guards ensure L1P2P implements giga.L1 and giga.NodeEmitter
+var _ giga.L1 = &L1P2P{}
+var _ giga.NodeEmitter = &L1P2P{}
+
+/*
+ * L1P2P is a chain source that speaks the Dogecoin P2P protocol directly
+ * to one or more peers (any Dogecoin node, including a pruned node without
+ * RPC enabled) instead of fetching blocks over Core RPC.
+ *
+ * It downloads headers from all peers (getheaders) to maintain the best
+ * chain in memory, and fetches blocks on demand (getdata) to answer the
+ * chain requests the ChainFollower makes. Other requests are delegated
+ * to the fallback L1 (e.g. L1CoreRPC for sending transactions.)
+ *
+ * It is also a NodeEmitter: it notifies subscribers (TipChaser) when the
+ * best block changes, which replaces ZMQ notifications from Core.
+ *
+ * NOTE: a pruned node can only provide recent blocks; ChainState must be
+ * within the node's prune window (blocks not found will be retried.)
+ */
+type L1P2P struct {
+	fallback  giga.L1
+	chain     *doge.ChainParams
+	addrs     []string
+	nonce     uint64 // our version nonce (detects connections to self)
+	quit      chan struct{}
+	wg        sync.WaitGroup
+	mu        sync.Mutex // protects below.
+	headers   *headerChain
+	synced    bool           // a peer has sent all of its headers
+	peers     map[*peer]bool // connected peers (after handshake)
+	listeners []chan<- giga.NodeEvent
+}
+
+// NewL1P2P returns a giga.L1 implementor that follows the chain from
+// the P2P peers in config.Core.P2PPeers on config.Gigawallet.Network
+// Allows other functions to delegate to another L1 implementation.
+func NewL1P2P(config giga.Config, fallback giga.L1) (*L1P2P, error) {
+	chain, err := doge.ChainFromName(config.Gigawallet.Network)
+	if err != nil {
+		return nil, fmt.Errorf("P2P: unknown network %q (must be mainnet, testnet or regtest)", config.Gigawallet.Network)
+	}
+	if len(config.Core.P2PPeers) < 1 {
+		return nil, errors.New("P2P: no peers configured (P2PPeers)")
+	}
+	addrs := make([]string, 0, len(config.Core.P2PPeers))
+	for _, addr := range config.Core.P2PPeers {
+		if _, _, err := net.SplitHostPort(addr); err != nil {
+			addr = net.JoinHostPort(addr, strconv.Itoa(chain.DefaultPort))
+		}
+		addrs = append(addrs, addr)
+	}
+	baseHash, baseHeight := chain.GenesisBlock, int64(0)
+	if config.Core.P2PStartHash != "" {
+		baseHash, baseHeight = config.Core.P2PStartHash, config.Core.P2PStartHeight
+	}
+	base, err := hashFromHex(baseHash)
+	if err != nil {
+		return nil, fmt.Errorf("P2P: P2PStartHash: %v", err)
+	}
+	return &L1P2P{
+		fallback: fallback,
+		chain:    chain,
+		addrs:    addrs,
+		nonce:    rand.Uint64(),
+		quit:     make(chan struct{}),
+		headers:  newHeaderChain(chain, base, baseHeight),
+		peers:    make(map[*peer]bool),
+	}, nil
+}
+
+func (l *L1P2P) Subscribe(ch chan<- giga.NodeEvent) {
+	l.mu.Lock()
+	l.listeners = append(l.listeners, ch)
+	l.mu.Unlock()
+}
+
+func (l *L1P2P) Run(started, stopped chan bool, stop chan context.Context) error {
+	for _, addr := range l.addrs {
+		l.wg.Add(1)
+		go l.runPeer(addr)
+	}
+	go func() {
+		started <- true
+		<-stop
+		close(l.quit)
+		l.wg.Wait()
+		close(stopped)
+	}()
+	return nil
+}
+
+func (l *L1P2P) addPeer(p *peer) {
+	l.mu.Lock()
+	l.peers[p] = true
+	l.mu.Unlock()
+}
+
+func (l *L1P2P) removePeer(p *peer) {
+	l.mu.Lock()
+	delete(l.peers, p)
+	l.mu.Unlock()
+}
+
+func (l *L1P2P) hasHeader(hash Hash) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.headers.get(hash) != nil
+}
+
+func (l *L1P2P) tipLocator() []Hash {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.headers.locator(l.headers.tip())
+}
+
+// addHeaders adds headers from a peer; returns a locator to request
+// more headers from the same peer, or nil if the peer has no more.
+// Returns an error if the peer sent an invalid header.
+func (l *L1P2P) addHeaders(headers []wireHeader) ([]Hash, error) {
+	l.mu.Lock()
+	var last *headerNode
+	changed := false
+	for _, h := range headers {
+		n, tipChanged, err := l.headers.add(h)
+		if err == errOrphanHeader {
+			// New block(s) we can't connect: ask for headers from our tip.
+			locator := l.headers.locator(l.headers.tip())
+			l.mu.Unlock()
+			return locator, nil
+		}
+		if err != nil {
+			l.mu.Unlock()
+			return nil, err
+		}
+		if n != nil {
+			last = n
+		}
+		changed = changed || tipChanged
+	}
+	var locator []Hash
+	if len(headers) >= MaxHeaders && last != nil {
+		// The peer has more headers (continue from the last one)
+		locator = l.headers.locator(last)
+	} else if len(headers) < MaxHeaders && !l.synced {
+		l.synced = true
+		log.Printf("P2P: header chain synced (height %d)", l.headers.tip().height)
+	}
+	tip := l.headers.tip().hash.String()
+	listeners := l.listeners
+	notify := changed && l.synced
+	l.mu.Unlock()
+	if notify {
+		for _, ch := range listeners {
+			ch <- giga.NodeEvent{Type: giga.Block, ID: tip}
+		}
+	}
+	return locator, nil
+}
+
+// fetchBlock requests a block from each connected peer in turn.
+func (l *L1P2P) fetchBlock(hash Hash) ([]byte, error) {
+	l.mu.Lock()
+	peers := make([]*peer, 0, len(l.peers))
+	for p := range l.peers {
+		peers = append(peers, p)
+	}
+	l.mu.Unlock()
+	err := errors.New("no peers connected")
+	for _, p := range peers {
+		var data []byte
+		data, err = p.requestBlock(hash)
+		if err == nil {
+			return data, nil
+		}
+		log.Printf("P2P: %s: %v", p.addr, err)
+	}
+	return nil, fmt.Errorf("P2P: cannot fetch block %v: %v", hash, err)
+}
+
+// Find a header by hash (caller must hold l.mu)
+func (l *L1P2P) findHeader(blockHash string) (*headerNode, error) {
+	hash, err := hashFromHex(blockHash)
+	if err != nil {
+		return nil, fmt.Errorf("P2P: %v", err)
+	}
+	n := l.headers.get(hash)
+	if n == nil {
+		return nil, fmt.Errorf("P2P: block not found: %v", blockHash)
+	}
+	return n, nil
+}
+
+func (l *L1P2P) GetBlockHex(blockHash string) (hex string, err error) {
+	hash, err := hashFromHex(blockHash)
+	if err != nil {
+		return "", fmt.Errorf("P2P: %v", err)
+	}
+	data, err := l.fetchBlock(hash)
+	if err != nil {
+		return "", err
+	}
+	return doge.HexEncode(data), nil
+}
+
+func (l *L1P2P) GetBlockHeader(blockHash string) (txn giga.RpcBlockHeader, err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	n, err := l.findHeader(blockHash)
+	if err != nil {
+		return
+	}
+	txn.Hash = n.hash.String()
+	txn.Height = n.height
+	txn.Confirmations = -1
+	if l.headers.onMain(n) {
+		txn.Confirmations = l.headers.tip().height - n.height + 1
+		if next := l.headers.atHeight(n.height + 1); next != nil {
+			txn.NextBlockHash = next.hash.String()
+		}
+	}
+	if n.prev != nil {
+		hdr := doge.DecodeBlockHeader(n.raw[:])
+		txn.Version = int(hdr.Version)
+		txn.VersionHex = fmt.Sprintf("%08x", hdr.Version)
+		txn.MerkleRoot = doge.HexEncodeReversed(hdr.MerkleRoot)
+		txn.Time = int(hdr.Timestamp)
+		txn.Nonce = int(hdr.Nonce)
+		txn.Bits = fmt.Sprintf("%08x", hdr.Bits)
+		txn.PreviousBlockHash = n.prev.hash.String()
+	}
+	return
+}
+
+func (l *L1P2P) GetRawBlockHeader(blockHash string) (bytes []byte, err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	n, err := l.findHeader(blockHash)
+	if err != nil {
+		return nil, err
+	}
+	if n.prev == nil {
+		return nil, fmt.Errorf("P2P: header not available for the starting block: %v", blockHash)
+	}
+	return append([]byte(nil), n.raw[:]...), nil
+}
+
+func (l *L1P2P) GetBlockHash(height int64) (hash string, err error) {
+	if height == 0 {
+		return l.chain.GenesisBlock, nil // even if we started later.
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	n := l.headers.atHeight(height)
+	if n == nil {
+		return "", fmt.Errorf("P2P: block height out of range: %v", height)
+	}
+	return n.hash.String(), nil
+}
+
+func (l *L1P2P) GetBestBlockHash() (blockHash string, err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.headers.tip().hash.String(), nil
+}
+
+func (l *L1P2P) GetBlockCount() (blockCount int64, err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.headers.tip().height, nil
+}
+
+func (l *L1P2P) GetBlockchainInfo() (info giga.RpcBlockchainInfo, err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	tip := l.headers.tip()
+	switch l.chain {
+	case &doge.DogeMainNetChain:
+		info.Chain = "main"
+	case &doge.DogeTestNetChain:
+		info.Chain = "test"
+	default:
+		info.Chain = "regtest"
+	}
+	info.Blocks = tip.height
+	info.Headers = tip.height
+	info.BestBlockHash = tip.hash.String()
+	info.InitialBlockDownload = !l.synced // until we have all headers.
+	return
+}
+
+func (l *L1P2P) MakeAddress(isTestNet bool) (giga.Address, giga.Privkey, error) {
+	if l.fallback != nil {
+		return l.fallback.MakeAddress(isTestNet)
+	}
+	return "", "", fmt.Errorf("not implemented")
+}
+
+func (l *L1P2P) MakeChildAddress(privkey giga.Privkey, addressIndex uint32, isInternal bool) (giga.Address, error) {
+	if l.fallback != nil {
+		return l.fallback.MakeChildAddress(privkey, addressIndex, isInternal)
+	}
+	return "", fmt.Errorf("not implemented")
+}
+
+func (l *L1P2P) MakeTransaction(inputs []giga.UTXO, outputs []giga.NewTxOut, fee giga.CoinAmount, change giga.Address, private_key giga.Privkey) (giga.NewTxn, error) {
+	if l.fallback != nil {
+		return l.fallback.MakeTransaction(inputs, outputs, fee, change, private_key)
+	}
+	return giga.NewTxn{}, fmt.Errorf("not implemented")
+}
+
+func (l *L1P2P) SignTransaction(txnHex string, inputs []giga.UTXO, private_key giga.Privkey) (string, error) {
+	if l.fallback != nil {
+		return l.fallback.SignTransaction(txnHex, inputs, private_key)
+	}
+	return "", fmt.Errorf("not implemented")
+}
+
+func (l *L1P2P) SignTransactionWithKey(txnHex string, inputs []giga.UTXO, ec_privkey_wif string) (string, error) {
+	if l.fallback != nil {
+		return l.fallback.SignTransactionWithKey(txnHex, inputs, ec_privkey_wif)
+	}
+	return "", fmt.Errorf("not implemented")
+}
+
+func (l *L1P2P) DecodeTransaction(txnHex string) (giga.RawTxn, error) {
+	if l.fallback != nil {
+		return l.fallback.DecodeTransaction(txnHex)
+	}
+	return giga.RawTxn{}, fmt.Errorf("not implemented")
+}
+
+func (l *L1P2P) GetBlock(blockHash string) (txn giga.RpcBlock, err error) {
+	if l.fallback != nil {
+		return l.fallback.GetBlock(blockHash)
+	}
+	return giga.RpcBlock{}, fmt.Errorf("not implemented")
+}
+
+func (l *L1P2P) GetTransaction(txnHash string) (txn giga.RawTxn, err error) {
+	if l.fallback != nil {
+		return l.fallback.GetTransaction(txnHash)
+	}
+	return giga.RawTxn{}, fmt.Errorf("not implemented")
+}
+
+func (l *L1P2P) ScanAddressUTXOs(address giga.Address) ([]giga.UTXO, error) {
+	if l.fallback != nil {
+		return l.fallback.ScanAddressUTXOs(address)
+	}
+	return nil, fmt.Errorf("not implemented")
+}
+
+func (l *L1P2P) Send(txnHex string) (txid string, err error) {
+	if l.fallback != nil {
+		return l.fallback.Send(txnHex)
+	}
+	return "", fmt.Errorf("not implemented")
+}
+
+func (l *L1P2P) EstimateFee(confirmTarget int) (feePerKB giga.CoinAmount, err error) {
+	if l.fallback != nil {
+		return l.fallback.EstimateFee(confirmTarget)
+	}
+	return giga.ZeroCoins, fmt.Errorf("not implemented")
+}
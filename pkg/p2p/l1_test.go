@@ -0,0 +1,361 @@
+package p2p
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	giga "github.com/dogecoinfoundation/gigawallet/pkg"
+	"github.com/dogecoinfoundation/gigawallet/pkg/doge"
+)
+
+// A block served by the fakePeer.
+type fakeBlock struct {
+	hash   Hash
+	prev   Hash
+	header []byte // header and AuxPoW, as in a `headers` message
+	data   []byte // the whole block
+}
+
+// fakePeer is a minimal Dogecoin node for testing: it serves headers
+// and blocks for one chain, and can announce new blocks.
+type fakePeer struct {
+	t      *testing.T
+	ln     net.Listener
+	magic  [4]byte
+	mu     sync.Mutex
+	chain  []*fakeBlock // best chain, from height 1
+	blocks map[Hash]*fakeBlock
+	conn   net.Conn
+}
+
+func newFakePeer(t *testing.T) *fakePeer {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	p := &fakePeer{t: t, ln: ln, magic: doge.DogeRegTestChain.MessageStart, blocks: make(map[Hash]*fakeBlock)}
+	go p.serve()
+	return p
+}
+
+// makeBlock creates a block on top of `prev` with a single coinbase tx,
+// mined at the regtest difficulty (merge-mined if auxPow is set)
+func makeBlock(prev Hash, nonce uint32, auxPow bool) *fakeBlock {
+	tx := doge.EncodeTx(doge.BlockTx{
+		Version: 1,
+		VIn:     []doge.BlockTxIn{{TxID: make([]byte, 32), VOut: doge.CoinbaseVOut, Script: []byte{byte(nonce), 0x01}, Sequence: 0xffffffff}},
+		VOut:    []doge.BlockTxOut{{Value: 10000 * 100_000_000, Script: []byte{0x51}}},
+	})
+	hdr := doge.BlockHeader{
+		Version:    0x00620004,
+		PrevBlock:  prev[:],
+		MerkleRoot: doge.DoubleSha256(tx), // single transaction.
+		Timestamp:  1700000000 + nonce,
+		Bits:       0x207fffff,
+		Nonce:      nonce << 16,
+	}
+	if auxPow {
+		hdr.Version |= doge.VersionAuxPoW
+	} else {
+		mine(&hdr)
+	}
+	raw := doge.EncodeBlockHeader(hdr)
+	header := append([]byte(nil), raw...)
+	if auxPow {
+		// The parent coinbase commits to our block hash (the chain merkle
+		// root, with an empty branch) followed by the tree size and nonce.
+		script := append([]byte{0x01}, doge.MergedMiningHeader...)
+		script = append(script, doge.DoubleSha256(raw)...)
+		reverse(script[len(script)-32:])
+		script = append(script, 1, 0, 0, 0, 0, 0, 0, 0)
+		coinbase := doge.EncodeTx(doge.BlockTx{
+			Version: 1,
+			VIn:     []doge.BlockTxIn{{TxID: make([]byte, 32), VOut: doge.CoinbaseVOut, Script: script, Sequence: 0xffffffff}},
+			VOut:    []doge.BlockTxOut{{Value: 0, Script: []byte{0x51}}},
+		})
+		parent := doge.BlockHeader{
+			Version:    4, // not our chain ID.
+			PrevBlock:  make([]byte, 32),
+			MerkleRoot: doge.DoubleSha256(coinbase),
+			Timestamp:  hdr.Timestamp,
+			Bits:       hdr.Bits,
+		}
+		mine(&parent)
+		header = append(header, coinbase...)                       // parent coinbase tx
+		header = append(header, make([]byte, 32)...)               // parent hash
+		header = append(header, 0, 0, 0, 0, 0)                     // coinbase branch (empty)
+		header = append(header, 0, 0, 0, 0, 0)                     // blockchain branch (empty)
+		header = append(header, doge.EncodeBlockHeader(parent)...) // parent header
+	}
+	data := append(append(append([]byte(nil), header...), 1), tx...)
+	return &fakeBlock{hash: hashOfHeader(raw), prev: prev, header: header, data: data}
+}
+
+// mine finds a nonce that meets the header's target.
+func mine(hdr *doge.BlockHeader) {
+	for doge.CheckProofOfWork(doge.ScryptHash(doge.EncodeBlockHeader(*hdr)), hdr.Bits, &doge.DogeRegTestChain) != nil {
+		hdr.Nonce++
+	}
+}
+
+func reverse(b []byte) {
+	for i, j := 0, len(b)-1; i < j; i, j = i+1, j-1 {
+		b[i], b[j] = b[j], b[i]
+	}
+}
+
+// extend adds blocks to the chain after `from` (genesis if nil)
+func (p *fakePeer) extend(from *fakeBlock, count int, nonce uint32) *fakeBlock {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	prev, _ := hashFromHex(doge.DogeRegTestChain.GenesisBlock)
+	height := 0
+	if from != nil {
+		prev = from.hash
+		for i, b := range p.chain {
+			if b == from {
+				height = i + 1
+			}
+		}
+	}
+	p.chain = p.chain[:height]
+	var b *fakeBlock
+	for i := 0; i < count; i++ {
+		b = makeBlock(prev, nonce+uint32(i), len(p.chain) == 1) // AuxPoW at height 2
+		p.blocks[b.hash] = b
+		p.chain = append(p.chain, b)
+		prev = b.hash
+	}
+	return b
+}
+
+func (p *fakePeer) announce(b *fakeBlock) {
+	p.mu.Lock()
+	conn := p.conn
+	p.mu.Unlock()
+	writeMessage(conn, p.magic, "inv", encodeInv([]invVect{{Type: MSG_BLOCK, Hash: b.hash}}))
+}
+
+func (p *fakePeer) serve() {
+	conn, err := p.ln.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	p.mu.Lock()
+	p.conn = conn
+	p.mu.Unlock()
+	for {
+		command, payload, err := readMessage(conn, p.magic)
+		if err != nil {
+			return
+		}
+		switch command {
+		case "version":
+			writeMessage(conn, p.magic, "version", encodeVersion(conn.RemoteAddr(), 1))
+			writeMessage(conn, p.magic, "verack", nil)
+		case "getheaders":
+			writeMessage(conn, p.magic, "headers", p.headersAfter(payload))
+		case "getdata":
+			inv, err := decodeInv(payload)
+			if err != nil {
+				p.t.Errorf("fakePeer: getdata: %v", err)
+				return
+			}
+			for _, v := range inv {
+				p.mu.Lock()
+				b := p.blocks[v.Hash]
+				p.mu.Unlock()
+				if b != nil {
+					writeMessage(conn, p.magic, "block", b.data)
+				} else {
+					writeMessage(conn, p.magic, "notfound", encodeInv([]invVect{v}))
+				}
+			}
+		}
+	}
+}
+
+// headersAfter answers getheaders: headers after the first locator
+// hash found in our best chain.
+func (p *fakePeer) headersAfter(getheaders []byte) []byte {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	s := doge.NewStream(getheaders)
+	s.Uint32le() // version
+	count := s.VarUint()
+	start := 0
+	for i := uint64(0); i < count; i++ {
+		var h Hash
+		copy(h[:], s.Bytes(32))
+		found := false
+		for height, b := range p.chain {
+			if b.hash == h {
+				start, found = height+1, true
+			}
+		}
+		if found {
+			break
+		}
+	}
+	buf := appendVarUint(nil, uint64(len(p.chain)-start))
+	for _, b := range p.chain[start:] {
+		buf = append(append(buf, b.header...), 0) // no transactions.
+	}
+	return buf
+}
+
+func TestL1P2P(t *testing.T) {
+	peer := newFakePeer(t)
+	defer peer.ln.Close()
+	b2 := peer.extend(nil, 2, 1)
+	b4 := peer.extend(b2, 2, 3)
+
+	conf := giga.TestConfig()
+	conf.Gigawallet.Network = "regtest"
+	conf.Core.P2PPeers = []string{peer.ln.Addr().String()}
+	l, err := NewL1P2P(conf, nil)
+	if err != nil {
+		t.Fatalf("NewL1P2P: %v", err)
+	}
+	events := make(chan giga.NodeEvent, 10)
+	l.Subscribe(events)
+	started, stopped, stop := make(chan bool, 1), make(chan bool), make(chan context.Context, 1)
+	err = l.Run(started, stopped, stop)
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	defer func() {
+		stop <- context.Background()
+		<-stopped
+	}()
+
+	// Wait for the header chain to sync.
+	for i := 0; ; i++ {
+		info, _ := l.GetBlockchainInfo()
+		if !info.InitialBlockDownload {
+			break
+		}
+		if i > 50 {
+			t.Fatalf("header chain did not sync")
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	info, _ := l.GetBlockchainInfo()
+	if info.Chain != "regtest" || info.Blocks != 4 || info.BestBlockHash != b4.hash.String() {
+		t.Errorf("wrong blockchain info: %+v", info)
+	}
+	genesis, _ := l.GetBlockHash(0)
+	if genesis != doge.DogeRegTestChain.GenesisBlock {
+		t.Errorf("wrong genesis block: %v", genesis)
+	}
+	for height, b := range peer.chain {
+		hash, err := l.GetBlockHash(int64(height + 1))
+		if err != nil || hash != b.hash.String() {
+			t.Errorf("GetBlockHash(%d): %v %v", height+1, hash, err)
+		}
+	}
+
+	// Header for the AuxPoW block (headers include AuxPoW)
+	b3 := peer.chain[2]
+	hdr, err := l.GetBlockHeader(b2.hash.String())
+	if err != nil {
+		t.Fatalf("GetBlockHeader: %v", err)
+	}
+	if hdr.Height != 2 || hdr.Confirmations != 3 || hdr.NextBlockHash != b3.hash.String() || hdr.PreviousBlockHash != peer.chain[0].hash.String() {
+		t.Errorf("wrong block header: %+v", hdr)
+	}
+	raw, err := l.GetRawBlockHeader(b2.hash.String())
+	if err != nil || hashOfHeader(raw) != b2.hash {
+		t.Errorf("GetRawBlockHeader: %x %v", raw, err)
+	}
+
+	// Fetch the AuxPoW block.
+	hex, err := l.GetBlockHex(b2.hash.String())
+	if err != nil {
+		t.Fatalf("GetBlockHex: %v", err)
+	}
+	data, _ := doge.HexDecode(hex)
+	block, err := doge.DecodeBlock(data, b2.hash.String(), true)
+	if err != nil || block.AuxPoW == nil || len(block.Tx) != 1 {
+		t.Errorf("DecodeBlock: %v", err)
+	}
+	_, err = l.GetBlockHex(makeBlock(b4.hash, 99, false).hash.String())
+	if err == nil {
+		t.Errorf("GetBlockHex: expecting an error for an unknown block")
+	}
+
+	// Reorg: the peer announces a longer fork from block 2.
+	b5 := peer.extend(b2, 3, 10)
+	peer.announce(b5)
+	for done := false; !done; {
+		select {
+		case e := <-events:
+			done = e.Type == giga.Block && e.ID == b5.hash.String()
+		case <-time.After(5 * time.Second):
+			t.Fatalf("no tip event after reorg")
+		}
+	}
+	hdr, _ = l.GetBlockHeader(b3.hash.String())
+	if hdr.Confirmations != -1 || hdr.NextBlockHash != "" {
+		t.Errorf("expecting block 3 off-chain after reorg: %+v", hdr)
+	}
+	hash, _ := l.GetBlockHash(3)
+	if hash != peer.chain[2].hash.String() {
+		t.Errorf("wrong block 3 after reorg: %v", hash)
+	}
+	count, _ := l.GetBlockCount()
+	if count != 5 {
+		t.Errorf("wrong block count after reorg: %v", count)
+	}
+}
+
+func TestHeaderChainChecks(t *testing.T) {
+	genesis, _ := hashFromHex(doge.DogeRegTestChain.GenesisBlock)
+	c := newHeaderChain(&doge.DogeRegTestChain, genesis, 0)
+	wire := func(b *fakeBlock) wireHeader {
+		headers, err := decodeHeaders(append(append([]byte{1}, b.header...), 0))
+		if err != nil {
+			t.Fatalf("decodeHeaders: %v", err)
+		}
+		return headers[0]
+	}
+	b1 := makeBlock(genesis, 1, false)
+	b2 := makeBlock(b1.hash, 2, true)
+	for _, b := range []*fakeBlock{b1, b2} {
+		if _, _, err := c.add(wire(b)); err != nil {
+			t.Fatalf("add: %v", err)
+		}
+	}
+
+	// Does not meet its target.
+	h := wire(makeBlock(b2.hash, 3, false))
+	for doge.CheckProofOfWork(doge.ScryptHash(h.Raw), h.Header.Bits, &doge.DogeRegTestChain) == nil {
+		h.Header.Nonce++
+		h.Raw = doge.EncodeBlockHeader(h.Header)
+		h.Hash = hashOfHeader(h.Raw)
+	}
+	if _, _, err := c.add(h); err == nil {
+		t.Errorf("add: expecting a proof-of-work error")
+	}
+	// Merge-mined, but the AuxPoW commits to a different block.
+	h = wire(makeBlock(b2.hash, 4, true))
+	h.AuxPoW = wire(b2).AuxPoW
+	if _, _, err := c.add(h); err == nil {
+		t.Errorf("add: expecting an AuxPoW error")
+	}
+	// Valid proof-of-work, but not the expected difficulty (regtest never retargets)
+	hdr := doge.DecodeBlockHeader(makeBlock(b2.hash, 5, false).header)
+	hdr.Bits = 0x2000ffff
+	mine(&hdr)
+	raw := doge.EncodeBlockHeader(hdr)
+	if _, _, err := c.add(wireHeader{Hash: hashOfHeader(raw), Header: hdr, Raw: raw}); err == nil {
+		t.Errorf("add: expecting a difficulty error")
+	}
+	if c.tip() != c.get(b2.hash) {
+		t.Errorf("expecting block 2 to be the tip")
+	}
+}
@@ -0,0 +1,252 @@
+package p2p
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	DIAL_TIMEOUT      = 10 * time.Second
+	HANDSHAKE_TIMEOUT = 30 * time.Second
+	IDLE_TIMEOUT      = 10 * time.Minute // peers ping every 2 minutes
+	BLOCK_TIMEOUT     = 60 * time.Second
+	RECONNECT_DELAY   = 10 * time.Second
+)
+
+var errPeerClosed = errors.New("peer disconnected")
+
+type blockReply struct {
+	data []byte
+	err  error
+}
+
+// A connection to a single P2P peer.
+type peer struct {
+	l        *L1P2P
+	addr     string
+	conn     net.Conn
+	wmu      sync.Mutex // serialises writes to conn
+	height   int64      // start_height from the peer's version
+	mu       sync.Mutex // protects requests, closed
+	requests map[Hash][]chan blockReply
+	closed   bool
+}
+
+// runPeer connects to a peer and reconnects after errors, until stopped.
+func (l *L1P2P) runPeer(addr string) {
+	defer l.wg.Done()
+	for {
+		err := l.connect(addr)
+		select {
+		case <-l.quit:
+			return
+		default:
+		}
+		log.Printf("P2P: %s: %v (will reconnect)", addr, err)
+		select {
+		case <-l.quit:
+			return
+		case <-time.After(RECONNECT_DELAY):
+		}
+	}
+}
+
+func (l *L1P2P) connect(addr string) error {
+	conn, err := net.DialTimeout("tcp", addr, DIAL_TIMEOUT)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-l.quit:
+			conn.Close() // unblock reads.
+		case <-done:
+		}
+	}()
+	p := &peer{l: l, addr: addr, conn: conn, requests: make(map[Hash][]chan blockReply)}
+	defer p.close()
+	err = p.handshake()
+	if err != nil {
+		return fmt.Errorf("handshake: %v", err)
+	}
+	log.Printf("P2P: %s: connected (height %d)", addr, p.height)
+	l.addPeer(p)
+	defer l.removePeer(p)
+	err = p.send("sendheaders", nil) // BIP 130: announce blocks with headers.
+	if err != nil {
+		return err
+	}
+	err = p.send("getheaders", encodeGetHeaders(l.tipLocator()))
+	if err != nil {
+		return err
+	}
+	for {
+		conn.SetReadDeadline(time.Now().Add(IDLE_TIMEOUT))
+		command, payload, err := readMessage(conn, l.chain.MessageStart)
+		if err != nil {
+			return err
+		}
+		err = p.handleMessage(command, payload)
+		if err != nil {
+			return fmt.Errorf("%s: %v", command, err)
+		}
+	}
+}
+
+func (p *peer) handshake() error {
+	p.conn.SetDeadline(time.Now().Add(HANDSHAKE_TIMEOUT))
+	defer p.conn.SetDeadline(time.Time{})
+	err := p.send("version", encodeVersion(p.conn.RemoteAddr(), p.l.nonce))
+	if err != nil {
+		return err
+	}
+	gotVersion, gotVerack := false, false
+	for !gotVersion || !gotVerack {
+		command, payload, err := readMessage(p.conn, p.l.chain.MessageStart)
+		if err != nil {
+			return err
+		}
+		switch command {
+		case "version":
+			v, err := decodeVersion(payload)
+			if err != nil {
+				return err
+			}
+			if v.Nonce == p.l.nonce {
+				return errors.New("connected to self")
+			}
+			p.height = v.StartHeight
+			gotVersion = true
+			err = p.send("verack", nil)
+			if err != nil {
+				return err
+			}
+		case "verack":
+			gotVerack = true
+		}
+	}
+	return nil
+}
+
+func (p *peer) send(command string, payload []byte) error {
+	p.wmu.Lock()
+	defer p.wmu.Unlock()
+	p.conn.SetWriteDeadline(time.Now().Add(HANDSHAKE_TIMEOUT))
+	return writeMessage(p.conn, p.l.chain.MessageStart, command, payload)
+}
+
+func (p *peer) handleMessage(command string, payload []byte) error {
+	switch command {
+	case "ping":
+		return p.send("pong", payload) // echo the nonce.
+	case "headers":
+		headers, err := decodeHeaders(payload)
+		if err != nil {
+			return err
+		}
+		locator, err := p.l.addHeaders(headers)
+		if err != nil {
+			return err // disconnect.
+		}
+		if locator != nil {
+			return p.send("getheaders", encodeGetHeaders(locator))
+		}
+	case "inv":
+		inv, err := decodeInv(payload)
+		if err != nil {
+			return err
+		}
+		for _, v := range inv {
+			if v.Type == MSG_BLOCK && !p.l.hasHeader(v.Hash) {
+				// Ask for headers up to the announced block.
+				return p.send("getheaders", encodeGetHeaders(p.l.tipLocator()))
+			}
+		}
+	case "block":
+		if len(payload) < 80 {
+			return errors.New("block too short")
+		}
+		p.deliver(hashOfHeader(payload[:80]), blockReply{data: payload})
+	case "notfound":
+		inv, err := decodeInv(payload)
+		if err != nil {
+			return err
+		}
+		for _, v := range inv {
+			if v.Type == MSG_BLOCK {
+				p.deliver(v.Hash, blockReply{err: fmt.Errorf("block not found: %v", v.Hash)})
+			}
+		}
+	}
+	return nil
+}
+
+// requestBlock fetches a block from this peer.
+func (p *peer) requestBlock(hash Hash) ([]byte, error) {
+	ch := make(chan blockReply, 1)
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil, errPeerClosed
+	}
+	p.requests[hash] = append(p.requests[hash], ch)
+	p.mu.Unlock()
+	err := p.send("getdata", encodeInv([]invVect{{Type: MSG_BLOCK, Hash: hash}}))
+	if err != nil {
+		p.cancel(hash, ch)
+		return nil, err
+	}
+	select {
+	case reply := <-ch:
+		return reply.data, reply.err
+	case <-time.After(BLOCK_TIMEOUT):
+		p.cancel(hash, ch)
+		return nil, fmt.Errorf("timeout waiting for block: %v", hash)
+	}
+}
+
+func (p *peer) deliver(hash Hash, reply blockReply) {
+	p.mu.Lock()
+	waiting := p.requests[hash]
+	delete(p.requests, hash)
+	p.mu.Unlock()
+	for _, ch := range waiting {
+		ch <- reply // buffered.
+	}
+}
+
+func (p *peer) cancel(hash Hash, ch chan blockReply) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	waiting := p.requests[hash]
+	for i, c := range waiting {
+		if c == ch {
+			p.requests[hash] = append(waiting[:i], waiting[i+1:]...)
+			break
+		}
+	}
+	if len(p.requests[hash]) == 0 {
+		delete(p.requests, hash)
+	}
+}
+
+// close fails all outstanding requests.
+func (p *peer) close() {
+	p.mu.Lock()
+	p.closed = true
+	requests := p.requests
+	p.requests = nil
+	p.mu.Unlock()
+	for _, waiting := range requests {
+		for _, ch := range waiting {
+			ch <- blockReply{err: errPeerClosed}
+		}
+	}
+}
@@ -0,0 +1,239 @@
+package p2p
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"time"
+
+	"github.com/dogecoinfoundation/gigawallet/pkg/doge"
+)
+
+const (
+	ProtocolVersion = 70015              // Dogecoin Core 1.14
+	UserAgent       = "/GigaWallet:0.1/" // BIP 14
+	MaxMessageSize  = doge.MaxVarIntSize // MAX_SIZE from Dogecoin Core (serialize.h)
+	MaxHeaders      = 2000               // MAX_HEADERS_RESULTS from Dogecoin Core
+	MaxInv          = 50000              // MAX_INV_SZ from Dogecoin Core
+
+	MSG_TX    = 1 // inventory types
+	MSG_BLOCK = 2
+
+	headerSize = 24 // message header: magic, command, length, checksum
+)
+
+// Hash is a block hash in internal byte order (the hex form is reversed)
+type Hash [32]byte
+
+func (h Hash) String() string {
+	return doge.HexEncodeReversed(h[:])
+}
+
+func hashFromHex(str string) (h Hash, err error) {
+	b, err := doge.HexDecode(str)
+	if err != nil || len(b) != 32 {
+		return h, fmt.Errorf("invalid block hash: %v", str)
+	}
+	for i := 0; i < 32; i++ {
+		h[i] = b[31-i]
+	}
+	return h, nil
+}
+
+func hashOfHeader(header []byte) (h Hash) {
+	copy(h[:], doge.DoubleSha256(header))
+	return
+}
+
+type invVect struct {
+	Type uint32
+	Hash Hash
+}
+
+// writeMessage frames and sends a P2P message.
+func writeMessage(w io.Writer, magic [4]byte, command string, payload []byte) error {
+	if len(command) > 12 {
+		return fmt.Errorf("command too long: %v", command)
+	}
+	msg := make([]byte, headerSize, headerSize+len(payload))
+	copy(msg[0:4], magic[:])
+	copy(msg[4:16], command)
+	binary.LittleEndian.PutUint32(msg[16:20], uint32(len(payload)))
+	copy(msg[20:24], doge.DoubleSha256(payload)[:4])
+	msg = append(msg, payload...)
+	_, err := w.Write(msg)
+	return err
+}
+
+// readMessage reads the next P2P message, verifying magic and checksum.
+func readMessage(r io.Reader, magic [4]byte) (command string, payload []byte, err error) {
+	var hdr [headerSize]byte
+	if _, err = io.ReadFull(r, hdr[:]); err != nil {
+		return
+	}
+	if !bytes.Equal(hdr[0:4], magic[:]) {
+		return "", nil, fmt.Errorf("wrong network magic: %x", hdr[0:4])
+	}
+	command = string(bytes.TrimRight(hdr[4:16], "\x00"))
+	size := binary.LittleEndian.Uint32(hdr[16:20])
+	if size > MaxMessageSize {
+		return "", nil, fmt.Errorf("message too large: %v (%v bytes)", command, size)
+	}
+	payload = make([]byte, size)
+	if _, err = io.ReadFull(r, payload); err != nil {
+		return
+	}
+	if !bytes.Equal(hdr[20:24], doge.DoubleSha256(payload)[:4]) {
+		return "", nil, fmt.Errorf("bad checksum: %v", command)
+	}
+	return
+}
+
+func encodeVersion(remote net.Addr, nonce uint64) []byte {
+	buf := appendUint32le(nil, ProtocolVersion)
+	buf = appendUint64le(buf, 0) // services: NODE_NONE
+	buf = appendUint64le(buf, uint64(time.Now().Unix()))
+	buf = appendNetAddr(buf, remote) // addr_recv
+	buf = appendNetAddr(buf, nil)    // addr_from
+	buf = appendUint64le(buf, nonce)
+	buf = appendVarUint(buf, uint64(len(UserAgent)))
+	buf = append(buf, UserAgent...)
+	buf = appendUint32le(buf, 0) // start_height
+	return append(buf, 0)        // relay: we don't want transactions
+}
+
+type versionMsg struct {
+	Version     uint32
+	Services    uint64
+	Nonce       uint64
+	UserAgent   string
+	StartHeight int64
+}
+
+func decodeVersion(payload []byte) (v versionMsg, err error) {
+	s := doge.NewStream(payload)
+	v.Version = s.Uint32le()
+	v.Services = s.Uint64le()
+	s.Uint64le() // timestamp
+	s.Bytes(26)  // addr_recv
+	s.Bytes(26)  // addr_from
+	v.Nonce = s.Uint64le()
+	agentLen := s.VarUint()
+	if agentLen > 256 {
+		return v, errors.New("version: user agent too long")
+	}
+	v.UserAgent = string(s.Bytes(agentLen))
+	v.StartHeight = int64(int32(s.Uint32le()))
+	if !s.Valid() {
+		return v, errors.New("version: message too short")
+	}
+	return v, nil
+}
+
+func encodeGetHeaders(locator []Hash) []byte {
+	buf := appendUint32le(nil, ProtocolVersion)
+	buf = appendVarUint(buf, uint64(len(locator)))
+	for _, h := range locator {
+		buf = append(buf, h[:]...)
+	}
+	return append(buf, make([]byte, 32)...) // hash_stop: as many as possible
+}
+
+// A block header from a `headers` message.
+type wireHeader struct {
+	Hash   Hash
+	Header doge.BlockHeader
+	AuxPoW *doge.MerkleTx // if Header.IsAuxPoW()
+	Raw    []byte         // 80-byte header
+}
+
+func decodeHeaders(payload []byte) ([]wireHeader, error) {
+	s := doge.NewStream(payload)
+	count := s.VarUint()
+	if count > MaxHeaders {
+		return nil, fmt.Errorf("headers: too many headers: %v", count)
+	}
+	result := make([]wireHeader, 0, count)
+	for i := uint64(0); i < count; i++ {
+		hdr, auxPow, raw, err := doge.ReadBlockHeader(s, "headers")
+		if err != nil {
+			return nil, fmt.Errorf("headers: %v", err)
+		}
+		s.VarUint() // transaction count (always zero)
+		if !s.Valid() {
+			return nil, errors.New("headers: message too short")
+		}
+		result = append(result, wireHeader{Hash: hashOfHeader(raw), Header: hdr, AuxPoW: auxPow, Raw: raw})
+	}
+	if !s.Complete() {
+		return nil, errors.New("headers: did not use all data")
+	}
+	return result, nil
+}
+
+// encodeInv encodes `inv`, `getdata` and `notfound` messages.
+func encodeInv(inv []invVect) []byte {
+	buf := appendVarUint(nil, uint64(len(inv)))
+	for _, v := range inv {
+		buf = appendUint32le(buf, v.Type)
+		buf = append(buf, v.Hash[:]...)
+	}
+	return buf
+}
+
+func decodeInv(payload []byte) ([]invVect, error) {
+	s := doge.NewStream(payload)
+	count := s.VarUint()
+	if count > MaxInv {
+		return nil, fmt.Errorf("inv: too many entries: %v", count)
+	}
+	result := make([]invVect, 0, count)
+	for i := uint64(0); i < count; i++ {
+		var v invVect
+		v.Type = s.Uint32le()
+		copy(v.Hash[:], s.Bytes(32))
+		result = append(result, v)
+	}
+	if !s.Complete() {
+		return nil, errors.New("inv: wrong message size")
+	}
+	return result, nil
+}
+
+// appendNetAddr appends a network address without timestamp (as in `version`)
+func appendNetAddr(buf []byte, addr net.Addr) []byte {
+	buf = appendUint64le(buf, 0) // services
+	ip := net.IPv6zero
+	port := 0
+	if tcp, ok := addr.(*net.TCPAddr); ok {
+		ip = tcp.IP.To16()
+		port = tcp.Port
+	}
+	buf = append(buf, ip...)
+	return append(buf, byte(port>>8), byte(port)) // big-endian
+}
+
+func appendUint32le(buf []byte, val uint32) []byte {
+	return append(buf, byte(val), byte(val>>8), byte(val>>16), byte(val>>24))
+}
+
+func appendUint64le(buf []byte, val uint64) []byte {
+	return appendUint32le(appendUint32le(buf, uint32(val)), uint32(val>>32))
+}
+
+// appendVarUint appends a variable-length unsigned integer (CompactSize in Core)
+func appendVarUint(buf []byte, val uint64) []byte {
+	if val < 253 {
+		return append(buf, byte(val))
+	}
+	if val <= 0xffff {
+		return append(buf, 253, byte(val), byte(val>>8))
+	}
+	if val <= 0xffffffff {
+		return appendUint32le(append(buf, 254), uint32(val))
+	}
+	return appendUint64le(append(buf, 255), val)
+}
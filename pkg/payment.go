@@ -1,24 +1,40 @@
 package giga
 
 import (
+	"encoding/json"
+	"log"
 	"time"
 
 	"github.com/shopspring/decimal"
 )
 
+// Payment Types
+type PaymentType string
+
+const (
+	PaymentTypePayout        PaymentType = "payout"        // pay-out from the account to other addresses
+	PaymentTypeConsolidation PaymentType = "consolidation" // internal: merges small UTXOs into one change output
+)
+
 type Payment struct {
-	ID               int64      // incrementing payment number, per account
-	AccountAddress   Address    // owner account (source of funds)
-	PayTo            []PayTo    // dogecoin addresses and amounts
-	Total            CoinAmount // total paid to others (excluding fees and change)
-	Fee              CoinAmount // fee paid by the transaction
-	Created          time.Time  // when the payment was created
-	PaidTxID         string     // TXID of the Transaction that made the payment
-	PaidHeight       int64      // Block Height of the Transaction that made the payment
-	ConfirmedHeight  int64      // Block Height when payment transaction was confirmed
-	OnChainEvent     time.Time  // Time when the on-chain event was sent
-	ConfirmedEvent   time.Time  // Time when the confirmed event was sent
-	UnconfirmedEvent time.Time  // Time when the unconfirmed event was sent
+	ID                int64          // incrementing payment number, per account
+	AccountAddress    Address        // owner account (source of funds)
+	Type              PaymentType    // 'payout' or an internal payment type, see PaymentType constants
+	PayTo             []PayTo        // dogecoin addresses and amounts
+	Total             CoinAmount     // total paid to others (excluding fees and change)
+	Fee               CoinAmount     // fee paid by the transaction
+	Memo              string         // optional memo in an OP_RETURN output (decoded from the transaction)
+	UnsignedTxn       string         // UnsignedTxn (JSON) waiting for offline signing (see SubmitSignedTxn)
+	ApprovalStatus    ApprovalStatus // approval status, if the payment requires approval (see Account.RequiresApproval)
+	PendingTxn        string         // PendingTxn (JSON) waiting to be sent (see SubmitPendingPayment)
+	Created           time.Time      // when the payment was created
+	PaidTxID          string         // TXID of the Transaction that made the payment
+	PaidHeight        int64          // Block Height of the Transaction that made the payment
+	ConfirmedHeight   int64          // Block Height when payment transaction was confirmed
+	OnChainEvent      time.Time      // Time when the on-chain event was sent
+	ConfirmedEvent    time.Time      // Time when the confirmed event was sent
+	UnconfirmedEvent  time.Time      // Time when the unconfirmed event was sent
+	DoubleSpendHeight int64          // Block Height when the payment was flagged as double-spent (see MarkDoubleSpends)
 }
 
 // Pay an amount to an address
@@ -28,3 +44,144 @@ type PayTo struct {
 	PayTo            Address         `json:"to"`
 	DeductFeePercent decimal.Decimal `json:"deduct_fee_percent"`
 }
+
+// ReservePayment creates the Payment record for a new transaction (from CreateTxn)
+// up-front, in a single store transaction: it saves changes to the Account
+// (NextInternalKey) and address pool, creates the Payment (with no txid yet),
+// reserves the UTXOs being spent and creates the 'change' UTXO.
+func ReservePayment(store Store, lib L1, account Account, payType PaymentType, payTo []PayTo, memo string, total CoinAmount, fee CoinAmount, spentUTXOs []UTXO, changeUTXO UTXO) (Payment, error) {
+	dbtx, err := store.Begin()
+	if err != nil {
+		return Payment{}, err
+	}
+	payment, err := ReservePaymentTx(dbtx, lib, account, payType, payTo, memo, total, fee, spentUTXOs, changeUTXO)
+	if err != nil {
+		dbtx.Rollback()
+		return Payment{}, err
+	}
+	err = dbtx.Commit()
+	if err != nil {
+		return Payment{}, err
+	}
+	return payment, nil
+}
+
+// ReservePaymentTx is ReservePayment within the caller's store transaction,
+// so the caller can make other changes in the same transaction.
+// The caller must Commit (or Rollback on error)
+func ReservePaymentTx(dbtx StoreTransaction, lib L1, account Account, payType PaymentType, payTo []PayTo, memo string, total CoinAmount, fee CoinAmount, spentUTXOs []UTXO, changeUTXO UTXO) (Payment, error) {
+	err := account.UpdatePoolAddresses(dbtx, lib) // we used a Change address.
+	if err != nil {
+		return Payment{}, err
+	}
+	err = dbtx.UpdateAccount(account) // for NextInternalKey (change address)
+	if err != nil {
+		return Payment{}, err
+	}
+	// Create the `payment` row with no txid or paid_height.
+	payment, err := dbtx.CreatePayment(account.Address, payType, payTo, memo, total, fee)
+	if err != nil {
+		return Payment{}, err
+	}
+	// Reserve the UTXOs we're spending so they can't be double-spent.
+	for _, utxo := range spentUTXOs {
+		err = dbtx.MarkUTXOReserved(utxo.TxID, utxo.VOut, payment.ID)
+		if err != nil {
+			return Payment{}, err
+		}
+	}
+	// Create the 'change' UTXO now, so the change can be spent immediately.
+	if !changeUTXO.Value.IsZero() {
+		err = dbtx.CreateUTXO(changeUTXO)
+		if err != nil {
+			return Payment{}, err
+		}
+	}
+	return payment, nil
+}
+
+// PendingTxn is a signed transaction held on a reserved Payment until it is
+// sent (e.g. once approved, or when a batch is re-submitted)
+type PendingTxn struct {
+	TxID   string `json:"txid"`             // hash of the transaction
+	TxnHex string `json:"tx"`               // signed transaction, hex-encoded
+	Change *UTXO  `json:"change,omitempty"` // change UTXO, created when the transaction is sent
+}
+
+// SetPendingTxn stores a signed transaction on a reserved Payment (see
+// ReservePaymentTx with no change UTXO) to be sent later by SubmitPendingPayment.
+func SetPendingTxn(dbtx StoreTransaction, paymentID int64, newTxn NewTxn, txid string, changeUTXO UTXO) error {
+	pending := PendingTxn{TxID: txid, TxnHex: newTxn.TxnHex}
+	if !changeUTXO.Value.IsZero() {
+		pending.Change = &changeUTXO
+	}
+	pendingJson, err := json.Marshal(pending)
+	if err != nil {
+		return NewErr(UnknownError, "cannot encode pending transaction: %v", err)
+	}
+	return dbtx.SetPaymentPendingTxn(paymentID, string(pendingJson))
+}
+
+// DecodePendingTxn decodes the PendingTxn stored on the Payment.
+func (p Payment) DecodePendingTxn() (PendingTxn, error) {
+	var pending PendingTxn
+	if p.PendingTxn == "" {
+		return pending, NewErr(BadRequest, "payment %v has no pending transaction", p.ID)
+	}
+	err := json.Unmarshal([]byte(p.PendingTxn), &pending)
+	if err != nil {
+		return pending, NewErr(UnknownError, "cannot decode pending transaction for payment %v: %v", p.ID, err)
+	}
+	return pending, nil
+}
+
+// SubmitPendingPayment submits the PendingTxn held on a reserved Payment
+// (see SetPendingTxn) then creates its 'change' UTXO, which is not created
+// until the transaction is sent, so nothing can spend it before then.
+func SubmitPendingPayment(store Store, lib L1, paymentID int64, pending PendingTxn) error {
+	err := SubmitPayment(store, lib, paymentID, pending.TxnHex, pending.TxID, true)
+	if err != nil {
+		return err
+	}
+	if pending.Change == nil {
+		return nil
+	}
+	dbtx, err := store.Begin()
+	if err != nil {
+		return err
+	}
+	err = dbtx.CreateUTXO(*pending.Change)
+	if err != nil {
+		dbtx.Rollback()
+		return err
+	}
+	return dbtx.Commit()
+}
+
+// SubmitPayment submits the transaction for a reserved Payment to the network
+// (if sendTx is true) then updates the Payment with the txid, which changes it
+// to "accepted" status (accepted by the network)
+// If this fails to submit the tx, the UTXOs stay reserved by the Payment
+// (which has no txid) until the tx is submitted again.
+func SubmitPayment(store Store, lib L1, paymentID int64, txHex string, txid string, sendTx bool) error {
+	if sendTx {
+		// Submit tx to the network.
+		coreTxid, err := lib.Send(txHex)
+		if err != nil {
+			return err
+		}
+		if coreTxid != txid {
+			log.Printf("[!] sendrawtransaction: Core Node did not return the precomputed txid: %s (expecting %s)", coreTxid, txid)
+		}
+	}
+	dbtx, err := store.Begin()
+	if err != nil {
+		return err
+	}
+	err = dbtx.UpdatePaymentWithTxID(paymentID, txid)
+	if err != nil {
+		dbtx.Rollback()
+		return err
+	}
+	return dbtx.Commit()
+}
@@ -66,19 +66,7 @@ func SetupCallbacks(cond *conductor.Conductor, bus giga.MessageBus, conf giga.Co
 		s := NewCallbackSender(c, bus)
 		cond.Service(fmt.Sprintf("Callback sender for: %s", c.Path), s)
 
-		types := []giga.EventType{}
-		for _, t := range c.Types {
-			match := false
-			for _, x := range giga.EVENT_TYPES {
-				if t == x.Type() {
-					match = true
-					types = append(types, x)
-				}
-			}
-			if !match {
-				fmt.Printf("⚠️  Callback %s: ignoring invalid message type: %s\n", name, t)
-			}
-		}
+		types := configEventTypes("Callback", name, c.Types)
 		bus.Register(s, types...)
 	}
 }
@@ -34,7 +34,12 @@ func (l MessageLogger) Run(started, stopped chan bool, stop chan context.Context
 				close(stopped)
 				return
 			case msg := <-l.Rec:
-				l.Log.Printf("%s:%s (%s): %s\n",
+				network := ""
+				if msg.Network != "" {
+					network = msg.Network + " "
+				}
+				l.Log.Printf("%s%s:%s (%s): %s\n",
+					network,
 					msg.EventType.Type(),
 					msg.EventType,
 					msg.ID,
@@ -63,19 +68,7 @@ func SetupLoggers(cond *conductor.Conductor, bus giga.MessageBus, conf giga.Conf
 		l := NewMessageLogger(c.Path)
 		cond.Service(fmt.Sprintf("Logger %s", c.Path), l)
 
-		types := []giga.EventType{}
-		for _, t := range c.Types {
-			match := false
-			for _, x := range giga.EVENT_TYPES {
-				if t == x.Type() {
-					match = true
-					types = append(types, x)
-				}
-			}
-			if !match {
-				fmt.Printf("⚠️  Logger %s: ignoring invalid message type: %s\n", name, t)
-			}
-		}
+		types := configEventTypes("Logger", name, c.Types)
 		bus.Register(l, types...)
 	}
 }
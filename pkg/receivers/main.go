@@ -1,6 +1,8 @@
 package receivers
 
 import (
+	"fmt"
+
 	giga "github.com/dogecoinfoundation/gigawallet/pkg"
 	"github.com/dogecoinfoundation/gigawallet/pkg/conductor"
 )
@@ -16,3 +18,22 @@ func SetUpReceivers(cond *conductor.Conductor, bus giga.MessageBus, conf giga.Co
 	// Set up configured MQTT queues
 	SetupMQTTs(cond, bus, conf)
 }
+
+// Look up the configured event type names (see giga.EVENT_TYPES)
+// ignoring unknown names, for the `kind` of receiver called `name`.
+func configEventTypes(kind string, name string, names []string) []giga.EventType {
+	types := []giga.EventType{}
+	for _, t := range names {
+		match := false
+		for _, x := range giga.EVENT_TYPES {
+			if t == x.Type() {
+				match = true
+				types = append(types, x)
+			}
+		}
+		if !match {
+			fmt.Printf("⚠️  %s %s: ignoring invalid message type: %s\n", kind, name, t)
+		}
+	}
+	return types
+}
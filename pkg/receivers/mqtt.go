@@ -92,7 +92,7 @@ func (s MQTTSender) Run(started, stopped chan bool, stop chan context.Context) e
 						}
 					}
 					if !cont {
-						break
+						continue // try the next queue.
 					}
 
 					//send message to topic
@@ -0,0 +1,93 @@
+package receivers
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	giga "github.com/dogecoinfoundation/gigawallet/pkg"
+)
+
+type testSubscriber struct {
+	rec chan giga.Message
+}
+
+func (s testSubscriber) GetChan() chan giga.Message {
+	return s.rec
+}
+
+func TestConfigEventTypes(t *testing.T) {
+	types := configEventTypes("Test", "test", []string{"PAYMENT", "INV", "BOGUS"})
+	if len(types) != 2 || types[0].Type() != "PAYMENT" || types[1].Type() != "INV" {
+		t.Fatalf("expected PAYMENT and INV event types, got %v", types)
+	}
+}
+
+func TestEventRouting(t *testing.T) {
+	bus := giga.NewMessageBus()
+	sub := testSubscriber{rec: make(chan giga.Message, 10)}
+	bus.Register(sub, configEventTypes("Test", "test", []string{"PAYMENT", "WITHDRAWAL"})...)
+	started, stopped, stop := make(chan bool, 1), make(chan bool, 1), make(chan context.Context, 1)
+	bus.Run(started, stopped, stop)
+	<-started
+	defer func() {
+		stop <- context.Background()
+		<-stopped
+	}()
+
+	bus.Send(giga.ACC_CREATED, "skipped")
+	bus.Send(giga.PAYMENT_SENT, "payment")
+	bus.Send(giga.INV_TOTAL_PAYMENT_DETECTED, "skipped")
+	bus.Send(giga.WITHDRAWAL_SENT, "withdrawal")
+	for _, want := range []giga.EventType{giga.PAYMENT_SENT, giga.WITHDRAWAL_SENT} {
+		select {
+		case msg := <-sub.rec:
+			if msg.EventType != want {
+				t.Fatalf("expected %v, got %v", want, msg.EventType)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("expected %v, got nothing", want)
+		}
+	}
+	select {
+	case msg := <-sub.rec:
+		t.Fatalf("expected no more messages, got %v", msg.EventType)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestEventNetwork(t *testing.T) {
+	bus := giga.NewMessageBus()
+	sub := testSubscriber{rec: make(chan giga.Message, 10)}
+	bus.Register(sub, giga.NET_NEW_BLOCK)
+	started, stopped, stop := make(chan bool, 1), make(chan bool, 1), make(chan context.Context, 1)
+	bus.Run(started, stopped, stop)
+	<-started
+	defer func() {
+		stop <- context.Background()
+		<-stopped
+	}()
+
+	bus.ForNetwork("testnet").Send(giga.NET_NEW_BLOCK, "testnet block")
+	bus.ForNetwork("mainnet").Send(giga.NET_NEW_BLOCK, "mainnet block")
+	bus.Send(giga.NET_NEW_BLOCK, "untagged block")
+	for _, want := range []string{"testnet", "mainnet", ""} {
+		select {
+		case msg := <-sub.rec:
+			if msg.Network != want {
+				t.Fatalf("expected network %q, got %q", want, msg.Network)
+			}
+			data, err := json.Marshal(msg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tagged := strings.Contains(string(data), `"network":"`+want+`"`); tagged != (want != "") {
+				t.Fatalf("expected network %q in JSON, got %s", want, data)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("expected a %s block, got nothing", want)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package giga
+
+import "time"
+
+// Scheduled Payment Status
+type ScheduleStatus string
+
+const (
+	SchedulePending   ScheduleStatus = "pending"   // waiting for the trigger (or retrying, see NextAttempt)
+	ScheduleSent      ScheduleStatus = "sent"      // paid (see PaymentID and TxID)
+	ScheduleFailed    ScheduleStatus = "failed"    // could not be paid (see Error)
+	ScheduleCancelled ScheduleStatus = "cancelled" // cancelled before it was paid
+)
+
+// Lock times below this are block heights, otherwise unix timestamps (nLockTime)
+const LockTimeThreshold = 500000000
+
+// ScheduledPayment is a pay-out from an account at a future time or block
+// height (the trigger). The PaymentScheduler service pays it when it is due,
+// retrying if the account does not have enough funds yet.
+//
+// If LockTime is set, the transaction is created when the payment is
+// scheduled, with an nLockTime that prevents it from being mined before the
+// trigger. Its UTXOs are reserved by the Payment (see PaymentID) which holds
+// the signed transaction (as a PendingTxn) until the scheduler sends it.
+type ScheduledPayment struct {
+	ID          int64          `json:"id"`            // incrementing scheduled payment number
+	AccountID   Address        `json:"account_id"`    // owner account (source of funds)
+	PayTo       []PayTo        `json:"pay"`           // dogecoin addresses and amounts
+	Memo        string         `json:"memo"`          // optional memo in an OP_RETURN output
+	PayAt       time.Time      `json:"pay_at"`        // trigger: time to pay (zero: use PayAtHeight)
+	PayAtHeight int64          `json:"pay_at_height"` // trigger: block height to pay (zero: use PayAt)
+	LockTime    bool           `json:"lock_time"`     // create an nLockTime transaction up-front
+	Status      ScheduleStatus `json:"status"`        // see ScheduleStatus constants
+	Attempts    int            `json:"attempts"`      // number of attempts to pay so far
+	NextAttempt time.Time      `json:"next_attempt"`  // earliest time for the next attempt (retry)
+	PaymentID   int64          `json:"payment_id"`    // Payment that pays this (once paid, or reserved for LockTime)
+	TxID        string         `json:"txid"`          // TXID of the transaction (once sent)
+	Error       string         `json:"error"`         // reason for the last failed attempt (if any)
+	Created     time.Time      `json:"created"`       // when the payment was scheduled
+}
+
+// IsDue reports whether the trigger has been reached at time `now` and best
+// block `height`, and the payment is ready for its next attempt.
+func (s ScheduledPayment) IsDue(now time.Time, height int64) bool {
+	if s.Status != SchedulePending || now.Before(s.NextAttempt) {
+		return false
+	}
+	if s.PayAtHeight > 0 {
+		// a transaction with nLockTime H can be included in block H+1
+		return height >= s.PayAtHeight
+	}
+	return !now.Before(s.PayAt)
+}
+
+// TxnLockTime is the nLockTime for the trigger: a block height or unix time.
+func (s ScheduledPayment) TxnLockTime() uint32 {
+	if s.PayAtHeight > 0 {
+		return uint32(s.PayAtHeight)
+	}
+	return uint32(s.PayAt.Unix())
+}
+
+func NewScheduleEvent(acc Account, sp ScheduledPayment) ScheduleEvent {
+	return ScheduleEvent{
+		ScheduleID:  sp.ID,
+		AccountID:   acc.Address,
+		ForeignID:   acc.ForeignID,
+		PayTo:       sp.PayTo,
+		PayAt:       sp.PayAt,
+		PayAtHeight: sp.PayAtHeight,
+		Status:      sp.Status,
+		Attempts:    sp.Attempts,
+		PaymentID:   sp.PaymentID,
+		TxID:        sp.TxID,
+		Error:       sp.Error,
+	}
+}
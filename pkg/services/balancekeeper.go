@@ -352,58 +352,48 @@ func (b BalanceKeeper) sendPaymentEvents(tx giga.StoreTransaction, acc *giga.Acc
 			log.Printf("BalanceKeeper: ListPayments '%s': %v\n", id, err)
 			return err
 		}
-		// Check each payment to see if it's confirmed.
+		// Check each payment to see if it's on-chain, confirmed or rolled back.
 		for n, pay := range payments {
+			// on paid_height = 0 && has onchain_event => PAYMENT_UNCONFIRMED
+			// on confirmed_height = 0 && has confirmed_event => PAYMENT_UNCONFIRMED
 			// on paid_height <> 0 && no onchain_event => PAYMENT_ON_CHAIN
 			// on confirmed_height <> 0 && no confirmed_event => PAYMENT_CONFIRMED
-			// on confirmed_height = 0 && has confirmed_event => PAYMENT_UNCONFIRMED
+			msg := giga.PaymentEvent{
+				PaymentID: pay.ID,
+				ForeignID: acc.ForeignID,
+				AccountID: acc.Address,
+				PayTo:     pay.PayTo,
+				Total:     pay.Total,
+				TxID:      pay.PaidTxID,
+				Memo:      pay.Memo,
+			}
+			if (pay.PaidHeight == 0 && !pay.OnChainEvent.IsZero()) || (pay.ConfirmedHeight == 0 && !pay.ConfirmedEvent.IsZero()) {
+				// rollback detected (the payment's block was reorged out)
+				// this also resets the events that were rolled back.
+				err = b.sendPaymentEvent(tx, giga.PAYMENT_UNCONFIRMED, msg, fmt.Sprintf("PUC-%d-%d", cursor, num_pay+n), id)
+				if err != nil {
+					return err
+				}
+				if pay.PaidHeight == 0 {
+					pay.OnChainEvent = time.Time{}
+				}
+				if pay.ConfirmedHeight == 0 {
+					pay.ConfirmedEvent = time.Time{}
+				}
+			}
 			if pay.PaidHeight != 0 && pay.OnChainEvent.IsZero() {
 				// payment is on-chain.
-				// notify BUS listeners.
-				msg := giga.PaymentEvent{
-					PaymentID: pay.ID,
-					ForeignID: acc.ForeignID,
-					AccountID: acc.Address,
-					PayTo:     pay.PayTo,
-					Total:     pay.Total,
-					TxID:      pay.PaidTxID,
-				}
-				event := giga.PAYMENT_ON_CHAIN
-				unique_id := fmt.Sprintf("POC-%d-%d", cursor, num_pay+n)
-				err = b.bus.Send(event, msg, unique_id)
+				err = b.sendPaymentEvent(tx, giga.PAYMENT_ON_CHAIN, msg, fmt.Sprintf("POC-%d-%d", cursor, num_pay+n), id)
 				if err != nil {
-					log.Printf("BalanceKeeper: bus error for '%s': %v\n", id, err)
 					return err
 				}
-				// err = tx.MarkPaymentEventSent(pay.ID, event)
-				// if err != nil {
-				// 	log.Printf("BalanceKeeper: MarkInvoiceEventSent '%s': %v\n", id, err)
-				// 	return err
-				// }
-				b.bus.Send(giga.SYS_MSG, fmt.Sprintf("BalanceKeeper: %s: %v in %s\n", event, pay.ID, id))
-			} else if pay.ConfirmedHeight != 0 && pay.ConfirmedEvent.IsZero() {
+			}
+			if pay.ConfirmedHeight != 0 && pay.ConfirmedEvent.IsZero() {
 				// payment is confirmed.
-				msg := giga.PaymentEvent{
-					PaymentID: pay.ID,
-					ForeignID: acc.ForeignID,
-					AccountID: acc.Address,
-					PayTo:     pay.PayTo,
-					Total:     pay.Total,
-					TxID:      pay.PaidTxID,
-				}
-				event := giga.PAYMENT_CONFIRMED
-				unique_id := fmt.Sprintf("PCC-%d-%d", cursor, num_pay+n)
-				err = b.bus.Send(event, msg, unique_id)
+				err = b.sendPaymentEvent(tx, giga.PAYMENT_CONFIRMED, msg, fmt.Sprintf("PCC-%d-%d", cursor, num_pay+n), id)
 				if err != nil {
-					log.Printf("BalanceKeeper: bus error for '%s': %v\n", id, err)
 					return err
 				}
-				// err = tx.MarkInvoiceEventSent(pay.ID, event)
-				// if err != nil {
-				// 	log.Printf("BalanceKeeper: MarkInvoiceEventSent '%s': %v\n", id, err)
-				// 	return err
-				// }
-				b.bus.Send(giga.SYS_MSG, fmt.Sprintf("BalanceKeeper: %s: %v in %s\n", event, pay.ID, id))
 			}
 		}
 		num_pay += len(payments)
@@ -413,6 +403,22 @@ func (b BalanceKeeper) sendPaymentEvents(tx giga.StoreTransaction, acc *giga.Acc
 	return nil
 }
 
+// Notify BUS listeners, and mark the event sent so it is only sent once.
+func (b BalanceKeeper) sendPaymentEvent(tx giga.StoreTransaction, event giga.EVENT_PAYMENT, msg giga.PaymentEvent, unique_id string, id giga.Address) error {
+	err := b.bus.Send(event, msg, unique_id)
+	if err != nil {
+		log.Printf("BalanceKeeper: bus error for '%s': %v\n", id, err)
+		return err
+	}
+	err = tx.MarkPaymentEventSent(msg.PaymentID, event)
+	if err != nil {
+		log.Printf("BalanceKeeper: MarkPaymentEventSent '%v': %v\n", msg.PaymentID, err)
+		return err
+	}
+	b.bus.Send(giga.SYS_MSG, fmt.Sprintf("BalanceKeeper: %s: %v in %s\n", event, msg.PaymentID, id))
+	return nil
+}
+
 func (b *BalanceKeeper) beginStoreTxn() (tx giga.StoreTransaction) {
 	for {
 		tx, err := b.store.Begin()
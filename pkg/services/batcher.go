@@ -0,0 +1,311 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	giga "github.com/dogecoinfoundation/gigawallet/pkg"
+)
+
+const (
+	BATCHER_MIN_INTERVAL = 5   // minimum seconds between checks
+	BATCHER_RESUBMIT     = 100 // maximum batched withdrawals re-submitted per pass
+)
+
+// PayoutBatcher pays queued withdrawals (see API.QueueWithdrawal)
+// by combining the pending withdrawals from each account into a single
+// multi-output transaction, which saves fees and UTXOs compared to
+// sending each withdrawal separately. A batch is sent once the oldest
+// withdrawal has waited for the account's BatchInterval, or sooner if
+// there are BatchSize withdrawals pending.
+// If the batch transaction cannot be sent (e.g. Core is not available)
+// the withdrawals stay 'batched' and the transaction is re-submitted on
+// the next pass, so they can never be paid by another batch.
+type PayoutBatcher struct {
+	store    giga.Store
+	l1       giga.L1
+	bus      giga.MessageBus
+	conf     giga.BatchingConfig
+	interval time.Duration // time between checks
+}
+
+func NewPayoutBatcher(store giga.Store, l1 giga.L1, bus giga.MessageBus, conf giga.BatchingConfig) PayoutBatcher {
+	interval := conf.Interval
+	if interval < BATCHER_MIN_INTERVAL {
+		interval = BATCHER_MIN_INTERVAL
+	}
+	return PayoutBatcher{
+		store:    store,
+		l1:       l1,
+		bus:      bus,
+		conf:     conf,
+		interval: time.Duration(interval) * time.Second,
+	}
+}
+
+// Implements conductor.Service
+func (b PayoutBatcher) Run(started, stopped chan bool, stop chan context.Context) error {
+	go func() {
+		started <- true
+		for {
+			select {
+			case <-stop:
+				close(stopped)
+				return
+			case <-time.After(b.interval):
+				b.runBatches(time.Now())
+			}
+		}
+	}()
+	return nil
+}
+
+// Send a batch for each account that has withdrawals ready to send.
+func (b PayoutBatcher) runBatches(now time.Time) {
+	b.resubmitBatches()
+	tx, err := b.store.Begin()
+	if err != nil {
+		log.Println("PayoutBatcher: Begin:", err)
+		return
+	}
+	ids, err := tx.ListPendingWithdrawalAccounts()
+	tx.Rollback()
+	if err != nil {
+		log.Println("PayoutBatcher: ListPendingWithdrawalAccounts:", err)
+		return
+	}
+	for _, id := range ids {
+		err = b.batchAccount(id, now)
+		if err != nil {
+			b.bus.Send(giga.SYS_ERR, fmt.Sprintf("PayoutBatcher: cannot send batch for account %s: %v", id, err))
+			// continue with other accounts (retry on the next check)
+		}
+	}
+}
+
+func (b PayoutBatcher) batchAccount(id giga.Address, now time.Time) error {
+	tx, err := b.store.Begin()
+	if err != nil {
+		return err
+	}
+	acc, err := tx.GetAccountByID(id)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	batchSize, batchInterval := b.batchSettings(acc)
+	pending, err := tx.ListPendingWithdrawals(acc.Address, batchSize)
+	tx.Rollback()
+	if err != nil {
+		return err
+	}
+	if len(pending) < 1 {
+		return nil // nothing to send.
+	}
+	if len(pending) < batchSize && now.Sub(pending[0].Created) < batchInterval {
+		return nil // keep collecting withdrawals.
+	}
+	if acc.OfflineSigning {
+		// cannot sign batch transactions (withdrawals queued before offline signing was enabled)
+		return b.failBatch(acc, pending, pendingIDs(pending), giga.NewErr(giga.BadRequest, "account %v uses offline signing", acc.ForeignID))
+	}
+
+	// Pay each withdrawal as a separate output in the batch transaction.
+	payTo := make([]giga.PayTo, 0, len(pending))
+	for _, wd := range pending {
+		payTo = append(payTo, giga.PayTo{Amount: wd.Amount, PayTo: wd.PayTo})
+	}
+	wids := pendingIDs(pending)
+	source := giga.NewUTXOSource(b.store, acc.Address)
+	newTxn, changeUTXO, spentUTXOs, txid, err := giga.CreateTxn(payTo, nil, giga.FeeOptions{}, acc, source, b.l1)
+	if err != nil {
+		// e.g. insufficient funds: fail the withdrawals so they can be re-queued.
+		return b.failBatch(acc, pending, wids, err)
+	}
+	total := newTxn.TotalOut
+	fee := newTxn.FeeAmount
+
+	// Reserve the payment, store the signed transaction on it (to re-submit)
+	// and mark the withdrawals as batched in the same store transaction,
+	// so they can never be paid twice.
+	tx, err = b.store.Begin()
+	if err != nil {
+		return err
+	}
+	payment, err := giga.ReservePaymentTx(tx, b.l1, acc, giga.PaymentTypePayout, payTo, "", total, fee, spentUTXOs, changeUTXO)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	err = giga.SetPendingTxn(tx, payment.ID, newTxn, txid, giga.UTXO{}) // change UTXO already created.
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	err = tx.UpdateWithdrawalStatus(wids, giga.WithdrawalBatched, payment.ID, "", "")
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+
+	// BEYOND THIS POINT: if we fail to submit the tx, the withdrawals stay
+	// batched and the tx is re-submitted on the next pass (resubmitBatches)
+	return b.sendBatch(acc, pending, payment, giga.PendingTxn{TxID: txid, TxnHex: newTxn.TxnHex})
+}
+
+// Re-submit batch transactions that were reserved but not sent.
+func (b PayoutBatcher) resubmitBatches() {
+	tx, err := b.store.Begin()
+	if err != nil {
+		log.Println("PayoutBatcher: Begin:", err)
+		return
+	}
+	batched, err := tx.ListBatchedWithdrawals(BATCHER_RESUBMIT)
+	tx.Rollback()
+	if err != nil {
+		log.Println("PayoutBatcher: ListBatchedWithdrawals:", err)
+		return
+	}
+	// Group the withdrawals by batch payment.
+	var order []int64
+	batches := make(map[int64][]giga.Withdrawal)
+	for _, wd := range batched {
+		if _, found := batches[wd.PaymentID]; !found {
+			order = append(order, wd.PaymentID)
+		}
+		batches[wd.PaymentID] = append(batches[wd.PaymentID], wd)
+	}
+	for _, paymentID := range order {
+		err = b.resubmitBatch(batches[paymentID])
+		if err != nil {
+			b.bus.Send(giga.SYS_ERR, fmt.Sprintf("PayoutBatcher: cannot re-submit batch payment %v: %v", paymentID, err))
+			// continue with other batches (retry on the next check)
+		}
+	}
+}
+
+func (b PayoutBatcher) resubmitBatch(batch []giga.Withdrawal) error {
+	tx, err := b.store.Begin()
+	if err != nil {
+		return err
+	}
+	acc, err := tx.GetAccountByID(batch[0].AccountID)
+	tx.Rollback()
+	if err != nil {
+		return err
+	}
+	payment, err := b.store.GetPayment(acc.Address, batch[0].PaymentID)
+	if err != nil {
+		return err
+	}
+	pending, err := payment.DecodePendingTxn()
+	if err != nil {
+		return err
+	}
+	return b.sendBatch(acc, batch, payment, pending)
+}
+
+// Send the batch transaction (unless it was sent already) and mark the
+// withdrawals as sent. If this fails, the withdrawals stay batched.
+func (b PayoutBatcher) sendBatch(acc giga.Account, batch []giga.Withdrawal, payment giga.Payment, pending giga.PendingTxn) error {
+	txid := pending.TxID
+	submit := payment.PaidTxID == "" // otherwise, we failed to update the withdrawals.
+	if submit {
+		err := giga.SubmitPendingPayment(b.store, b.l1, payment.ID, pending)
+		if err != nil {
+			return err
+		}
+	}
+	err := b.updateStatus(pendingIDs(batch), giga.WithdrawalSent, payment.ID, txid, "")
+	if err != nil {
+		return err
+	}
+	for _, wd := range batch {
+		b.bus.Send(giga.WITHDRAWAL_SENT, giga.WithdrawalEvent{
+			WithdrawalID: wd.ID,
+			AccountID:    acc.Address,
+			ForeignID:    acc.ForeignID,
+			PayTo:        wd.PayTo,
+			Amount:       wd.Amount,
+			Status:       giga.WithdrawalSent,
+			PaymentID:    payment.ID,
+			TxID:         txid,
+		})
+	}
+	if submit {
+		b.bus.Send(giga.PAYMENT_SENT, giga.PaymentEvent{
+			PaymentID: payment.ID,
+			ForeignID: acc.ForeignID,
+			AccountID: acc.Address,
+			PayTo:     payment.PayTo,
+			Total:     payment.Total,
+			TxID:      txid,
+		})
+	}
+	b.bus.Send(giga.SYS_MSG, fmt.Sprintf("PayoutBatcher: paid %d withdrawals (total %v fee %v) in %s: %s", len(batch), payment.Total, payment.Fee, acc.ForeignID, txid))
+	return nil
+}
+
+// Per-account batch settings, or the config defaults.
+func (b PayoutBatcher) batchSettings(acc giga.Account) (int, time.Duration) {
+	batchSize := acc.BatchSize
+	if batchSize < 1 {
+		batchSize = b.conf.BatchSize
+	}
+	if batchSize < 1 {
+		batchSize = 1
+	}
+	batchInterval := acc.BatchInterval
+	if batchInterval < 1 {
+		batchInterval = b.conf.BatchInterval
+	}
+	return batchSize, time.Duration(batchInterval) * time.Second
+}
+
+func pendingIDs(pending []giga.Withdrawal) []int64 {
+	wids := make([]int64, 0, len(pending))
+	for _, wd := range pending {
+		wids = append(wids, wd.ID)
+	}
+	return wids
+}
+
+// Mark the withdrawals failed and send WITHDRAWAL_FAILED events.
+// Only before the batch payment is reserved (the withdrawals can be re-queued)
+func (b PayoutBatcher) failBatch(acc giga.Account, pending []giga.Withdrawal, wids []int64, reason error) error {
+	err := b.updateStatus(wids, giga.WithdrawalFailed, 0, "", reason.Error())
+	if err != nil {
+		return err
+	}
+	for _, wd := range pending {
+		b.bus.Send(giga.WITHDRAWAL_FAILED, giga.WithdrawalEvent{
+			WithdrawalID: wd.ID,
+			AccountID:    acc.Address,
+			ForeignID:    acc.ForeignID,
+			PayTo:        wd.PayTo,
+			Amount:       wd.Amount,
+			Status:       giga.WithdrawalFailed,
+			Error:        reason.Error(),
+		})
+	}
+	return reason
+}
+
+func (b PayoutBatcher) updateStatus(wids []int64, status giga.WithdrawalStatus, paymentID int64, txid string, errMsg string) error {
+	tx, err := b.store.Begin()
+	if err != nil {
+		return err
+	}
+	err = tx.UpdateWithdrawalStatus(wids, status, paymentID, txid, errMsg)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,175 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	giga "github.com/dogecoinfoundation/gigawallet/pkg"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	CONSOLIDATOR_KEY          = "UTXOConsolidator" // service name, stored in the database
+	CONSOLIDATOR_BATCH_SIZE   = 10                 // number of Accounts to check at once
+	CONSOLIDATOR_FEE_TARGET   = 6                  // confirmation target for EstimateFee
+	CONSOLIDATOR_MIN_INTERVAL = 10                 // minimum seconds between runs
+)
+
+// UTXOConsolidator merges many small UTXOs in an account into a single
+// change-address output, so later payouts need fewer inputs (smaller fees.)
+// It only runs when the fee estimate is low, and records each consolidation
+// as an internal payment (giga.PaymentTypeConsolidation)
+type UTXOConsolidator struct {
+	store    giga.Store
+	l1       giga.L1
+	bus      giga.MessageBus
+	conf     giga.ConsolidationConfig
+	maxFee   giga.CoinAmount // maximum fee-per-KB for a low-fee window
+	interval time.Duration   // time between runs
+}
+
+func NewUTXOConsolidator(store giga.Store, l1 giga.L1, bus giga.MessageBus, conf giga.ConsolidationConfig) UTXOConsolidator {
+	interval := conf.Interval
+	if interval < CONSOLIDATOR_MIN_INTERVAL {
+		interval = CONSOLIDATOR_MIN_INTERVAL
+	}
+	return UTXOConsolidator{
+		store:    store,
+		l1:       l1,
+		bus:      bus,
+		conf:     conf,
+		maxFee:   decimal.NewFromFloat(conf.MaxFeePerKB),
+		interval: time.Duration(interval) * time.Second,
+	}
+}
+
+// Implements conductor.Service
+func (c UTXOConsolidator) Run(started, stopped chan bool, stop chan context.Context) error {
+	go func() {
+		started <- true
+		cursor, err := c.store.GetServiceCursor(CONSOLIDATOR_KEY)
+		if err != nil {
+			log.Println("UTXOConsolidator: GetServiceCursor:", err)
+		}
+		for {
+			select {
+			case <-stop:
+				close(stopped)
+				return
+			case <-time.After(c.interval):
+				if !c.isLowFeeWindow() {
+					continue // try again later (accounts remain after cursor)
+				}
+				newCursor, err := c.runBatch(cursor)
+				if err != nil {
+					continue // retry.
+				}
+				cursor = newCursor // advance the cursor.
+			}
+		}
+	}()
+	return nil
+}
+
+// Check the fee estimate from Core is at or below the configured maximum.
+func (c UTXOConsolidator) isLowFeeWindow() bool {
+	feePerKB, err := c.l1.EstimateFee(CONSOLIDATOR_FEE_TARGET)
+	if err != nil {
+		log.Println("UTXOConsolidator: cannot estimate fee:", err)
+		return false
+	}
+	if feePerKB.GreaterThan(c.maxFee) {
+		log.Printf("UTXOConsolidator: waiting for a low-fee window: %v > %v per KB\n", feePerKB, c.maxFee)
+		return false
+	}
+	return true
+}
+
+func (c UTXOConsolidator) runBatch(cursor int64) (int64, error) {
+	tx, err := c.store.Begin()
+	if err != nil {
+		log.Println("UTXOConsolidator: Begin:", err)
+		return cursor, err
+	}
+	ids, newCursor, err := tx.ListAccountsModifiedSince(cursor, CONSOLIDATOR_BATCH_SIZE)
+	tx.Rollback()
+	if err != nil {
+		log.Println("UTXOConsolidator: ListAccountsModifiedSince:", err)
+		return cursor, err
+	}
+	for _, id := range ids {
+		err = c.consolidateAccount(giga.Address(id))
+		if err != nil {
+			c.bus.Send(giga.SYS_ERR, fmt.Sprintf("UTXOConsolidator: cannot consolidate account %s: %v", id, err))
+			// continue with other accounts (the account will be checked
+			// again when it is next modified)
+		}
+	}
+	if newCursor > cursor {
+		tx, err = c.store.Begin()
+		if err != nil {
+			log.Println("UTXOConsolidator: Begin:", err)
+			return cursor, err
+		}
+		err = tx.SetServiceCursor(CONSOLIDATOR_KEY, newCursor)
+		if err != nil {
+			tx.Rollback()
+			log.Println("UTXOConsolidator: SetServiceCursor:", err)
+			return cursor, err
+		}
+		err = tx.Commit()
+		if err != nil {
+			log.Println("UTXOConsolidator: Commit:", err)
+			return cursor, err
+		}
+	}
+	return newCursor, nil
+}
+
+func (c UTXOConsolidator) consolidateAccount(id giga.Address) error {
+	tx, err := c.store.Begin()
+	if err != nil {
+		return err
+	}
+	acc, err := tx.GetAccountByID(id)
+	tx.Rollback()
+	if err != nil {
+		return err
+	}
+	if acc.OfflineSigning {
+		return nil // cannot sign consolidation transactions.
+	}
+	utxos, err := c.store.GetAllUnreservedUTXOs(acc.Address)
+	if err != nil {
+		return err
+	}
+	if len(utxos) <= c.conf.UTXOThreshold {
+		return nil // not enough UTXOs to consolidate.
+	}
+	// Merge the smallest UTXOs first, up to MaxInputs.
+	sort.SliceStable(utxos, func(i, j int) bool {
+		return utxos[i].Value.LessThan(utxos[j].Value)
+	})
+	if c.conf.MaxInputs > 0 && len(utxos) > c.conf.MaxInputs {
+		utxos = utxos[:c.conf.MaxInputs]
+	}
+	newTxn, payTo, consolidated, err := giga.CreateConsolidationTxn(utxos, giga.TxnRecommendedMaxFee, &acc, c.l1)
+	if err != nil {
+		return err
+	}
+	// Reserve the UTXOs and create the consolidated UTXO, recorded as an
+	// internal payment (Total is zero: nothing is paid to others.)
+	payment, err := giga.ReservePayment(c.store, c.l1, acc, giga.PaymentTypeConsolidation, payTo, "", giga.ZeroCoins, newTxn.FeeAmount, utxos, consolidated)
+	if err != nil {
+		return err
+	}
+	err = giga.SubmitPayment(c.store, c.l1, payment.ID, newTxn.TxnHex, consolidated.TxID, true)
+	if err != nil {
+		return err
+	}
+	c.bus.Send(giga.SYS_MSG, fmt.Sprintf("UTXOConsolidator: merged %d UTXOs into %v (fee %v) in %s: %s", len(utxos), consolidated.Value, newTxn.FeeAmount, acc.ForeignID, consolidated.TxID))
+	return nil
+}
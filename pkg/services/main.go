@@ -5,8 +5,29 @@ import (
 	"github.com/dogecoinfoundation/gigawallet/pkg/conductor"
 )
 
-func StartServices(cond *conductor.Conductor, bus giga.MessageBus, conf giga.Config, store giga.Store) {
+func StartServices(cond *conductor.Conductor, bus giga.MessageBus, conf giga.Config, store giga.Store, l1 giga.L1, api giga.API) {
+	// Name each service after its network, e.g. "PayoutBatcher (mainnet)"
+	network := " (" + conf.Gigawallet.Network + ")"
+
 	// BalanceKeeper updates stored balances and sends ACC_BALANCE_CHANGE events.
 	keeper := NewBalanceKeeper(store, bus)
-	cond.Service("NewBalanceKeeper", keeper)
+	cond.Service("NewBalanceKeeper"+network, keeper)
+
+	// UTXOConsolidator merges small UTXOs during low-fee windows.
+	if conf.Consolidation.Enabled {
+		consolidator := NewUTXOConsolidator(store, l1, bus, conf.Consolidation)
+		cond.Service("UTXOConsolidator"+network, consolidator)
+	}
+
+	// PayoutBatcher pays queued withdrawals in batch transactions.
+	if conf.Batching.Enabled {
+		batcher := NewPayoutBatcher(store, l1, bus, conf.Batching)
+		cond.Service("PayoutBatcher"+network, batcher)
+	}
+
+	// PaymentScheduler pays scheduled payments when they are due.
+	if conf.Scheduling.Enabled {
+		scheduler := NewPaymentScheduler(api, store, l1, bus, conf.Scheduling)
+		cond.Service("PaymentScheduler"+network, scheduler)
+	}
 }
@@ -0,0 +1,222 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	giga "github.com/dogecoinfoundation/gigawallet/pkg"
+)
+
+const (
+	SCHEDULER_MIN_INTERVAL = 5   // minimum seconds between checks
+	SCHEDULER_BATCH        = 100 // maximum pending scheduled payments checked per pass
+)
+
+// PaymentScheduler pays scheduled payments (see API.SchedulePayment) once
+// their trigger time or block height is reached, through SendFundsToAddress.
+// A payment that cannot be paid yet (e.g. not enough funds, or Core is not
+// available) is retried after RetryInterval, up to MaxAttempts.
+// A LockTime payment already has a signed transaction (see PendingTxn)
+// which is sent when it is due.
+type PaymentScheduler struct {
+	api      giga.API
+	store    giga.Store
+	l1       giga.L1
+	bus      giga.MessageBus
+	conf     giga.SchedulingConfig
+	interval time.Duration // time between checks
+}
+
+func NewPaymentScheduler(api giga.API, store giga.Store, l1 giga.L1, bus giga.MessageBus, conf giga.SchedulingConfig) PaymentScheduler {
+	interval := conf.Interval
+	if interval < SCHEDULER_MIN_INTERVAL {
+		interval = SCHEDULER_MIN_INTERVAL
+	}
+	return PaymentScheduler{
+		api:      api,
+		store:    store,
+		l1:       l1,
+		bus:      bus,
+		conf:     conf,
+		interval: time.Duration(interval) * time.Second,
+	}
+}
+
+// Implements conductor.Service
+func (s PaymentScheduler) Run(started, stopped chan bool, stop chan context.Context) error {
+	go func() {
+		started <- true
+		for {
+			select {
+			case <-stop:
+				close(stopped)
+				return
+			case <-time.After(s.interval):
+				s.RunScheduled(time.Now())
+			}
+		}
+	}()
+	return nil
+}
+
+// RunScheduled pays every scheduled payment that is due at time `now`.
+func (s PaymentScheduler) RunScheduled(now time.Time) {
+	state, err := s.store.GetChainState()
+	if err != nil && !giga.IsNotFoundError(err) {
+		log.Println("PaymentScheduler: GetChainState:", err)
+		return
+	}
+	tx, err := s.store.Begin()
+	if err != nil {
+		log.Println("PaymentScheduler: Begin:", err)
+		return
+	}
+	pending, err := tx.ListPendingScheduledPayments(SCHEDULER_BATCH)
+	tx.Rollback()
+	if err != nil {
+		log.Println("PaymentScheduler: ListPendingScheduledPayments:", err)
+		return
+	}
+	for _, sp := range pending {
+		if !sp.IsDue(now, state.BestBlockHeight) {
+			continue
+		}
+		err = s.pay(sp, now)
+		if err != nil {
+			s.bus.Send(giga.SYS_ERR, fmt.Sprintf("PaymentScheduler: cannot update scheduled payment %v: %v", sp.ID, err))
+			// continue with other payments (retry on the next check)
+		}
+	}
+}
+
+func (s PaymentScheduler) pay(sp giga.ScheduledPayment, now time.Time) error {
+	tx, err := s.store.Begin()
+	if err != nil {
+		return err
+	}
+	acc, err := tx.GetAccountByID(sp.AccountID)
+	tx.Rollback()
+	if err != nil {
+		return err
+	}
+	sp.Attempts++
+	var paymentID int64
+	var txid string
+	var retry bool
+	if sp.LockTime {
+		paymentID, txid, err = s.sendLocked(acc, sp)
+		retry = true // e.g. not final yet (median time past lags the clock)
+	} else if s.needsApproval(acc, sp) {
+		// The account's policy changed after the payment was scheduled:
+		// fail rather than leave a payment waiting for approvals.
+		err = giga.NewErr(giga.BadRequest, "payment from account %v requires approval or offline signing", acc.ForeignID)
+	} else {
+		// The Idempotency-Key ensures the payment is only made once,
+		// even if we fail to update the scheduled payment below.
+		// If the tx was not sent, a retry with the same key sends it.
+		idem := giga.NewIdempotencyKey("scheduled-"+strconv.FormatInt(sp.ID, 10), []byte("scheduled"))
+		var res giga.SendFundsResult
+		res, err = s.api.SendFundsToAddress(acc.ForeignID, sp.PayTo, sp.Memo, giga.FeeOptions{}, true, idem)
+		paymentID, txid = res.PaymentID, res.TxId
+		retry = giga.IsError(err, giga.InsufficientFunds) || giga.IsError(err, giga.NotAvailable) ||
+			giga.IsError(err, giga.L1Error) || giga.IsDBConflictError(err)
+		if err == nil && (res.ApprovalStatus != giga.ApprovalNone || res.Unsigned != nil) {
+			// Held for approval or offline signing: not sent yet.
+			sp.PaymentID = paymentID
+			err = giga.NewErr(giga.BadRequest, "payment %v from account %v was not sent: it requires approval or offline signing", paymentID, acc.ForeignID)
+		}
+	}
+	if err != nil {
+		return s.retryOrFail(acc, sp, now, retry, err)
+	}
+	sp.Status = giga.ScheduleSent
+	sp.PaymentID = paymentID
+	sp.TxID = txid
+	sp.Error = ""
+	err = s.update(sp)
+	if err != nil {
+		return err
+	}
+	s.bus.Send(giga.SCHEDULE_SENT, giga.NewScheduleEvent(acc, sp))
+	s.bus.Send(giga.SYS_MSG, fmt.Sprintf("PaymentScheduler: paid scheduled payment %v from %s: %s", sp.ID, acc.ForeignID, txid))
+	return nil
+}
+
+// True if SendFundsToAddress would hold the payment rather than send it.
+func (s PaymentScheduler) needsApproval(acc giga.Account, sp giga.ScheduledPayment) bool {
+	total := giga.ZeroCoins
+	for _, pay := range sp.PayTo {
+		total = total.Add(pay.Amount)
+	}
+	return acc.OfflineSigning || acc.RequiresApproval(sp.PayTo, total)
+}
+
+// Send the nLockTime transaction held on the reserved Payment.
+func (s PaymentScheduler) sendLocked(acc giga.Account, sp giga.ScheduledPayment) (int64, string, error) {
+	payment, err := s.store.GetPayment(acc.Address, sp.PaymentID)
+	if err != nil {
+		return 0, "", err
+	}
+	if payment.PaidTxID != "" {
+		return payment.ID, payment.PaidTxID, nil // already sent.
+	}
+	pending, err := payment.DecodePendingTxn()
+	if err != nil {
+		return 0, "", err
+	}
+	err = giga.SubmitPendingPayment(s.store, s.l1, payment.ID, pending)
+	if err != nil {
+		return 0, "", err
+	}
+	s.bus.Send(giga.PAYMENT_SENT, giga.PaymentEvent{
+		PaymentID: payment.ID,
+		ForeignID: acc.ForeignID,
+		AccountID: acc.Address,
+		PayTo:     payment.PayTo,
+		Total:     payment.Total,
+		TxID:      pending.TxID,
+		Memo:      payment.Memo,
+	})
+	return payment.ID, pending.TxID, nil
+}
+
+// Schedule a retry after RetryInterval, or fail the scheduled payment.
+func (s PaymentScheduler) retryOrFail(acc giga.Account, sp giga.ScheduledPayment, now time.Time, retry bool, reason error) error {
+	sp.Error = reason.Error()
+	if retry && (s.conf.MaxAttempts < 1 || sp.Attempts < s.conf.MaxAttempts) {
+		sp.NextAttempt = now.Add(time.Duration(s.conf.RetryInterval) * time.Second)
+		err := s.update(sp)
+		if err != nil {
+			return err
+		}
+		s.bus.Send(giga.SCHEDULE_RETRY, giga.NewScheduleEvent(acc, sp))
+		return nil
+	}
+	sp.Status = giga.ScheduleFailed
+	err := s.update(sp)
+	if err != nil {
+		return err
+	}
+	s.bus.Send(giga.SCHEDULE_FAILED, giga.NewScheduleEvent(acc, sp))
+	if sp.LockTime {
+		// the reserved payment keeps its UTXOs until the transaction is sent.
+		s.bus.Send(giga.SYS_ERR, fmt.Sprintf("PaymentScheduler: scheduled payment %v from %s failed: payment %v is still reserved: %v", sp.ID, acc.ForeignID, sp.PaymentID, reason))
+	}
+	return nil
+}
+
+func (s PaymentScheduler) update(sp giga.ScheduledPayment) error {
+	tx, err := s.store.Begin()
+	if err != nil {
+		return err
+	}
+	err = tx.UpdateScheduledPayment(sp)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,438 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	giga "github.com/dogecoinfoundation/gigawallet/pkg"
+	"github.com/dogecoinfoundation/gigawallet/pkg/testutil"
+	"github.com/shopspring/decimal"
+)
+
+func TestConsolidator(t *testing.T) {
+	config, store, l1, bus, api := newTestRig(t)
+	acc := makeFundedAccount(t, api, store, l1, "Consolidate")
+
+	t.Run("Waits for a low-fee window", func(t *testing.T) {
+		config.Consolidation.MaxFeePerKB = 0.01 // mock estimate is 0.67891013
+		c := NewUTXOConsolidator(store, l1, bus, config.Consolidation)
+		if c.isLowFeeWindow() {
+			t.Fatalf("expected fee estimate above MaxFeePerKB")
+		}
+		config.Consolidation.MaxFeePerKB = 1
+		c = NewUTXOConsolidator(store, l1, bus, config.Consolidation)
+		if !c.isLowFeeWindow() {
+			t.Fatalf("expected fee estimate below MaxFeePerKB")
+		}
+	})
+
+	t.Run("Ignores accounts below the threshold", func(t *testing.T) {
+		config.Consolidation.UTXOThreshold = 10
+		c := NewUTXOConsolidator(store, l1, bus, config.Consolidation)
+		_, err := c.runBatch(0)
+		if err != nil {
+			t.Fatalf("runBatch: %v", err)
+		}
+		utxos, err := store.GetAllUnreservedUTXOs(acc.Address)
+		if err != nil {
+			t.Fatalf("GetAllUnreservedUTXOs: %v", err)
+		}
+		if len(utxos) != 10 {
+			t.Fatalf("expected 10 unreserved UTXOs, got %v", len(utxos))
+		}
+	})
+
+	t.Run("Consolidates accounts above the threshold", func(t *testing.T) {
+		config.Consolidation.UTXOThreshold = 5
+		config.Consolidation.MaxInputs = 8
+		c := NewUTXOConsolidator(store, l1, bus, config.Consolidation)
+		cursor, err := c.runBatch(0)
+		if err != nil {
+			t.Fatalf("runBatch: %v", err)
+		}
+		if cursor < 1 {
+			t.Fatalf("expected the cursor to advance, got %v", cursor)
+		}
+		saved, err := store.GetServiceCursor(CONSOLIDATOR_KEY)
+		if err != nil {
+			t.Fatalf("GetServiceCursor: %v", err)
+		}
+		if saved != cursor {
+			t.Fatalf("expected stored cursor %v, got %v", cursor, saved)
+		}
+		// 8 inputs reserved (MaxInputs): 2 remain, plus the consolidated UTXO.
+		utxos, err := store.GetAllUnreservedUTXOs(acc.Address)
+		if err != nil {
+			t.Fatalf("GetAllUnreservedUTXOs: %v", err)
+		}
+		if len(utxos) != 3 {
+			t.Fatalf("expected 3 unreserved UTXOs, got %v", len(utxos))
+		}
+		payments, _, err := store.ListPayments(acc.Address, 0, 10)
+		if err != nil {
+			t.Fatalf("ListPayments: %v", err)
+		}
+		if len(payments) != 1 || payments[0].PaidTxID == "" {
+			t.Fatalf("expected one sent consolidation payment, got %+v", payments)
+		}
+	})
+}
+
+func TestPayoutBatcher(t *testing.T) {
+	config, store, l1, bus, api := newTestRig(t)
+	acc := makeFundedAccount(t, api, store, l1, "Batcher")
+	payTo, _, err := acc.NextChangeAddress(l1)
+	if err != nil {
+		t.Fatalf("NextChangeAddress: %v", err)
+	}
+	config.Batching.BatchSize = 2
+	config.Batching.BatchInterval = 3600
+	b := NewPayoutBatcher(store, l1, bus, config.Batching)
+
+	first, err := api.QueueWithdrawal(acc.ForeignID, payTo, decimal.NewFromInt(5))
+	if err != nil {
+		t.Fatalf("QueueWithdrawal: %v", err)
+	}
+	b.runBatches(time.Now())
+	expectWithdrawal(t, api, acc, first.ID, giga.WithdrawalPending)
+
+	second, err := api.QueueWithdrawal(acc.ForeignID, payTo, decimal.NewFromInt(7))
+	if err != nil {
+		t.Fatalf("QueueWithdrawal: %v", err)
+	}
+	b.runBatches(time.Now())
+	wd1 := expectWithdrawal(t, api, acc, first.ID, giga.WithdrawalSent)
+	wd2 := expectWithdrawal(t, api, acc, second.ID, giga.WithdrawalSent)
+	if wd1.PaymentID == 0 || wd1.PaymentID != wd2.PaymentID || wd1.TxID == "" {
+		t.Fatalf("expected both withdrawals in one payment, got %+v and %+v", wd1, wd2)
+	}
+	payment, err := store.GetPayment(acc.Address, wd1.PaymentID)
+	if err != nil {
+		t.Fatalf("GetPayment: %v", err)
+	}
+	if len(payment.PayTo) != 2 || !payment.Total.Equal(decimal.NewFromInt(12)) {
+		t.Fatalf("expected a batch payment of 12 to 2 outputs, got %+v", payment)
+	}
+
+	// A single withdrawal is sent once it has waited for BatchInterval.
+	third, err := api.QueueWithdrawal(acc.ForeignID, payTo, decimal.NewFromInt(3))
+	if err != nil {
+		t.Fatalf("QueueWithdrawal: %v", err)
+	}
+	b.runBatches(time.Now().Add(time.Hour))
+	expectWithdrawal(t, api, acc, third.ID, giga.WithdrawalSent)
+
+	// A batch that cannot be sent stays batched, and is re-submitted.
+	failing := &testutil.FailingL1{L1: l1, Fail: true}
+	fb := NewPayoutBatcher(store, failing, bus, config.Batching)
+	fourth, err := api.QueueWithdrawal(acc.ForeignID, payTo, decimal.NewFromInt(4))
+	if err != nil {
+		t.Fatalf("QueueWithdrawal: %v", err)
+	}
+	fb.runBatches(time.Now().Add(time.Hour))
+	wd4 := expectWithdrawal(t, api, acc, fourth.ID, giga.WithdrawalBatched)
+	fb.runBatches(time.Now().Add(time.Hour))
+	expectWithdrawal(t, api, acc, fourth.ID, giga.WithdrawalBatched)
+	failing.Fail = false
+	fb.runBatches(time.Now().Add(time.Hour))
+	sent := expectWithdrawal(t, api, acc, fourth.ID, giga.WithdrawalSent)
+	if sent.PaymentID != wd4.PaymentID || sent.TxID == "" || failing.Sent != 1 {
+		t.Fatalf("expected the batch payment %v to be sent once, got %+v (sent %v)", wd4.PaymentID, sent, failing.Sent)
+	}
+	payment, err = store.GetPayment(acc.Address, sent.PaymentID)
+	if err != nil {
+		t.Fatalf("GetPayment: %v", err)
+	}
+	if payment.PaidTxID != sent.TxID {
+		t.Fatalf("expected the batch payment to be sent: %v vs %v", payment.PaidTxID, sent.TxID)
+	}
+}
+
+func TestPaymentScheduler(t *testing.T) {
+	config, store, l1, bus, api := newTestRig(t)
+	acc := makeFundedAccount(t, api, store, l1, "Scheduler")
+	payTo, _, err := acc.NextChangeAddress(l1)
+	if err != nil {
+		t.Fatalf("NextChangeAddress: %v", err)
+	}
+	s := NewPaymentScheduler(api, store, l1, bus, config.Scheduling)
+	pay := []giga.PayTo{{PayTo: payTo, Amount: decimal.NewFromInt(5)}}
+	payAt := time.Now().Add(time.Hour)
+
+	t.Run("Cancelled payments are not paid", func(t *testing.T) {
+		sp, err := api.SchedulePayment(acc.ForeignID, pay, "", payAt, 0, false)
+		if err != nil {
+			t.Fatalf("SchedulePayment: %v", err)
+		}
+		_, err = api.CancelScheduledPayment(acc.ForeignID, sp.ID)
+		if err != nil {
+			t.Fatalf("CancelScheduledPayment: %v", err)
+		}
+		s.RunScheduled(payAt.Add(time.Minute))
+		sp, err = api.GetScheduledPayment(acc.ForeignID, sp.ID)
+		if err != nil {
+			t.Fatalf("GetScheduledPayment: %v", err)
+		}
+		if sp.Status != giga.ScheduleCancelled || sp.PaymentID != 0 || sp.Attempts != 0 {
+			t.Fatalf("expected the cancelled payment to be left alone, got %+v", sp)
+		}
+		_, err = api.CancelScheduledPayment(acc.ForeignID, sp.ID)
+		if !giga.IsError(err, giga.Conflict) {
+			t.Fatalf("expected Conflict cancelling twice, got %v", err)
+		}
+	})
+
+	t.Run("Due payments are paid", func(t *testing.T) {
+		sp, err := api.SchedulePayment(acc.ForeignID, pay, "", payAt, 0, false)
+		if err != nil {
+			t.Fatalf("SchedulePayment: %v", err)
+		}
+		s.RunScheduled(payAt.Add(-time.Minute))
+		sp, err = api.GetScheduledPayment(acc.ForeignID, sp.ID)
+		if err != nil {
+			t.Fatalf("GetScheduledPayment: %v", err)
+		}
+		if sp.Status != giga.SchedulePending {
+			t.Fatalf("expected the payment to wait for pay_at, got %+v", sp)
+		}
+		s.RunScheduled(payAt.Add(time.Minute))
+		sp, err = api.GetScheduledPayment(acc.ForeignID, sp.ID)
+		if err != nil {
+			t.Fatalf("GetScheduledPayment: %v", err)
+		}
+		if sp.Status != giga.ScheduleSent || sp.PaymentID == 0 || sp.TxID == "" {
+			t.Fatalf("expected the payment to be sent, got %+v", sp)
+		}
+	})
+
+	t.Run("Unsent payments are sent on retry", func(t *testing.T) {
+		failing := &testutil.FailingL1{L1: l1, Fail: true}
+		fs := NewPaymentScheduler(giga.NewAPI(store, failing, bus, &giga.MockFollower{}, config), store, failing, bus, config.Scheduling)
+		sp, err := api.SchedulePayment(acc.ForeignID, pay, "", payAt, 0, false)
+		if err != nil {
+			t.Fatalf("SchedulePayment: %v", err)
+		}
+		fs.RunScheduled(payAt.Add(time.Minute))
+		sp, err = api.GetScheduledPayment(acc.ForeignID, sp.ID)
+		if err != nil {
+			t.Fatalf("GetScheduledPayment: %v", err)
+		}
+		if sp.Status != giga.SchedulePending || sp.Attempts != 1 || sp.TxID != "" {
+			t.Fatalf("expected the payment to be retried, got %+v", sp)
+		}
+		failing.Fail = false
+		fs.RunScheduled(sp.NextAttempt.Add(time.Minute))
+		sp, err = api.GetScheduledPayment(acc.ForeignID, sp.ID)
+		if err != nil {
+			t.Fatalf("GetScheduledPayment: %v", err)
+		}
+		if sp.Status != giga.ScheduleSent || sp.TxID == "" || failing.Sent != 1 {
+			t.Fatalf("expected the payment to be sent once, got %+v (sent %v)", sp, failing.Sent)
+		}
+		payment, err := store.GetPayment(acc.Address, sp.PaymentID)
+		if err != nil {
+			t.Fatalf("GetPayment: %v", err)
+		}
+		if payment.PaidTxID != sp.TxID {
+			t.Fatalf("expected the payment to be sent: %v vs %v", payment.PaidTxID, sp.TxID)
+		}
+	})
+
+	t.Run("Payments that require approval are not scheduled", func(t *testing.T) {
+		vault := makeFundedAccount(t, api, store, l1, "Vault")
+		sp, err := api.SchedulePayment(vault.ForeignID, pay, "", payAt, 0, false)
+		if err != nil {
+			t.Fatalf("SchedulePayment: %v", err)
+		}
+		vault.ApprovalsRequired = 2
+		vault.ApprovalThreshold = decimal.NewFromInt(1)
+		updateAccount(t, store, vault)
+		_, err = api.SchedulePayment(vault.ForeignID, pay, "", payAt, 0, false)
+		if !giga.IsError(err, giga.BadRequest) {
+			t.Fatalf("expected BadRequest scheduling a payment that requires approval, got %v", err)
+		}
+
+		// Scheduled before the account required approval.
+		s.RunScheduled(payAt.Add(time.Minute))
+		sp, err = api.GetScheduledPayment(vault.ForeignID, sp.ID)
+		if err != nil {
+			t.Fatalf("GetScheduledPayment: %v", err)
+		}
+		if sp.Status != giga.ScheduleFailed || sp.PaymentID != 0 || sp.TxID != "" {
+			t.Fatalf("expected the payment to fail without paying, got %+v", sp)
+		}
+	})
+}
+
+func TestBalanceKeeper(t *testing.T) {
+	_, store, l1, _, api := newTestRig(t)
+	acc := makeFundedAccount(t, api, store, l1, "Keeper")
+	payTo, _, err := acc.NextChangeAddress(l1)
+	if err != nil {
+		t.Fatalf("NextChangeAddress: %v", err)
+	}
+	res, err := api.SendFundsToAddress(acc.ForeignID, []giga.PayTo{{PayTo: payTo, Amount: decimal.NewFromInt(5)}}, "", giga.FeeOptions{}, true, giga.IdempotencyKey{})
+	if err != nil {
+		t.Fatalf("SendFundsToAddress: %v", err)
+	}
+	bus, rec := newBusRecorder(t)
+	b := NewBalanceKeeper(store, bus)
+	var cursor, seq int64 = 0, 1 // FundAccount sets chain_seq 1.
+
+	// Apply chain changes to the payment (as the ChainFollower does) then run the
+	// BalanceKeeper until it has seen every modified account.
+	expectEvents := func(change func(tx giga.StoreTransaction) error, events ...giga.EventType) {
+		t.Helper()
+		tx, err := store.Begin()
+		if err != nil {
+			t.Fatalf("store.Begin: %v", err)
+		}
+		err = change(tx)
+		if err == nil {
+			seq++
+			err = tx.IncChainSeqForAccounts(map[string]int64{string(acc.Address): seq})
+		}
+		if err != nil {
+			tx.Rollback()
+			t.Fatalf("changing the payment: %v", err)
+		}
+		err = tx.Commit()
+		if err != nil {
+			t.Fatalf("tx.Commit: %v", err)
+		}
+		for {
+			next, err := b.runBatch(cursor)
+			if err != nil {
+				t.Fatalf("runBatch: %v", err)
+			}
+			if next == cursor {
+				break
+			}
+			cursor = next
+		}
+		msgs := rec.take(giga.PAYMENT_ON_CHAIN, giga.PAYMENT_CONFIRMED, giga.PAYMENT_UNCONFIRMED)
+		if len(msgs) != len(events) {
+			t.Fatalf("expected events %v, got %+v", events, msgs)
+		}
+		for i, msg := range msgs {
+			if msg.EventType != events[i] || msg.Message.(giga.PaymentEvent).PaymentID != res.PaymentID {
+				t.Fatalf("expected events %v, got %+v", events, msgs)
+			}
+		}
+	}
+	unchanged := func(tx giga.StoreTransaction) error { return nil }
+	onChain := func(height int64) func(tx giga.StoreTransaction) error {
+		return func(tx giga.StoreTransaction) error {
+			_, err := tx.MarkPaymentsOnChain([]string{res.TxId}, height)
+			return err
+		}
+	}
+	confirm := func(height int64) func(tx giga.StoreTransaction) error {
+		return func(tx giga.StoreTransaction) error {
+			_, err := tx.ConfirmPayments(6, height)
+			return err
+		}
+	}
+
+	expectEvents(unchanged)
+	expectEvents(onChain(200), giga.PAYMENT_ON_CHAIN)
+	expectEvents(unchanged)
+	expectEvents(confirm(206), giga.PAYMENT_CONFIRMED)
+	expectEvents(unchanged)
+
+	// Reorg back to 199: the payment's block is rolled back.
+	expectEvents(func(tx giga.StoreTransaction) error {
+		_, err := tx.RevertChangesAboveHeight(199, seq, 206, false)
+		return err
+	}, giga.PAYMENT_UNCONFIRMED)
+	expectEvents(unchanged)
+
+	// Re-mined on the new chain.
+	expectEvents(onChain(201), giga.PAYMENT_ON_CHAIN)
+	expectEvents(confirm(207), giga.PAYMENT_CONFIRMED)
+	expectEvents(unchanged)
+}
+
+func newTestRig(t *testing.T) (giga.Config, giga.Store, giga.L1, giga.MessageBus, giga.API) {
+	config := giga.TestConfig()
+	config.Scheduling.Enabled = true
+	store, l1, bus, api := testutil.NewTestAPI(t, config)
+	return config, store, l1, bus, api
+}
+
+// Create an account holding 10 confirmed P2PKH UTXOs of 10 DOGE each.
+func makeFundedAccount(t *testing.T, api giga.API, store giga.Store, l1 giga.L1, foreignID string) giga.Account {
+	_, err := api.CreateAccount(giga.AccountCreateRequest{}, foreignID, false)
+	if err != nil {
+		t.Fatalf("CreateAccount: %v", err)
+	}
+	return testutil.FundAccount(t, store, l1, foreignID)
+}
+
+func updateAccount(t *testing.T, store giga.Store, acc giga.Account) {
+	tx, err := store.Begin()
+	if err != nil {
+		t.Fatalf("store.Begin: %v", err)
+	}
+	err = tx.UpdateAccount(acc)
+	if err != nil {
+		tx.Rollback()
+		t.Fatalf("tx.UpdateAccount: %v", err)
+	}
+	err = tx.Commit()
+	if err != nil {
+		t.Fatalf("tx.Commit: %v", err)
+	}
+}
+
+func expectWithdrawal(t *testing.T, api giga.API, acc giga.Account, id int64, status giga.WithdrawalStatus) giga.Withdrawal {
+	wd, err := api.GetWithdrawal(acc.ForeignID, id)
+	if err != nil {
+		t.Fatalf("GetWithdrawal: %v", err)
+	}
+	if wd.Status != status {
+		t.Fatalf("expected withdrawal %v to be %v, got %+v", id, status, wd)
+	}
+	return wd
+}
+
+// Collects the messages sent on a MessageBus.
+type busRecorder struct {
+	rec chan giga.Message
+}
+
+func (r busRecorder) GetChan() chan giga.Message {
+	return r.rec
+}
+
+func newBusRecorder(t *testing.T) (giga.MessageBus, busRecorder) {
+	bus := giga.NewMessageBus()
+	r := busRecorder{rec: make(chan giga.Message, 1000)}
+	bus.Register(r, giga.EVENT_ALL("ALL"))
+	started, stopped, stop := make(chan bool, 1), make(chan bool, 1), make(chan context.Context, 1)
+	bus.Run(started, stopped, stop)
+	<-started
+	t.Cleanup(func() {
+		stop <- context.Background()
+		<-stopped
+	})
+	return bus, r
+}
+
+// Take the messages received so far (waiting briefly for delivery) that
+// match any of `types`, discarding the rest.
+func (r busRecorder) take(types ...giga.EventType) (msgs []giga.Message) {
+	for {
+		select {
+		case msg := <-r.rec:
+			for _, t := range types {
+				if msg.EventType == t {
+					msgs = append(msgs, msg)
+				}
+			}
+		case <-time.After(50 * time.Millisecond):
+			return
+		}
+	}
+}
@@ -1,5 +1,7 @@
 package giga
 
+import "time"
+
 // A store represents a connection to a database
 // with a transactional API that
 type Store interface {
@@ -29,6 +31,21 @@ type Store interface {
 	// pagination: stores CAN return < limit (or zero) items WITH next_cursor > 0 (due to filtering)
 	ListPayments(account Address, cursor int64, limit int) (items []Payment, next_cursor int64, err error)
 
+	// GetWithdrawal returns the queued Withdrawal for the given ID
+	// It returns giga.NotFound if the withdrawal does not exist (key: account, ID)
+	GetWithdrawal(account Address, id int64) (Withdrawal, error)
+
+	// GetScheduledPayment returns the ScheduledPayment for the given ID
+	// It returns giga.NotFound if the scheduled payment does not exist (key: account, ID)
+	GetScheduledPayment(account Address, id int64) (ScheduledPayment, error)
+
+	// GetIdempotentResponse returns the stored response for an Idempotency-Key.
+	// It returns giga.NotFound if the key has not been used (key: account, Key)
+	GetIdempotentResponse(account Address, key string) (IdempotentResponse, error)
+
+	// ListPaymentApprovals returns the approve/reject decisions for a payment, oldest first.
+	ListPaymentApprovals(paymentID int64) ([]PaymentApproval, error)
+
 	// List all unreserved UTXOs in the account's wallet.
 	// Unreserved means not already being used in a pending transaction.
 	GetAllUnreservedUTXOs(account Address) ([]UTXO, error)
@@ -37,6 +54,14 @@ type Store interface {
 	// It returns giga.NotFound if the chainstate record does not exist.
 	GetChainState() (ChainState, error)
 
+	// GetVerifiedHeader gets the block header verified at a block height (see VerifyBlocks)
+	// It returns giga.NotFound if no header has been verified at that height.
+	GetVerifiedHeader(height int64) (VerifiedHeader, error)
+
+	// ListSkippedBlocks returns the blocks the ChainFollower could not decode
+	// (see StoreSkippedBlock) in height order.
+	ListSkippedBlocks() ([]SkippedBlock, error)
+
 	// Get a Service Cursor, used to keep track of where services are "up to"
 	// in terms of account sequence numbers. This means services can always catch up
 	// even if they get a long way behind (e.g. due to a bug, or comms push-back)
@@ -96,8 +121,8 @@ type StoreTransaction interface {
 	StoreInvoice(invoice Invoice) error
 
 	// Store a 'payment' which represents a pay-out to another address from a gigawallet
-	// managed account.
-	CreatePayment(account Address, payTo []PayTo, total CoinAmount, fee CoinAmount) (Payment, error)
+	// managed account, or an internal payment such as a UTXO consolidation (see PaymentType)
+	CreatePayment(account Address, payType PaymentType, payTo []PayTo, memo string, total CoinAmount, fee CoinAmount) (Payment, error)
 
 	// GetPayment returns the Payment for the given ID
 	GetPayment(account Address, id int64) (Payment, error)
@@ -105,12 +130,80 @@ type StoreTransaction interface {
 	// Update txid on a payment.
 	UpdatePaymentWithTxID(paymentID int64, txID string) error
 
+	// Store the unsigned transaction (UnsignedTxn JSON) for an offline-signed payment.
+	SetPaymentUnsignedTxn(paymentID int64, unsignedTxn string) error
+
+	// Store the signed transaction (PendingTxn JSON) for a payment that is not sent yet.
+	SetPaymentPendingTxn(paymentID int64, pendingTxn string) error
+
+	// Update the approval status of a payment (see Account.RequiresApproval)
+	UpdatePaymentApprovalStatus(paymentID int64, status ApprovalStatus) error
+
+	// Store an approve/reject decision for a pending payment.
+	// It returns giga.AlreadyExists if the approver has already decided (key: PaymentID, Approver)
+	AddPaymentApproval(approval PaymentApproval) error
+
+	// ListPaymentApprovals returns the approve/reject decisions for a payment, oldest first.
+	ListPaymentApprovals(paymentID int64) ([]PaymentApproval, error)
+
+	// Release the UTXOs reserved by a payment that will not be sent (e.g. rejected)
+	// so they can be spent by another payment. Spent UTXOs are not affected.
+	ReleasePaymentUTXOs(paymentID int64) error
+
 	// ListPayments returns a list of payments for an account.
 	// pagination: next_cursor should be passed as 'cursor' on the next call (initial cursor = 0)
 	// pagination: when next_cursor == 0, that is the final page of results.
 	// pagination: stores CAN return < limit (or zero) items WITH next_cursor > 0 (due to filtering)
 	ListPayments(account Address, cursor int64, limit int) (items []Payment, next_cursor int64, err error)
 
+	// Queue a Withdrawal (pay-out) from an account, with status 'pending'
+	// The PayoutBatcher pays pending withdrawals in batches (see UpdateWithdrawalStatus)
+	CreateWithdrawal(account Address, payTo Address, amount CoinAmount) (Withdrawal, error)
+
+	// GetWithdrawal returns the queued Withdrawal for the given ID
+	// It returns giga.NotFound if the withdrawal does not exist (key: account, ID)
+	GetWithdrawal(account Address, id int64) (Withdrawal, error)
+
+	// List up to `limit` pending withdrawals for an account, oldest first.
+	ListPendingWithdrawals(account Address, limit int) ([]Withdrawal, error)
+
+	// List the IDs of all accounts that have pending withdrawals.
+	ListPendingWithdrawalAccounts() ([]Address, error)
+
+	// List up to `limit` batched withdrawals (from any account) oldest first:
+	// their batch payment is reserved, but was not sent.
+	ListBatchedWithdrawals(limit int) ([]Withdrawal, error)
+
+	// Update the status of withdrawals, storing the batch paymentID, txid
+	// and error message (any of which can be empty)
+	UpdateWithdrawalStatus(ids []int64, status WithdrawalStatus, paymentID int64, txID string, errMsg string) error
+
+	// Store a ScheduledPayment with status 'pending' (the store sets ID, Status and Created)
+	// PaymentID is stored for a LockTime payment (reserved up-front)
+	// The PaymentScheduler pays it when it is due (see UpdateScheduledPayment)
+	CreateScheduledPayment(sp ScheduledPayment) (ScheduledPayment, error)
+
+	// GetScheduledPayment returns the ScheduledPayment for the given ID
+	// It returns giga.NotFound if the scheduled payment does not exist (key: account, ID)
+	GetScheduledPayment(account Address, id int64) (ScheduledPayment, error)
+
+	// List up to `limit` pending scheduled payments (all accounts), oldest first.
+	ListPendingScheduledPayments(limit int) ([]ScheduledPayment, error)
+
+	// Update the Status, Attempts, NextAttempt, PaymentID, TxID and Error
+	// of a scheduled payment.
+	// It returns giga.NotFound if the scheduled payment does not exist (key: ID)
+	UpdateScheduledPayment(sp ScheduledPayment) error
+
+	// GetIdempotentResponse returns the stored response for an Idempotency-Key.
+	// It returns giga.NotFound if the key has not been used (key: account, Key)
+	GetIdempotentResponse(account Address, key string) (IdempotentResponse, error)
+
+	// Store the response for an Idempotency-Key, in the same transaction
+	// that creates the Payment or Invoice.
+	// It returns giga.AlreadyExists if the key has already been used (key: account, Key)
+	StoreIdempotentResponse(res IdempotentResponse) error
+
 	// CreateAccount stores a NEW account.
 	// It returns giga.AlreadyExists if the account already exists (key: ForeignID)
 	CreateAccount(account Account) error
@@ -131,12 +224,21 @@ type StoreTransaction interface {
 	// UpdateChainState updates the Best Block information (checkpoint for restart)
 	UpdateChainState(state ChainState, writeRoot bool) error
 
+	// StoreVerifiedHeaders records block headers that passed verification (see VerifyBlocks)
+	// Headers above the fork-point are removed by RevertChangesAboveHeight.
+	StoreVerifiedHeaders(headers []VerifiedHeader) error
+
+	// StoreSkippedBlock records a block that could not be decoded, so its changes were not applied.
+	// Skipped blocks above the fork-point are removed by RevertChangesAboveHeight.
+	StoreSkippedBlock(block SkippedBlock) error
+
 	// Create a new Unspent Transaction Output in the database.
 	CreateUTXO(utxo UTXO) error
 
 	// Mark a UTXO as reserved for an outgoing payment (storing the given txid)
 	// This prevents Gigawallet trying to double-spend the UTXO before MarkPaymentsOnChain.
 	// Reserved UTXOs are counted as "outgoing" for balance purposes.
+	// Returns DBConflict if the UTXO is already reserved (by a concurrent payment)
 	MarkUTXOReserved(txID string, vOut int, paymentID int64) error
 
 	// Mark an Unspent Transaction Output as spent (storing the given block-height and txid)
@@ -167,9 +269,34 @@ type StoreTransaction interface {
 	// Set an event-sent timestamp on an invoice.
 	MarkInvoiceEventSent(invoiceID Address, event EVENT_INV) error
 
+	// Set an event-sent timestamp on a payment (PAYMENT_ON_CHAIN, PAYMENT_CONFIRMED)
+	// PAYMENT_UNCONFIRMED also clears the event timestamps of any chain-heights that
+	// were rolled back, so those events are sent again when the payment is re-mined.
+	MarkPaymentEventSent(paymentID int64, event EVENT_PAYMENT) error
+
 	// RevertChangesAboveHeight clears chain-heights above the given height recorded in UTXOs and Payments.
 	// This serves to roll back the effects of adding or spending those UTXOs and/or Payments.
-	RevertChangesAboveHeight(maxValidHeight int64, nextSeq int64) (newSeq int64, err error)
+	// Verified headers and skipped blocks above the given height are also removed (see StoreVerifiedHeaders)
+	// For a reorg, reorgFromHeight is the height of the old tip: confirmed UTXOs and Payments that
+	// are rolled back are marked with the fork-point and old tip (see MarkDoubleSpends)
+	// Other rollbacks (ReSync, RollbackChain, RetrySkippedBlocks) pass zero, since those
+	// blocks are replayed from the same chain.
+	// If keepPayments is set (the old tip is still on-chain) Payments keep their chain-heights,
+	// because the replayed blocks contain the same Payments: this avoids sending PAYMENT_UNCONFIRMED
+	// and repeating PAYMENT_ON_CHAIN and PAYMENT_CONFIRMED events (see BalanceKeeper)
+	RevertChangesAboveHeight(maxValidHeight int64, nextSeq int64, reorgFromHeight int64, keepPayments bool) (newSeq int64, err error)
+
+	// ListReorgedTxIDs returns the TxIDs of confirmed UTXOs and Payments that were rolled
+	// back to the fork-point `forkHeight` by RevertChangesAboveHeight (and not yet re-mined)
+	ListReorgedTxIDs(forkHeight int64) ([]string, error)
+
+	// MarkDoubleSpends flags the confirmed UTXOs and Payments that were rolled back by a
+	// reorg (see RevertChangesAboveHeight) and have not been re-mined within `rejections`
+	// blocks of the fork-point, once the new chain is past the old tip, at the current
+	// block height passed in blockHeight. Invoices paid by those UTXOs are flagged as well
+	// (the flags are cleared if they are re-mined later.) Returns the newly-flagged
+	// Invoices and Payments.
+	MarkDoubleSpends(rejections int, blockHeight int64) ([]DoubleSpend, error)
 
 	// Increment the chain-sequence-number for multiple accounts.
 	// Use this after modifying accounts' blockchain-derived state (UTXOs, TXNs)
@@ -179,6 +306,16 @@ type StoreTransaction interface {
 	SetServiceCursor(name string, cursor int64) error
 }
 
+// DoubleSpend is an Invoice or Payment whose confirmed transaction was rolled back
+// by a reorg, and was not re-mined within RejectionsNeeded blocks (see MarkDoubleSpends)
+type DoubleSpend struct {
+	AccountID  Address  // owner account
+	InvoiceID  Address  // the Invoice paid by the transactions (or "")
+	PaymentID  int64    // the Payment made by the transaction (or 0)
+	TxIDs      []string // transactions that were not re-mined
+	ForkHeight int64    // block height of the fork-point (last common block)
+}
+
 // Current chainstate in the database.
 // Gigawallet TRANSACTIONALLY moves ChainState forward in batches of blocks,
 // updating UTXOs, Invoices and Account Balances in the same DB transaction.
@@ -196,3 +333,20 @@ func (c *ChainState) GetSeq() int64 {
 	c.NextSeq += 1
 	return seq
 }
+
+// VerifiedHeader is a block header on the chain being sync'd that passed
+// proof-of-work and merkle-root checks (see ChainFollowerConfig.VerifyBlocks)
+type VerifiedHeader struct {
+	Height int64  // block height
+	Hash   string // block hash (hex)
+	Header string // 80-byte block header (hex)
+}
+
+// SkippedBlock is a block on the chain being sync'd that the ChainFollower could
+// not decode, so any payments in it are missing (see ChainFollowerConfig.HaltOnSkippedBlock)
+type SkippedBlock struct {
+	Height  int64     `json:"height"`  // block height
+	Hash    string    `json:"hash"`    // block hash (hex)
+	Error   string    `json:"error"`   // decoding error
+	Skipped time.Time `json:"skipped"` // when the block was skipped
+}
@@ -123,6 +123,112 @@ CREATE TABLE IF NOT EXISTS services (
 const SQL_MIGRATION_v2 = `
 ALTER TABLE utxo ADD COLUMN spend_payment INTEGER;
 `
+const SQL_MIGRATION_v3 = `
+ALTER TABLE account ADD COLUMN coin_selection TEXT NOT NULL DEFAULT '';
+`
+const SQL_MIGRATION_v4 = `
+ALTER TABLE payment ADD COLUMN pay_type TEXT NOT NULL DEFAULT 'payout';
+`
+const SQL_MIGRATION_v5 = `
+ALTER TABLE payment ADD COLUMN memo TEXT NOT NULL DEFAULT '';
+`
+const SQL_MIGRATION_v6 = `
+ALTER TABLE account ADD COLUMN batch_size INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE account ADD COLUMN batch_interval INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE payment ADD COLUMN pending_tx TEXT NOT NULL DEFAULT '';
+
+CREATE TABLE IF NOT EXISTS withdrawal (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	account_address TEXT NOT NULL,
+	pay_to TEXT NOT NULL,
+	amount NUMERIC(18,8) NOT NULL,
+	status TEXT NOT NULL,
+	created DATETIME NOT NULL,
+	payment_id INTEGER,
+	paid_txid TEXT,
+	error TEXT NOT NULL DEFAULT ''
+);
+CREATE INDEX IF NOT EXISTS withdrawal_status_i ON withdrawal (status, account_address);
+`
+
+const SQL_MIGRATION_v7 = `
+CREATE TABLE IF NOT EXISTS idempotency (
+	account_address TEXT NOT NULL,
+	idem_key TEXT NOT NULL,
+	request_hash TEXT NOT NULL,
+	response TEXT NOT NULL,
+	created DATETIME NOT NULL,
+	PRIMARY KEY (account_address, idem_key)
+);
+`
+
+const SQL_MIGRATION_v8 = `
+ALTER TABLE account ADD COLUMN offline_signing BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE payment ADD COLUMN unsigned_tx TEXT NOT NULL DEFAULT '';
+`
+
+const SQL_MIGRATION_v9 = `
+ALTER TABLE account ADD COLUMN approvals_required INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE account ADD COLUMN approval_threshold NUMERIC(18,8) NOT NULL DEFAULT 0;
+ALTER TABLE account ADD COLUMN approved_addresses TEXT NOT NULL DEFAULT '';
+ALTER TABLE payment ADD COLUMN approval_status TEXT NOT NULL DEFAULT '';
+CREATE TABLE IF NOT EXISTS payment_approval (
+	payment_id INTEGER NOT NULL,
+	approver TEXT NOT NULL,
+	approved BOOLEAN NOT NULL,
+	created DATETIME NOT NULL,
+	PRIMARY KEY (payment_id, approver)
+);
+`
+
+const SQL_MIGRATION_v10 = `
+CREATE TABLE IF NOT EXISTS scheduled_payment (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	account_address TEXT NOT NULL,
+	pay_to TEXT NOT NULL,
+	memo TEXT NOT NULL DEFAULT '',
+	pay_at DATETIME,
+	pay_at_height INTEGER NOT NULL DEFAULT 0,
+	lock_time BOOLEAN NOT NULL DEFAULT FALSE,
+	status TEXT NOT NULL,
+	attempts INTEGER NOT NULL DEFAULT 0,
+	next_attempt DATETIME,
+	payment_id INTEGER,
+	paid_txid TEXT,
+	error TEXT NOT NULL DEFAULT '',
+	created DATETIME NOT NULL
+);
+CREATE INDEX IF NOT EXISTS scheduled_payment_status_i ON scheduled_payment (status);
+`
+
+const SQL_MIGRATION_v11 = `
+ALTER TABLE utxo ADD COLUMN reorg_height INTEGER;
+ALTER TABLE utxo ADD COLUMN double_spend_height INTEGER;
+ALTER TABLE invoice ADD COLUMN double_spend_height INTEGER;
+ALTER TABLE payment ADD COLUMN reorg_height INTEGER;
+ALTER TABLE payment ADD COLUMN double_spend_height INTEGER;
+ALTER TABLE utxo ADD COLUMN reorg_tip_height INTEGER;
+ALTER TABLE payment ADD COLUMN reorg_tip_height INTEGER;
+CREATE INDEX IF NOT EXISTS utxo_reorg_i ON utxo (reorg_height);
+CREATE INDEX IF NOT EXISTS payment_reorg_i ON payment (reorg_height);
+`
+
+const SQL_MIGRATION_v12 = `
+CREATE TABLE IF NOT EXISTS block_header (
+	height INTEGER NOT NULL PRIMARY KEY,
+	block_hash TEXT NOT NULL,
+	header TEXT NOT NULL
+);
+`
+
+const SQL_MIGRATION_v13 = `
+CREATE TABLE IF NOT EXISTS skipped_block (
+	height INTEGER NOT NULL PRIMARY KEY,
+	block_hash TEXT NOT NULL,
+	error TEXT NOT NULL,
+	skipped DATETIME NOT NULL
+);
+`
 
 var MIGRATIONS = []struct {
 	ver   int
@@ -130,6 +236,17 @@ var MIGRATIONS = []struct {
 }{
 	{1, SETUP_SQL},
 	{2, SQL_MIGRATION_v2},
+	{3, SQL_MIGRATION_v3},
+	{4, SQL_MIGRATION_v4},
+	{5, SQL_MIGRATION_v5},
+	{6, SQL_MIGRATION_v6},
+	{7, SQL_MIGRATION_v7},
+	{8, SQL_MIGRATION_v8},
+	{9, SQL_MIGRATION_v9},
+	{10, SQL_MIGRATION_v10},
+	{11, SQL_MIGRATION_v11},
+	{12, SQL_MIGRATION_v12},
+	{13, SQL_MIGRATION_v13},
 }
 
 /****************** SQLiteStore implements giga.Store ********************/
@@ -256,6 +373,22 @@ func (s SQLiteStore) ListPayments(account giga.Address, cursor int64, limit int)
 	return s.listPaymentsCommon(s.db, account, cursor, limit)
 }
 
+func (s SQLiteStore) GetWithdrawal(account giga.Address, id int64) (giga.Withdrawal, error) {
+	return s.getWithdrawalCommon(s.db, account, id)
+}
+
+func (s SQLiteStore) GetScheduledPayment(account giga.Address, id int64) (giga.ScheduledPayment, error) {
+	return s.getScheduledPaymentCommon(s.db, account, id)
+}
+
+func (s SQLiteStore) GetIdempotentResponse(account giga.Address, key string) (giga.IdempotentResponse, error) {
+	return s.getIdempotentResponseCommon(s.db, account, key)
+}
+
+func (s SQLiteStore) ListPaymentApprovals(paymentID int64) ([]giga.PaymentApproval, error) {
+	return s.listPaymentApprovalsCommon(s.db, paymentID)
+}
+
 func (s SQLiteStore) GetAllUnreservedUTXOs(account giga.Address) (result []giga.UTXO, err error) {
 	return s.getAllUnreservedUTXOsCommon(s.db, account)
 }
@@ -282,6 +415,40 @@ func (s SQLiteStore) getChainStateCommon(tx Queryable) (giga.ChainState, error)
 	return state, nil
 }
 
+func (s SQLiteStore) GetVerifiedHeader(height int64) (giga.VerifiedHeader, error) {
+	row := s.db.QueryRow("SELECT height, block_hash, header FROM block_header WHERE height=$1", height)
+	var hdr giga.VerifiedHeader
+	err := row.Scan(&hdr.Height, &hdr.Hash, &hdr.Header)
+	if err == sql.ErrNoRows {
+		return giga.VerifiedHeader{}, giga.NewErr(giga.NotFound, "block header not found: %v", height)
+	}
+	if err != nil {
+		return giga.VerifiedHeader{}, s.dbErr(err, "GetVerifiedHeader: row.Scan")
+	}
+	return hdr, nil
+}
+
+func (s SQLiteStore) ListSkippedBlocks() ([]giga.SkippedBlock, error) {
+	rows, err := s.db.Query("SELECT height, block_hash, error, skipped FROM skipped_block ORDER BY height")
+	if err != nil {
+		return nil, s.dbErr(err, "ListSkippedBlocks: query")
+	}
+	defer rows.Close()
+	blocks := []giga.SkippedBlock{}
+	for rows.Next() {
+		var b giga.SkippedBlock
+		err := rows.Scan(&b.Height, &b.Hash, &b.Error, &b.Skipped)
+		if err != nil {
+			return nil, s.dbErr(err, "ListSkippedBlocks: row.Scan")
+		}
+		blocks = append(blocks, b)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, s.dbErr(err, "ListSkippedBlocks: rows.Next")
+	}
+	return blocks, nil
+}
+
 func (s SQLiteStore) GetServiceCursor(name string) (cursor int64, err error) {
 	row := s.db.QueryRow("SELECT cursor FROM services WHERE name=$1", name)
 	err = row.Scan(&cursor)
@@ -296,7 +463,7 @@ func (s SQLiteStore) GetServiceCursor(name string) (cursor int64, err error) {
 
 func (s SQLiteStore) getAccountCommon(tx Queryable, accountKey string, isForeignKey bool) (giga.Account, error) {
 	// Used to fetch an Account by ID (Address) or by ForeignID.
-	query := "SELECT foreign_id,address,privkey,next_int_key,next_ext_key,next_pool_int,next_pool_ext,payout_address,payout_threshold,payout_frequency,current_balance,incoming_balance,outgoing_balance FROM account WHERE "
+	query := "SELECT foreign_id,address,privkey,next_int_key,next_ext_key,next_pool_int,next_pool_ext,payout_address,payout_threshold,payout_frequency,coin_selection,batch_size,batch_interval,offline_signing,approvals_required,approval_threshold,approved_addresses,current_balance,incoming_balance,outgoing_balance FROM account WHERE "
 	if isForeignKey {
 		query += "foreign_id = $1"
 	} else {
@@ -304,11 +471,13 @@ func (s SQLiteStore) getAccountCommon(tx Queryable, accountKey string, isForeign
 	}
 	row := tx.QueryRow(query, accountKey)
 	var acc giga.Account
+	var approved_addresses string
 	err := row.Scan(
 		&acc.ForeignID, &acc.Address, &acc.Privkey,
 		&acc.NextInternalKey, &acc.NextExternalKey,
 		&acc.NextPoolInternal, &acc.NextPoolExternal,
-		&acc.PayoutAddress, &acc.PayoutThreshold, &acc.PayoutFrequency, // common (see updateAccount)
+		&acc.PayoutAddress, &acc.PayoutThreshold, &acc.PayoutFrequency, &acc.CoinSelection, &acc.BatchSize, &acc.BatchInterval, &acc.OfflineSigning, // common (see updateAccount)
+		&acc.ApprovalsRequired, &acc.ApprovalThreshold, &approved_addresses,
 		&acc.CurrentBalance, &acc.IncomingBalance, &acc.OutgoingBalance) // not in updateAccount.
 	if err == sql.ErrNoRows {
 		return giga.Account{}, giga.NewErr(giga.NotFound, "account not found: %s", accountKey)
@@ -316,6 +485,12 @@ func (s SQLiteStore) getAccountCommon(tx Queryable, accountKey string, isForeign
 	if err != nil {
 		return giga.Account{}, s.dbErr(err, "GetAccount: row.Scan")
 	}
+	if approved_addresses != "" {
+		err = json.Unmarshal([]byte(approved_addresses), &acc.ApprovedAddresses)
+		if err != nil {
+			return giga.Account{}, s.dbErr(err, "GetAccount: json.Unmarshal approved_addresses")
+		}
+	}
 	return acc, nil
 }
 
@@ -323,12 +498,12 @@ func (s SQLiteStore) calculateBalanceCommon(tx Queryable, accountID giga.Address
 	// policy: change (is_internal) is never 'incoming' or 'outgoing', only 'current' until spent.
 	// incoming: utxo: !is_internal && added_height && !spendable_height
 	// current: utxo: (is_internal || spendable_height) && (!spending_height && !spend_payment)
-	// outgoing: payment.total where !confirmed_height (until confirmed)
+	// outgoing: payment.total where !confirmed_height (until confirmed) && not rejected
 	// this query uses the index on (account_address)
 	row := tx.QueryRow(`
 SELECT COALESCE((SELECT SUM(value) FROM utxo WHERE account_address=$1 AND is_internal=FALSE AND added_height IS NOT NULL AND spendable_height IS NULL),0),
 COALESCE((SELECT SUM(value) FROM utxo WHERE account_address=$1 AND (is_internal=TRUE OR spendable_height IS NOT NULL) AND spending_height IS NULL AND spend_payment IS NULL),0),
-COALESCE((SELECT SUM(total) FROM payment WHERE account_address=$1 AND confirmed_height IS NULL),0)`, accountID)
+COALESCE((SELECT SUM(total) FROM payment WHERE account_address=$1 AND confirmed_height IS NULL AND approval_status != 'rejected'),0)`, accountID)
 	err = row.Scan(&bal.IncomingBalance, &bal.CurrentBalance, &bal.OutgoingBalance)
 	return
 }
@@ -372,7 +547,7 @@ type Scannable interface {
 }
 
 // These must match the row.Scan in scanInvoice below.
-const invoice_select_cols = `invoice_address, account_address, items, key_index, block_id, confirmations, created, total, paid_height, paid_event, last_incoming, last_paid,
+const invoice_select_cols = `invoice_address, account_address, items, key_index, block_id, confirmations, created, total, paid_height, paid_event, last_incoming, last_paid, double_spend_height,
 COALESCE((SELECT SUM(value) FROM utxo WHERE added_height IS NOT NULL AND script_address=invoice.invoice_address),0) AS incoming_amount,
 COALESCE((SELECT SUM(value) FROM utxo WHERE spendable_height IS NOT NULL AND script_address=invoice.invoice_address),0) AS paid_amount`
 
@@ -385,8 +560,9 @@ func (s SQLiteStore) scanInvoice(row Scannable, invoiceID giga.Address) (giga.In
 	var paid_amount sql.NullString
 	var last_incoming sql.NullString
 	var last_paid sql.NullString
+	var double_spend_height sql.NullInt64
 	inv := giga.Invoice{}
-	err := row.Scan(&inv.ID, &inv.Account, &items_json, &inv.KeyIndex, &block_id, &inv.Confirmations, &inv.Created, &inv.Total, &paid_height, &paid_event, &last_incoming, &last_paid, &incoming_amount, &paid_amount)
+	err := row.Scan(&inv.ID, &inv.Account, &items_json, &inv.KeyIndex, &block_id, &inv.Confirmations, &inv.Created, &inv.Total, &paid_height, &paid_event, &last_incoming, &last_paid, &double_spend_height, &incoming_amount, &paid_amount)
 	if err == sql.ErrNoRows {
 		return inv, giga.NewErr(giga.NotFound, "invoice not found: %v", invoiceID)
 	}
@@ -406,6 +582,9 @@ func (s SQLiteStore) scanInvoice(row Scannable, invoiceID giga.Address) (giga.In
 	if paid_event.Valid {
 		inv.PaidEvent = paid_event.Time
 	}
+	if double_spend_height.Valid {
+		inv.DoubleSpendHeight = double_spend_height.Int64
+	}
 	if incoming_amount.Valid {
 		inv.IncomingAmount, err = decimal.NewFromString(incoming_amount.String)
 		if err != nil {
@@ -475,7 +654,7 @@ func (s SQLiteStore) listInvoicesCommon(tx Queryable, account giga.Address, curs
 }
 
 // These must match the row.Scan in scanPayment below.
-const payment_select_cols = "id, account_address, total, fee, created, paid_txid, paid_height, confirmed_height, on_chain_event, confirmed_event, unconfirmed_event"
+const payment_select_cols = "id, account_address, pay_type, total, fee, memo, unsigned_tx, approval_status, pending_tx, created, paid_txid, paid_height, confirmed_height, on_chain_event, confirmed_event, unconfirmed_event, double_spend_height"
 
 func (s SQLiteStore) scanPayment(row Scannable, account giga.Address) (giga.Payment, error) {
 	var paid_txid sql.NullString
@@ -484,8 +663,9 @@ func (s SQLiteStore) scanPayment(row Scannable, account giga.Address) (giga.Paym
 	var on_chain_event sql.NullTime
 	var confirmed_event sql.NullTime
 	var unconfirmed_event sql.NullTime
+	var double_spend_height sql.NullInt64
 	pay := giga.Payment{}
-	err := row.Scan(&pay.ID, &pay.AccountAddress, &pay.Total, &pay.Fee, &pay.Created, &paid_txid, &paid_height, &confirmed_height, &on_chain_event, &confirmed_event, &unconfirmed_event)
+	err := row.Scan(&pay.ID, &pay.AccountAddress, &pay.Type, &pay.Total, &pay.Fee, &pay.Memo, &pay.UnsignedTxn, &pay.ApprovalStatus, &pay.PendingTxn, &pay.Created, &paid_txid, &paid_height, &confirmed_height, &on_chain_event, &confirmed_event, &unconfirmed_event, &double_spend_height)
 	if err == sql.ErrNoRows {
 		return pay, giga.NewErr(giga.NotFound, "payment not found: %v", account)
 	}
@@ -510,6 +690,9 @@ func (s SQLiteStore) scanPayment(row Scannable, account giga.Address) (giga.Paym
 	if unconfirmed_event.Valid {
 		pay.UnconfirmedEvent = unconfirmed_event.Time
 	}
+	if double_spend_height.Valid {
+		pay.DoubleSpendHeight = double_spend_height.Int64
+	}
 	return pay, nil
 }
 
@@ -574,6 +757,112 @@ func (s SQLiteStore) listPaymentsCommon(tx Queryable, account giga.Address, curs
 	return
 }
 
+// These must match the row.Scan in scanWithdrawal below.
+const withdrawal_select_cols = "id, account_address, pay_to, amount, status, created, payment_id, paid_txid, error"
+
+func (s SQLiteStore) scanWithdrawal(row Scannable, account giga.Address) (giga.Withdrawal, error) {
+	var payment_id sql.NullInt64
+	var paid_txid sql.NullString
+	wd := giga.Withdrawal{}
+	err := row.Scan(&wd.ID, &wd.AccountID, &wd.PayTo, &wd.Amount, &wd.Status, &wd.Created, &payment_id, &paid_txid, &wd.Error)
+	if err == sql.ErrNoRows {
+		return wd, giga.NewErr(giga.NotFound, "withdrawal not found: %v", account)
+	}
+	if err != nil {
+		return wd, s.dbErr(err, "ScanWithdrawal: row.Scan")
+	}
+	if payment_id.Valid {
+		wd.PaymentID = payment_id.Int64
+	}
+	if paid_txid.Valid {
+		wd.TxID = paid_txid.String
+	}
+	return wd, nil
+}
+
+var get_withdrawal_sql = fmt.Sprintf("SELECT %s FROM withdrawal WHERE id = $1 AND account_address = $2", withdrawal_select_cols)
+
+func (s SQLiteStore) getWithdrawalCommon(tx Queryable, account giga.Address, id int64) (giga.Withdrawal, error) {
+	return s.scanWithdrawal(tx.QueryRow(get_withdrawal_sql, id, account), account)
+}
+
+// These must match the row.Scan in scanScheduledPayment below.
+const scheduled_select_cols = "id, account_address, pay_to, memo, pay_at, pay_at_height, lock_time, status, attempts, next_attempt, payment_id, paid_txid, error, created"
+
+func (s SQLiteStore) scanScheduledPayment(row Scannable) (giga.ScheduledPayment, error) {
+	var pay_to string
+	var pay_at sql.NullTime
+	var next_attempt sql.NullTime
+	var payment_id sql.NullInt64
+	var paid_txid sql.NullString
+	sp := giga.ScheduledPayment{}
+	err := row.Scan(&sp.ID, &sp.AccountID, &pay_to, &sp.Memo, &pay_at, &sp.PayAtHeight, &sp.LockTime, &sp.Status, &sp.Attempts, &next_attempt, &payment_id, &paid_txid, &sp.Error, &sp.Created)
+	if err == sql.ErrNoRows {
+		return sp, giga.NewErr(giga.NotFound, "scheduled payment not found")
+	}
+	if err != nil {
+		return sp, s.dbErr(err, "ScanScheduledPayment: row.Scan")
+	}
+	err = json.Unmarshal([]byte(pay_to), &sp.PayTo)
+	if err != nil {
+		return sp, s.dbErr(err, "ScanScheduledPayment: json.Unmarshal pay_to")
+	}
+	if pay_at.Valid {
+		sp.PayAt = pay_at.Time
+	}
+	if next_attempt.Valid {
+		sp.NextAttempt = next_attempt.Time
+	}
+	if payment_id.Valid {
+		sp.PaymentID = payment_id.Int64
+	}
+	if paid_txid.Valid {
+		sp.TxID = paid_txid.String
+	}
+	return sp, nil
+}
+
+var get_scheduled_sql = fmt.Sprintf("SELECT %s FROM scheduled_payment WHERE id = $1 AND account_address = $2", scheduled_select_cols)
+
+func (s SQLiteStore) getScheduledPaymentCommon(tx Queryable, account giga.Address, id int64) (giga.ScheduledPayment, error) {
+	return s.scanScheduledPayment(tx.QueryRow(get_scheduled_sql, id, account))
+}
+
+func (s SQLiteStore) getIdempotentResponseCommon(tx Queryable, account giga.Address, key string) (giga.IdempotentResponse, error) {
+	row := tx.QueryRow("SELECT request_hash, response, created FROM idempotency WHERE account_address = $1 AND idem_key = $2", account, key)
+	res := giga.IdempotentResponse{AccountID: account, Key: key}
+	var response string
+	err := row.Scan(&res.RequestHash, &response, &res.Created)
+	if err == sql.ErrNoRows {
+		return res, giga.NewErr(giga.NotFound, "idempotency key not found: %v", key)
+	}
+	if err != nil {
+		return res, s.dbErr(err, "GetIdempotentResponse: row.Scan")
+	}
+	res.Response = []byte(response)
+	return res, nil
+}
+
+func (s SQLiteStore) listPaymentApprovalsCommon(tx Queryable, paymentID int64) (result []giga.PaymentApproval, err error) {
+	rows, err := tx.Query("SELECT approver, approved, created FROM payment_approval WHERE payment_id = $1 ORDER BY created", paymentID)
+	if err != nil {
+		return nil, s.dbErr(err, "ListPaymentApprovals: querying approvals")
+	}
+	defer rows.Close()
+	for rows.Next() {
+		approval := giga.PaymentApproval{PaymentID: paymentID}
+		err := rows.Scan(&approval.Approver, &approval.Approved, &approval.Created)
+		if err != nil {
+			return nil, s.dbErr(err, "ListPaymentApprovals: scanning row")
+		}
+		result = append(result, approval)
+	}
+	if err = rows.Err(); err != nil { // docs say this check is required!
+		return nil, s.dbErr(err, "ListPaymentApprovals: querying approvals")
+	}
+	return
+}
+
 func (s SQLiteStore) getAllUnreservedUTXOsCommon(tx Queryable, account giga.Address) (result []giga.UTXO, err error) {
 	// • spendable_height > 0    –– the UTXO Txn has been "confirmed" (included in CurrentBalance)
 	// • or is_internal          –– the UTXO is change generated by Gigawallet (included in CurrentBalance)
@@ -666,6 +955,52 @@ func (t SQLiteStoreTransaction) UpdatePaymentWithTxID(paymentID int64, txID stri
 	return nil
 }
 
+func (t SQLiteStoreTransaction) SetPaymentUnsignedTxn(paymentID int64, unsignedTxn string) error {
+	_, err := t.tx.Exec("UPDATE payment SET unsigned_tx=$1 WHERE id=$2", unsignedTxn, paymentID)
+	if err != nil {
+		return t.store.dbErr(err, "SetPaymentUnsignedTxn: stmt.Exec update")
+	}
+	return nil
+}
+
+func (t SQLiteStoreTransaction) SetPaymentPendingTxn(paymentID int64, pendingTxn string) error {
+	_, err := t.tx.Exec("UPDATE payment SET pending_tx=$1 WHERE id=$2", pendingTxn, paymentID)
+	if err != nil {
+		return t.store.dbErr(err, "SetPaymentPendingTxn: stmt.Exec update")
+	}
+	return nil
+}
+
+func (t SQLiteStoreTransaction) UpdatePaymentApprovalStatus(paymentID int64, status giga.ApprovalStatus) error {
+	_, err := t.tx.Exec("UPDATE payment SET approval_status=$1 WHERE id=$2", status, paymentID)
+	if err != nil {
+		return t.store.dbErr(err, "UpdatePaymentApprovalStatus: stmt.Exec update")
+	}
+	return nil
+}
+
+func (t SQLiteStoreTransaction) AddPaymentApproval(approval giga.PaymentApproval) error {
+	_, err := t.tx.Exec(
+		"INSERT INTO payment_approval (payment_id, approver, approved, created) VALUES ($1,$2,$3,$4)",
+		approval.PaymentID, approval.Approver, approval.Approved, approval.Created)
+	if err != nil {
+		return t.store.dbErr(err, "AddPaymentApproval: insert")
+	}
+	return nil
+}
+
+func (t SQLiteStoreTransaction) ListPaymentApprovals(paymentID int64) ([]giga.PaymentApproval, error) {
+	return t.store.listPaymentApprovalsCommon(t.tx, paymentID)
+}
+
+func (t SQLiteStoreTransaction) ReleasePaymentUTXOs(paymentID int64) error {
+	_, err := t.tx.Exec("UPDATE utxo SET spend_payment=NULL WHERE spend_payment=$1 AND spending_height IS NULL", paymentID)
+	if err != nil {
+		return t.store.dbErr(err, "ReleasePaymentUTXOs: executing update")
+	}
+	return nil
+}
+
 func (t SQLiteStoreTransaction) ListPayments(account giga.Address, cursor int64, limit int) (items []giga.Payment, next_cursor int64, err error) {
 	return t.store.listPaymentsCommon(t.tx, account, cursor, limit)
 }
@@ -695,7 +1030,7 @@ func (t SQLiteStoreTransaction) StoreInvoice(inv giga.Invoice) error {
 	return nil
 }
 
-func (t SQLiteStoreTransaction) CreatePayment(accountAddr giga.Address, payTo []giga.PayTo, total giga.CoinAmount, fee giga.CoinAmount) (giga.Payment, error) {
+func (t SQLiteStoreTransaction) CreatePayment(accountAddr giga.Address, payType giga.PaymentType, payTo []giga.PayTo, memo string, total giga.CoinAmount, fee giga.CoinAmount) (giga.Payment, error) {
 	stmt, err := t.tx.Prepare("INSERT INTO output (payment_id, vout, pay_to, amount, deduct_fee_percent) VALUES ($1,$2,$3,$4,$5)")
 	if err != nil {
 		return giga.Payment{}, t.store.dbErr(err, "CreatePayment: preparing insert")
@@ -703,8 +1038,8 @@ func (t SQLiteStoreTransaction) CreatePayment(accountAddr giga.Address, payTo []
 	defer stmt.Close()
 	now := time.Now()
 	row := t.tx.QueryRow(
-		"INSERT INTO payment (account_address, created, total, fee) VALUES ($1,$2,$3,$4) RETURNING ID",
-		accountAddr, now, total, fee)
+		"INSERT INTO payment (account_address, pay_type, created, total, fee, memo) VALUES ($1,$2,$3,$4,$5,$6) RETURNING ID",
+		accountAddr, payType, now, total, fee, memo)
 	var id int64
 	err = row.Scan(&id)
 	if err != nil {
@@ -719,20 +1054,234 @@ func (t SQLiteStoreTransaction) CreatePayment(accountAddr giga.Address, payTo []
 	return giga.Payment{
 		ID:             id,
 		AccountAddress: accountAddr,
+		Type:           payType,
 		PayTo:          payTo,
 		Total:          total,
 		Fee:            fee,
+		Memo:           memo,
 		Created:        now,
 	}, nil
 }
 
-func (t SQLiteStoreTransaction) CreateAccount(acc giga.Account) error {
+func (t SQLiteStoreTransaction) CreateWithdrawal(accountAddr giga.Address, payTo giga.Address, amount giga.CoinAmount) (giga.Withdrawal, error) {
+	now := time.Now()
+	row := t.tx.QueryRow(
+		"INSERT INTO withdrawal (account_address, pay_to, amount, status, created) VALUES ($1,$2,$3,$4,$5) RETURNING id",
+		accountAddr, payTo, amount, giga.WithdrawalPending, now)
+	var id int64
+	err := row.Scan(&id)
+	if err != nil {
+		return giga.Withdrawal{}, t.store.dbErr(err, "CreateWithdrawal: insert")
+	}
+	return giga.Withdrawal{
+		ID:        id,
+		AccountID: accountAddr,
+		PayTo:     payTo,
+		Amount:    amount,
+		Status:    giga.WithdrawalPending,
+		Created:   now,
+	}, nil
+}
+
+func (t SQLiteStoreTransaction) GetWithdrawal(account giga.Address, id int64) (giga.Withdrawal, error) {
+	return t.store.getWithdrawalCommon(t.tx, account, id)
+}
+
+func (t SQLiteStoreTransaction) CreateScheduledPayment(sp giga.ScheduledPayment) (giga.ScheduledPayment, error) {
+	pay_to, err := json.Marshal(sp.PayTo)
+	if err != nil {
+		return giga.ScheduledPayment{}, t.store.dbErr(err, "CreateScheduledPayment: json.Marshal pay_to")
+	}
+	sp.Status = giga.SchedulePending
+	sp.Created = time.Now()
+	var payment_id sql.NullInt64
+	if sp.PaymentID != 0 {
+		payment_id = sql.NullInt64{Int64: sp.PaymentID, Valid: true}
+	}
+	row := t.tx.QueryRow(
+		"INSERT INTO scheduled_payment (account_address, pay_to, memo, pay_at, pay_at_height, lock_time, status, payment_id, created) VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9) RETURNING id",
+		sp.AccountID, string(pay_to), sp.Memo, sql.NullTime{Time: sp.PayAt, Valid: !sp.PayAt.IsZero()}, sp.PayAtHeight, sp.LockTime, sp.Status, payment_id, sp.Created)
+	err = row.Scan(&sp.ID)
+	if err != nil {
+		return giga.ScheduledPayment{}, t.store.dbErr(err, "CreateScheduledPayment: insert")
+	}
+	return sp, nil
+}
+
+func (t SQLiteStoreTransaction) GetScheduledPayment(account giga.Address, id int64) (giga.ScheduledPayment, error) {
+	return t.store.getScheduledPaymentCommon(t.tx, account, id)
+}
+
+var list_pending_scheduled_sql = fmt.Sprintf("SELECT %s FROM scheduled_payment WHERE status = $1 ORDER BY id LIMIT $2", scheduled_select_cols)
+
+func (t SQLiteStoreTransaction) ListPendingScheduledPayments(limit int) (items []giga.ScheduledPayment, err error) {
+	// note: there is an index on (status) for this query.
+	rows, err := t.tx.Query(list_pending_scheduled_sql, giga.SchedulePending, limit)
+	if err != nil {
+		return nil, t.store.dbErr(err, "ListPendingScheduledPayments: querying scheduled payments")
+	}
+	defer rows.Close()
+	for rows.Next() {
+		sp, err := t.store.scanScheduledPayment(rows)
+		if err != nil {
+			return nil, err // already s.dbErr
+		}
+		items = append(items, sp)
+	}
+	if err = rows.Err(); err != nil { // docs say this check is required!
+		return nil, t.store.dbErr(err, "ListPendingScheduledPayments: querying scheduled payments")
+	}
+	return
+}
+
+func (t SQLiteStoreTransaction) UpdateScheduledPayment(sp giga.ScheduledPayment) error {
+	var payment_id sql.NullInt64
+	if sp.PaymentID != 0 {
+		payment_id = sql.NullInt64{Int64: sp.PaymentID, Valid: true}
+	}
+	var paid_txid sql.NullString
+	if sp.TxID != "" {
+		paid_txid = sql.NullString{String: sp.TxID, Valid: true}
+	}
+	res, err := t.tx.Exec(
+		"UPDATE scheduled_payment SET status=$1, attempts=$2, next_attempt=$3, payment_id=$4, paid_txid=$5, error=$6 WHERE id=$7",
+		sp.Status, sp.Attempts, sql.NullTime{Time: sp.NextAttempt, Valid: !sp.NextAttempt.IsZero()}, payment_id, paid_txid, sp.Error, sp.ID)
+	if err != nil {
+		return t.store.dbErr(err, "UpdateScheduledPayment: executing update")
+	}
+	num_rows, err := res.RowsAffected()
+	if err != nil {
+		return t.store.dbErr(err, "UpdateScheduledPayment: res.RowsAffected")
+	}
+	if num_rows < 1 {
+		return giga.NewErr(giga.NotFound, "scheduled payment not found: %v", sp.ID)
+	}
+	return nil
+}
+
+func (t SQLiteStoreTransaction) GetIdempotentResponse(account giga.Address, key string) (giga.IdempotentResponse, error) {
+	return t.store.getIdempotentResponseCommon(t.tx, account, key)
+}
+
+func (t SQLiteStoreTransaction) StoreIdempotentResponse(res giga.IdempotentResponse) error {
 	_, err := t.tx.Exec(
-		"insert into account(foreign_id,address,privkey,next_int_key,next_ext_key,next_pool_int,next_pool_ext,payout_address,payout_threshold,payout_frequency) values($1,$2,$3,$4,$5,$6,$7,$8,$9,$10)",
+		"INSERT INTO idempotency (account_address, idem_key, request_hash, response, created) VALUES ($1,$2,$3,$4,$5)",
+		res.AccountID, res.Key, res.RequestHash, string(res.Response), res.Created)
+	if err != nil {
+		return t.store.dbErr(err, "StoreIdempotentResponse: insert")
+	}
+	return nil
+}
+
+var list_pending_withdrawals_sql = fmt.Sprintf("SELECT %s FROM withdrawal WHERE status = $1 AND account_address = $2 ORDER BY id LIMIT $3", withdrawal_select_cols)
+
+func (t SQLiteStoreTransaction) ListPendingWithdrawals(account giga.Address, limit int) (items []giga.Withdrawal, err error) {
+	// note: there is an index on (status, account_address) for this query.
+	rows, err := t.tx.Query(list_pending_withdrawals_sql, giga.WithdrawalPending, account, limit)
+	if err != nil {
+		return nil, t.store.dbErr(err, "ListPendingWithdrawals: querying withdrawals")
+	}
+	defer rows.Close()
+	for rows.Next() {
+		wd, err := t.store.scanWithdrawal(rows, account)
+		if err != nil {
+			return nil, err // already s.dbErr
+		}
+		items = append(items, wd)
+	}
+	if err = rows.Err(); err != nil { // docs say this check is required!
+		return nil, t.store.dbErr(err, "ListPendingWithdrawals: querying withdrawals")
+	}
+	return
+}
+
+var list_batched_withdrawals_sql = fmt.Sprintf("SELECT %s FROM withdrawal WHERE status = $1 ORDER BY id LIMIT $2", withdrawal_select_cols)
+
+func (t SQLiteStoreTransaction) ListBatchedWithdrawals(limit int) (items []giga.Withdrawal, err error) {
+	// note: there is an index on (status, account_address) for this query.
+	rows, err := t.tx.Query(list_batched_withdrawals_sql, giga.WithdrawalBatched, limit)
+	if err != nil {
+		return nil, t.store.dbErr(err, "ListBatchedWithdrawals: querying withdrawals")
+	}
+	defer rows.Close()
+	for rows.Next() {
+		wd, err := t.store.scanWithdrawal(rows, "")
+		if err != nil {
+			return nil, err // already s.dbErr
+		}
+		items = append(items, wd)
+	}
+	if err = rows.Err(); err != nil { // docs say this check is required!
+		return nil, t.store.dbErr(err, "ListBatchedWithdrawals: querying withdrawals")
+	}
+	return
+}
+
+func (t SQLiteStoreTransaction) ListPendingWithdrawalAccounts() (accounts []giga.Address, err error) {
+	// note: there is an index on (status, account_address) for this query.
+	rows, err := t.tx.Query("SELECT DISTINCT account_address FROM withdrawal WHERE status = $1", giga.WithdrawalPending)
+	if err != nil {
+		return nil, t.store.dbErr(err, "ListPendingWithdrawalAccounts: querying withdrawals")
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id giga.Address
+		err = rows.Scan(&id)
+		if err != nil {
+			return nil, t.store.dbErr(err, "ListPendingWithdrawalAccounts: scanning row")
+		}
+		accounts = append(accounts, id)
+	}
+	if err = rows.Err(); err != nil { // docs say this check is required!
+		return nil, t.store.dbErr(err, "ListPendingWithdrawalAccounts: querying withdrawals")
+	}
+	return
+}
+
+func (t SQLiteStoreTransaction) UpdateWithdrawalStatus(ids []int64, status giga.WithdrawalStatus, paymentID int64, txID string, errMsg string) error {
+	stmt, err := t.tx.Prepare("UPDATE withdrawal SET status=$1, payment_id=$2, paid_txid=$3, error=$4 WHERE id=$5")
+	if err != nil {
+		return t.store.dbErr(err, "UpdateWithdrawalStatus: preparing update")
+	}
+	defer stmt.Close()
+	var payment_id sql.NullInt64
+	if paymentID != 0 {
+		payment_id = sql.NullInt64{Int64: paymentID, Valid: true}
+	}
+	var paid_txid sql.NullString
+	if txID != "" {
+		paid_txid = sql.NullString{String: txID, Valid: true}
+	}
+	for _, id := range ids {
+		_, err = stmt.Exec(status, payment_id, paid_txid, errMsg, id)
+		if err != nil {
+			return t.store.dbErr(err, "UpdateWithdrawalStatus: executing update")
+		}
+	}
+	return nil
+}
+
+// Approved addresses are stored as a JSON array (empty: no list)
+func encodeApprovedAddresses(addrs []giga.Address) (string, error) {
+	if len(addrs) == 0 {
+		return "", nil
+	}
+	b, err := json.Marshal(addrs)
+	return string(b), err
+}
+
+func (t SQLiteStoreTransaction) CreateAccount(acc giga.Account) error {
+	approved_addresses, err := encodeApprovedAddresses(acc.ApprovedAddresses)
+	if err != nil {
+		return t.store.dbErr(err, "createAccount: json.Marshal approved_addresses")
+	}
+	_, err = t.tx.Exec(
+		"insert into account(foreign_id,address,privkey,next_int_key,next_ext_key,next_pool_int,next_pool_ext,payout_address,payout_threshold,payout_frequency,coin_selection,batch_size,batch_interval,offline_signing,approvals_required,approval_threshold,approved_addresses) values($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14,$15,$16,$17)",
 		acc.ForeignID, acc.Address, acc.Privkey, // only in createAccount.
 		acc.NextInternalKey, acc.NextExternalKey, // common (see updateAccount) ...
 		acc.NextPoolInternal, acc.NextPoolExternal,
-		acc.PayoutAddress, acc.PayoutThreshold, acc.PayoutFrequency)
+		acc.PayoutAddress, acc.PayoutThreshold, acc.PayoutFrequency, acc.CoinSelection, acc.BatchSize, acc.BatchInterval, acc.OfflineSigning,
+		acc.ApprovalsRequired, acc.ApprovalThreshold, approved_addresses)
 	if err != nil {
 		return t.store.dbErr(err, "createAccount: executing insert")
 	}
@@ -740,14 +1289,19 @@ func (t SQLiteStoreTransaction) CreateAccount(acc giga.Account) error {
 }
 
 func (t SQLiteStoreTransaction) UpdateAccount(acc giga.Account) error {
-	sql := "UPDATE account SET next_int_key=MAX(next_int_key,$1), next_ext_key=MAX(next_ext_key,$2), next_pool_int=MAX(next_pool_int,$3), next_pool_ext=MAX(next_pool_ext,$4), payout_address=$5, payout_threshold=$6, payout_frequency=$7 WHERE foreign_id=$8"
+	sql := "UPDATE account SET next_int_key=MAX(next_int_key,$1), next_ext_key=MAX(next_ext_key,$2), next_pool_int=MAX(next_pool_int,$3), next_pool_ext=MAX(next_pool_ext,$4), payout_address=$5, payout_threshold=$6, payout_frequency=$7, coin_selection=$8, batch_size=$9, batch_interval=$10, offline_signing=$11, approvals_required=$12, approval_threshold=$13, approved_addresses=$14 WHERE foreign_id=$15"
 	if t.store.isPostgres {
-		sql = "UPDATE account SET next_int_key=GREATEST(next_int_key,$1), next_ext_key=GREATEST(next_ext_key,$2), next_pool_int=GREATEST(next_pool_int,$3), next_pool_ext=GREATEST(next_pool_ext,$4), payout_address=$5, payout_threshold=$6, payout_frequency=$7 WHERE foreign_id=$8"
+		sql = "UPDATE account SET next_int_key=GREATEST(next_int_key,$1), next_ext_key=GREATEST(next_ext_key,$2), next_pool_int=GREATEST(next_pool_int,$3), next_pool_ext=GREATEST(next_pool_ext,$4), payout_address=$5, payout_threshold=$6, payout_frequency=$7, coin_selection=$8, batch_size=$9, batch_interval=$10, offline_signing=$11, approvals_required=$12, approval_threshold=$13, approved_addresses=$14 WHERE foreign_id=$15"
+	}
+	approved_addresses, err := encodeApprovedAddresses(acc.ApprovedAddresses)
+	if err != nil {
+		return t.store.dbErr(err, "updateAccount: json.Marshal approved_addresses")
 	}
 	res, err := t.tx.Exec(sql,
 		acc.NextInternalKey, acc.NextExternalKey, // common (see createAccount) ...
 		acc.NextPoolInternal, acc.NextPoolExternal,
-		acc.PayoutAddress, acc.PayoutThreshold, acc.PayoutFrequency,
+		acc.PayoutAddress, acc.PayoutThreshold, acc.PayoutFrequency, acc.CoinSelection, acc.BatchSize, acc.BatchInterval, acc.OfflineSigning,
+		acc.ApprovalsRequired, acc.ApprovalThreshold, approved_addresses,
 		acc.ForeignID) // the Key (not updated)
 	return t.checkRowsAffected(res, err, "account", acc.ForeignID)
 }
@@ -831,8 +1385,31 @@ func (t SQLiteStoreTransaction) UpdateChainState(state giga.ChainState, writeRoo
 	return nil
 }
 
-const create_utxo_sqlite = "INSERT INTO utxo (txn_id, vout, value, script, script_type, script_address, account_address, key_index, is_internal, added_height) VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10) ON CONFLICT DO UPDATE SET value=$3, script=$4, script_type=$5, script_address=$6, account_address=$7, key_index=$8, is_internal=$9, added_height=$10 WHERE txn_id=$1 AND vout=$2"
-const create_utxo_psql = "INSERT INTO utxo (txn_id, vout, value, script, script_type, script_address, account_address, key_index, is_internal, added_height) VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10) ON CONFLICT ON CONSTRAINT utxo_pkey DO UPDATE SET value=$3, script=$4, script_type=$5, script_address=$6, account_address=$7, key_index=$8, is_internal=$9, added_height=$10"
+func (t SQLiteStoreTransaction) StoreVerifiedHeaders(headers []giga.VerifiedHeader) error {
+	stmt, err := t.tx.Prepare("INSERT INTO block_header (height, block_hash, header) VALUES ($1,$2,$3) ON CONFLICT (height) DO UPDATE SET block_hash=$2, header=$3")
+	if err != nil {
+		return t.store.dbErr(err, "StoreVerifiedHeaders: preparing insert")
+	}
+	defer stmt.Close()
+	for _, hdr := range headers {
+		_, err = stmt.Exec(hdr.Height, hdr.Hash, hdr.Header)
+		if err != nil {
+			return t.store.dbErr(err, "StoreVerifiedHeaders: executing insert")
+		}
+	}
+	return nil
+}
+
+func (t SQLiteStoreTransaction) StoreSkippedBlock(block giga.SkippedBlock) error {
+	_, err := t.tx.Exec("INSERT INTO skipped_block (height, block_hash, error, skipped) VALUES ($1,$2,$3,$4) ON CONFLICT (height) DO UPDATE SET block_hash=$2, error=$3, skipped=$4", block.Height, block.Hash, block.Error, block.Skipped)
+	if err != nil {
+		return t.store.dbErr(err, "StoreSkippedBlock: executing insert")
+	}
+	return nil
+}
+
+const create_utxo_sqlite = "INSERT INTO utxo (txn_id, vout, value, script, script_type, script_address, account_address, key_index, is_internal, added_height) VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10) ON CONFLICT DO UPDATE SET value=$3, script=$4, script_type=$5, script_address=$6, account_address=$7, key_index=$8, is_internal=$9, added_height=$10, reorg_height=NULL, reorg_tip_height=NULL, double_spend_height=NULL WHERE txn_id=$1 AND vout=$2"
+const create_utxo_psql = "INSERT INTO utxo (txn_id, vout, value, script, script_type, script_address, account_address, key_index, is_internal, added_height) VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10) ON CONFLICT ON CONSTRAINT utxo_pkey DO UPDATE SET value=$3, script=$4, script_type=$5, script_address=$6, account_address=$7, key_index=$8, is_internal=$9, added_height=$10, reorg_height=NULL, reorg_tip_height=NULL, double_spend_height=NULL"
 
 func (t SQLiteStoreTransaction) CreateUTXO(utxo giga.UTXO) error {
 	// Create a new Unspent Transaction Output in the database.
@@ -851,14 +1428,28 @@ func (t SQLiteStoreTransaction) CreateUTXO(utxo giga.UTXO) error {
 	if err != nil {
 		return t.store.dbErr(err, "CreateUTXO: preparing insert")
 	}
+	// A double-spent UTXO that is re-mined clears the flag on its invoice (see MarkDoubleSpends)
+	_, err = t.tx.Exec("UPDATE invoice SET double_spend_height=NULL WHERE invoice_address=$1 AND double_spend_height IS NOT NULL AND NOT EXISTS (SELECT 1 FROM utxo WHERE script_address=$1 AND double_spend_height IS NOT NULL)", utxo.ScriptAddress)
+	if err != nil {
+		return t.store.dbErr(err, "CreateUTXO: invoice double_spend_height")
+	}
 	return nil
 }
 
 func (t SQLiteStoreTransaction) MarkUTXOReserved(txID string, vOut int, paymentID int64) error {
-	_, err := t.tx.Exec("UPDATE utxo SET spend_payment=$1 WHERE txn_id=$2 AND vout=$3", paymentID, txID, vOut)
+	// Only if not already reserved: another payment may have read the same
+	// unreserved UTXOs (e.g. a background service alongside /pay)
+	res, err := t.tx.Exec("UPDATE utxo SET spend_payment=$1 WHERE txn_id=$2 AND vout=$3 AND spend_payment IS NULL", paymentID, txID, vOut)
 	if err != nil {
 		return t.store.dbErr(err, "MarkUTXOReserved: executing update")
 	}
+	num_rows, err := res.RowsAffected()
+	if err != nil {
+		return t.store.dbErr(err, "MarkUTXOReserved: res.RowsAffected")
+	}
+	if num_rows < 1 {
+		return giga.NewErr(giga.DBConflict, "MarkUTXOReserved: UTXO is already reserved or does not exist: %v:%v", txID, vOut)
+	}
 	return nil
 }
 
@@ -877,11 +1468,11 @@ func (t SQLiteStoreTransaction) MarkUTXOSpent(txID string, vOut int, blockHeight
 // Mark payments paid that match any of the txIDs (storing the given block-height)
 // Returns the IDs of the Accounts that own any affected payments (can have duplicates)
 func (t SQLiteStoreTransaction) MarkPaymentsOnChain(txIDs []string, blockHeight int64) (accounts []string, err error) {
-	stmt, err := t.tx.Prepare("UPDATE payment SET paid_height=$1 WHERE paid_txid=$2 RETURNING account_address")
+	stmt, err := t.tx.Prepare("UPDATE payment SET paid_height=$1, reorg_height=NULL, reorg_tip_height=NULL, double_spend_height=NULL WHERE paid_txid=$2 RETURNING account_address")
 	if err != nil {
 		return nil, t.store.dbErr(err, "MarkPaymentsOnChain: preparing update")
 	}
-	for id := range txIDs {
+	for _, id := range txIDs {
 		rows, err := stmt.Query(blockHeight, id)
 		if accounts, err = collectArrayIDs(rows, err, accounts); err != nil {
 			return nil, t.store.dbErr(err, "MarkPaymentsOnChain")
@@ -955,6 +1546,31 @@ func (t SQLiteStoreTransaction) MarkInvoiceEventSent(invoiceID giga.Address, eve
 	return nil
 }
 
+func (t SQLiteStoreTransaction) MarkPaymentEventSent(paymentID int64, event giga.EVENT_PAYMENT) error {
+	sql := ""
+	switch event {
+	case giga.PAYMENT_ON_CHAIN:
+		// set on_chain_event = NOW
+		sql = "UPDATE payment SET on_chain_event=CURRENT_TIMESTAMP, unconfirmed_event=NULL WHERE id=$1"
+	case giga.PAYMENT_CONFIRMED:
+		// set confirmed_event = NOW
+		sql = "UPDATE payment SET confirmed_event=CURRENT_TIMESTAMP, unconfirmed_event=NULL WHERE id=$1"
+	case giga.PAYMENT_UNCONFIRMED:
+		// set unconfirmed_event = NOW, clear events for the heights that were rolled back
+		sql = `UPDATE payment SET unconfirmed_event=CURRENT_TIMESTAMP,
+on_chain_event=CASE WHEN paid_height IS NULL THEN NULL ELSE on_chain_event END,
+confirmed_event=CASE WHEN confirmed_height IS NULL THEN NULL ELSE confirmed_event END
+WHERE id=$1`
+	default:
+		return giga.NewErr(giga.BadRequest, "unsupported event")
+	}
+	_, err := t.tx.Exec(sql, paymentID)
+	if err != nil {
+		return t.store.dbErr(err, "MarkPaymentEventSent: UPDATE")
+	}
+	return nil
+}
+
 // Prepare query for MarkInvoicesPaid.
 // Summing all UTXOs that payTo the Invoice Address that have been confirmed (spendable_height is non-null)
 var sum_utxos_for_invoice = "SELECT SUM(value) FROM utxo WHERE script_address=i.invoice_address AND spendable_height IS NOT NULL"
@@ -990,6 +1606,15 @@ func collectArrayIDs(rows *sql.Rows, err error, ids []string) ([]string, error)
 	return ids, rows.Err()
 }
 
+func appendUniqueID(ids []string, id string) []string {
+	for _, have := range ids {
+		if have == id {
+			return ids
+		}
+	}
+	return append(ids, id)
+}
+
 func collectIDs(rows *sql.Rows, dbErr error, accounts map[string]int64, seq int64) (int64, error) {
 	if dbErr != nil {
 		return seq, dbErr
@@ -1009,13 +1634,26 @@ func collectIDs(rows *sql.Rows, dbErr error, accounts map[string]int64, seq int6
 	return seq, rows.Err()
 }
 
-func (t SQLiteStoreTransaction) RevertChangesAboveHeight(maxValidHeight int64, seq int64) (int64, error) {
+func (t SQLiteStoreTransaction) RevertChangesAboveHeight(maxValidHeight int64, seq int64, reorgFromHeight int64, keepPayments bool) (int64, error) {
 	// UTXOs.
 	// The presence of a height in added_height, spendable_height, spending_height, spent_height
 	// indicates that the UTXO is in the process of being added, or has been added (confirmed);
 	// is reserved for spending, or has been spent (confirmed)
 	// When we undo one of these, we always undo the stages that happen later as well.
 	accounts := make(map[string]int64)
+	// Double-spend detection (reorgs only)
+	// Record the fork-point and old tip on confirmed UTXOs and Payments that are about to be
+	// rolled back; this is cleared if they are re-mined, otherwise MarkDoubleSpends will flag them.
+	if reorgFromHeight > maxValidHeight {
+		_, err := t.tx.Exec("UPDATE utxo SET reorg_height=$1, reorg_tip_height=$2 WHERE spendable_height>$1", maxValidHeight, reorgFromHeight)
+		if err != nil {
+			return seq, t.store.dbErr(err, "RevertUTXOsAboveHeight: utxo reorg_height")
+		}
+		_, err = t.tx.Exec("UPDATE payment SET reorg_height=$1, reorg_tip_height=$2 WHERE confirmed_height>$1", maxValidHeight, reorgFromHeight)
+		if err != nil {
+			return seq, t.store.dbErr(err, "RevertUTXOsAboveHeight: payment reorg_height")
+		}
+	}
 	rows, err := t.tx.Query("UPDATE utxo SET added_height=NULL,spendable_height=NULL,spending_height=NULL,spent_height=NULL WHERE added_height>$1 RETURNING account_address", maxValidHeight)
 	if seq, err = collectIDs(rows, err, accounts, seq); err != nil {
 		return seq, t.store.dbErr(err, "RevertUTXOsAboveHeight: utxo update 1")
@@ -1032,6 +1670,16 @@ func (t SQLiteStoreTransaction) RevertChangesAboveHeight(maxValidHeight int64, s
 	if seq, err = collectIDs(rows, err, accounts, seq); err != nil {
 		return seq, t.store.dbErr(err, "RevertUTXOsAboveHeight: utxo update 4")
 	}
+	// Verified headers (see StoreVerifiedHeaders)
+	_, err = t.tx.Exec("DELETE FROM block_header WHERE height>$1", maxValidHeight)
+	if err != nil {
+		return seq, t.store.dbErr(err, "RevertUTXOsAboveHeight: block_header delete")
+	}
+	// Skipped blocks (see StoreSkippedBlock)
+	_, err = t.tx.Exec("DELETE FROM skipped_block WHERE height>$1", maxValidHeight)
+	if err != nil {
+		return seq, t.store.dbErr(err, "RevertUTXOsAboveHeight: skipped_block delete")
+	}
 	// Invoices.
 	// Presence of paid_height means MarkInvoicesPaid has seen sum(utxos) > total where
 	// the UTXOs have been marked as confirmed (i.e. N confirmations where N comes from the invoice!)
@@ -1040,20 +1688,106 @@ func (t SQLiteStoreTransaction) RevertChangesAboveHeight(maxValidHeight int64, s
 		return seq, t.store.dbErr(err, "RevertUTXOsAboveHeight: invoice update")
 	}
 	// Payments.
-	// Presence of paid_height means MarkPaymentsOnChain has seen the payment in a block.
-	// If we undo this, we also undo confirmed_height (which happens later)
-	rows, err = t.tx.Query("UPDATE payment SET paid_height=NULL,confirmed_height=NULL WHERE paid_height>$1 RETURNING account_address", maxValidHeight)
-	if seq, err = collectIDs(rows, err, accounts, seq); err != nil {
-		return seq, t.store.dbErr(err, "RevertUTXOsAboveHeight: payment update 1")
-	}
-	// Presence of confirmed_height means ConfirmPayments has seen N confirmations.
-	rows, err = t.tx.Query("UPDATE payment SET confirmed_height=NULL WHERE confirmed_height>$1 RETURNING account_address", maxValidHeight)
-	if seq, err = collectIDs(rows, err, accounts, seq); err != nil {
-		return seq, t.store.dbErr(err, "RevertUTXOsAboveHeight: payment update 2")
+	// When replaying the same blocks (keepPayments) they will be marked at the same heights.
+	if !keepPayments {
+		// Presence of paid_height means MarkPaymentsOnChain has seen the payment in a block.
+		// If we undo this, we also undo confirmed_height (which happens later)
+		rows, err = t.tx.Query("UPDATE payment SET paid_height=NULL,confirmed_height=NULL WHERE paid_height>$1 RETURNING account_address", maxValidHeight)
+		if seq, err = collectIDs(rows, err, accounts, seq); err != nil {
+			return seq, t.store.dbErr(err, "RevertUTXOsAboveHeight: payment update 1")
+		}
+		// Presence of confirmed_height means ConfirmPayments has seen N confirmations.
+		rows, err = t.tx.Query("UPDATE payment SET confirmed_height=NULL WHERE confirmed_height>$1 RETURNING account_address", maxValidHeight)
+		if seq, err = collectIDs(rows, err, accounts, seq); err != nil {
+			return seq, t.store.dbErr(err, "RevertUTXOsAboveHeight: payment update 2")
+		}
 	}
 	return seq, t.IncChainSeqForAccounts(accounts)
 }
 
+func (t SQLiteStoreTransaction) ListReorgedTxIDs(forkHeight int64) ([]string, error) {
+	rows, err := t.tx.Query("SELECT txn_id FROM utxo WHERE reorg_height=$1 AND added_height IS NULL UNION SELECT paid_txid FROM payment WHERE reorg_height=$1 AND paid_height IS NULL", forkHeight)
+	txIDs, err := collectArrayIDs(rows, err, nil)
+	if err != nil {
+		return nil, t.store.dbErr(err, "ListReorgedTxIDs")
+	}
+	return txIDs, nil
+}
+
+// There is an index on (reorg_height) for these queries.
+// Blocks of the new chain up to the old tip may yet re-mine the transaction, so
+// wait until the new chain is past the old tip (reorg_tip_height)
+const double_spend_utxos_sql = "UPDATE utxo SET double_spend_height=$1 WHERE reorg_height IS NOT NULL AND reorg_height+$2 <= $1 AND COALESCE(reorg_tip_height,reorg_height) < $1 AND added_height IS NULL AND double_spend_height IS NULL RETURNING txn_id, script_address, account_address, reorg_height"
+const double_spend_payments_sql = "UPDATE payment SET double_spend_height=$1 WHERE reorg_height IS NOT NULL AND reorg_height+$2 <= $1 AND COALESCE(reorg_tip_height,reorg_height) < $1 AND paid_height IS NULL AND double_spend_height IS NULL RETURNING id, account_address, paid_txid, reorg_height"
+
+func (t SQLiteStoreTransaction) MarkDoubleSpends(rejections int, blockHeight int64) (result []giga.DoubleSpend, err error) {
+	// UTXOs: collect the flagged UTXOs by script address (i.e. Invoice ID)
+	rows, err := t.tx.Query(double_spend_utxos_sql, blockHeight, rejections)
+	if err != nil {
+		return nil, t.store.dbErr(err, "MarkDoubleSpends: utxo update")
+	}
+	invoices := make(map[giga.Address]*giga.DoubleSpend)
+	var addresses []giga.Address // in order found.
+	for rows.Next() {
+		var txID string
+		var address, account giga.Address
+		var forkHeight int64
+		err = rows.Scan(&txID, &address, &account, &forkHeight)
+		if err != nil {
+			rows.Close()
+			return nil, t.store.dbErr(err, "MarkDoubleSpends: utxo scan")
+		}
+		ds, found := invoices[address]
+		if !found {
+			ds = &giga.DoubleSpend{AccountID: account, InvoiceID: address, ForkHeight: forkHeight}
+			invoices[address] = ds
+			addresses = append(addresses, address)
+		}
+		ds.TxIDs = appendUniqueID(ds.TxIDs, txID)
+		if forkHeight < ds.ForkHeight {
+			ds.ForkHeight = forkHeight
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return nil, t.store.dbErr(err, "MarkDoubleSpends: utxo rows")
+	}
+	// Invoices: flag the invoices paid by those UTXOs.
+	// UTXOs that do not pay an invoice are only flagged on the UTXO.
+	for _, address := range addresses {
+		res, err := t.tx.Exec("UPDATE invoice SET double_spend_height=COALESCE(double_spend_height,$1) WHERE invoice_address=$2", blockHeight, address)
+		if err != nil {
+			return nil, t.store.dbErr(err, "MarkDoubleSpends: invoice update")
+		}
+		num, err := res.RowsAffected()
+		if err != nil {
+			return nil, t.store.dbErr(err, "MarkDoubleSpends: invoice rows affected")
+		}
+		if num > 0 {
+			result = append(result, *invoices[address])
+		}
+	}
+	// Payments.
+	rows, err = t.tx.Query(double_spend_payments_sql, blockHeight, rejections)
+	if err != nil {
+		return nil, t.store.dbErr(err, "MarkDoubleSpends: payment update")
+	}
+	defer rows.Close()
+	for rows.Next() {
+		ds := giga.DoubleSpend{}
+		var txID string
+		err = rows.Scan(&ds.PaymentID, &ds.AccountID, &txID, &ds.ForkHeight)
+		if err != nil {
+			return nil, t.store.dbErr(err, "MarkDoubleSpends: payment scan")
+		}
+		ds.TxIDs = []string{txID}
+		result = append(result, ds)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, t.store.dbErr(err, "MarkDoubleSpends: payment rows")
+	}
+	return result, nil
+}
+
 func (t SQLiteStoreTransaction) IncChainSeqForAccounts(accounts map[string]int64) error {
 	// Increment the chain-sequence-number for multiple accounts.
 	// Use this after modifying accounts' blockchain-derived state (UTXOs, TXNs)
@@ -0,0 +1,120 @@
+// Package testutil has test fixtures shared by the tests in several packages.
+package testutil
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+
+	giga "github.com/dogecoinfoundation/gigawallet/pkg"
+	"github.com/dogecoinfoundation/gigawallet/pkg/doge"
+	"github.com/dogecoinfoundation/gigawallet/pkg/dogecoin"
+	dbstore "github.com/dogecoinfoundation/gigawallet/pkg/store"
+	"github.com/shopspring/decimal"
+)
+
+// NewTestAPI creates an API with an in-memory store and a mock L1 (with
+// libdogecoin for keys and transactions)
+func NewTestAPI(t testing.TB, config giga.Config) (giga.Store, giga.L1, giga.MessageBus, giga.API) {
+	store, err := dbstore.NewSQLiteStore(":memory:")
+	if err != nil {
+		t.Fatalf("Cannot create in-memory database: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	mock, err := dogecoin.NewL1Mock(config)
+	if err != nil {
+		t.Fatalf("Cannot init L1 mock: %v", err)
+	}
+	l1, err := dogecoin.NewL1Libdogecoin(config, mock)
+	if err != nil {
+		t.Fatalf("Cannot init libdogecoin: %v", err)
+	}
+	bus := giga.NewMessageBus()
+	api := giga.NewAPI(store, l1, bus, &giga.MockFollower{}, config)
+	return store, l1, bus, api
+}
+
+// FundAccount adds 10 confirmed P2PKH UTXOs of 10 DOGE each to an account,
+// returns the updated account.
+func FundAccount(t testing.TB, store giga.Store, l1 giga.L1, foreignID string) giga.Account {
+	tx, err := store.Begin()
+	if err != nil {
+		t.Fatalf("store.Begin: %v", err)
+	}
+	defer tx.Rollback()
+	acc, err := tx.GetAccount(foreignID)
+	if err != nil {
+		t.Fatalf("tx.GetAccount: %v", err)
+	}
+	// A distinct txid per account, so the UTXOs don't collide.
+	txid := sha256.Sum256([]byte(foreignID))
+	for vout := 0; vout < 10; vout++ {
+		payTo, keyIndex, err := acc.NextPayToAddress(l1)
+		if err != nil {
+			t.Fatalf("NextPayToAddress: %v", err)
+		}
+		err = tx.CreateUTXO(giga.UTXO{
+			TxID:          hex.EncodeToString(txid[:]),
+			VOut:          vout,
+			Value:         decimal.NewFromInt(10),
+			ScriptHex:     P2PKHScriptHex(t, payTo),
+			ScriptType:    doge.ScriptTypeP2PKH,
+			ScriptAddress: payTo,
+			AccountID:     acc.Address,
+			KeyIndex:      keyIndex,
+			IsInternal:    false,
+			BlockHeight:   100,
+		})
+		if err != nil {
+			t.Fatalf("tx.CreateUTXO: %v", err)
+		}
+	}
+	err = tx.UpdateAccount(acc)
+	if err != nil {
+		t.Fatalf("tx.UpdateAccount: %v", err)
+	}
+	// Currently Gigawallet won't spend UTXOs until they are confirmed.
+	_, err = tx.ConfirmUTXOs(6, 120) // 100 + 6 <= 120
+	if err != nil {
+		t.Fatalf("tx.ConfirmUTXOs: %v", err)
+	}
+	// As the ChainFollower does for accounts with new UTXOs.
+	err = tx.IncChainSeqForAccounts(map[string]int64{string(acc.Address): 1})
+	if err != nil {
+		t.Fatalf("tx.IncChainSeqForAccounts: %v", err)
+	}
+	err = tx.Commit()
+	if err != nil {
+		t.Fatalf("tx.Commit: %v", err)
+	}
+	return acc
+}
+
+// P2PKHScriptHex returns the locking script (hex) that pays to a P2PKH address.
+func P2PKHScriptHex(t testing.TB, addr giga.Address) string {
+	payload, err := doge.Base58DecodeCheck(string(addr))
+	if err != nil {
+		t.Fatalf("Base58DecodeCheck: %v", err)
+	}
+	hash := doge.HexEncode(payload[1:]) // skip "version" byte.
+	if len(hash) != 0x14*2 {
+		t.Fatalf("wrong hash len: %v (need 20 bytes)", len(hash))
+	}
+	return "76a914" + hash + "88ac"
+}
+
+// FailingL1 wraps an L1 so that Send fails while Fail is set, as when the
+// Core Node is not available. Sent counts the transactions sent.
+type FailingL1 struct {
+	giga.L1
+	Fail bool
+	Sent int
+}
+
+func (l *FailingL1) Send(txnHex string) (string, error) {
+	if l.Fail {
+		return "", giga.NewErr(giga.NotAvailable, "sendrawtransaction: Core Node is not available")
+	}
+	l.Sent++
+	return l.L1.Send(txnHex)
+}
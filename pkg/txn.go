@@ -15,21 +15,79 @@ var oneThousand = decimal.NewFromInt(1000)
 // We may need to use addUTXOsUpToAmount during calculateFee (source, inputs, used)
 // and we need to generate the tx hex repeatedly (lib, acc, inputs, outputs, changeAddress)
 type txState struct {
-	lib       L1         // L1 for MakeTransaction
-	account   Account    // Account for private key to sign transactions
-	inputs    []UTXO     // accumulated tx inputs (from addUTXOsUpToAmount)
-	outputs   []NewTxOut // specified tx outputs (from payTo)
-	outputSum CoinAmount // sum specified tx outputs (from payTo)
-	used      UTXOSet    // accumulated tx inputs (as a set)
-	source    UTXOSource // source of available UTXOs (cached on Account)
-	deductFee bool       // payTo has DeductFeePercent specified
+	lib       L1           // L1 for MakeTransaction
+	account   Account      // Account for private key to sign transactions
+	inputs    []UTXO       // accumulated tx inputs (from addUTXOsUpToAmount)
+	outputs   []NewTxOut   // specified tx outputs (from payTo)
+	outputSum CoinAmount   // sum specified tx outputs (from payTo)
+	used      UTXOSet      // accumulated tx inputs (as a set)
+	source    UTXOSource   // source of available UTXOs (cached on Account)
+	selector  CoinSelector // chooses UTXOs from source (Account.CoinSelection)
+	deductFee bool         // payTo has DeductFeePercent specified
+	extraSize int64        // bytes by which the signed transaction exceeded sizeOfTxn
 }
 
-func CreateTxn(payTo []PayTo, fixedFee CoinAmount, maxFee CoinAmount, acc Account, source UTXOSource, lib L1) (newTx NewTxn, change UTXO, inputs []UTXO, txid string, err error) {
+// Size of the transaction with the current outputs: the estimate from
+// sizeOfTxn plus any excess measured from the signed transaction.
+func (s *txState) sizeOf(numIn int, withChange bool) int64 {
+	return sizeOfTxn(numIn, s.outputs, withChange) + s.extraSize
+}
+
+// Default confirmation target (in blocks) for fee estimates.
+const DefaultConfirmTarget = 6
+
+// FeeOptions specify how CreateTxn calculates the transaction fee.
+type FeeOptions struct {
+	FixedFee      CoinAmount // explicit fee (zero: calculate the fee from the size)
+	MaxFee        CoinAmount // maximum fee (zero: TxnRecommendedMaxFee)
+	FeePerByte    CoinAmount // explicit fee rate (zero: use the Core fee estimate)
+	ConfirmTarget int        // confirmation target for the fee estimate (zero: DefaultConfirmTarget)
+}
+
+// Get the fee rate: the explicit FeePerByte or the estimate for ConfirmTarget,
+// but never below the base consensus TxnFeePerByte.
+func (o FeeOptions) feeRate(lib L1) CoinAmount {
+	if o.FeePerByte.IsPositive() {
+		return decimal.Max(o.FeePerByte, TxnFeePerByte)
+	}
+	return estimateFeePerByte(lib, o.ConfirmTarget)
+}
+
+func (o FeeOptions) maxFee() CoinAmount {
+	if o.MaxFee.IsPositive() {
+		return o.MaxFee
+	}
+	return TxnRecommendedMaxFee
+}
+
+// CreateTxn creates and signs a transaction paying to `payTo`, with an optional
+// `memo` (up to doge.MaxNullDataSize bytes) in an OP_RETURN output.
+// If the account uses OfflineSigning, the transaction is not signed and the
+// txid is the hash of the unsigned transaction.
+func CreateTxn(payTo []PayTo, memo []byte, feeOpts FeeOptions, acc Account, source UTXOSource, lib L1) (newTx NewTxn, change UTXO, inputs []UTXO, txid string, err error) {
+	return createTxn(payTo, memo, feeOpts, 0, acc, source, lib)
+}
+
+// CreateLockedTxn is CreateTxn with an nLockTime: a block height, or a unix
+// time at or above LockTimeThreshold. The network will not accept the
+// transaction until that block height or time has passed.
+func CreateLockedTxn(payTo []PayTo, memo []byte, feeOpts FeeOptions, lockTime uint32, acc Account, source UTXOSource, lib L1) (newTx NewTxn, change UTXO, inputs []UTXO, txid string, err error) {
+	if lockTime == 0 {
+		err = NewErr(InvalidTxn, "cannot create a locked transaction with zero lock time")
+		return
+	}
+	return createTxn(payTo, memo, feeOpts, lockTime, acc, source, lib)
+}
+
+func createTxn(payTo []PayTo, memo []byte, feeOpts FeeOptions, lockTime uint32, acc Account, source UTXOSource, lib L1) (newTx NewTxn, change UTXO, inputs []UTXO, txid string, err error) {
 	outputSum, deductFee, err := sumPayTo(payTo)
 	if err != nil {
 		return
 	}
+	selector, err := NewCoinSelector(acc.CoinSelection)
+	if err != nil {
+		return
+	}
 	changeAddress, changeIndex, err := acc.NextChangeAddress(lib)
 	if err != nil {
 		return
@@ -42,58 +100,105 @@ func CreateTxn(payTo []PayTo, fixedFee CoinAmount, maxFee CoinAmount, acc Accoun
 		outputSum: outputSum,
 		used:      NewUTXOSet(),
 		source:    source,
+		selector:  selector,
 		deductFee: deductFee,
 	}
 
-	err = addUTXOsUpToAmount(outputSum, state)
-	if err != nil {
-		return
-	}
 	for _, pay := range payTo {
 		err = addOutput(pay.PayTo, pay.Amount, state)
 		if err != nil {
 			return
 		}
 	}
-
-	var fee CoinAmount
-	if deductFee {
-		fee, err = calculateAndDeductFee(fixedFee, maxFee, payTo, state)
+	if len(memo) > 0 {
+		// after the payTo outputs (calculateAndDeductFee uses payTo indexes)
+		err = addMemoOutput(memo, state)
 		if err != nil {
 			return
 		}
-	} else {
-		fee, err = calculateFee(fixedFee, maxFee, state)
+	}
+
+	// For an OfflineSigning account, the transaction is left unsigned (see UnsignedTxn)
+	privkey := state.account.Privkey
+	if acc.OfflineSigning {
+		privkey = ""
+	}
+
+	// The fee is calculated from the estimated size of the transaction, then
+	// checked against the size of the signed transaction (signatures vary in
+	// size): if the signed transaction is larger, calculate the fee again.
+	outputs := append([]NewTxOut{}, state.outputs...)
+	var txData []byte
+	for attempt := 0; ; attempt++ {
+		if attempt > 10 {
+			err = NewErr(InvalidTxn, "Too many attempts to find a stable fee (the signed transaction is larger than the estimate;) 10 attempts were made.")
+			return
+		}
+		state.outputs = append(state.outputs[:0], outputs...) // undo calculateAndDeductFee
+		var fee CoinAmount
+		if deductFee {
+			fee, err = calculateAndDeductFee(feeOpts, payTo, state)
+		} else {
+			fee, err = calculateFee(feeOpts, state)
+		}
 		if err != nil {
 			return
 		}
-	}
+		// Change below the Dust Limit cannot be an output: pay it to the fee.
+		excess := sumInputs(state.inputs).Sub(sumOutputs(state.outputs)).Sub(fee)
+		if excess.IsPositive() && excess.LessThan(TxnDustLimit) {
+			fee = fee.Add(excess)
+		}
 
-	// Build the transaction with the current inputs and fee.
-	newTx, err = state.lib.MakeTransaction(state.inputs, state.outputs, fee, changeAddress, state.account.Privkey)
-	if err != nil {
-		return
+		// Build the transaction with the current inputs and fee.
+		if lockTime != 0 {
+			// Set the lock time before signing (libdogecoin cannot set it)
+			newTx, err = state.lib.MakeTransaction(state.inputs, state.outputs, fee, changeAddress, "")
+			if err != nil {
+				return
+			}
+			newTx.TxnHex, err = setTxnLockTime(newTx.TxnHex, lockTime)
+			if err != nil {
+				return
+			}
+			if privkey != "" {
+				newTx.TxnHex, err = state.lib.SignTransaction(newTx.TxnHex, state.inputs, privkey)
+				if err != nil {
+					return
+				}
+			}
+		} else {
+			newTx, err = state.lib.MakeTransaction(state.inputs, state.outputs, fee, changeAddress, privkey)
+			if err != nil {
+				return
+			}
+		}
+		txData, err = doge.HexDecode(newTx.TxnHex)
+		if err != nil {
+			return
+		}
+		if privkey == "" || feeOpts.FixedFee.IsPositive() {
+			break // unsigned (cannot measure) or fee specified by the caller.
+		}
+		extra := int64(len(txData)) - sizeOfTxn(len(state.inputs), state.outputs, newTx.ChangeAmount.IsPositive())
+		if extra <= state.extraSize {
+			break // the fee covers the signed size.
+		}
+		state.extraSize = extra
 	}
+	newTx.Size = state.sizeOf(len(state.inputs), newTx.ChangeAmount.IsPositive())
 
-	// Check all outputs are >= TxnDustLimit
-	txData, err := doge.HexDecode(newTx.TxnHex)
-	if err != nil {
-		return
-	}
+	// Check all outputs are >= TxnDustLimit (except the memo)
 	txid = doge.TxHashHex(txData)
 	dTx, err := doge.DecodeTx(txData, txid)
 	if err != nil {
 		return newTx, change, state.inputs, txid, fmt.Errorf("error decoding transaction: %v", err)
 	}
-	chain := doge.ChainFromWIFString(string(acc.Address))
+	chain := acc.Chain()
 	for n, out := range dTx.VOut {
 		// This should be caught before now, e.g. in subtractFeeFromOutput.
 		stype, addr := doge.ClassifyScript(out.Script, chain)
 		if stype == doge.ScriptTypeP2PKH && addr == changeAddress {
-			if out.Value < TxnDustLimit_64 {
-				err = NewErr(InvalidTxn, "BUG: Tx Change Output cannot be less than the Dogecoin Dust Limit (%vƉ): tx output %v is %v koinu", TxnDustLimit.String(), n, doge.KoinuToDecimal(out.Value).String())
-				return
-			}
 			change = UTXO{
 				TxID:          txid,
 				VOut:          n,
@@ -105,6 +210,9 @@ func CreateTxn(payTo []PayTo, fixedFee CoinAmount, maxFee CoinAmount, acc Accoun
 				KeyIndex:      changeIndex,
 				IsInternal:    true,
 			}
+		} else if stype == doge.ScriptTypeNullData {
+			// Decode the memo back out of the transaction.
+			newTx.Memo, _ = doge.ExtractNullData(out.Script)
 		} else if out.Value < TxnDustLimit_64 {
 			err = NewErr(InvalidTxn, "BUG: Tx Output cannot be less than the Dogecoin Dust Limit (%vƉ): tx output %v is %v koinu", TxnDustLimit.String(), n, doge.KoinuToDecimal(out.Value).String())
 			return
@@ -114,6 +222,111 @@ func CreateTxn(payTo []PayTo, fixedFee CoinAmount, maxFee CoinAmount, acc Accoun
 	return newTx, change, state.inputs, txid, nil
 }
 
+// Set the nLockTime of an unsigned transaction. The lock time is only enforced
+// if an input has a sequence number below 0xffffffff (final), so the sequence
+// of every input is set to 0xfffffffe (which does not enable replacement)
+func setTxnLockTime(txHex string, lockTime uint32) (string, error) {
+	txBytes, err := doge.HexDecode(txHex)
+	if err != nil {
+		return "", NewErr(InvalidTxn, "cannot decode transaction: %v", err)
+	}
+	tx, err := doge.DecodeTx(txBytes, "")
+	if err != nil {
+		return "", NewErr(InvalidTxn, "cannot decode transaction: %v", err)
+	}
+	for n := range tx.VIn {
+		tx.VIn[n].Sequence = 0xfffffffe
+	}
+	tx.LockTime = lockTime
+	return doge.HexEncode(doge.EncodeTx(tx)), nil
+}
+
+// CreateConsolidationTxn creates a transaction that merges all of `utxos` into
+// a single output paid to the account's next change address, with the fee
+// deducted from that output. Modifies `NextInternalKey` so the caller should
+// commit the account changes (see ReservePayment)
+func CreateConsolidationTxn(utxos []UTXO, maxFee CoinAmount, acc *Account, lib L1) (newTx NewTxn, payTo []PayTo, consolidated UTXO, err error) {
+	address, keyIndex, err := acc.NextChangeAddress(lib)
+	if err != nil {
+		return
+	}
+	payTo = []PayTo{{Amount: sumInputs(utxos), PayTo: address, DeductFeePercent: oneHundred}}
+	// Spend every UTXO given, in order (ignore the account's CoinSelection)
+	spender := *acc
+	spender.CoinSelection = CoinSelectDefault
+	newTx, _, _, txid, err := CreateTxn(payTo, nil, FeeOptions{MaxFee: maxFee}, spender, NewArrayUTXOSource(utxos), lib)
+	if err != nil {
+		return
+	}
+	txData, err := doge.HexDecode(newTx.TxnHex)
+	if err != nil {
+		return
+	}
+	dTx, err := doge.DecodeTx(txData, txid)
+	if err != nil {
+		return newTx, payTo, consolidated, fmt.Errorf("error decoding transaction: %v", err)
+	}
+	chain := acc.Chain()
+	for n, out := range dTx.VOut {
+		stype, addr := doge.ClassifyScript(out.Script, chain)
+		if stype == doge.ScriptTypeP2PKH && addr == address {
+			consolidated = UTXO{
+				TxID:          txid,
+				VOut:          n,
+				Value:         doge.KoinuToDecimal(out.Value),
+				ScriptHex:     hex.EncodeToString(out.Script),
+				ScriptType:    stype,
+				ScriptAddress: addr,
+				AccountID:     acc.Address,
+				KeyIndex:      keyIndex,
+				IsInternal:    true,
+			}
+			payTo[0].Amount = consolidated.Value // after deducting the fee.
+			return newTx, payTo, consolidated, nil
+		}
+	}
+	return newTx, payTo, consolidated, NewErr(InvalidTxn, "BUG: consolidation Tx has no output to %v", address)
+}
+
+// CreateSweepTxn creates a transaction that spends all of `utxos`, paid to the
+// single EC key `ecPrivKeyWIF` (e.g. a paper wallet), to `payTo` with the fee
+// deducted from that output, and signs it with that key.
+func CreateSweepTxn(utxos []UTXO, ecPrivKeyWIF string, payTo Address, feeOpts FeeOptions, lib L1) (newTx NewTxn, txid string, err error) {
+	if len(utxos) < 1 {
+		return newTx, "", NewErr(InsufficientFunds, "no unspent outputs to sweep")
+	}
+	total := sumInputs(utxos)
+	outputs := []NewTxOut{{ScriptType: doge.ScriptTypeP2PKH, Amount: total, ScriptAddress: payTo}}
+	feePerByte := feeOpts.feeRate(lib)
+	extraSize := int64(0) // bytes by which the signed transaction exceeded sizeOfTxn
+	for attempt := 0; attempt <= 10; attempt++ {
+		fee := feeForTxn(sizeOfTxn(len(utxos), outputs, false)+extraSize, feePerByte, feeOpts.FixedFee, feeOpts.maxFee())
+		outputs[0].Amount = total.Sub(fee)
+		if outputs[0].Amount.LessThan(TxnDustLimit) {
+			return newTx, "", NewErr(InsufficientFunds, "not enough funds to sweep: %vƉ minus fee %vƉ is less than the Dogecoin Dust Limit (%vƉ)", total, fee, TxnDustLimit)
+		}
+		newTx, err = lib.MakeTransaction(utxos, outputs, fee, payTo, "")
+		if err != nil {
+			return
+		}
+		newTx.TxnHex, err = lib.SignTransactionWithKey(newTx.TxnHex, utxos, ecPrivKeyWIF)
+		if err != nil {
+			return
+		}
+		txData, err := doge.HexDecode(newTx.TxnHex)
+		if err != nil {
+			return newTx, "", err
+		}
+		// Calculate the fee again if the signed transaction is larger than estimated.
+		extra := int64(len(txData)) - sizeOfTxn(len(utxos), outputs, false)
+		if extra <= extraSize || feeOpts.FixedFee.IsPositive() {
+			return newTx, doge.TxHashHex(txData), nil
+		}
+		extraSize = extra
+	}
+	return newTx, "", NewErr(InvalidTxn, "Too many attempts to find a stable fee (the signed transaction is larger than the estimate;) 10 attempts were made.")
+}
+
 func sumPayTo(payTo []PayTo) (CoinAmount, bool, error) {
 	total := decimal.Zero
 	deduct := decimal.Zero
@@ -140,22 +353,39 @@ func sumPayTo(payTo []PayTo) (CoinAmount, bool, error) {
 }
 
 func addUTXOsUpToAmount(amount CoinAmount, state *txState) error {
+	return addUTXOsUpToTarget(func(numInputs int) CoinAmount { return amount }, state)
+}
+
+// Use the account's CoinSelector to add inputs until the inputs cover
+// target(number of inputs), i.e. the target can include a fee per input.
+func addUTXOsUpToTarget(target SelectionTarget, state *txState) error {
 	current := sumInputs(state.inputs)
-	for current.LessThan(amount) {
-		utxo, err := state.source.NextUnspentUTXO(state.used)
-		if err == nil {
-			// add input
-			state.inputs = append(state.inputs, utxo)
-			state.used.Add(utxo.TxID, utxo.VOut)
-			// update current total
-			current = current.Add(utxo.Value)
-		} else {
-			return err
-		}
+	numInputs := len(state.inputs)
+	if current.GreaterThanOrEqual(target(numInputs)) {
+		return nil
+	}
+	utxos, err := state.selector.SelectUTXOs(state.source, state.used, func(numAdded int) CoinAmount {
+		// the selector only needs to cover the remainder.
+		return target(numInputs + numAdded).Sub(current)
+	})
+	if err != nil {
+		return err
+	}
+	for _, utxo := range utxos {
+		state.inputs = append(state.inputs, utxo)
+		state.used.Add(utxo.TxID, utxo.VOut)
 	}
 	return nil
 }
 
+func sumOutputs(outputs []NewTxOut) CoinAmount {
+	total := ZeroCoins
+	for _, out := range outputs {
+		total = total.Add(out.Amount)
+	}
+	return total
+}
+
 func sumInputs(inputs []UTXO) CoinAmount {
 	total := ZeroCoins
 	for _, utxo := range inputs {
@@ -171,14 +401,45 @@ func addOutput(payTo Address, amount CoinAmount, state *txState) error {
 	if amount.LessThanOrEqual(ZeroCoins) {
 		return NewErr(InvalidTxn, "Invalid transaction output: the 'amount' is negative or zero.")
 	}
+	scriptType, err := scriptTypeForAddress(payTo, state.account)
+	if err != nil {
+		return err
+	}
 	state.outputs = append(state.outputs, NewTxOut{
-		ScriptType:    doge.ScriptTypeP2PKH,
+		ScriptType:    scriptType,
 		Amount:        amount,
 		ScriptAddress: payTo,
 	})
 	return nil
 }
 
+// Add an OP_RETURN output carrying `memo` (with zero value)
+func addMemoOutput(memo []byte, state *txState) error {
+	if len(memo) > doge.MaxNullDataSize {
+		return NewErr(InvalidTxn, "Invalid transaction output: the memo is %v bytes (maximum is %v bytes)", len(memo), doge.MaxNullDataSize)
+	}
+	state.outputs = append(state.outputs, NewTxOut{
+		ScriptType: doge.ScriptTypeNullData,
+		Amount:     ZeroCoins,
+		Data:       memo,
+	})
+	return nil
+}
+
+// Detect the kind of output script required to pay to an address,
+// from the address version byte: P2PKH ('D' on mainnet) or P2SH
+// ('9' or 'A' on mainnet, including multisig deposit addresses.)
+func scriptTypeForAddress(payTo Address, acc Account) (ScriptType, error) {
+	chain := acc.Chain()
+	if doge.ValidateP2PKH(payTo, chain) {
+		return doge.ScriptTypeP2PKH, nil
+	}
+	if doge.ValidateP2SH(payTo, chain) {
+		return doge.ScriptTypeP2SH, nil
+	}
+	return "", NewErr(InvalidTxn, "Invalid transaction output: '%v' is not a valid P2PKH or P2SH address on %v", payTo, chain.ChainName)
+}
+
 // Adjust an output's amount by deducting a percentage of the fee.
 // This uses an array of originalAmounts captured by addOutput.
 func subtractFeeFromOutput(output int, fee decimal.Decimal, feePercent decimal.Decimal, state *txState) (CoinAmount, error) {
@@ -201,17 +462,41 @@ func subtractFeeFromOutput(output int, fee decimal.Decimal, feePercent decimal.D
 	return feeAmount, nil
 }
 
-// Calculate the size of a P2PKH transaction.
-func sizeOfP2PKH(nIn int, nOut int) int64 {
+// Calculate the size of a transaction spending P2PKH inputs
+// to the given outputs (including any memo), plus a P2PKH Change output if withChange.
+func sizeOfTxn(nIn int, outputs []NewTxOut, withChange bool) int64 {
 	// inspired by https://bitcoinops.org/en/tools/calc-size/
 	// max size for up to 252 inputs and outputs
-	return 10 + int64(nIn)*148 + int64(nOut)*34
+	size := 10 + int64(nIn)*148
+	for _, out := range outputs {
+		size += sizeOfOutput(out)
+	}
+	if withChange {
+		size += sizeOfOutput(NewTxOut{ScriptType: doge.ScriptTypeP2PKH})
+	}
+	return size
+}
+
+// Calculate the size of a transaction output:
+// value (8) + script length (1) + locking script.
+func sizeOfOutput(out NewTxOut) int64 {
+	switch out.ScriptType {
+	case doge.ScriptTypeP2SH:
+		return 8 + 1 + 23 // OP_HASH160 <20> OP_EQUAL
+	case doge.ScriptTypeNullData:
+		return 8 + 1 + int64(len(doge.NullDataScript(out.Data))) // OP_RETURN <data>
+	default:
+		return 8 + 1 + 25 // OP_DUP OP_HASH160 <20> OP_EQUALVERIFY OP_CHECKSIG
+	}
 }
 
 // Get fee estimate from Core `estimatesmartfee` if available,
 // otherwise use the base consensus TxnFeePerByte.
-func estimateFeePerByte(lib L1) CoinAmount {
-	feePerKB, err := lib.EstimateFee(6)
+func estimateFeePerByte(lib L1, confirmTarget int) CoinAmount {
+	if confirmTarget < 1 {
+		confirmTarget = DefaultConfirmTarget
+	}
+	feePerKB, err := lib.EstimateFee(confirmTarget)
 	if err != nil {
 		log.Printf("feeForP2PKH: did not use estimatesmartfee due to error: %s", err.Error())
 	} else {
@@ -234,40 +519,71 @@ func feeForTxn(sizeBytes int64, feePerByte CoinAmount, fixedFee CoinAmount, maxF
 // Calculate the Fee based on the size of the transaction.
 // Make sure the UTXO Inputs cover that fee as well as all Outputs:
 // add new UTXOs to cover the fee if necessary (and loop.)
-func calculateFee(fixedFee CoinAmount, maxFee CoinAmount, state *txState) (CoinAmount, error) {
-	attempt := 0
-	feePerByte := estimateFeePerByte(state.lib)
-	for {
-		// Calculate the fee required for the transaction size.
-		sizeBytes := sizeOfP2PKH(len(state.inputs), len(state.outputs)+1) // +1 for Change output
-		fee := feeForTxn(sizeBytes, feePerByte, fixedFee, maxFee)
-		// Calculate the input total required to cover that fee.
-		newTotal := state.outputSum.Add(fee)
-		// Add new transaction inputs if necessary to cover the fee.
-		prevInputs := len(state.inputs)
-		err := addUTXOsUpToAmount(newTotal, state)
+// If the excess input value is too small for a change output, it is
+// added to the fee instead, so the transaction has no change output.
+func calculateFee(feeOpts FeeOptions, state *txState) (CoinAmount, error) {
+	fixedFee, maxFee := feeOpts.FixedFee, feeOpts.maxFee()
+	feePerByte := feeOpts.feeRate(state.lib)
+	feeFor := func(numIn int, withChange bool) CoinAmount {
+		return feeForTxn(state.sizeOf(numIn, withChange), feePerByte, fixedFee, maxFee)
+	}
+	// First try to cover the Outputs and the fee without a Change output.
+	target := func(numIn int) CoinAmount {
+		return state.outputSum.Add(feeFor(numIn, false))
+	}
+	for attempt := 0; attempt <= 10; attempt++ {
+		// Add transaction inputs if necessary to cover the target.
+		err := addUTXOsUpToTarget(target, state)
 		if err != nil {
 			return ZeroCoins, err
 		}
-		// If we added an input, it changes the size of the transaction.
-		if len(state.inputs) > prevInputs {
-			attempt += 1
-			if attempt > 10 {
-				return ZeroCoins, NewErr(InvalidTxn, "Too many attempts to find a stable fee (adding inputs to pay for the transaction fee;) 10 attempts were made.")
-			}
-			continue
+		excess := sumInputs(state.inputs).Sub(state.outputSum)
+		// If the change would be below the Dust Limit, pay it to the fee (no Change output)
+		feeNoChange := feeFor(len(state.inputs), false)
+		change := excess.Sub(feeNoChange)
+		if change.IsZero() || (change.IsPositive() && change.LessThan(TxnDustLimit) && canPayExcessToFee(feeNoChange, change, fixedFee, maxFee)) {
+			return excess, nil
+		}
+		// Otherwise the fee must also pay for the Change output.
+		fee := feeFor(len(state.inputs), true)
+		if excess.Sub(fee).GreaterThanOrEqual(TxnDustLimit) {
+			// Done: current set of inputs covers the current fee.
+			return fee, nil
+		}
+		// Add inputs to cover the fee including a Change output at or above the Dust Limit.
+		target = func(numIn int) CoinAmount {
+			return state.outputSum.Add(feeFor(numIn, true)).Add(TxnDustLimit)
 		}
-		// Done: current set of inputs covers the current fee.
-		return fee, nil
 	}
+	return ZeroCoins, NewErr(InvalidTxn, "Too many attempts to find a stable fee (adding inputs to pay for the transaction fee;) 10 attempts were made.")
 }
 
 // Calculate the Fee based on the transaction size, then
 // subtract the fee from the outputs acccording to DeductFeePercent
-func calculateAndDeductFee(fixedFee CoinAmount, maxFee CoinAmount, payTo []PayTo, state *txState) (CoinAmount, error) {
+func calculateAndDeductFee(feeOpts FeeOptions, payTo []PayTo, state *txState) (CoinAmount, error) {
+	fixedFee, maxFee := feeOpts.FixedFee, feeOpts.maxFee()
+	// Add transaction inputs to cover the outputs (the fee is deducted from the outputs)
+	err := addUTXOsUpToAmount(state.outputSum, state)
+	if err != nil {
+		return ZeroCoins, err
+	}
+	feePerByte := feeOpts.feeRate(state.lib)
+	excess := sumInputs(state.inputs).Sub(state.outputSum)
+	if excess.IsPositive() && excess.LessThan(TxnDustLimit) {
+		// The excess is too small for a Change output: pay it to the fee if allowed,
+		// otherwise add inputs for a Change output at or above the Dust Limit.
+		feeNoChange := feeForTxn(state.sizeOf(len(state.inputs), false), feePerByte, fixedFee, maxFee)
+		if !canPayExcessToFee(feeNoChange, excess, fixedFee, maxFee) {
+			err = addUTXOsUpToAmount(state.outputSum.Add(TxnDustLimit), state)
+			if err != nil {
+				return ZeroCoins, err
+			}
+			excess = sumInputs(state.inputs).Sub(state.outputSum)
+		}
+	}
 	// Calculate the fee required for the transaction size.
-	feePerByte := estimateFeePerByte(state.lib)
-	sizeBytes := sizeOfP2PKH(len(state.inputs), len(state.outputs)+1) // +1 for Change output
+	hasChange := excess.GreaterThanOrEqual(TxnDustLimit)
+	sizeBytes := state.sizeOf(len(state.inputs), hasChange)
 	fee := feeForTxn(sizeBytes, feePerByte, fixedFee, maxFee)
 	// Deduct the fee from all outputs as per DeductFeePercent (update state.outputs)
 	deductedFee := ZeroCoins
@@ -278,5 +594,16 @@ func calculateAndDeductFee(fixedFee CoinAmount, maxFee CoinAmount, payTo []PayTo
 		}
 		deductedFee = deductedFee.Add(amt)
 	}
+	if !hasChange {
+		// No Change output: the excess is paid to the fee.
+		deductedFee = deductedFee.Add(excess)
+	}
 	return deductedFee, nil
 }
+
+// Excess input value below the Dust Limit cannot be returned as change,
+// so it can be paid to the fee instead, unless the caller specified the
+// fee or the fee would exceed maxFee.
+func canPayExcessToFee(fee CoinAmount, excess CoinAmount, fixedFee CoinAmount, maxFee CoinAmount) bool {
+	return !fixedFee.IsPositive() && fee.Add(excess).LessThanOrEqual(maxFee)
+}
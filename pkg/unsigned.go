@@ -0,0 +1,138 @@
+package giga
+
+import (
+	"bytes"
+
+	"github.com/dogecoinfoundation/gigawallet/pkg/doge"
+)
+
+// UnsignedTxn is a portable (JSON) unsigned transaction for offline signing.
+// It carries the HD Wallet key path (KeyIndex, IsInternal) and the locking
+// script of each input, so it can be signed with the account's master key
+// on an offline machine (see `gigawallet sign`) then submitted to
+// API.SubmitSignedTxn, which verifies it against the reserved Payment.
+type UnsignedTxn struct {
+	PaymentID int64          `json:"payment_id"`       // reserved Payment waiting for the signed transaction
+	AccountID Address        `json:"account_id"`       // Account (HD Wallet) that owns the inputs
+	TxnHex    string         `json:"tx"`               // unsigned transaction, hex-encoded
+	Inputs    []UnsignedUTXO `json:"inputs"`           // inputs to sign, in transaction order
+	Change    *UnsignedUTXO  `json:"change,omitempty"` // change output paid back to the account (if any)
+}
+
+// UnsignedUTXO is an input to sign (or the change output) with the HD Wallet
+// key path of its address.
+type UnsignedUTXO struct {
+	TxID       string     `json:"txid,omitempty"` // previous transaction (inputs only)
+	VOut       int        `json:"vout"`           // output number in the previous (or new) transaction
+	Value      CoinAmount `json:"value"`          // amount of the output
+	ScriptHex  string     `json:"script"`         // locking script, hex-encoded
+	Address    Address    `json:"address"`        // P2PKH address of the key
+	KeyIndex   uint32     `json:"key_index"`      // HD Wallet key-index of the address
+	IsInternal bool       `json:"is_internal"`    // HD Wallet internal/external address flag
+}
+
+// NewUnsignedTxn makes the UnsignedTxn for a transaction from CreateTxn,
+// with its spent `inputs` and `change` UTXO (zero Value if none)
+func NewUnsignedTxn(paymentID int64, account Address, txHex string, inputs []UTXO, change UTXO) UnsignedTxn {
+	u := UnsignedTxn{PaymentID: paymentID, AccountID: account, TxnHex: txHex}
+	for _, utxo := range inputs {
+		u.Inputs = append(u.Inputs, UnsignedUTXO{
+			TxID:       utxo.TxID,
+			VOut:       utxo.VOut,
+			Value:      utxo.Value,
+			ScriptHex:  utxo.ScriptHex,
+			Address:    utxo.ScriptAddress,
+			KeyIndex:   utxo.KeyIndex,
+			IsInternal: utxo.IsInternal,
+		})
+	}
+	if !change.Value.IsZero() {
+		u.Change = &UnsignedUTXO{
+			VOut:       change.VOut,
+			Value:      change.Value,
+			ScriptHex:  change.ScriptHex,
+			Address:    change.ScriptAddress,
+			KeyIndex:   change.KeyIndex,
+			IsInternal: change.IsInternal,
+		}
+	}
+	return u
+}
+
+// InputUTXOs returns the inputs to sign (see L1.SignTransaction)
+func (u UnsignedTxn) InputUTXOs() []UTXO {
+	utxos := make([]UTXO, 0, len(u.Inputs))
+	for _, in := range u.Inputs {
+		utxos = append(utxos, UTXO{
+			TxID:          in.TxID,
+			VOut:          in.VOut,
+			Value:         in.Value,
+			ScriptHex:     in.ScriptHex,
+			ScriptType:    doge.ScriptTypeP2PKH,
+			ScriptAddress: in.Address,
+			AccountID:     u.AccountID,
+			KeyIndex:      in.KeyIndex,
+			IsInternal:    in.IsInternal,
+		})
+	}
+	return utxos
+}
+
+// ChangeUTXO returns the change UTXO created by the signed transaction `txid`
+func (u UnsignedTxn) ChangeUTXO(txid string) (UTXO, bool) {
+	if u.Change == nil {
+		return UTXO{}, false
+	}
+	return UTXO{
+		TxID:          txid,
+		VOut:          u.Change.VOut,
+		Value:         u.Change.Value,
+		ScriptHex:     u.Change.ScriptHex,
+		ScriptType:    doge.ScriptTypeP2PKH,
+		ScriptAddress: u.Change.Address,
+		AccountID:     u.AccountID,
+		KeyIndex:      u.Change.KeyIndex,
+		IsInternal:    u.Change.IsInternal,
+	}, true
+}
+
+// VerifySigned checks that `signedHex` is the unsigned transaction with
+// every input signed, i.e. it spends the same inputs and pays the same
+// outputs. Returns the txid of the signed transaction.
+// Signatures are verified by Core when the transaction is submitted.
+func (u UnsignedTxn) VerifySigned(signedHex string) (txid string, err error) {
+	unsignedBytes, err := doge.HexDecode(u.TxnHex)
+	if err != nil {
+		return "", NewErr(InvalidTxn, "cannot decode unsigned transaction: %v", err)
+	}
+	unsigned, err := doge.DecodeTx(unsignedBytes, "")
+	if err != nil {
+		return "", NewErr(InvalidTxn, "cannot decode unsigned transaction: %v", err)
+	}
+	signedBytes, err := doge.HexDecode(signedHex)
+	if err != nil {
+		return "", NewErr(BadRequest, "cannot decode signed transaction hex: %v", err)
+	}
+	txid = doge.TxHashHex(signedBytes)
+	signed, err := doge.DecodeTx(signedBytes, txid)
+	if err != nil {
+		return "", NewErr(BadRequest, "cannot decode signed transaction: %v", err)
+	}
+	if len(signed.VIn) != len(u.Inputs) {
+		return "", NewErr(InvalidTxn, "signed transaction has %v inputs, expecting %v", len(signed.VIn), len(u.Inputs))
+	}
+	for n := range signed.VIn {
+		if len(signed.VIn[n].Script) == 0 {
+			return "", NewErr(InvalidTxn, "signed transaction input %v is not signed", n)
+		}
+		// compare the transactions without input scripts (signatures)
+		signed.VIn[n].Script = nil
+	}
+	for n := range unsigned.VIn {
+		unsigned.VIn[n].Script = nil
+	}
+	if !bytes.Equal(doge.EncodeTx(signed), doge.EncodeTx(unsigned)) {
+		return "", NewErr(InvalidTxn, "signed transaction does not match the unsigned transaction for payment %v", u.PaymentID)
+	}
+	return txid, nil
+}
@@ -12,7 +12,7 @@ type UTXO struct {
 	AccountID     Address    // Account ID (by searching for ScriptAddress using FindAccountForAddress)
 	KeyIndex      uint32     // Account HD Wallet key-index of the ScriptAddress (needed to spend)
 	IsInternal    bool       // Account HD Wallet internal/external address flag for ScriptAddress (needed to spend)
-	BlockHeight   int64      // Block Height of the Block that contains this UTXO (NB. used only when inserting!)
+	BlockHeight   int64      // Block Height of the Block that contains this UTXO (NB. spendable height, or zero, in GetAllUnreservedUTXOs)
 	SpendTxID     string     // TxID of the spending transaction
 	PaymentID     int64      // ID of payment in `payment` table (if spent by us)
 }
@@ -25,4 +25,5 @@ type UTXOSet interface {
 type UTXOSource interface {
 	NextUnspentUTXO(taken UTXOSet) (UTXO, error)
 	FindUTXOLargerThan(amount CoinAmount, taken UTXOSet) (UTXO, error)
+	AllUnspentUTXOs(taken UTXOSet) ([]UTXO, error)
 }
@@ -101,3 +101,22 @@ func (s *StoreUTXOSource) FindUTXOLargerThan(amount CoinAmount, taken UTXOSet) (
 		return UTXO{}, NewErr(InsufficientFunds, "not enough funds in account")
 	}
 }
+
+func (s *StoreUTXOSource) AllUnspentUTXOs(taken UTXOSet) ([]UTXO, error) {
+	if !s.noMore {
+		err := s.fetchMoreUTXOs()
+		if err != nil {
+			return nil, err // error fetching UTXOs.
+		}
+	}
+	result := []UTXO{}
+	for _, utxo := range s.unspent {
+		if utxo.ScriptType == doge.ScriptTypeP2PKH {
+			// Exclude UTXOs that have already been taken from the source.
+			if !taken.Includes(utxo.TxID, utxo.VOut) {
+				result = append(result, utxo)
+			}
+		}
+	}
+	return result, nil
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
 
@@ -16,6 +17,7 @@ var httpCodeForError = map[string]int{
 	string(giga.NotFound):      404,
 	string(giga.AlreadyExists): 500,
 	string(giga.Unauthorized):  401,
+	string(giga.Conflict):      409,
 	string(giga.UnknownError):  500,
 }
 
@@ -27,6 +29,24 @@ func HttpStatusForError(code giga.ErrorCode) int {
 	return status
 }
 
+// readIdempotentRequest reads the request body and the optional Idempotency-Key
+// header. The key's RequestHash covers the method, path and body, so that
+// re-using a key for a different request can be detected.
+func readIdempotentRequest(r *http.Request) ([]byte, giga.IdempotencyKey, error) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, giga.IdempotencyKey{}, fmt.Errorf("cannot read request body: %v", err)
+	}
+	key := r.Header.Get("Idempotency-Key")
+	if key == "" {
+		return body, giga.IdempotencyKey{}, nil
+	}
+	if len(key) > giga.MaxIdempotencyKeyLength {
+		return nil, giga.IdempotencyKey{}, fmt.Errorf("Idempotency-Key is too long (maximum %d characters)", giga.MaxIdempotencyKeyLength)
+	}
+	return body, giga.NewIdempotencyKey(key, []byte(r.Method), []byte(r.URL.Path), body), nil
+}
+
 func sendResponse(w http.ResponseWriter, payload any) {
 	// note: w.Header after this, so we can call sendError
 	b, err := json.Marshal(payload)
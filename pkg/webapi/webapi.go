@@ -1,7 +1,9 @@
 package webapi
 
 import (
+	"bytes"
 	"context"
+	"crypto/subtle"
 	"encoding/json"
 	"fmt"
 	"log"
@@ -9,6 +11,7 @@ import (
 	"net/url"
 	"strconv"
 	"strings"
+	"time"
 
 	giga "github.com/dogecoinfoundation/gigawallet/pkg"
 	"github.com/dogecoinfoundation/gigawallet/pkg/conductor"
@@ -17,15 +20,85 @@ import (
 
 // WebAPI implements conductor.Service
 type WebAPI struct {
-	api    giga.API
-	config giga.Config
+	api      giga.API   // the primary network (config.Gigawallet.Network)
+	networks []giga.API // all networks, primary first (see GigawalletConfig.Networks)
+	config   giga.Config
 }
 
 // interface guard ensures WebAPI implements conductor.Service
 var _ conductor.Service = WebAPI{}
 
-func NewWebAPI(config giga.Config, api giga.API) (WebAPI, error) {
-	return WebAPI{api: api, config: config}, nil
+// NewWebAPI serves the API for the primary network, and the APIs for
+// any other networks running side by side; account requests are routed
+// to the network the account is bound to.
+func NewWebAPI(config giga.Config, api giga.API, others ...giga.API) (WebAPI, error) {
+	return WebAPI{api: api, networks: append([]giga.API{api}, others...), config: config}, nil
+}
+
+// network returns the API for a network by name, or the primary network
+// if the name is empty.
+func (t WebAPI) network(name string) (giga.API, error) {
+	if name == "" {
+		return t.api, nil
+	}
+	for _, api := range t.networks {
+		if api.Network() == name {
+			return api, nil
+		}
+	}
+	return giga.API{}, giga.NewErr(giga.BadRequest, "unknown network: %s", name)
+}
+
+// findAccount returns the API for the network the account is bound to;
+// found is false if the account does not exist (returns the primary network)
+// It returns an error if any network cannot be checked, because the account
+// might exist on that network.
+func (t WebAPI) findAccount(foreignID string) (api giga.API, found bool, err error) {
+	if len(t.networks) < 2 {
+		return t.api, true, nil
+	}
+	for _, api := range t.networks {
+		_, err := api.Store.GetAccount(foreignID)
+		if err == nil {
+			return api, true, nil
+		}
+		if !giga.IsNotFoundError(err) {
+			return t.api, false, err
+		}
+	}
+	return t.api, false, nil
+}
+
+// forAccount returns the API for the network the account is bound to.
+// If the networks cannot be checked, the request goes to the primary
+// network, which does not create accounts (see upsertAccount)
+func (t WebAPI) forAccount(foreignID string) giga.API {
+	api, _, _ := t.findAccount(foreignID)
+	return api
+}
+
+// forInvoice returns the API for the network the invoice was created on.
+func (t WebAPI) forInvoice(id giga.Address) giga.API {
+	if len(t.networks) < 2 {
+		return t.api
+	}
+	for _, api := range t.networks {
+		if _, err := api.Store.GetInvoice(id); err == nil {
+			return api
+		}
+	}
+	return t.api
+}
+
+// forRequest returns the API for the "network" query parameter, or the
+// primary network if there is none.
+func (t WebAPI) forRequest(w http.ResponseWriter, r *http.Request) (giga.API, bool) {
+	api, err := t.network(r.URL.Query().Get("network"))
+	if err != nil {
+		sendError(w, "network", err)
+		return api, false
+	}
+	return api, true
 }
 
 func (t WebAPI) Run(started, stopped chan bool, stop chan context.Context) error {
@@ -88,15 +161,68 @@ func (t WebAPI) authMiddleware(handler httprouter.Handle) httprouter.Handle {
 	}
 }
 
+type approverKey struct{}
+
+// approverMiddleware authenticates a payment approver (see WebAPIConfig.Approvers)
+// and passes the approver identity to the handler in the request context.
+// The admin bearer token cannot be used to approve payments.
+func (t WebAPI) approverMiddleware(handler httprouter.Handle) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		authHeader := r.Header.Get("Authorization")
+		if authHeader == "" {
+			sendErrorResponse(w, http.StatusUnauthorized, giga.Unauthorized, "Authorization header required")
+			return
+		}
+
+		parts := strings.Split(authHeader, " ")
+		if len(parts) != 2 || strings.ToLower(parts[0]) != "bearer" {
+			sendErrorResponse(w, http.StatusUnauthorized, giga.Unauthorized, "Invalid authorization format, expected 'Bearer TOKEN'")
+			return
+		}
+
+		for approver, token := range t.config.WebAPI.Approvers {
+			if token != "" && subtle.ConstantTimeCompare([]byte(parts[1]), []byte(token)) == 1 {
+				ctx := context.WithValue(r.Context(), approverKey{}, approver)
+				handler(w, r.WithContext(ctx), ps)
+				return
+			}
+		}
+		sendErrorResponse(w, http.StatusUnauthorized, giga.Unauthorized, "Invalid approver token")
+	}
+}
+
 func (t WebAPI) createRouters() (adminMux *httprouter.Router, pubMux *httprouter.Router) {
 	adminMux = httprouter.New() // Admin APIs
 	pubMux = httprouter.New()   // Public APIs
 
 	// Admin APIs
+	// (chain admin APIs take an optional ?network= when running several networks)
 
 	adminMux.POST("/admin/setsyncheight/:blockheight", t.authMiddleware(t.setSyncHeight))
 
+	// GET /admin/skippedblocks -> [ SkippedBlock ] blocks that could not be decoded
+	adminMux.GET("/admin/skippedblocks", t.authMiddleware(t.listSkippedBlocks))
+
+	// POST /admin/skippedblocks/retry -> re-process skipped blocks that can now be decoded
+	adminMux.POST("/admin/skippedblocks/retry", t.authMiddleware(t.retrySkippedBlocks))
+
+	// GET /admin/chain -> { ChainStatus } sync status of the ChainFollower
+	adminMux.GET("/admin/chain", t.authMiddleware(t.getChainStatus))
+
+	// POST /admin/chain/pause -> stop following the chain (e.g. for maintenance)
+	adminMux.POST("/admin/chain/pause", t.authMiddleware(t.pauseChain))
+
+	// POST /admin/chain/resume -> resume following the chain
+	adminMux.POST("/admin/chain/resume", t.authMiddleware(t.resumeChain))
+
+	// POST /admin/chain/restart -> restart the ChainFollower (if stuck)
+	adminMux.POST("/admin/chain/restart", t.authMiddleware(t.restartChain))
+
+	// POST /admin/chain/rollback/:blockheight -> roll back and follow the chain from there
+	adminMux.POST("/admin/chain/rollback/:blockheight", t.authMiddleware(t.rollbackChain))
+
 	// POST { account } /account/:foreignID -> { account } upsert account
+	// (optional "network" in the body binds a new account to that network)
 	adminMux.POST("/account/:foreignID", t.authMiddleware(t.upsertAccount))
 
 	// GET /account/:foreignID -> { account } return an account
@@ -121,6 +247,39 @@ func (t WebAPI) createRouters() (adminMux *httprouter.Router, pubMux *httprouter
 	// POST /account/:foreignID/paytx { "pay": [{ "amount":"1.0", "to": "DPeTgZm7LabnmFTJkAPfADkwiKreEMmzio" }] } -> { tx }
 	adminMux.POST("/account/:foreignID/paytx", t.authMiddleware(t.payTransaction))
 
+	// POST /account/:foreignID/submit-signed { "payment_id": 1, "tx": "…hex" } -> { status } send an offline-signed payment
+	adminMux.POST("/account/:foreignID/submit-signed", t.authMiddleware(t.submitSigned))
+
+	// POST /account/:foreignID/payment/:paymentID/approve -> { payment } approve a pending payment (approver token)
+	adminMux.POST("/account/:foreignID/payment/:paymentID/approve", t.approverMiddleware(t.approvePayment))
+
+	// POST /account/:foreignID/payment/:paymentID/reject -> { payment } reject a pending payment (approver token)
+	adminMux.POST("/account/:foreignID/payment/:paymentID/reject", t.approverMiddleware(t.rejectPayment))
+
+	// GET /account/:foreignID/payment/:paymentID/approvals -> [ approval, … ] list approvals for a payment
+	adminMux.GET("/account/:foreignID/payment/:paymentID/approvals", t.authMiddleware(t.listPaymentApprovals))
+
+	// POST /account/:foreignID/quote { "amount": "1.0", "to": "DPeTgZm7LabnmFTJkAPfADkwiKreEMmzio" } -> { quotes } fee quotes without paying
+	adminMux.POST("/account/:foreignID/quote", t.authMiddleware(t.quoteFees))
+
+	// POST /account/:foreignID/sweep { "wif": "…" } -> { txid } sweep an external private key into the account
+	adminMux.POST("/account/:foreignID/sweep", t.authMiddleware(t.sweepPrivateKey))
+
+	// POST /account/:foreignID/withdraw { "amount": "1.0", "to": "DPeTgZm7LabnmFTJkAPfADkwiKreEMmzio" } -> { withdrawal } queue a batched payout
+	adminMux.POST("/account/:foreignID/withdraw", t.authMiddleware(t.queueWithdrawal))
+
+	// GET /account/:foreignID/withdraw/:withdrawalID -> { withdrawal } get status of a queued payout
+	adminMux.GET("/account/:foreignID/withdraw/:withdrawalID", t.authMiddleware(t.getWithdrawal))
+
+	// POST /account/:foreignID/schedule { "amount": "1.0", "to": "DPeT…", "pay_at": "2026-11-01T00:00:00Z" } -> { scheduled } schedule a payout
+	adminMux.POST("/account/:foreignID/schedule", t.authMiddleware(t.schedulePayment))
+
+	// GET /account/:foreignID/schedule/:scheduleID -> { scheduled } get status of a scheduled payout
+	adminMux.GET("/account/:foreignID/schedule/:scheduleID", t.authMiddleware(t.getScheduledPayment))
+
+	// POST /account/:foreignID/schedule/:scheduleID/cancel -> { scheduled } cancel a scheduled payout
+	adminMux.POST("/account/:foreignID/schedule/:scheduleID/cancel", t.authMiddleware(t.cancelScheduledPayment))
+
 	// POST /invoice/:invoiceID/payfrom/:foreignID -> { status } pay invoice from internal account
 	adminMux.POST("/invoice/:invoiceID/payfrom/:foreignID", t.authMiddleware(t.payInvoiceFromInternal))
 
@@ -170,7 +329,11 @@ func (t WebAPI) setSyncHeight(w http.ResponseWriter, r *http.Request, p httprout
 		return
 	}
 
-	err = t.api.SetSyncHeight(n)
+	api, ok := t.forRequest(w, r)
+	if !ok {
+		return
+	}
+	err = api.SetSyncHeight(n)
 	if err != nil {
 		sendError(w, "SetSyncHeight failed", err)
 		return
@@ -178,7 +341,102 @@ func (t WebAPI) setSyncHeight(w http.ResponseWriter, r *http.Request, p httprout
 	sendResponse(w, "Set sync height")
 }
 
+// listSkippedBlocks returns the blocks the ChainFollower skipped because
+// they could not be decoded; any payments in them are missing, so account
+// balances are marked unreliable until they are re-processed.
+func (t WebAPI) listSkippedBlocks(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	api, ok := t.forRequest(w, r)
+	if !ok {
+		return
+	}
+	blocks, err := api.ListSkippedBlocks()
+	if err != nil {
+		sendError(w, "ListSkippedBlocks", err)
+		return
+	}
+	sendResponse(w, blocks)
+}
+
+// retrySkippedBlocks asks the ChainFollower to re-process skipped blocks that
+// can now be decoded (e.g. after an upgrade) This rolls back to the lowest
+// such block and re-scans from there, so the same caution applies as for
+// setSyncHeight.
+func (t WebAPI) retrySkippedBlocks(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	api, ok := t.forRequest(w, r)
+	if !ok {
+		return
+	}
+	api.RetrySkippedBlocks()
+	sendResponse(w, "Retrying skipped blocks")
+}
+
+// getChainStatus returns the sync status of the ChainFollower: the last
+// block processed, Core's best block height, and the current batch.
+func (t WebAPI) getChainStatus(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	api, ok := t.forRequest(w, r)
+	if !ok {
+		return
+	}
+	status, err := api.ChainStatus()
+	if err != nil {
+		sendError(w, "ChainStatus", err)
+		return
+	}
+	sendResponse(w, status)
+}
+
+// pauseChain stops the ChainFollower following the chain until resumed;
+// no new payments are discovered while paused.
+func (t WebAPI) pauseChain(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	api, ok := t.forRequest(w, r)
+	if !ok {
+		return
+	}
+	api.PauseChainFollower()
+	sendResponse(w, "Pausing chain follower")
+}
+
+func (t WebAPI) resumeChain(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	api, ok := t.forRequest(w, r)
+	if !ok {
+		return
+	}
+	api.ResumeChainFollower()
+	sendResponse(w, "Resuming chain follower")
+}
+
+func (t WebAPI) restartChain(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	api, ok := t.forRequest(w, r)
+	if !ok {
+		return
+	}
+	api.RestartChainFollower()
+	sendResponse(w, "Restarting chain follower")
+}
+
+// rollbackChain rolls the chain-state back to a block height below the last
+// block processed, and re-scans the chain from there. The same caution
+// applies as for setSyncHeight.
+func (t WebAPI) rollbackChain(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	n, err := strconv.ParseInt(p.ByName("blockheight"), 10, 64)
+	if err != nil {
+		sendBadRequest(w, "blockheight invalid, must convert to int64")
+		return
+	}
+	api, ok := t.forRequest(w, r)
+	if !ok {
+		return
+	}
+	err = api.RollbackChain(n)
+	if err != nil {
+		sendError(w, "RollbackChain", err)
+		return
+	}
+	sendResponse(w, "Rolling back chain")
+}
+
 // createInvoice returns the ID of the created Invoice (which is the one-time address for this transaction) for the foreignID in the URL and the InvoiceCreateRequest in the body
+// optional "Idempotency-Key" header: a retry with the same key returns the original response.
 func (t WebAPI) createInvoice(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
 	// the foreignID is a 3rd-party ID for the account
 	foreignID := p.ByName("foreignID")
@@ -186,10 +444,15 @@ func (t WebAPI) createInvoice(w http.ResponseWriter, r *http.Request, p httprout
 		sendBadRequest(w, "missing invoice ID in URL")
 		return
 	}
+	body, idem, err := readIdempotentRequest(r)
+	if err != nil {
+		sendBadRequest(w, err.Error())
+		return
+	}
 	o := giga.InvoiceCreateRequest{
 		Confirmations: -1,
 	}
-	err := json.NewDecoder(r.Body).Decode(&o)
+	err = json.NewDecoder(bytes.NewReader(body)).Decode(&o)
 	if err != nil {
 		sendBadRequest(w, fmt.Sprintf("bad request body (expecting JSON): %v", err))
 		return
@@ -198,7 +461,7 @@ func (t WebAPI) createInvoice(w http.ResponseWriter, r *http.Request, p httprout
 		sendBadRequest(w, "missing 'items' in JSON body")
 		return
 	}
-	invoice, err := t.api.CreateInvoice(o, foreignID)
+	invoice, err := t.forAccount(foreignID).CreateInvoice(o, foreignID, idem)
 	if err != nil {
 		sendError(w, "CreateInvoice", err)
 		return
@@ -220,12 +483,13 @@ func (t WebAPI) getAccountInvoice(w http.ResponseWriter, r *http.Request, p http
 		sendBadRequest(w, "missing invoice ID")
 		return
 	}
-	acc, err := t.api.GetAccount(foreignID)
+	api := t.forAccount(foreignID)
+	acc, err := api.GetAccount(foreignID)
 	if err != nil {
 		sendError(w, "GetAccount", err)
 		return
 	}
-	invoice, err := t.api.GetInvoice(giga.Address(id))
+	invoice, err := api.GetInvoice(giga.Address(id))
 	if err != nil {
 		sendErrorResponse(w, 404, giga.NotFound, "no such invoice in this account")
 		return
@@ -244,7 +508,7 @@ func (t WebAPI) getInvoiceConnect(w http.ResponseWriter, r *http.Request, p http
 		sendBadRequest(w, "missing invoice ID")
 		return
 	}
-	invoice, err := t.api.GetInvoice(giga.Address(id))
+	invoice, err := t.forInvoice(giga.Address(id)).GetInvoice(giga.Address(id))
 	if err != nil {
 		sendErrorResponse(w, 404, giga.NotFound, "no such invoice")
 		return
@@ -274,7 +538,7 @@ func (t WebAPI) getInvoiceQR(w http.ResponseWriter, r *http.Request, p httproute
 		sendBadRequest(w, "missing invoice ID")
 		return
 	}
-	invoice, err := t.api.GetInvoice(giga.Address(id))
+	invoice, err := t.forInvoice(giga.Address(id)).GetInvoice(giga.Address(id))
 	if err != nil {
 		sendErrorResponse(w, 404, giga.NotFound, "no such invoice")
 		return
@@ -305,7 +569,7 @@ func (t WebAPI) getInvoice(w http.ResponseWriter, r *http.Request, p httprouter.
 		sendBadRequest(w, "missing invoice ID")
 		return
 	}
-	invoice, err := t.api.GetInvoice(giga.Address(id))
+	invoice, err := t.forInvoice(giga.Address(id)).GetInvoice(giga.Address(id))
 	if err != nil {
 		sendError(w, "GetInvoice", err)
 		return
@@ -346,7 +610,7 @@ func (t WebAPI) listInvoices(w http.ResponseWriter, r *http.Request, p httproute
 			return
 		}
 	}
-	invoices, err := t.api.ListInvoices(foreignID, icursor, ilimit)
+	invoices, err := t.forAccount(foreignID).ListInvoices(foreignID, icursor, ilimit)
 	if err != nil {
 		sendError(w, "ListInvoices", err)
 		return
@@ -365,11 +629,23 @@ type ListInvoicesResponse struct {
 }
 
 type PayToAddressRequest struct {
-	Amount      giga.CoinAmount `json:"amount"`
-	PayTo       giga.Address    `json:"to"`
-	ExplicitFee giga.CoinAmount `json:"explicit_fee"` // optional fee override (missing or zero: calculate the fee)
-	MaxFee      giga.CoinAmount `json:"max_fee"`      // optional maximum fee (missing or zero: maximum is 1 DOGE)
-	Pay         []giga.PayTo    `json:"pay"`          // either Pay, or Amount and PayTo.
+	Amount        giga.CoinAmount `json:"amount"`
+	PayTo         giga.Address    `json:"to"`
+	ExplicitFee   giga.CoinAmount `json:"explicit_fee"`   // optional fee override (missing or zero: calculate the fee)
+	MaxFee        giga.CoinAmount `json:"max_fee"`        // optional maximum fee (missing or zero: maximum is 1 DOGE)
+	Pay           []giga.PayTo    `json:"pay"`            // either Pay, or Amount and PayTo.
+	Memo          string          `json:"memo"`           // optional memo (up to 80 bytes) in an OP_RETURN output
+	FeePerByte    giga.CoinAmount `json:"fee_per_byte"`   // optional fee rate (missing or zero: use the fee estimate)
+	ConfirmTarget int             `json:"confirm_target"` // optional fee estimate target in blocks (missing or zero: 6 blocks)
+}
+
+func (o PayToAddressRequest) feeOptions() giga.FeeOptions {
+	return giga.FeeOptions{
+		FixedFee:      o.ExplicitFee,
+		MaxFee:        o.MaxFee,
+		FeePerByte:    o.FeePerByte,
+		ConfirmTarget: o.ConfirmTarget,
+	}
 }
 
 type PayToAddressResponse = giga.SendFundsResult
@@ -377,6 +653,9 @@ type PayToAddressResponse = giga.SendFundsResult
 // Pays funds from an account managed by gigawallet to any Dogecoin Address.
 // POST /account/:foreignID/pay { "amount": "1.0", "to": "DPeTgZm7LabnmFTJkAPfADkwiKreEMmzio" } -> { status }
 // or { "explicit_fee": "0.2", "pay": [ "amount": "1.0", "to": "DPeT…", "deduct_fee_percent": "100" ] }
+// optional { "memo": "INV-1234" } is embedded in an OP_RETURN output.
+// optional { "confirm_target": 2 } or { "fee_per_byte": "0.00002" } instead of "explicit_fee".
+// optional "Idempotency-Key" header: a retry with the same key returns the original response.
 func (t WebAPI) payToAddress(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
 	// the foreignID is a 3rd-party ID for the account
 	foreignID := p.ByName("foreignID")
@@ -384,8 +663,13 @@ func (t WebAPI) payToAddress(w http.ResponseWriter, r *http.Request, p httproute
 		sendBadRequest(w, "missing account ID in URL")
 		return
 	}
+	body, idem, err := readIdempotentRequest(r)
+	if err != nil {
+		sendBadRequest(w, err.Error())
+		return
+	}
 	var o PayToAddressRequest
-	err := json.NewDecoder(r.Body).Decode(&o)
+	err = json.NewDecoder(bytes.NewReader(body)).Decode(&o)
 	if err != nil {
 		sendBadRequest(w, fmt.Sprintf("bad request body (expecting JSON): %v", err))
 		return
@@ -394,7 +678,7 @@ func (t WebAPI) payToAddress(w http.ResponseWriter, r *http.Request, p httproute
 		// treat 'PayTo' request as an array of one item.
 		o.Pay = append(o.Pay, giga.PayTo{Amount: o.Amount, PayTo: o.PayTo})
 	}
-	res, err := t.api.SendFundsToAddress(foreignID, o.Pay, o.ExplicitFee, o.MaxFee, true)
+	res, err := t.forAccount(foreignID).SendFundsToAddress(foreignID, o.Pay, o.Memo, o.feeOptions(), true, idem)
 	if err != nil {
 		sendError(w, "SendFundsToAddress", err)
 		return
@@ -405,6 +689,8 @@ func (t WebAPI) payToAddress(w http.ResponseWriter, r *http.Request, p httproute
 // Create and sign a transaction to pay out funds from the account.
 // POST /account/:foreignID/paytx { "pay":[{ "amount": "1.0", "to": "DPeT…" }] } -> { tx:"…hex" }
 // or { "explicit_fee": "0.2", "pay": [ "amount": "1.0", "to": "DPeT…", "deduct_fee_percent": "100" ] }
+// optional { "memo": "INV-1234" } is embedded in an OP_RETURN output.
+// optional "Idempotency-Key" header: a retry with the same key returns the original response.
 func (t WebAPI) payTransaction(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
 	// the foreignID is a 3rd-party ID for the account
 	foreignID := p.ByName("foreignID")
@@ -412,8 +698,13 @@ func (t WebAPI) payTransaction(w http.ResponseWriter, r *http.Request, p httprou
 		sendBadRequest(w, "missing account ID in URL")
 		return
 	}
+	body, idem, err := readIdempotentRequest(r)
+	if err != nil {
+		sendBadRequest(w, err.Error())
+		return
+	}
 	var o PayToAddressRequest
-	err := json.NewDecoder(r.Body).Decode(&o)
+	err = json.NewDecoder(bytes.NewReader(body)).Decode(&o)
 	if err != nil {
 		sendBadRequest(w, fmt.Sprintf("bad request body (expecting JSON): %v", err))
 		return
@@ -422,7 +713,7 @@ func (t WebAPI) payTransaction(w http.ResponseWriter, r *http.Request, p httprou
 		// treat 'PayTo' request as an array of one item.
 		o.Pay = append(o.Pay, giga.PayTo{Amount: o.Amount, PayTo: o.PayTo})
 	}
-	res, err := t.api.SendFundsToAddress(foreignID, o.Pay, o.ExplicitFee, o.MaxFee, false)
+	res, err := t.forAccount(foreignID).SendFundsToAddress(foreignID, o.Pay, o.Memo, o.feeOptions(), false, idem)
 	if err != nil {
 		sendError(w, "PayTransaction", err)
 		return
@@ -430,8 +721,310 @@ func (t WebAPI) payTransaction(w http.ResponseWriter, r *http.Request, p httprou
 	sendResponse(w, res)
 }
 
+type SubmitSignedRequest struct {
+	PaymentID int64  `json:"payment_id"` // from the UnsignedTxn
+	TxData    string `json:"tx"`         // signed transaction, hex-encoded
+}
+
+// Submits an offline-signed transaction for a payment from an account with
+// offline_signing, i.e. the "unsigned" transaction returned by /pay after
+// signing it with `gigawallet sign`
+// POST /account/:foreignID/submit-signed { "payment_id": 1, "tx": "…hex" } -> { status }
+func (t WebAPI) submitSigned(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	// the foreignID is a 3rd-party ID for the account
+	foreignID := p.ByName("foreignID")
+	if foreignID == "" {
+		sendBadRequest(w, "missing account ID in URL")
+		return
+	}
+	var o SubmitSignedRequest
+	err := json.NewDecoder(r.Body).Decode(&o)
+	if err != nil {
+		sendBadRequest(w, fmt.Sprintf("bad request body (expecting JSON): %v", err))
+		return
+	}
+	if o.PaymentID == 0 || o.TxData == "" {
+		sendBadRequest(w, "missing 'payment_id' or 'tx' in JSON body")
+		return
+	}
+	res, err := t.forAccount(foreignID).SubmitSignedTxn(foreignID, o.PaymentID, o.TxData)
+	if err != nil {
+		sendError(w, "SubmitSignedTxn", err)
+		return
+	}
+	sendResponse(w, res)
+}
+
+// Approves a pending payment, as the authenticated approver.
+// The payment is sent once the account's approvals_required approvers have approved.
+// POST /account/:foreignID/payment/:paymentID/approve -> { payment }
+func (t WebAPI) approvePayment(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	foreignID, paymentID, ok := paymentParams(w, p)
+	if !ok {
+		return
+	}
+	approver, _ := r.Context().Value(approverKey{}).(string)
+	res, err := t.forAccount(foreignID).ApprovePayment(foreignID, paymentID, approver)
+	if err != nil {
+		sendError(w, "ApprovePayment", err)
+		return
+	}
+	sendResponse(w, res)
+}
+
+// Rejects a pending payment, as the authenticated approver.
+// POST /account/:foreignID/payment/:paymentID/reject -> { payment }
+func (t WebAPI) rejectPayment(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	foreignID, paymentID, ok := paymentParams(w, p)
+	if !ok {
+		return
+	}
+	approver, _ := r.Context().Value(approverKey{}).(string)
+	res, err := t.forAccount(foreignID).RejectPayment(foreignID, paymentID, approver)
+	if err != nil {
+		sendError(w, "RejectPayment", err)
+		return
+	}
+	sendResponse(w, res)
+}
+
+// GET /account/:foreignID/payment/:paymentID/approvals -> [ approval, … ]
+func (t WebAPI) listPaymentApprovals(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	foreignID, paymentID, ok := paymentParams(w, p)
+	if !ok {
+		return
+	}
+	res, err := t.forAccount(foreignID).ListPaymentApprovals(foreignID, paymentID)
+	if err != nil {
+		sendError(w, "ListPaymentApprovals", err)
+		return
+	}
+	if res == nil {
+		res = []giga.PaymentApproval{}
+	}
+	sendResponse(w, res)
+}
+
+func paymentParams(w http.ResponseWriter, p httprouter.Params) (foreignID string, paymentID int64, ok bool) {
+	// the foreignID is a 3rd-party ID for the account
+	foreignID = p.ByName("foreignID")
+	if foreignID == "" {
+		sendBadRequest(w, "missing account ID in URL")
+		return
+	}
+	paymentID, err := strconv.ParseInt(p.ByName("paymentID"), 10, 64)
+	if err != nil {
+		sendBadRequest(w, "invalid payment ID in URL")
+		return
+	}
+	return foreignID, paymentID, true
+}
+
+type QuoteRequest struct {
+	PayToAddressRequest
+	ConfirmTargets []int `json:"confirm_targets"` // optional confirmation targets to quote (default: 2, 6 and 24 blocks)
+}
+
+// Quotes the fee to pay out funds from the account, without making a payment.
+// POST /account/:foreignID/quote { "amount": "1.0", "to": "DPeT…" } -> { quotes:[{ "confirm_target": 2, "fee": "0.01", … }] }
+// or { "pay": [ … ], "confirm_targets": [1, 3] } or { "pay": [ … ], "fee_per_byte": "0.00002" }
+func (t WebAPI) quoteFees(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	// the foreignID is a 3rd-party ID for the account
+	foreignID := p.ByName("foreignID")
+	if foreignID == "" {
+		sendBadRequest(w, "missing account ID in URL")
+		return
+	}
+	var o QuoteRequest
+	err := json.NewDecoder(r.Body).Decode(&o)
+	if err != nil {
+		sendBadRequest(w, fmt.Sprintf("bad request body (expecting JSON): %v", err))
+		return
+	}
+	if len(o.Pay) == 0 {
+		// treat 'PayTo' request as an array of one item.
+		o.Pay = append(o.Pay, giga.PayTo{Amount: o.Amount, PayTo: o.PayTo})
+	}
+	res, err := t.forAccount(foreignID).QuoteFees(foreignID, o.Pay, o.Memo, o.feeOptions(), o.ConfirmTargets)
+	if err != nil {
+		sendError(w, "QuoteFees", err)
+		return
+	}
+	sendResponse(w, res)
+}
+
+type SweepRequest struct {
+	WIF           string          `json:"wif"`            // WIF-encoded private key to sweep (never stored)
+	MaxFee        giga.CoinAmount `json:"max_fee"`        // optional maximum fee (missing or zero: maximum is 1 DOGE)
+	FeePerByte    giga.CoinAmount `json:"fee_per_byte"`   // optional fee rate (missing or zero: use the fee estimate)
+	ConfirmTarget int             `json:"confirm_target"` // optional fee estimate target in blocks (missing or zero: 6 blocks)
+}
+
+// Sweeps all funds held by an external private key (e.g. a paper wallet)
+// into the account's next receive address. Requires a Core Node that
+// supports `scantxoutset` to find the funds.
+// POST /account/:foreignID/sweep { "wif": "QP…" } -> { "txid": "…", "paid": "10.0", … }
+func (t WebAPI) sweepPrivateKey(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	// the foreignID is a 3rd-party ID for the account
+	foreignID := p.ByName("foreignID")
+	if foreignID == "" {
+		sendBadRequest(w, "missing account ID in URL")
+		return
+	}
+	var o SweepRequest
+	err := json.NewDecoder(r.Body).Decode(&o)
+	if err != nil {
+		sendBadRequest(w, fmt.Sprintf("bad request body (expecting JSON): %v", err))
+		return
+	}
+	if o.WIF == "" {
+		sendBadRequest(w, "missing 'wif' in JSON body")
+		return
+	}
+	feeOpts := giga.FeeOptions{MaxFee: o.MaxFee, FeePerByte: o.FeePerByte, ConfirmTarget: o.ConfirmTarget}
+	res, err := t.forAccount(foreignID).SweepPrivateKey(foreignID, o.WIF, feeOpts)
+	if err != nil {
+		sendError(w, "SweepPrivateKey", err)
+		return
+	}
+	sendResponse(w, res)
+}
+
+type WithdrawalRequest struct {
+	Amount giga.CoinAmount `json:"amount"`
+	PayTo  giga.Address    `json:"to"`
+}
+
+// Queues a pay-out from an account, to be paid in a batch transaction
+// with other withdrawals from the same account (see PayoutBatcher)
+// POST /account/:foreignID/withdraw { "amount": "1.0", "to": "DPeTgZm7LabnmFTJkAPfADkwiKreEMmzio" } -> { withdrawal }
+func (t WebAPI) queueWithdrawal(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	// the foreignID is a 3rd-party ID for the account
+	foreignID := p.ByName("foreignID")
+	if foreignID == "" {
+		sendBadRequest(w, "missing account ID in URL")
+		return
+	}
+	var o WithdrawalRequest
+	err := json.NewDecoder(r.Body).Decode(&o)
+	if err != nil {
+		sendBadRequest(w, fmt.Sprintf("bad request body (expecting JSON): %v", err))
+		return
+	}
+	res, err := t.forAccount(foreignID).QueueWithdrawal(foreignID, o.PayTo, o.Amount)
+	if err != nil {
+		sendError(w, "QueueWithdrawal", err)
+		return
+	}
+	sendResponse(w, res)
+}
+
+// GET /account/:foreignID/withdraw/:withdrawalID -> { withdrawal }
+func (t WebAPI) getWithdrawal(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	// the foreignID is a 3rd-party ID for the account
+	foreignID := p.ByName("foreignID")
+	if foreignID == "" {
+		sendBadRequest(w, "missing account ID in URL")
+		return
+	}
+	id, err := strconv.ParseInt(p.ByName("withdrawalID"), 10, 64)
+	if err != nil {
+		sendBadRequest(w, "invalid withdrawal ID in URL")
+		return
+	}
+	res, err := t.forAccount(foreignID).GetWithdrawal(foreignID, id)
+	if err != nil {
+		sendError(w, "GetWithdrawal", err)
+		return
+	}
+	sendResponse(w, res)
+}
+
+type ScheduleRequest struct {
+	Amount      giga.CoinAmount `json:"amount"`
+	PayTo       giga.Address    `json:"to"`
+	Pay         []giga.PayTo    `json:"pay"`           // either Pay, or Amount and PayTo.
+	Memo        string          `json:"memo"`          // optional memo (up to 80 bytes) in an OP_RETURN output
+	PayAt       time.Time       `json:"pay_at"`        // time to pay (RFC 3339) or:
+	PayAtHeight int64           `json:"pay_at_height"` // block height to pay
+	LockTime    bool            `json:"lock_time"`     // optional: create an nLockTime transaction now
+}
+
+// Schedules a payout from the account at a future time or block height
+// (requires the scheduled payment service, see SchedulingConfig)
+// POST /account/:foreignID/schedule { "amount": "1.0", "to": "DPeT…", "pay_at": "2026-11-01T00:00:00Z" } -> { scheduled }
+// or { "pay": [ … ], "pay_at_height": 5000000, "lock_time": true }
+func (t WebAPI) schedulePayment(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	// the foreignID is a 3rd-party ID for the account
+	foreignID := p.ByName("foreignID")
+	if foreignID == "" {
+		sendBadRequest(w, "missing account ID in URL")
+		return
+	}
+	var o ScheduleRequest
+	err := json.NewDecoder(r.Body).Decode(&o)
+	if err != nil {
+		sendBadRequest(w, fmt.Sprintf("bad request body (expecting JSON): %v", err))
+		return
+	}
+	if len(o.Pay) == 0 {
+		// treat 'PayTo' request as an array of one item.
+		o.Pay = append(o.Pay, giga.PayTo{Amount: o.Amount, PayTo: o.PayTo})
+	}
+	res, err := t.forAccount(foreignID).SchedulePayment(foreignID, o.Pay, o.Memo, o.PayAt, o.PayAtHeight, o.LockTime)
+	if err != nil {
+		sendError(w, "SchedulePayment", err)
+		return
+	}
+	sendResponse(w, res)
+}
+
+// GET /account/:foreignID/schedule/:scheduleID -> { scheduled }
+func (t WebAPI) getScheduledPayment(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	foreignID, id, ok := scheduleParams(w, p)
+	if !ok {
+		return
+	}
+	res, err := t.forAccount(foreignID).GetScheduledPayment(foreignID, id)
+	if err != nil {
+		sendError(w, "GetScheduledPayment", err)
+		return
+	}
+	sendResponse(w, res)
+}
+
+// POST /account/:foreignID/schedule/:scheduleID/cancel -> { scheduled }
+func (t WebAPI) cancelScheduledPayment(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	foreignID, id, ok := scheduleParams(w, p)
+	if !ok {
+		return
+	}
+	res, err := t.forAccount(foreignID).CancelScheduledPayment(foreignID, id)
+	if err != nil {
+		sendError(w, "CancelScheduledPayment", err)
+		return
+	}
+	sendResponse(w, res)
+}
+
+func scheduleParams(w http.ResponseWriter, p httprouter.Params) (foreignID string, id int64, ok bool) {
+	// the foreignID is a 3rd-party ID for the account
+	foreignID = p.ByName("foreignID")
+	if foreignID == "" {
+		sendBadRequest(w, "missing account ID in URL")
+		return
+	}
+	id, err := strconv.ParseInt(p.ByName("scheduleID"), 10, 64)
+	if err != nil {
+		sendBadRequest(w, "invalid schedule ID in URL")
+		return
+	}
+	return foreignID, id, true
+}
+
 // pays an invoice from another account managed by gigawallet
 // POST /invoice/:invoiceID/payfrom/:foreignID -> { status }
+// optional "Idempotency-Key" header: a retry with the same key returns the original response.
 func (t WebAPI) payInvoiceFromInternal(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
 	invoice_id := p.ByName("invoiceID")
 	if invoice_id == "" {
@@ -443,7 +1036,12 @@ func (t WebAPI) payInvoiceFromInternal(w http.ResponseWriter, r *http.Request, p
 		sendBadRequest(w, "missing foreign ID in URL")
 		return
 	}
-	res, err := t.api.PayInvoiceFromAccount(giga.Address(invoice_id), foreign_id)
+	_, idem, err := readIdempotentRequest(r)
+	if err != nil {
+		sendBadRequest(w, err.Error())
+		return
+	}
+	res, err := t.forAccount(foreign_id).PayInvoiceFromAccount(giga.Address(invoice_id), foreign_id, idem)
 	if err != nil {
 		sendError(w, "PayInvoiceFromAccount", err)
 		return
@@ -465,7 +1063,26 @@ func (t WebAPI) upsertAccount(w http.ResponseWriter, r *http.Request, p httprout
 		sendBadRequest(w, fmt.Sprintf("bad request body (expecting JSON): %v", err))
 		return
 	}
-	acc, err := t.api.CreateAccount(o, foreignID, true)
+	// Create the account on the requested network, or find the network
+	// an existing account is bound to.
+	api, found, err := t.findAccount(foreignID)
+	if err != nil {
+		sendError(w, "CreateAccount", err)
+		return
+	}
+	if o.Network != "" {
+		requested, err := t.network(o.Network)
+		if err != nil {
+			sendError(w, "CreateAccount", err)
+			return
+		}
+		if found && requested.Network() != api.Network() {
+			sendErrorResponse(w, http.StatusConflict, giga.Conflict, fmt.Sprintf("account already exists on network '%s'", api.Network()))
+			return
+		}
+		api = requested
+	}
+	acc, err := api.CreateAccount(o, foreignID, true)
 	if err != nil {
 		sendError(w, "CreateAccount", err)
 		return
@@ -481,7 +1098,7 @@ func (t WebAPI) getAccount(w http.ResponseWriter, r *http.Request, p httprouter.
 		sendBadRequest(w, "missing account ID in URL")
 		return
 	}
-	acc, err := t.api.GetAccount(id)
+	acc, err := t.forAccount(id).GetAccount(id)
 	if err != nil {
 		sendError(w, "GetAccount", err)
 		return
@@ -496,7 +1113,7 @@ func (t WebAPI) getAccountBalance(w http.ResponseWriter, r *http.Request, p http
 		sendBadRequest(w, "missing account ID in URL")
 		return
 	}
-	bal, err := t.api.CalculateBalance(id)
+	bal, err := t.forAccount(id).CalculateBalance(id)
 	if err != nil {
 		sendError(w, "CalculateBalance", err)
 		return
@@ -515,7 +1132,11 @@ func (t WebAPI) decodeTxn(w http.ResponseWriter, r *http.Request, p httprouter.P
 		sendBadRequest(w, fmt.Sprintf("bad request body (expecting JSON): %v", err))
 		return
 	}
-	rawTxn, err := t.api.L1.DecodeTransaction(o.Hex)
+	api, ok := t.forRequest(w, r)
+	if !ok {
+		return
+	}
+	rawTxn, err := api.L1.DecodeTransaction(o.Hex)
 	if err != nil {
 		sendBadRequest(w, fmt.Sprintf("error decoding transaction: %v", err))
 		return
@@ -4,13 +4,13 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
 	"strings"
 	"testing"
 
 	giga "github.com/dogecoinfoundation/gigawallet/pkg"
 	"github.com/dogecoinfoundation/gigawallet/pkg/doge"
-	"github.com/dogecoinfoundation/gigawallet/pkg/dogecoin"
-	dbstore "github.com/dogecoinfoundation/gigawallet/pkg/store"
+	"github.com/dogecoinfoundation/gigawallet/pkg/testutil"
 	"github.com/julienschmidt/httprouter"
 	"github.com/shopspring/decimal"
 )
@@ -87,6 +87,11 @@ func TestWebAPI(t *testing.T) {
 		t.Fatalf("Pay To Address 1: wrong fee: %v", payTo.Fee)
 	}
 
+	// Pay to an address on the wrong network (mainnet)
+	mainAddr := doge.Hash160toAddress(make([]byte, 20), 0x1e)
+	var wrongNet map[string]any
+	requestWithKey(t, admin, "/account/Pepper/pay", `{"amount":"2","to":"`+string(mainAddr)+`"}`, "", http.StatusBadRequest, &wrongNet)
+
 	// Pay with explicit fee
 	request(t, admin, "/account/Pepper/pay", `{"amount":"2","to":"`+to_1+`","explicit_fee":"1"}`, &payTo)
 	if payTo.TxId == "" {
@@ -116,6 +121,198 @@ func TestWebAPI(t *testing.T) {
 	if !payTo.Paid.Equals(decimal.RequireFromString("2.9")) {
 		t.Fatalf("Pay To Address 3: wrong paid: %v", payTo.Paid)
 	}
+
+	// Quote fees for a payment at the default confirmation targets
+	var quote giga.QuoteFeesResult
+	request(t, admin, "/account/Pepper/quote", `{"amount":"2","to":"`+to_1+`"}`, &quote)
+	if len(quote.Quotes) != 3 || quote.Quotes[0].ConfirmTarget != 2 || quote.Quotes[2].ConfirmTarget != 24 {
+		t.Fatalf("Quote: expected quotes for 2, 6 and 24 blocks: %v", quote.Quotes)
+	}
+	for _, q := range quote.Quotes {
+		if q.Inputs < 1 || q.Size < 1 || !q.Fee.IsPositive() || !q.Paid.Equals(decimal.RequireFromString("2")) {
+			t.Fatalf("Quote: wrong quote: %v", q)
+		}
+	}
+
+	// Quote with an explicit fee rate
+	request(t, admin, "/account/Pepper/quote", `{"amount":"2","to":"`+to_1+`","fee_per_byte":"0.001"}`, &quote)
+	if len(quote.Quotes) != 1 || !quote.Quotes[0].FeePerByte.Equals(decimal.RequireFromString("0.001")) {
+		t.Fatalf("Quote: expected a single quote at the fee rate: %v", quote.Quotes)
+	}
+
+	// Pay with an explicit fee rate
+	request(t, admin, "/account/Pepper/pay", `{"amount":"2","to":"`+to_1+`","fee_per_byte":"0.001"}`, &payTo)
+	if !payTo.Fee.Equals(quote.Quotes[0].Fee) {
+		t.Fatalf("Pay To Address 4: fee does not match the quote: %v vs %v", payTo.Fee, quote.Quotes[0].Fee)
+	}
+
+	// Retry a payment with the same Idempotency-Key
+	var payIdem1, payIdem2 PayToAddressResponse
+	payBody := `{"amount":"2","to":"` + to_1 + `"}`
+	requestWithKey(t, admin, "/account/Pepper/pay", payBody, "pay-1", http.StatusOK, &payIdem1)
+	requestWithKey(t, admin, "/account/Pepper/pay", payBody, "pay-1", http.StatusOK, &payIdem2)
+	if payIdem1.TxId == "" || payIdem2.TxId != payIdem1.TxId || !payIdem2.Total.Equals(payIdem1.Total) {
+		t.Fatalf("Idempotent Pay: retry did not return the original result: %v vs %v", payIdem2, payIdem1)
+	}
+
+	// Re-use the Idempotency-Key with a different request
+	var conflict map[string]any
+	requestWithKey(t, admin, "/account/Pepper/pay", `{"amount":"3","to":"`+to_1+`"}`, "pay-1", http.StatusConflict, &conflict)
+
+	// Retry an invoice with the same Idempotency-Key
+	var invIdem1, invIdem2 giga.PublicInvoice
+	invBody := `{"items":[{"type":"item","name":"Socks","sku":"S-001","description":"Nice socks","value":"5","quantity":1}]}`
+	requestWithKey(t, admin, "/account/Pepper/invoice", invBody, "inv-1", http.StatusOK, &invIdem1)
+	requestWithKey(t, admin, "/account/Pepper/invoice", invBody, "inv-1", http.StatusOK, &invIdem2)
+	if invIdem2.ID != invIdem1.ID || !invIdem2.Total.Equals(invIdem1.Total) {
+		t.Fatalf("Idempotent Invoice: retry did not return the original invoice: %v vs %v", invIdem2.ID, invIdem1.ID)
+	}
+
+	// Pay from an account with offline signing, then submit the signed transaction
+	var cold giga.AccountPublic
+	request(t, admin, "/account/Cold", `{"offline_signing":true}`, &cold)
+	if !cold.OfflineSigning {
+		t.Fatalf("Create Account did not round-trip offline_signing")
+	}
+	addFundsToAccount(t, store, l1, "Cold")
+	var coldQuote giga.QuoteFeesResult
+	request(t, admin, "/account/Cold/quote", `{"amount":"2","to":"`+to_1+`","fee_per_byte":"0.001"}`, &coldQuote)
+	var unsignedPay PayToAddressResponse
+	request(t, admin, "/account/Cold/pay", `{"amount":"2","to":"`+to_1+`"}`, &unsignedPay)
+	if unsignedPay.Unsigned == nil || unsignedPay.TxId != "" || len(unsignedPay.Unsigned.Inputs) == 0 {
+		t.Fatalf("Offline Pay: expected an unsigned transaction: %v", unsignedPay)
+	}
+	if unsignedPay.PaymentID == 0 || unsignedPay.PaymentID != unsignedPay.Unsigned.PaymentID {
+		t.Fatalf("Offline Pay: expected the payment_id on the result: %v", unsignedPay)
+	}
+	coldAcc, err := store.GetAccount("Cold")
+	if err != nil {
+		t.Fatalf("GetAccount: %v", err)
+	}
+	signed, err := l1.SignTransaction(unsignedPay.Unsigned.TxnHex, unsignedPay.Unsigned.InputUTXOs(), coldAcc.Privkey)
+	if err != nil {
+		t.Fatalf("SignTransaction: %v", err)
+	}
+	if coldQuote.Quotes[0].Size < len(signed)/2 {
+		t.Fatalf("Offline Quote: expected the size of the signed transaction: %v < %v", coldQuote.Quotes[0].Size, len(signed)/2)
+	}
+	var signedPay PayToAddressResponse
+	paymentID := strconv.FormatInt(unsignedPay.Unsigned.PaymentID, 10)
+	request(t, admin, "/account/Cold/submit-signed", `{"payment_id":`+paymentID+`,"tx":"`+signed+`"}`, &signedPay)
+	if signedPay.TxId == "" || signedPay.TxData != signed || !signedPay.Total.Equals(unsignedPay.Total) {
+		t.Fatalf("Submit Signed: wrong result: %v", signedPay)
+	}
+
+	// Pay from an account that requires 2 approvals above 5 doge
+	request(t, admin, "/account/Vault", `{"approvals_required":2,"approval_threshold":"5"}`, &giga.AccountPublic{})
+	addFundsToAccount(t, store, l1, "Vault")
+	var smallPay PayToAddressResponse
+	request(t, admin, "/account/Vault/pay", `{"amount":"2","to":"`+to_1+`"}`, &smallPay)
+	if smallPay.ApprovalStatus != giga.ApprovalNone || smallPay.TxId == "" {
+		t.Fatalf("Pay below the approval threshold: expected no approval: %v", smallPay)
+	}
+	var pendingPay PayToAddressResponse
+	request(t, admin, "/account/Vault/pay", `{"amount":"20","to":"`+to_1+`"}`, &pendingPay)
+	if pendingPay.ApprovalStatus != giga.ApprovalPending || pendingPay.PaymentID == 0 {
+		t.Fatalf("Pay above the approval threshold: expected pending approval: %v", pendingPay)
+	}
+	pendingURL := "/account/Vault/payment/" + strconv.FormatInt(pendingPay.PaymentID, 10)
+	var noAdmin map[string]any
+	requestAs(t, admin, pendingURL+"/approve", "", http.StatusUnauthorized, &noAdmin)
+	var approved giga.Payment
+	requestAs(t, admin, pendingURL+"/approve", "alice-token", http.StatusOK, &approved)
+	if approved.ApprovalStatus != giga.ApprovalPending || approved.PaidTxID != "" {
+		t.Fatalf("Approve: expected pending after one approval: %v", approved)
+	}
+	var again map[string]any
+	requestAs(t, admin, pendingURL+"/approve", "alice-token", http.StatusConflict, &again)
+	requestAs(t, admin, pendingURL+"/approve", "bob-token", http.StatusOK, &approved)
+	if approved.ApprovalStatus != giga.ApprovalApproved || approved.PaidTxID != pendingPay.TxId {
+		t.Fatalf("Approve: expected sent after two approvals: %v", approved)
+	}
+	var approvals []giga.PaymentApproval
+	request(t, admin, pendingURL+"/approvals", "", &approvals)
+	if len(approvals) != 2 || approvals[0].Approver != "alice" || approvals[1].Approver != "bob" {
+		t.Fatalf("List Approvals: wrong approvals: %v", approvals)
+	}
+
+	// Reject a pending payment: releases the reserved UTXOs
+	vault, err := store.GetAccount("Vault")
+	if err != nil {
+		t.Fatalf("GetAccount: %v", err)
+	}
+	unreserved, err := store.GetAllUnreservedUTXOs(vault.Address)
+	if err != nil {
+		t.Fatalf("GetAllUnreservedUTXOs: %v", err)
+	}
+	var before, after giga.AccountBalance
+	request(t, admin, "/account/Vault/balance", "", &before)
+	var rejectPay PayToAddressResponse
+	request(t, admin, "/account/Vault/pay", `{"amount":"20","to":"`+to_1+`"}`, &rejectPay)
+	var rejected giga.Payment
+	requestAs(t, admin, "/account/Vault/payment/"+strconv.FormatInt(rejectPay.PaymentID, 10)+"/reject", "bob-token", http.StatusOK, &rejected)
+	if rejected.ApprovalStatus != giga.ApprovalRejected {
+		t.Fatalf("Reject: expected rejected: %v", rejected)
+	}
+	request(t, admin, "/account/Vault/balance", "", &after)
+	if !after.CurrentBalance.Equals(before.CurrentBalance) || !after.OutgoingBalance.Equals(before.OutgoingBalance) {
+		t.Fatalf("Reject: expected the balance to be restored: %v vs %v", after, before)
+	}
+	released, err := store.GetAllUnreservedUTXOs(vault.Address)
+	if err != nil {
+		t.Fatalf("GetAllUnreservedUTXOs: %v", err)
+	}
+	if len(released) != len(unreserved) {
+		t.Fatalf("Reject: expected reserved UTXOs to be released: %v vs %v", len(released), len(unreserved))
+	}
+
+	// Schedule a payment at a block height, then cancel it
+	var sched giga.ScheduledPayment
+	request(t, admin, "/account/Pepper/schedule", `{"amount":"2","to":"`+to_1+`","pay_at_height":5000000}`, &sched)
+	if sched.ID == 0 || sched.Status != giga.SchedulePending || sched.PayAtHeight != 5000000 || sched.PaymentID != 0 {
+		t.Fatalf("Schedule Payment: expected a pending scheduled payment: %v", sched)
+	}
+	var sched2 giga.ScheduledPayment
+	request(t, admin, "/account/Pepper/schedule/"+strconv.FormatInt(sched.ID, 10), "", &sched2)
+	if sched2.ID != sched.ID || len(sched2.PayTo) != 1 || string(sched2.PayTo[0].PayTo) != to_1 {
+		t.Fatalf("Get Scheduled Payment did not return matching data: %v vs %v", sched2, sched)
+	}
+	request(t, admin, "/account/Pepper/schedule/"+strconv.FormatInt(sched.ID, 10)+"/cancel", "{}", &sched2)
+	if sched2.Status != giga.ScheduleCancelled {
+		t.Fatalf("Cancel Scheduled Payment: expected cancelled: %v", sched2)
+	}
+	var badSched map[string]any
+	requestWithKey(t, admin, "/account/Pepper/schedule", `{"amount":"2","to":"`+to_1+`"}`, "", http.StatusBadRequest, &badSched)
+
+	// Schedule a payment with lock_time: the transaction is created now
+	var locked giga.ScheduledPayment
+	request(t, admin, "/account/Vault/schedule", `{"amount":"2","to":"`+to_1+`","pay_at":"2030-01-01T00:00:00Z","lock_time":true}`, &locked)
+	if locked.PaymentID == 0 || !locked.LockTime {
+		t.Fatalf("Schedule Payment: expected a reserved payment for lock_time: %v", locked)
+	}
+	requestWithKey(t, admin, "/account/Vault/schedule/"+strconv.FormatInt(locked.ID, 10)+"/cancel", "{}", "", http.StatusConflict, &badSched)
+
+	// Sweep a private key: reject a key for another chain (mainnet)
+	mainKey, err := doge.GenerateECPrivKey()
+	if err != nil {
+		t.Fatalf("GenerateECPrivKey: %v", err)
+	}
+	var badKey map[string]any
+	requestWithKey(t, admin, "/account/Pepper/sweep", `{"wif":"`+doge.EncodeECPrivKeyWIF(mainKey, &doge.DogeMainNetChain)+`"}`, "", http.StatusBadRequest, &badKey)
+
+	// Queue a Withdrawal
+	var wd giga.Withdrawal
+	request(t, admin, "/account/Pepper/withdraw", `{"amount":"2","to":"`+to_1+`"}`, &wd)
+	if wd.ID == 0 || wd.Status != giga.WithdrawalPending {
+		t.Fatalf("Queue Withdrawal: expected a pending withdrawal: %v", wd)
+	}
+
+	// Get the Withdrawal status
+	var wd2 giga.Withdrawal
+	request(t, admin, "/account/Pepper/withdraw/"+strconv.FormatInt(wd.ID, 10), "", &wd2)
+	if wd2.ID != wd.ID || string(wd2.PayTo) != to_1 || !wd2.Amount.Equals(decimal.RequireFromString("2")) || wd2.Status != giga.WithdrawalPending {
+		t.Fatalf("Get Withdrawal did not return matching data: %v vs %v", wd2, wd)
+	}
 }
 
 // Helpers.
@@ -141,37 +338,227 @@ func request(t *testing.T, adminMux *httprouter.Router, path string, body string
 	return result
 }
 
-func newTestRig(t *testing.T) (admin *httprouter.Router, pub *httprouter.Router, store giga.Store, L1 giga.L1) {
-	config := giga.TestConfig()
-	store, err := dbstore.NewSQLiteStore(":memory:")
+func requestWithKey(t *testing.T, adminMux *httprouter.Router, path string, body string, key string, status int, out any) *http.Response {
+	req := httptest.NewRequest("POST", path, strings.NewReader(body))
+	req.Header.Set("Idempotency-Key", key)
+	res := httptest.NewRecorder()
+	adminMux.ServeHTTP(res, req)
+	result := res.Result()
+	if result.StatusCode != status {
+		t.Fatalf("%s request: expected status %v: %v %v", path, status, result.StatusCode, res.Body)
+	}
+	err := json.NewDecoder(res.Body).Decode(out)
 	if err != nil {
-		t.Fatalf("Cannot create in-memory database: %v", err)
+		t.Fatalf("%s bad json: %v", path, res.Body)
 	}
-	mock, err := dogecoin.NewL1Mock(config)
+	return result
+}
+
+func TestIdempotentRetryAfterSendFails(t *testing.T) {
+	admin, store, l1, failing := newFailingRig(t)
+
+	var pepper giga.AccountPublic
+	request(t, admin, "/account/Pepper", `{}`, &pepper)
+	to_1, _ := addFundsToAccount(t, store, l1, "Pepper")
+
+	// The payment is reserved, but the tx cannot be sent.
+	var bad map[string]any
+	payBody := `{"amount":"2","to":"` + to_1 + `"}`
+	requestWithKey(t, admin, "/account/Pepper/pay", payBody, "pay-1", http.StatusServiceUnavailable, &bad)
+	if failing.Sent != 0 {
+		t.Fatalf("expected no tx to be sent: %v", failing.Sent)
+	}
+
+	// A retry with the same key sends the reserved payment (once)
+	failing.Fail = false
+	var pay1, pay2 PayToAddressResponse
+	requestWithKey(t, admin, "/account/Pepper/pay", payBody, "pay-1", http.StatusOK, &pay1)
+	requestWithKey(t, admin, "/account/Pepper/pay", payBody, "pay-1", http.StatusOK, &pay2)
+	if failing.Sent != 1 {
+		t.Fatalf("expected the reserved tx to be sent once: %v", failing.Sent)
+	}
+	if pay1.TxId == "" || pay2.TxId != pay1.TxId {
+		t.Fatalf("retry did not return the original result: %v vs %v", pay2, pay1)
+	}
+	payment, err := store.GetPayment(pepper.Address, pay1.PaymentID)
 	if err != nil {
-		t.Fatalf("Cannot init L1 mock: %v", err)
+		t.Fatalf("GetPayment: %v", err)
+	}
+	if payment.PaidTxID != pay1.TxId {
+		t.Fatalf("expected the payment to be sent: %v vs %v", payment.PaidTxID, pay1.TxId)
+	}
+}
+
+func requestAs(t *testing.T, adminMux *httprouter.Router, path string, token string, status int, out any) *http.Response {
+	req := httptest.NewRequest("POST", path, nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	res := httptest.NewRecorder()
+	adminMux.ServeHTTP(res, req)
+	result := res.Result()
+	if result.StatusCode != status {
+		t.Fatalf("%s request: expected status %v: %v %v", path, status, result.StatusCode, res.Body)
 	}
-	l1, err := dogecoin.NewL1Libdogecoin(config, mock)
+	err := json.NewDecoder(res.Body).Decode(out)
 	if err != nil {
-		t.Fatalf("Cannot init libdogecoin: %v", err)
+		t.Fatalf("%s bad json: %v", path, res.Body)
 	}
-	bus := giga.NewMessageBus()
-	mockFollower := giga.MockFollower{}
-	api := giga.NewAPI(store, l1, bus, &mockFollower, config)
-	web := WebAPI{api: api, config: config}
-	adminMux, pubMux := web.createRouters()
-	return adminMux, pubMux, store, l1
+	return result
 }
 
-func addFundsToAccount(t *testing.T, store giga.Store, l1 giga.L1, foreignID string) (string, string) {
+func TestChainAdmin(t *testing.T) {
+	admin, _, store, _ := newTestRig(t)
 	tx, err := store.Begin()
 	if err != nil {
 		t.Fatalf("store.Begin: %v", err)
 	}
-	acc, err := tx.GetAccount(foreignID)
+	err = tx.UpdateChainState(giga.ChainState{BestBlockHash: "aa", BestBlockHeight: 90, RootHash: "bb", FirstHeight: 10}, true)
 	if err != nil {
-		t.Fatalf("tx.GetAccount: %v", err)
+		t.Fatalf("tx.UpdateChainState: %v", err)
+	}
+	err = tx.Commit()
+	if err != nil {
+		t.Fatalf("tx.Commit: %v", err)
+	}
+
+	// Status compares the chain-state with Core's best block (100 in the mock)
+	var status giga.ChainStatus
+	request(t, admin, "/admin/chain", "", &status)
+	if status.BestBlockHeight != 90 || status.CoreHeight != 100 || status.Behind != 10 || status.Network != "testnet" {
+		t.Fatalf("unexpected chain status: %+v", status)
+	}
+	var bad map[string]any
+	req := httptest.NewRequest("GET", "/admin/chain?network=mainnet", nil)
+	res := httptest.NewRecorder()
+	admin.ServeHTTP(res, req)
+	if res.Code != http.StatusBadRequest {
+		t.Fatalf("expected an unknown network to be rejected: %v", res.Code)
+	}
+
+	// Roll back only below the last block processed.
+	requestWithKey(t, admin, "/admin/chain/rollback/90", "", "", http.StatusBadRequest, &bad)
+	requestWithKey(t, admin, "/admin/chain/rollback/5", "", "", http.StatusBadRequest, &bad)
+	var msg string
+	requestWithKey(t, admin, "/admin/chain/pause", "", "", http.StatusOK, &msg)
+	requestWithKey(t, admin, "/admin/chain/resume", "", "", http.StatusOK, &msg)
+}
+
+func TestMultiNetwork(t *testing.T) {
+	config := giga.TestConfig()
+	testnet := newTestAPI(t, config)
+	mainConfig := giga.TestConfig()
+	mainConfig.Gigawallet.Network = "mainnet"
+	mainnet := newTestAPI(t, mainConfig)
+	web, err := NewWebAPI(config, testnet, mainnet)
+	if err != nil {
+		t.Fatalf("NewWebAPI: %v", err)
+	}
+	admin, pub := web.createRouters()
+
+	// Accounts are created on the primary network by default.
+	var pepper giga.AccountPublic
+	request(t, admin, "/account/Pepper", `{}`, &pepper)
+	if pepper.Network != "testnet" || !doge.ValidateP2PKH(pepper.Address, &doge.DogeTestNetChain) {
+		t.Fatalf("expected a testnet account: %v %v", pepper.Network, pepper.Address)
+	}
+
+	// Accounts can be bound to another network.
+	var shibe giga.AccountPublic
+	request(t, admin, "/account/Shibe", `{"network":"mainnet"}`, &shibe)
+	if shibe.Network != "mainnet" || !doge.ValidateP2PKH(shibe.Address, &doge.DogeMainNetChain) {
+		t.Fatalf("expected a mainnet account: %v %v", shibe.Network, shibe.Address)
+	}
+	if _, err := testnet.Store.GetAccount("Shibe"); err == nil {
+		t.Fatalf("mainnet account was stored on testnet")
+	}
+
+	// Requests are routed to the account's network.
+	var inv giga.PublicInvoice
+	request(t, admin, "/account/Shibe/invoice", `{"items":[{"type":"item","name":"Bone","value":"5","quantity":1}]}`, &inv)
+	if !doge.ValidateP2PKH(inv.ID, &doge.DogeMainNetChain) {
+		t.Fatalf("expected a mainnet invoice address: %v", inv.ID)
+	}
+	var inv2 giga.PublicInvoice
+	request(t, pub, "/invoice/"+string(inv.ID), "", &inv2)
+	if inv2.ID != inv.ID {
+		t.Fatalf("public invoice was not found: %v", inv2.ID)
+	}
+
+	// An account cannot move to another network, or an unknown one.
+	var bad map[string]any
+	requestWithKey(t, admin, "/account/Shibe", `{"network":"testnet"}`, "", http.StatusConflict, &bad)
+	requestWithKey(t, admin, "/account/Rex", `{"network":"moonnet"}`, "", http.StatusBadRequest, &bad)
+
+	// An account cannot be created while a network cannot be checked
+	// (the account might exist there)
+	mainnet.Store.Close()
+	requestWithKey(t, admin, "/account/Shibe", `{}`, "", http.StatusServiceUnavailable, &bad)
+	if _, err := testnet.Store.GetAccount("Shibe"); !giga.IsNotFoundError(err) {
+		t.Fatalf("mainnet account was created on testnet: %v", err)
+	}
+}
+
+func TestApproveAfterSendFails(t *testing.T) {
+	admin, store, l1, failing := newFailingRig(t)
+	request(t, admin, "/account/Vault", `{"approvals_required":1,"approval_threshold":"5"}`, &giga.AccountPublic{})
+	to_1, _ := addFundsToAccount(t, store, l1, "Vault")
+	var pendingPay PayToAddressResponse
+	request(t, admin, "/account/Vault/pay", `{"amount":"20","to":"`+to_1+`"}`, &pendingPay)
+	if pendingPay.ApprovalStatus != giga.ApprovalPending {
+		t.Fatalf("expected pending approval: %v", pendingPay)
+	}
+
+	// The payment is approved, but the tx cannot be sent.
+	pendingURL := "/account/Vault/payment/" + strconv.FormatInt(pendingPay.PaymentID, 10)
+	var bad map[string]any
+	requestAs(t, admin, pendingURL+"/approve", "alice-token", http.StatusServiceUnavailable, &bad)
+
+	// Approving again re-submits the tx (once it is sent, it is a Conflict)
+	failing.Fail = false
+	var approved giga.Payment
+	requestAs(t, admin, pendingURL+"/approve", "bob-token", http.StatusOK, &approved)
+	if approved.ApprovalStatus != giga.ApprovalApproved || approved.PaidTxID != pendingPay.TxId || failing.Sent != 1 {
+		t.Fatalf("expected the approved payment to be sent once: %v (sent %v)", approved, failing.Sent)
+	}
+	requestAs(t, admin, pendingURL+"/approve", "bob-token", http.StatusConflict, &bad)
+	var approvals []giga.PaymentApproval
+	request(t, admin, pendingURL+"/approvals", "", &approvals)
+	if len(approvals) != 1 || approvals[0].Approver != "alice" {
+		t.Fatalf("expected a single approval: %v", approvals)
 	}
+}
+
+func newTestAPI(t *testing.T, config giga.Config) giga.API {
+	_, _, _, api := testutil.NewTestAPI(t, config)
+	return api
+}
+
+func newTestRig(t *testing.T) (admin *httprouter.Router, pub *httprouter.Router, store giga.Store, L1 giga.L1) {
+	config := giga.TestConfig()
+	config.WebAPI.Approvers = map[string]string{"alice": "alice-token", "bob": "bob-token"}
+	config.Scheduling.Enabled = true
+	store, l1, _, api := testutil.NewTestAPI(t, config)
+	web := WebAPI{api: api, config: config}
+	adminMux, pubMux := web.createRouters()
+	return adminMux, pubMux, store, l1
+}
+
+// A test rig with an L1 that fails to send transactions while failing.Fail is set.
+func newFailingRig(t *testing.T) (admin *httprouter.Router, store giga.Store, l1 giga.L1, failing *testutil.FailingL1) {
+	config := giga.TestConfig()
+	config.WebAPI.Approvers = map[string]string{"alice": "alice-token", "bob": "bob-token"}
+	store, l1, bus, _ := testutil.NewTestAPI(t, config)
+	failing = &testutil.FailingL1{L1: l1, Fail: true}
+	api := giga.NewAPI(store, failing, bus, &giga.MockFollower{}, config)
+	web := WebAPI{api: api, config: config}
+	admin, _ = web.createRouters()
+	return admin, store, l1, failing
+}
+
+// Add 10 UTXOs to the account, returns two of its change addresses to pay to.
+func addFundsToAccount(t *testing.T, store giga.Store, l1 giga.L1, foreignID string) (string, string) {
+	acc := testutil.FundAccount(t, store, l1, foreignID)
 	to_1, _, err := acc.NextChangeAddress(l1)
 	if err != nil {
 		t.Fatalf("NextChangeAddress: %v", err)
@@ -180,52 +567,17 @@ func addFundsToAccount(t *testing.T, store giga.Store, l1 giga.L1, foreignID str
 	if err != nil {
 		t.Fatalf("NextChangeAddress: %v", err)
 	}
-	// Insert 10 x P2PKH UTXOs with Value=10 and AccountID set.
-	for vout := 0; vout < 10; vout++ {
-		payTo, keyIndex, err := acc.NextPayToAddress(l1)
-		if err != nil {
-			t.Fatalf("NextPayToAddress: %v", err)
-		}
-		err = tx.CreateUTXO(giga.UTXO{
-			TxID:          "3f8e64a8453377def77868188811c2c7ed25fb31a16957e0001e28774d6d0208",
-			VOut:          vout,
-			Value:         decimal.NewFromInt(10),
-			ScriptHex:     p2pkhScriptHex(t, payTo),
-			ScriptType:    doge.ScriptTypeP2PKH,
-			ScriptAddress: payTo,
-			AccountID:     acc.Address,
-			KeyIndex:      keyIndex,
-			IsInternal:    false,
-			BlockHeight:   100,
-		})
-		if err != nil {
-			t.Fatalf("tx.CreateUTXO: %v", err)
-		}
+	tx, err := store.Begin()
+	if err != nil {
+		t.Fatalf("store.Begin: %v", err)
 	}
 	err = tx.UpdateAccount(acc)
 	if err != nil {
 		t.Fatalf("tx.UpdateAccount: %v", err)
 	}
-	// Currently Gigawallet won't spend UTXOs until they are confirmed.
-	_, err = tx.ConfirmUTXOs(6, 120) // 100 + 6 <= 120
-	if err != nil {
-		t.Fatalf("tx.ConfirmUTXOs: %v", err)
-	}
 	err = tx.Commit()
 	if err != nil {
 		t.Fatalf("tx.Commit: %v", err)
 	}
 	return string(to_1), string(to_2)
 }
-
-func p2pkhScriptHex(t *testing.T, addr giga.Address) string {
-	payload, err := doge.Base58DecodeCheck(string(addr))
-	if err != nil {
-		t.Fatalf("Base58DecodeCheck: %v", err)
-	}
-	hash := doge.HexEncode(payload[1:]) // skip "version" byte.
-	if len(hash) != 0x14*2 {
-		t.Fatalf("wrong hash len: %v (need 20 bytes)", len(hash))
-	}
-	return "76a914" + hash + "88ac"
-}
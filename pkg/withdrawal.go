@@ -0,0 +1,28 @@
+package giga
+
+import "time"
+
+// Withdrawal Status
+type WithdrawalStatus string
+
+const (
+	WithdrawalPending WithdrawalStatus = "pending" // queued, waiting for the next batch
+	WithdrawalBatched WithdrawalStatus = "batched" // included in a batch payment, not yet sent
+	WithdrawalSent    WithdrawalStatus = "sent"    // batch transaction accepted by the network (see TxID)
+	WithdrawalFailed  WithdrawalStatus = "failed"  // batch transaction could not be created or sent (see Error)
+)
+
+// Withdrawal is a queued pay-out request from an account.
+// The PayoutBatcher service combines pending withdrawals from the
+// same account into a single multi-output transaction (a Payment)
+type Withdrawal struct {
+	ID        int64            `json:"id"`         // incrementing withdrawal number
+	AccountID Address          `json:"account_id"` // owner account (source of funds)
+	PayTo     Address          `json:"to"`         // dogecoin address to pay
+	Amount    CoinAmount       `json:"amount"`     // amount to pay (the batch pays the fee)
+	Status    WithdrawalStatus `json:"status"`     // see WithdrawalStatus constants
+	PaymentID int64            `json:"payment_id"` // batch Payment that includes this withdrawal (if batched)
+	TxID      string           `json:"txid"`       // TXID of the batch transaction (once sent)
+	Error     string           `json:"error"`      // reason the withdrawal failed (if failed)
+	Created   time.Time        `json:"created"`    // when the withdrawal was queued
+}
@@ -3,9 +3,12 @@ package test
 import (
 	"testing"
 
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/decred/dcrd/dcrec/secp256k1/v4/ecdsa"
 	giga "github.com/dogecoinfoundation/gigawallet/pkg"
 	"github.com/dogecoinfoundation/gigawallet/pkg/doge"
 	"github.com/dogecoinfoundation/gigawallet/pkg/dogecoin"
+	"github.com/dogecoinfoundation/gigawallet/pkg/testutil"
 	"github.com/shopspring/decimal"
 )
 
@@ -37,7 +40,7 @@ func TestNewTxn(t *testing.T) {
 		}
 		source := giga.NewArrayUTXOSource(testUTXOs)
 		// NewTxn, change UTXO, inputs []UTXO, txid, error
-		txn, _, _, _, err := giga.CreateTxn(payTo, giga.ZeroCoins, giga.OneCoin, acc, source, lib)
+		txn, _, _, _, err := giga.CreateTxn(payTo, nil, giga.FeeOptions{MaxFee: giga.OneCoin}, acc, source, lib)
 		if err != nil {
 			t.Fatalf("%v", err)
 		}
@@ -55,6 +58,565 @@ func TestNewTxn(t *testing.T) {
 		}
 		t.Logf("DeductFeePercent: %v In => %v Out + %v Fee = %v + Change %v", txn.TotalIn, txn.TotalOut, txn.FeeAmount, txn.TotalOut.Add(txn.FeeAmount), txn.ChangeAmount)
 	})
+
+	t.Run("CreateTxn with FeePerByte", func(t *testing.T) {
+		payTo := []giga.PayTo{{Amount: dc("3"), PayTo: to_1}}
+		feeOpts := giga.FeeOptions{FeePerByte: dc("0.005"), MaxFee: dc("10")}
+		txn, _, _, _, err := giga.CreateTxn(payTo, nil, feeOpts, acc, giga.NewArrayUTXOSource(testUTXOs), lib)
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		// the fee is calculated from the estimated size (within 2% of the signed size)
+		sizeFee := dc("0.005").Mul(decimal.NewFromInt(int64(len(txn.TxnHex) / 2)))
+		if txn.FeeAmount.Sub(sizeFee).Abs().GreaterThan(sizeFee.Mul(dc("0.02"))) {
+			t.Fatalf("fee does not match fee_per_byte: %v vs %v", txn.FeeAmount, sizeFee)
+		}
+		def, _, _, _, err := giga.CreateTxn(payTo, nil, giga.FeeOptions{MaxFee: dc("10")}, acc, giga.NewArrayUTXOSource(testUTXOs), lib)
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		if !txn.FeeAmount.GreaterThan(def.FeeAmount) {
+			t.Fatalf("expected a higher fee than the estimate: %v vs %v", txn.FeeAmount, def.FeeAmount)
+		}
+	})
+
+	t.Run("CreateTxn fee covers the signed size", func(t *testing.T) {
+		// signed transactions 30 bytes per input larger than the estimate.
+		padded := paddedL1{L1: lib, pad: 30}
+		payTo := []giga.PayTo{{Amount: dc("3"), PayTo: to_1}}
+		feeOpts := giga.FeeOptions{FeePerByte: dc("0.005"), MaxFee: dc("10")}
+		txn, _, _, _, err := giga.CreateTxn(payTo, nil, feeOpts, acc, giga.NewArrayUTXOSource(testUTXOs), padded)
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		sizeFee := dc("0.005").Mul(decimal.NewFromInt(int64(len(txn.TxnHex) / 2)))
+		if txn.FeeAmount.LessThan(sizeFee) {
+			t.Fatalf("fee does not cover the signed size: %v vs %v", txn.FeeAmount, sizeFee)
+		}
+		if !txn.TotalIn.Equals(txn.TotalOut.Add(txn.FeeAmount).Add(txn.ChangeAmount)) {
+			t.Fatalf("inputs do not match outputs plus fee: %v vs %v + %v + %v", txn.TotalIn, txn.TotalOut, txn.FeeAmount, txn.ChangeAmount)
+		}
+	})
+}
+
+// paddedL1 makes signed transactions larger than the size estimate
+// by padding the unlocking script of every input.
+type paddedL1 struct {
+	giga.L1
+	pad int
+}
+
+func (l paddedL1) MakeTransaction(inputs []giga.UTXO, outputs []giga.NewTxOut, fee giga.CoinAmount, change giga.Address, private_key giga.Privkey) (giga.NewTxn, error) {
+	txn, err := l.L1.MakeTransaction(inputs, outputs, fee, change, private_key)
+	if err != nil || private_key == "" {
+		return txn, err
+	}
+	txBytes, err := doge.HexDecode(txn.TxnHex)
+	if err != nil {
+		return txn, err
+	}
+	tx, err := doge.DecodeTx(txBytes, "")
+	if err != nil {
+		return txn, err
+	}
+	for n := range tx.VIn {
+		tx.VIn[n].Script = append(tx.VIn[n].Script, make([]byte, l.pad)...)
+	}
+	txn.TxnHex = doge.HexEncode(doge.EncodeTx(tx))
+	return txn, nil
+}
+
+func TestCoinSelection(t *testing.T) {
+	lib := newTestRig(t)
+	acc := makeAccount(t, "Selector", lib)
+
+	// Setup: UTXOs of different values and ages.
+	var testUTXOs []giga.UTXO
+	for vout, val := range []string{"1", "2", "3", "5", "8", "13", "21"} {
+		utxo := makeUTXO(t, vout, val, &acc, lib)
+		utxo.BlockHeight = int64(200 - vout) // larger UTXOs are older.
+		testUTXOs = append(testUTXOs, utxo)
+	}
+	to, _, err := acc.NextChangeAddress(lib)
+	if err != nil {
+		t.Fatalf("NextChangeAddress: %v", err)
+	}
+
+	createTxn := func(strategy string, amount string, utxos []giga.UTXO) (giga.NewTxn, []giga.UTXO) {
+		acc.CoinSelection = strategy
+		payTo := []giga.PayTo{{Amount: dc(amount), PayTo: to}}
+		source := giga.NewArrayUTXOSource(utxos)
+		txn, _, inputs, _, err := giga.CreateTxn(payTo, nil, giga.FeeOptions{MaxFee: giga.OneCoin}, acc, source, lib)
+		if err != nil {
+			t.Fatalf("CreateTxn (%s): %v", strategy, err)
+		}
+		return txn, inputs
+	}
+
+	t.Run("Default uses source order", func(t *testing.T) {
+		_, inputs := createTxn(giga.CoinSelectDefault, "4", testUTXOs)
+		if len(inputs) != 3 || inputs[0].VOut != 0 || inputs[2].VOut != 2 {
+			t.Fatalf("expected the first three UTXOs, got %v", inputs)
+		}
+	})
+
+	t.Run("Selectors do not modify taken", func(t *testing.T) {
+		for _, strategy := range []string{giga.CoinSelectDefault, giga.CoinSelectLargestFirst, giga.CoinSelectOldestFirst, giga.CoinSelectBranchAndBound, giga.CoinSelectPrivacy} {
+			selector, err := giga.NewCoinSelector(strategy)
+			if err != nil {
+				t.Fatalf("NewCoinSelector: %v", err)
+			}
+			taken := giga.NewUTXOSet()
+			utxos, err := selector.SelectUTXOs(giga.NewArrayUTXOSource(testUTXOs), taken, func(int) giga.CoinAmount { return dc("4") })
+			if err != nil || len(utxos) < 1 {
+				t.Fatalf("SelectUTXOs (%s): %v %v", strategy, utxos, err)
+			}
+			for _, utxo := range utxos {
+				if taken.Includes(utxo.TxID, utxo.VOut) {
+					t.Fatalf("SelectUTXOs (%s) added a selected UTXO to taken", strategy)
+				}
+			}
+		}
+	})
+
+	t.Run("Largest first", func(t *testing.T) {
+		_, inputs := createTxn(giga.CoinSelectLargestFirst, "4", testUTXOs)
+		if len(inputs) != 1 || !inputs[0].Value.Equals(dc("21")) {
+			t.Fatalf("expected the largest UTXO, got %v", inputs)
+		}
+	})
+
+	t.Run("Oldest first", func(t *testing.T) {
+		_, inputs := createTxn(giga.CoinSelectOldestFirst, "25", testUTXOs)
+		if len(inputs) != 2 || inputs[0].BlockHeight != 194 || inputs[1].BlockHeight != 195 {
+			t.Fatalf("expected the two oldest UTXOs, got %v", inputs)
+		}
+	})
+
+	t.Run("Branch and bound avoids change", func(t *testing.T) {
+		// 3 + 5 covers 7.76 plus the fee for 2 inputs, with less than the dust limit left over.
+		txn, inputs := createTxn(giga.CoinSelectBranchAndBound, "7.76", testUTXOs)
+		if len(inputs) != 2 || !sumValues(inputs).Equals(dc("8")) {
+			t.Fatalf("expected inputs 3 + 5, got %v", inputs)
+		}
+		if !txn.ChangeAmount.IsZero() {
+			t.Fatalf("expected no change, got %v", txn.ChangeAmount)
+		}
+		if !txn.FeeAmount.Equals(dc("0.24")) {
+			t.Fatalf("expected the excess to be added to the fee: %v", txn.FeeAmount)
+		}
+	})
+
+	t.Run("Privacy spends whole addresses", func(t *testing.T) {
+		// Two UTXOs paid to the same address, and one larger UTXO.
+		first := makeUTXO(t, 0, "4", &acc, lib)
+		second := first
+		second.VOut = 1
+		large := makeUTXO(t, 2, "10", &acc, lib)
+		utxos := []giga.UTXO{large, first, second}
+		_, inputs := createTxn(giga.CoinSelectPrivacy, "5", utxos)
+		if len(inputs) != 2 || inputs[0].ScriptAddress != first.ScriptAddress || inputs[1].ScriptAddress != first.ScriptAddress {
+			t.Fatalf("expected both UTXOs from the same address, got %v", inputs)
+		}
+	})
+
+	t.Run("Unknown strategy", func(t *testing.T) {
+		acc.CoinSelection = "random"
+		payTo := []giga.PayTo{{Amount: dc("1"), PayTo: to}}
+		_, _, _, _, err := giga.CreateTxn(payTo, nil, giga.FeeOptions{MaxFee: giga.OneCoin}, acc, giga.NewArrayUTXOSource(testUTXOs), lib)
+		if !giga.IsError(err, giga.BadRequest) {
+			t.Fatalf("expected BadRequest error, got %v", err)
+		}
+	})
+}
+
+func TestConsolidationTxn(t *testing.T) {
+	lib := newTestRig(t)
+	acc := makeAccount(t, "Consolidate", lib)
+
+	// Setup: generate 20 small UTXOs worth 0.5 doge each.
+	var testUTXOs []giga.UTXO
+	for vout := 0; vout < 20; vout++ {
+		testUTXOs = append(testUTXOs, makeUTXO(t, vout, "0.5", &acc, lib))
+	}
+	nextKey := acc.NextInternalKey
+
+	txn, payTo, utxo, err := giga.CreateConsolidationTxn(testUTXOs, giga.OneCoin, &acc, lib)
+	if err != nil {
+		t.Fatalf("CreateConsolidationTxn: %v", err)
+	}
+	if !txn.TotalIn.Equals(dc("10")) {
+		t.Fatalf("expected all UTXOs to be spent: %v", txn.TotalIn)
+	}
+	if !txn.ChangeAmount.IsZero() {
+		t.Fatalf("expected no change output: %v", txn.ChangeAmount)
+	}
+	if !utxo.Value.Equals(dc("10").Sub(txn.FeeAmount)) || !payTo[0].Amount.Equals(utxo.Value) {
+		t.Fatalf("expected the fee to be deducted from the output: %v fee %v", utxo.Value, txn.FeeAmount)
+	}
+	if !utxo.IsInternal || utxo.KeyIndex != nextKey || acc.NextInternalKey != nextKey+1 {
+		t.Fatalf("expected output to the next change address: %v", utxo)
+	}
+	if utxo.ScriptAddress != payTo[0].PayTo || utxo.VOut != 0 {
+		t.Fatalf("wrong consolidated UTXO: %v", utxo)
+	}
+}
+
+func TestSignShortSignatures(t *testing.T) {
+	// About 1% of DER signatures are shorter than 70 bytes (libdogecoin aborts
+	// on these) so sign until some inputs have short signatures.
+	lib := newTestRig(t)
+	short := 0
+	for i := 0; i < 100 && short == 0; i++ {
+		acc := makeAccount(t, "Signer", lib)
+		var testUTXOs []giga.UTXO
+		for vout := 0; vout < 20; vout++ {
+			testUTXOs = append(testUTXOs, makeUTXO(t, vout, "1", &acc, lib))
+		}
+		txn, err := lib.MakeTransaction(testUTXOs, []giga.NewTxOut{{ScriptType: doge.ScriptTypeP2PKH, Amount: dc("19"), ScriptAddress: testUTXOs[0].ScriptAddress}}, dc("1"), testUTXOs[0].ScriptAddress, acc.Privkey)
+		if err != nil {
+			t.Fatalf("MakeTransaction: %v", err)
+		}
+		tx, err := doge.DecodeTx(hexBytes(t, txn.TxnHex), "")
+		if err != nil {
+			t.Fatalf("DecodeTx: %v", err)
+		}
+		// Every input is signed: <sig+SIGHASH_ALL> <pubkey>
+		for n, in := range tx.VIn {
+			sigLen := int(in.Script[0])
+			der, pub := in.Script[1:sigLen], in.Script[sigLen+2:]
+			if in.Script[sigLen] != doge.SIGHASH_ALL || len(pub) != doge.ECPubKeyCompressedLen {
+				t.Fatalf("input %v: wrong unlocking script: %x", n, in.Script)
+			}
+			sig, err := ecdsa.ParseDERSignature(der)
+			if err != nil {
+				t.Fatalf("input %v: ParseDERSignature: %v", n, err)
+			}
+			key, err := secp256k1.ParsePubKey(pub)
+			if err != nil {
+				t.Fatalf("input %v: ParsePubKey: %v", n, err)
+			}
+			if !sig.Verify(doge.SignatureHash(tx, n, hexBytes(t, testUTXOs[n].ScriptHex)), key) {
+				t.Fatalf("input %v: invalid signature", n)
+			}
+			if len(der) < 70 {
+				short++
+			}
+		}
+	}
+	if short == 0 {
+		t.Fatalf("expected some short signatures")
+	}
+}
+
+func TestP2SHOutput(t *testing.T) {
+	lib := newTestRig(t)
+	acc := makeAccount(t, "Multisig", lib)
+
+	var testUTXOs []giga.UTXO
+	for vout := 0; vout < 3; vout++ {
+		testUTXOs = append(testUTXOs, makeUTXO(t, vout, "2", &acc, lib))
+	}
+	// P2SH address for the redeem script OP_TRUE.
+	p2sh := doge.ScriptToP2SH([]byte{0x51}, &doge.DogeTestNetChain)
+
+	t.Run("Pay to P2SH address", func(t *testing.T) {
+		payTo := []giga.PayTo{{Amount: dc("3"), PayTo: p2sh}}
+		txn, _, _, txid, err := giga.CreateTxn(payTo, nil, giga.FeeOptions{MaxFee: giga.OneCoin}, acc, giga.NewArrayUTXOSource(testUTXOs), lib)
+		if err != nil {
+			t.Fatalf("CreateTxn: %v", err)
+		}
+		txBytes, err := doge.HexDecode(txn.TxnHex)
+		if err != nil {
+			t.Fatalf("HexDecode: %v", err)
+		}
+		tx, err := doge.DecodeTx(txBytes, txid)
+		if err != nil {
+			t.Fatalf("DecodeTx: %v", err)
+		}
+		found := false
+		for _, out := range tx.VOut {
+			scriptType, addr := doge.ClassifyScript(out.Script, &doge.DogeTestNetChain)
+			if scriptType == doge.ScriptTypeP2SH && addr == p2sh {
+				found = true
+			}
+		}
+		if !found {
+			t.Fatalf("expected a P2SH output paying to %v", p2sh)
+		}
+	})
+
+	t.Run("Reject P2SH address on another chain", func(t *testing.T) {
+		mainnet := doge.ScriptToP2SH([]byte{0x51}, &doge.DogeMainNetChain)
+		payTo := []giga.PayTo{{Amount: dc("1"), PayTo: mainnet}}
+		_, _, _, _, err := giga.CreateTxn(payTo, nil, giga.FeeOptions{MaxFee: giga.OneCoin}, acc, giga.NewArrayUTXOSource(testUTXOs), lib)
+		if !giga.IsError(err, giga.InvalidTxn) {
+			t.Fatalf("expected InvalidTxn error, got %v", err)
+		}
+	})
+}
+
+func TestMemoOutput(t *testing.T) {
+	lib := newTestRig(t)
+	acc := makeAccount(t, "Memo", lib)
+
+	var testUTXOs []giga.UTXO
+	for vout := 0; vout < 3; vout++ {
+		testUTXOs = append(testUTXOs, makeUTXO(t, vout, "2", &acc, lib))
+	}
+	to, _, err := acc.NextChangeAddress(lib)
+	if err != nil {
+		t.Fatalf("NextChangeAddress: %v", err)
+	}
+	payTo := []giga.PayTo{{Amount: dc("3"), PayTo: to}}
+
+	t.Run("Pay with memo", func(t *testing.T) {
+		memo := []byte("settlement ref: INV-0001")
+		txn, change, _, txid, err := giga.CreateTxn(payTo, memo, giga.FeeOptions{MaxFee: giga.OneCoin}, acc, giga.NewArrayUTXOSource(testUTXOs), lib)
+		if err != nil {
+			t.Fatalf("CreateTxn: %v", err)
+		}
+		if string(txn.Memo) != string(memo) {
+			t.Fatalf("wrong memo decoded from the transaction: %q", txn.Memo)
+		}
+		txBytes, err := doge.HexDecode(txn.TxnHex)
+		if err != nil {
+			t.Fatalf("HexDecode: %v", err)
+		}
+		tx, err := doge.DecodeTx(txBytes, txid)
+		if err != nil {
+			t.Fatalf("DecodeTx: %v", err)
+		}
+		if len(tx.VOut) != 3 {
+			t.Fatalf("expected payment, memo and change outputs: %v", len(tx.VOut))
+		}
+		if scriptType, _ := doge.ClassifyScript(tx.VOut[1].Script, &doge.DogeTestNetChain); scriptType != doge.ScriptTypeNullData || tx.VOut[1].Value != 0 {
+			t.Fatalf("expected a zero-value OP_RETURN output: %v %v", scriptType, tx.VOut[1].Value)
+		}
+		if change.VOut != 2 || change.TxID != txid {
+			t.Fatalf("wrong change UTXO: %v", change)
+		}
+		// The fee covers the memo output.
+		plain, _, _, _, err := giga.CreateTxn(payTo, nil, giga.FeeOptions{MaxFee: giga.OneCoin}, acc, giga.NewArrayUTXOSource(testUTXOs), lib)
+		if err != nil {
+			t.Fatalf("CreateTxn: %v", err)
+		}
+		if !txn.FeeAmount.GreaterThan(plain.FeeAmount) {
+			t.Fatalf("expected a larger fee with a memo: %v vs %v", txn.FeeAmount, plain.FeeAmount)
+		}
+	})
+
+	t.Run("Memo too large", func(t *testing.T) {
+		memo := make([]byte, doge.MaxNullDataSize+1)
+		_, _, _, _, err := giga.CreateTxn(payTo, memo, giga.FeeOptions{MaxFee: giga.OneCoin}, acc, giga.NewArrayUTXOSource(testUTXOs), lib)
+		if !giga.IsError(err, giga.InvalidTxn) {
+			t.Fatalf("expected InvalidTxn error, got %v", err)
+		}
+	})
+}
+
+func TestLockedTxn(t *testing.T) {
+	lib := newTestRig(t)
+	acc := makeAccount(t, "Locked", lib)
+
+	var testUTXOs []giga.UTXO
+	for vout := 0; vout < 3; vout++ {
+		testUTXOs = append(testUTXOs, makeUTXO(t, vout, "2", &acc, lib))
+	}
+	to, _, err := acc.NextChangeAddress(lib)
+	if err != nil {
+		t.Fatalf("NextChangeAddress: %v", err)
+	}
+	payTo := []giga.PayTo{{Amount: dc("3"), PayTo: to}}
+
+	t.Run("Locked until a block height", func(t *testing.T) {
+		txn, change, inputs, txid, err := giga.CreateLockedTxn(payTo, nil, giga.FeeOptions{MaxFee: giga.OneCoin}, 5000000, acc, giga.NewArrayUTXOSource(testUTXOs), lib)
+		if err != nil {
+			t.Fatalf("CreateLockedTxn: %v", err)
+		}
+		txBytes, err := doge.HexDecode(txn.TxnHex)
+		if err != nil {
+			t.Fatalf("HexDecode: %v", err)
+		}
+		if doge.TxHashHex(txBytes) != txid || change.TxID != txid {
+			t.Fatalf("txid does not match the locked transaction: %v %v", txid, change.TxID)
+		}
+		tx, err := doge.DecodeTx(txBytes, txid)
+		if err != nil {
+			t.Fatalf("DecodeTx: %v", err)
+		}
+		if tx.LockTime != 5000000 {
+			t.Fatalf("wrong lock time: %v", tx.LockTime)
+		}
+		for n, in := range tx.VIn {
+			if in.Sequence == 0xffffffff || len(in.Script) == 0 {
+				t.Fatalf("input %v: expected a signed, non-final input: %x %v", n, in.Script, in.Sequence)
+			}
+		}
+		if len(inputs) != len(tx.VIn) {
+			t.Fatalf("wrong number of inputs: %v vs %v", len(inputs), len(tx.VIn))
+		}
+	})
+
+	t.Run("Zero lock time", func(t *testing.T) {
+		_, _, _, _, err := giga.CreateLockedTxn(payTo, nil, giga.FeeOptions{}, 0, acc, giga.NewArrayUTXOSource(testUTXOs), lib)
+		if !giga.IsError(err, giga.InvalidTxn) {
+			t.Fatalf("expected InvalidTxn for zero lock time: %v", err)
+		}
+	})
+}
+
+func TestOfflineSigning(t *testing.T) {
+	lib := newTestRig(t)
+	acc := makeAccount(t, "Offline", lib)
+	acc.OfflineSigning = true
+
+	var testUTXOs []giga.UTXO
+	for vout := 0; vout < 3; vout++ {
+		testUTXOs = append(testUTXOs, makeUTXO(t, vout, "2", &acc, lib))
+	}
+	to, _, err := acc.NextChangeAddress(lib)
+	if err != nil {
+		t.Fatalf("NextChangeAddress: %v", err)
+	}
+	payTo := []giga.PayTo{{Amount: dc("3"), PayTo: to}}
+
+	txn, change, inputs, txid, err := giga.CreateTxn(payTo, nil, giga.FeeOptions{MaxFee: giga.OneCoin}, acc, giga.NewArrayUTXOSource(testUTXOs), lib)
+	if err != nil {
+		t.Fatalf("CreateTxn: %v", err)
+	}
+	unsigned := giga.NewUnsignedTxn(1, acc.Address, txn.TxnHex, inputs, change)
+	if len(unsigned.Inputs) != 2 || unsigned.Change == nil {
+		t.Fatalf("expected 2 inputs and change: %v", unsigned)
+	}
+
+	t.Run("Unsigned transaction is not accepted", func(t *testing.T) {
+		_, err := unsigned.VerifySigned(txn.TxnHex)
+		if !giga.IsError(err, giga.InvalidTxn) {
+			t.Fatalf("expected InvalidTxn error, got %v", err)
+		}
+	})
+
+	t.Run("Sign offline and verify", func(t *testing.T) {
+		signed, err := lib.SignTransaction(unsigned.TxnHex, unsigned.InputUTXOs(), acc.Privkey)
+		if err != nil {
+			t.Fatalf("SignTransaction: %v", err)
+		}
+		signedID, err := unsigned.VerifySigned(signed)
+		if err != nil {
+			t.Fatalf("VerifySigned: %v", err)
+		}
+		if signedID == txid {
+			t.Fatalf("expected the signed txid to differ from the unsigned txid")
+		}
+		// Same as signing online.
+		online := acc
+		online.OfflineSigning = false
+		onlineTxn, _, _, onlineID, err := giga.CreateTxn(payTo, nil, giga.FeeOptions{MaxFee: giga.OneCoin}, online, giga.NewArrayUTXOSource(testUTXOs), lib)
+		if err != nil {
+			t.Fatalf("CreateTxn: %v", err)
+		}
+		if signed != onlineTxn.TxnHex || signedID != onlineID {
+			t.Fatalf("offline signed transaction differs from online signed transaction")
+		}
+		utxo, ok := unsigned.ChangeUTXO(signedID)
+		if !ok || utxo.TxID != signedID || !utxo.Value.Equals(change.Value) || !utxo.IsInternal {
+			t.Fatalf("wrong change UTXO: %v", utxo)
+		}
+	})
+
+	t.Run("Reject a different transaction", func(t *testing.T) {
+		other := []giga.PayTo{{Amount: dc("3.5"), PayTo: to}}
+		acc := acc
+		acc.OfflineSigning = false
+		otherTxn, _, _, _, err := giga.CreateTxn(other, nil, giga.FeeOptions{MaxFee: giga.OneCoin}, acc, giga.NewArrayUTXOSource(testUTXOs), lib)
+		if err != nil {
+			t.Fatalf("CreateTxn: %v", err)
+		}
+		_, err = unsigned.VerifySigned(otherTxn.TxnHex)
+		if !giga.IsError(err, giga.InvalidTxn) {
+			t.Fatalf("expected InvalidTxn error, got %v", err)
+		}
+	})
+}
+
+func TestSweepTxn(t *testing.T) {
+	lib := newTestRig(t)
+	acc := makeAccount(t, "Sweep", lib)
+	to, _, err := acc.NextPayToAddress(lib)
+	if err != nil {
+		t.Fatalf("NextPayToAddress: %v", err)
+	}
+
+	// Fixed external key (e.g. a paper wallet) holding 3 UTXOs.
+	wif := doge.EncodeECPrivKeyWIF(hexBytes(t, "0c28fca386c7a227600b2fe50b7cae11ec86d3bf1fbe471be89827e19d72aa1d"), &doge.DogeTestNetChain)
+	from, err := doge.GenerateP2PKHFromECPrivKeyWIF(wif)
+	if err != nil {
+		t.Fatalf("GenerateP2PKHFromECPrivKeyWIF: %v", err)
+	}
+	var utxos []giga.UTXO
+	for vout := 0; vout < 3; vout++ {
+		utxos = append(utxos, giga.UTXO{
+			TxID:          "3f8e64a8453377def77868188811c2c7ed25fb31a16957e0001e28774d6d0208",
+			VOut:          vout,
+			Value:         dc("2"),
+			ScriptHex:     testutil.P2PKHScriptHex(t, from),
+			ScriptType:    doge.ScriptTypeP2PKH,
+			ScriptAddress: from,
+		})
+	}
+
+	t.Run("Sweep all UTXOs", func(t *testing.T) {
+		txn, txid, err := giga.CreateSweepTxn(utxos, wif, to, giga.FeeOptions{}, lib)
+		if err != nil {
+			t.Fatalf("CreateSweepTxn: %v", err)
+		}
+		txBytes, err := doge.HexDecode(txn.TxnHex)
+		if err != nil {
+			t.Fatalf("HexDecode: %v", err)
+		}
+		tx, err := doge.DecodeTx(txBytes, txid)
+		if err != nil {
+			t.Fatalf("DecodeTx: %v", err)
+		}
+		if len(tx.VIn) != 3 || len(tx.VOut) != 1 {
+			t.Fatalf("expected 3 inputs and a single output: %v %v", len(tx.VIn), len(tx.VOut))
+		}
+		for n, in := range tx.VIn {
+			if len(in.Script) == 0 {
+				t.Fatalf("input %v is not signed", n)
+			}
+		}
+		if !txn.TotalIn.Equals(dc("6")) || !txn.TotalOut.Add(txn.FeeAmount).Equals(dc("6")) || !txn.FeeAmount.IsPositive() {
+			t.Fatalf("expected the fee deducted from the swept amount: %v %v %v", txn.TotalIn, txn.TotalOut, txn.FeeAmount)
+		}
+		if stype, addr := doge.ClassifyScript(tx.VOut[0].Script, &doge.DogeTestNetChain); stype != doge.ScriptTypeP2PKH || addr != to {
+			t.Fatalf("expected output paid to the account: %v %v", stype, addr)
+		}
+	})
+
+	t.Run("Reject UTXOs for another key", func(t *testing.T) {
+		other := makeUTXO(t, 0, "2", &acc, lib)
+		_, _, err := giga.CreateSweepTxn([]giga.UTXO{other}, wif, to, giga.FeeOptions{}, lib)
+		if !giga.IsError(err, giga.InvalidTxn) {
+			t.Fatalf("expected InvalidTxn error, got %v", err)
+		}
+	})
+
+	t.Run("Nothing to sweep", func(t *testing.T) {
+		_, _, err := giga.CreateSweepTxn(nil, wif, to, giga.FeeOptions{}, lib)
+		if !giga.IsError(err, giga.InsufficientFunds) {
+			t.Fatalf("expected InsufficientFunds error, got %v", err)
+		}
+	})
+}
+
+func sumValues(utxos []giga.UTXO) decimal.Decimal {
+	total := decimal.Zero
+	for _, utxo := range utxos {
+		total = total.Add(utxo.Value)
+	}
+	return total
 }
 
 func dc(val string) decimal.Decimal {
@@ -95,7 +657,7 @@ func makeUTXO(t *testing.T, vout int, val string, acc *giga.Account, lib giga.L1
 		TxID:          "3f8e64a8453377def77868188811c2c7ed25fb31a16957e0001e28774d6d0208",
 		VOut:          vout,
 		Value:         dc(val),
-		ScriptHex:     p2pkhScriptHex(t, payTo),
+		ScriptHex:     testutil.P2PKHScriptHex(t, payTo),
 		ScriptType:    doge.ScriptTypeP2PKH,
 		ScriptAddress: payTo,
 		AccountID:     acc.Address,
@@ -105,14 +667,10 @@ func makeUTXO(t *testing.T, vout int, val string, acc *giga.Account, lib giga.L1
 	}
 }
 
-func p2pkhScriptHex(t *testing.T, addr giga.Address) string {
-	payload, err := doge.Base58DecodeCheck(string(addr))
+func hexBytes(t *testing.T, hex string) []byte {
+	b, err := doge.HexDecode(hex)
 	if err != nil {
-		t.Fatalf("Base58DecodeCheck: %v", err)
-	}
-	hash := doge.HexEncode(payload[1:]) // skip "version" byte.
-	if len(hash) != 0x14*2 {
-		t.Fatalf("wrong hash len: %v (need 20 bytes)", len(hash))
+		t.Fatalf("HexDecode: %v", err)
 	}
-	return "76a914" + hash + "88ac"
+	return b
 }
@@ -13,6 +13,7 @@ import (
 const addr1 giga.Address = "DHxxxxxxxxxxxxxxxxxxxxxxxxxxxxxx1L"
 const addr2 giga.Address = "DHxxxxxxxxxxxxxxxxxxxxxxxxxxxxxx2L"
 const addr3 giga.Address = "DHxxxxxxxxxxxxxxxxxxxxxxxxxxxxxx3L"
+const addr4 giga.Address = "DHxxxxxxxxxxxxxxxxxxxxxxxxxxxxxx4L"
 
 var pi giga.CoinAmount = decimal.RequireFromString("3.14159")
 
@@ -73,6 +74,10 @@ func TestStore(t *testing.T) {
 
 			updatedAccount := retrievedAccount
 			updatedAccount.PayoutAddress = addr2
+			updatedAccount.OfflineSigning = true
+			updatedAccount.ApprovalsRequired = 2
+			updatedAccount.ApprovalThreshold = decimal.NewFromInt(100)
+			updatedAccount.ApprovedAddresses = []giga.Address{addr2, addr3}
 			err = tx.UpdateAccount(updatedAccount)
 			if err != nil {
 				t.Fatal(n("UpdateAccount"), err)
@@ -89,9 +94,13 @@ func TestStore(t *testing.T) {
 				t.Fatal(n("GetAccount"), err)
 			}
 
-			if retrievedAccount.PayoutAddress != addr2 {
+			if retrievedAccount.PayoutAddress != addr2 || !retrievedAccount.OfflineSigning {
 				t.Fatal(n("verify updateAccount failed"), retrievedAccount)
 			}
+			if retrievedAccount.ApprovalsRequired != 2 || !retrievedAccount.ApprovalThreshold.Equals(decimal.NewFromInt(100)) ||
+				len(retrievedAccount.ApprovedAddresses) != 2 || retrievedAccount.ApprovedAddresses[1] != addr3 {
+				t.Fatal(n("verify updateAccount approval policy failed"), retrievedAccount)
+			}
 
 		})
 
@@ -216,7 +225,7 @@ func TestStore(t *testing.T) {
 					DeductFeePercent: decimal.NewFromInt(100),
 				},
 			}
-			pay, err := tx.CreatePayment(addr1, payTo, decimal.NewFromInt(100), decimal.NewFromInt(1))
+			pay, err := tx.CreatePayment(addr1, giga.PaymentTypePayout, payTo, "INV-0001", decimal.NewFromInt(100), decimal.NewFromInt(1))
 			if err != nil {
 				t.Fatal(n("CreatePayment"), err)
 			}
@@ -229,6 +238,12 @@ func TestStore(t *testing.T) {
 			if retrievedPayment.AccountAddress != addr1 || len(retrievedPayment.PayTo) != 1 {
 				t.Fatal(n("GetPayment: wrong payment address or len"))
 			}
+			if retrievedPayment.Type != giga.PaymentTypePayout {
+				t.Fatal(n("GetPayment: wrong payment type"), retrievedPayment.Type)
+			}
+			if retrievedPayment.Memo != "INV-0001" {
+				t.Fatal(n("GetPayment: wrong payment memo"), retrievedPayment.Memo)
+			}
 			if !retrievedPayment.Total.Equals(decimal.NewFromInt(100)) || !retrievedPayment.Fee.Equals(decimal.NewFromInt(1)) {
 				t.Fatal(n("GetPayment: wrong payment values"))
 			}
@@ -237,6 +252,131 @@ func TestStore(t *testing.T) {
 				t.Fatal(n("GetPayment: wrong PayTo details"))
 			}
 
+			// Test SetPaymentUnsignedTxn
+			err = tx.SetPaymentUnsignedTxn(pay.ID, `{"payment_id":1}`)
+			if err != nil {
+				t.Fatal(n("SetPaymentUnsignedTxn"), err)
+			}
+			retrievedPayment, err = tx.GetPayment(addr1, pay.ID)
+			if err != nil {
+				t.Fatal(n("GetPayment"), err)
+			}
+			if retrievedPayment.UnsignedTxn != `{"payment_id":1}` {
+				t.Fatal(n("GetPayment: wrong unsigned transaction"), retrievedPayment.UnsignedTxn)
+			}
+
+			// Test SetPaymentPendingTxn, UpdatePaymentApprovalStatus
+			err = tx.SetPaymentPendingTxn(pay.ID, `{"txid":"abc"}`)
+			if err != nil {
+				t.Fatal(n("SetPaymentPendingTxn"), err)
+			}
+			err = tx.UpdatePaymentApprovalStatus(pay.ID, giga.ApprovalPending)
+			if err != nil {
+				t.Fatal(n("UpdatePaymentApprovalStatus"), err)
+			}
+			retrievedPayment, err = tx.GetPayment(addr1, pay.ID)
+			if err != nil {
+				t.Fatal(n("GetPayment"), err)
+			}
+			if retrievedPayment.PendingTxn != `{"txid":"abc"}` || retrievedPayment.ApprovalStatus != giga.ApprovalPending {
+				t.Fatal(n("GetPayment: wrong pending transaction or approval status"), retrievedPayment)
+			}
+
+			// Test AddPaymentApproval, ListPaymentApprovals
+			err = tx.AddPaymentApproval(giga.PaymentApproval{PaymentID: pay.ID, Approver: "alice", Approved: true, Created: time.Now()})
+			if err != nil {
+				t.Fatal(n("AddPaymentApproval"), err)
+			}
+			err = tx.AddPaymentApproval(giga.PaymentApproval{PaymentID: pay.ID, Approver: "alice", Approved: false, Created: time.Now()})
+			if !giga.IsAlreadyExistsError(err) {
+				t.Fatal(n("AddPaymentApproval: expected AlreadyExists for the same approver"), err)
+			}
+			approvals, err := tx.ListPaymentApprovals(pay.ID)
+			if err != nil {
+				t.Fatal(n("ListPaymentApprovals"), err)
+			}
+			if len(approvals) != 1 || approvals[0].Approver != "alice" || !approvals[0].Approved {
+				t.Fatal(n("ListPaymentApprovals: wrong approvals"), approvals)
+			}
+
+			// Test MarkUTXOReserved: a UTXO can only be reserved by one payment
+			err = tx.CreateUTXO(giga.UTXO{TxID: "a5e1", VOut: 0, Value: decimal.NewFromInt(5), ScriptHex: "76a9", ScriptType: "p2pkh",
+				ScriptAddress: addr2, AccountID: addr1, BlockHeight: 90})
+			if err != nil {
+				t.Fatal(n("CreateUTXO"), err)
+			}
+			err = tx.MarkUTXOReserved("a5e1", 0, pay.ID)
+			if err != nil {
+				t.Fatal(n("MarkUTXOReserved"), err)
+			}
+			err = tx.MarkUTXOReserved("a5e1", 0, pay.ID+1)
+			if !giga.IsDBConflictError(err) {
+				t.Fatal(n("MarkUTXOReserved: expected DBConflict for a reserved UTXO"), err)
+			}
+			err = tx.MarkUTXOReserved("a5e1", 1, pay.ID)
+			if !giga.IsDBConflictError(err) {
+				t.Fatal(n("MarkUTXOReserved: expected DBConflict for an unknown UTXO"), err)
+			}
+
+			// Test MarkPaymentEventSent through on-chain, confirmed and a rollback
+			err = tx.UpdatePaymentWithTxID(pay.ID, "e0f1")
+			if err != nil {
+				t.Fatal(n("UpdatePaymentWithTxID"), err)
+			}
+			accounts, err := tx.MarkPaymentsOnChain([]string{"e0f1"}, 100)
+			if err != nil || len(accounts) != 1 {
+				t.Fatal(n("MarkPaymentsOnChain"), accounts, err)
+			}
+			err = tx.MarkPaymentEventSent(pay.ID, giga.PAYMENT_ON_CHAIN)
+			if err != nil {
+				t.Fatal(n("MarkPaymentEventSent"), err)
+			}
+			_, err = tx.ConfirmPayments(5, 105)
+			if err != nil {
+				t.Fatal(n("ConfirmPayments"), err)
+			}
+			err = tx.MarkPaymentEventSent(pay.ID, giga.PAYMENT_CONFIRMED)
+			if err != nil {
+				t.Fatal(n("MarkPaymentEventSent"), err)
+			}
+			retrievedPayment, err = tx.GetPayment(addr1, pay.ID)
+			if err != nil {
+				t.Fatal(n("GetPayment"), err)
+			}
+			if retrievedPayment.PaidHeight != 100 || retrievedPayment.ConfirmedHeight != 105 || retrievedPayment.OnChainEvent.IsZero() || retrievedPayment.ConfirmedEvent.IsZero() {
+				t.Fatal(n("GetPayment: expected on-chain and confirmed events"), retrievedPayment)
+			}
+			_, err = tx.RevertChangesAboveHeight(102, 1, 0, true)
+			if err != nil {
+				t.Fatal(n("RevertChangesAboveHeight"), err)
+			}
+			retrievedPayment, err = tx.GetPayment(addr1, pay.ID)
+			if err != nil {
+				t.Fatal(n("GetPayment"), err)
+			}
+			if retrievedPayment.PaidHeight != 100 || retrievedPayment.ConfirmedHeight != 105 {
+				t.Fatal(n("RevertChangesAboveHeight: expected keepPayments to keep the chain-heights"), retrievedPayment)
+			}
+			_, err = tx.RevertChangesAboveHeight(102, 1, 0, false)
+			if err != nil {
+				t.Fatal(n("RevertChangesAboveHeight"), err)
+			}
+			err = tx.MarkPaymentEventSent(pay.ID, giga.PAYMENT_UNCONFIRMED)
+			if err != nil {
+				t.Fatal(n("MarkPaymentEventSent"), err)
+			}
+			retrievedPayment, err = tx.GetPayment(addr1, pay.ID)
+			if err != nil {
+				t.Fatal(n("GetPayment"), err)
+			}
+			if retrievedPayment.OnChainEvent.IsZero() || !retrievedPayment.ConfirmedEvent.IsZero() || retrievedPayment.UnconfirmedEvent.IsZero() {
+				t.Fatal(n("GetPayment: expected only the confirmed event to be rolled back"), retrievedPayment)
+			}
+			err = tx.MarkPaymentEventSent(pay.ID, giga.PAYMENT_SENT)
+			if err == nil {
+				t.Fatal(n("MarkPaymentEventSent: expected an error for an unsupported event"))
+			}
+
 			// Test ListPayments
 			payments, counter, err := tx.ListPayments(addr1, 0, 10)
 			if err != nil {
@@ -257,7 +397,7 @@ func TestStore(t *testing.T) {
 						DeductFeePercent: decimal.Zero,
 					},
 				}
-				_, err := tx.CreatePayment(addr1, payTo, decimal.NewFromInt(100), decimal.NewFromInt(1))
+				_, err := tx.CreatePayment(addr1, giga.PaymentTypePayout, payTo, "", decimal.NewFromInt(100), decimal.NewFromInt(1))
 				if err != nil {
 					t.Fatal(n("CreatePayment"), err)
 				}
@@ -301,5 +441,394 @@ func TestStore(t *testing.T) {
 			}
 		})
 
+		t.Run(n("DoubleSpend"), func(t *testing.T) {
+			tx, err := store.Begin()
+			if err != nil {
+				t.Fatal(n("establish transaction"), err)
+			}
+
+			// An invoice paid by a confirmed UTXO, and a confirmed payment.
+			err = tx.StoreInvoice(giga.Invoice{ID: addr4, Account: addr1, Created: time.Now(), Confirmations: 6,
+				Items: []giga.Item{{Type: "item", Name: "bar", Value: pi, Quantity: 1}}})
+			if err != nil {
+				t.Fatal(n("StoreInvoice"), err)
+			}
+			err = tx.CreateUTXO(giga.UTXO{TxID: "d5a1", VOut: 0, Value: pi, ScriptHex: "76a9", ScriptType: "p2pkh",
+				ScriptAddress: addr4, AccountID: addr1, BlockHeight: 200})
+			if err != nil {
+				t.Fatal(n("CreateUTXO"), err)
+			}
+			_, err = tx.ConfirmUTXOs(6, 206)
+			if err != nil {
+				t.Fatal(n("ConfirmUTXOs"), err)
+			}
+			pay, err := tx.CreatePayment(addr1, giga.PaymentTypePayout, []giga.PayTo{{Amount: pi, PayTo: addr2}}, "", pi, decimal.NewFromInt(1))
+			if err != nil {
+				t.Fatal(n("CreatePayment"), err)
+			}
+			err = tx.UpdatePaymentWithTxID(pay.ID, "d5a2")
+			if err != nil {
+				t.Fatal(n("UpdatePaymentWithTxID"), err)
+			}
+			_, err = tx.MarkPaymentsOnChain([]string{"d5a2"}, 201)
+			if err != nil {
+				t.Fatal(n("MarkPaymentsOnChain"), err)
+			}
+			_, err = tx.ConfirmPayments(5, 206)
+			if err != nil {
+				t.Fatal(n("ConfirmPayments"), err)
+			}
+
+			// Test RevertChangesAboveHeight, ListReorgedTxIDs (reorg back to 199)
+			_, err = tx.RevertChangesAboveHeight(199, 1, 201, false)
+			if err != nil {
+				t.Fatal(n("RevertChangesAboveHeight"), err)
+			}
+			txIDs, err := tx.ListReorgedTxIDs(199)
+			if err != nil {
+				t.Fatal(n("ListReorgedTxIDs"), err)
+			}
+			if len(txIDs) != 2 {
+				t.Fatal(n("ListReorgedTxIDs: expected the UTXO and payment txids"), txIDs)
+			}
+
+			// Test MarkDoubleSpends: not re-mined within 12 blocks of the fork-point.
+			found, err := tx.MarkDoubleSpends(12, 210)
+			if err != nil {
+				t.Fatal(n("MarkDoubleSpends"), err)
+			}
+			if len(found) != 0 {
+				t.Fatal(n("MarkDoubleSpends: expected no double-spends before RejectionsNeeded"), found)
+			}
+			found, err = tx.MarkDoubleSpends(12, 211)
+			if err != nil {
+				t.Fatal(n("MarkDoubleSpends"), err)
+			}
+			if len(found) != 2 || found[0].InvoiceID != addr4 || len(found[0].TxIDs) != 1 || found[0].TxIDs[0] != "d5a1" ||
+				found[1].PaymentID != pay.ID || found[1].TxIDs[0] != "d5a2" || found[1].ForkHeight != 199 {
+				t.Fatal(n("MarkDoubleSpends: wrong double-spends"), found)
+			}
+			inv, err := tx.GetInvoice(addr4)
+			if err != nil {
+				t.Fatal(n("GetInvoice"), err)
+			}
+			retrievedPayment, err := tx.GetPayment(addr1, pay.ID)
+			if err != nil {
+				t.Fatal(n("GetPayment"), err)
+			}
+			if inv.DoubleSpendHeight != 211 || retrievedPayment.DoubleSpendHeight != 211 {
+				t.Fatal(n("MarkDoubleSpends: invoice and payment not flagged"), inv.DoubleSpendHeight, retrievedPayment.DoubleSpendHeight)
+			}
+			found, err = tx.MarkDoubleSpends(12, 212)
+			if err != nil || len(found) != 0 {
+				t.Fatal(n("MarkDoubleSpends: expected double-spends to be flagged once"), found, err)
+			}
+
+			// Test CreateUTXO clears the invoice flag when the UTXO is re-mined.
+			err = tx.CreateUTXO(giga.UTXO{TxID: "d5a1", VOut: 0, Value: pi, ScriptHex: "76a9", ScriptType: "p2pkh",
+				ScriptAddress: addr4, AccountID: addr1, BlockHeight: 212})
+			if err != nil {
+				t.Fatal(n("CreateUTXO"), err)
+			}
+			inv, err = tx.GetInvoice(addr4)
+			if err != nil {
+				t.Fatal(n("GetInvoice"), err)
+			}
+			if inv.DoubleSpendHeight != 0 {
+				t.Fatal(n("CreateUTXO: invoice still flagged after re-mining"), inv.DoubleSpendHeight)
+			}
+
+			// Test a rollback that is not a reorg (reorgFromHeight 0) never flags double-spends.
+			_, err = tx.ConfirmUTXOs(1, 213)
+			if err != nil {
+				t.Fatal(n("ConfirmUTXOs"), err)
+			}
+			_, err = tx.RevertChangesAboveHeight(205, 1, 0, false)
+			if err != nil {
+				t.Fatal(n("RevertChangesAboveHeight"), err)
+			}
+			found, err = tx.MarkDoubleSpends(12, 230)
+			if err != nil || len(found) != 0 {
+				t.Fatal(n("MarkDoubleSpends: expected no double-spends after a non-reorg rollback"), found, err)
+			}
+
+			err = tx.Commit()
+			if err != nil {
+				t.Fatal(n("commit transaction"), err)
+			}
+		})
+
+		t.Run(n("Withdrawal"), func(t *testing.T) {
+			tx, err := store.Begin()
+			if err != nil {
+				t.Fatal(n("establish transaction"), err)
+			}
+
+			// Test Withdrawal creation
+			wd1, err := tx.CreateWithdrawal(addr1, addr2, decimal.NewFromInt(5))
+			if err != nil {
+				t.Fatal(n("CreateWithdrawal"), err)
+			}
+			wd2, err := tx.CreateWithdrawal(addr1, addr3, pi)
+			if err != nil {
+				t.Fatal(n("CreateWithdrawal"), err)
+			}
+			if wd1.Status != giga.WithdrawalPending || wd2.ID <= wd1.ID {
+				t.Fatal(n("CreateWithdrawal: wrong status or ID"), wd1, wd2)
+			}
+
+			// Test GetWithdrawal
+			retrieved, err := tx.GetWithdrawal(addr1, wd2.ID)
+			if err != nil {
+				t.Fatal(n("GetWithdrawal"), err)
+			}
+			if retrieved.PayTo != addr3 || !retrieved.Amount.Equals(pi) || retrieved.Status != giga.WithdrawalPending {
+				t.Fatal(n("GetWithdrawal: wrong withdrawal details"), retrieved)
+			}
+			_, err = tx.GetWithdrawal(addr2, wd2.ID)
+			if !giga.IsNotFoundError(err) {
+				t.Fatal(n("GetWithdrawal: expected NotFound for another account"), err)
+			}
+
+			// Test ListPendingWithdrawalAccounts
+			accounts, err := tx.ListPendingWithdrawalAccounts()
+			if err != nil {
+				t.Fatal(n("ListPendingWithdrawalAccounts"), err)
+			}
+			if len(accounts) != 1 || accounts[0] != addr1 {
+				t.Fatal(n("ListPendingWithdrawalAccounts: wrong accounts"), accounts)
+			}
+
+			// Test ListPendingWithdrawals (oldest first, limit)
+			pending, err := tx.ListPendingWithdrawals(addr1, 1)
+			if err != nil {
+				t.Fatal(n("ListPendingWithdrawals"), err)
+			}
+			if len(pending) != 1 || pending[0].ID != wd1.ID {
+				t.Fatal(n("ListPendingWithdrawals: expected the oldest withdrawal"), pending)
+			}
+
+			// Test ListBatchedWithdrawals
+			err = tx.UpdateWithdrawalStatus([]int64{wd1.ID}, giga.WithdrawalBatched, 7, "", "")
+			if err != nil {
+				t.Fatal(n("UpdateWithdrawalStatus"), err)
+			}
+			batched, err := tx.ListBatchedWithdrawals(10)
+			if err != nil {
+				t.Fatal(n("ListBatchedWithdrawals"), err)
+			}
+			if len(batched) != 1 || batched[0].ID != wd1.ID || batched[0].AccountID != addr1 || batched[0].PaymentID != 7 {
+				t.Fatal(n("ListBatchedWithdrawals: expected the batched withdrawal"), batched)
+			}
+
+			// Test UpdateWithdrawalStatus
+			err = tx.UpdateWithdrawalStatus([]int64{wd1.ID, wd2.ID}, giga.WithdrawalSent, 7, "abc123", "")
+			if err != nil {
+				t.Fatal(n("UpdateWithdrawalStatus"), err)
+			}
+			retrieved, err = tx.GetWithdrawal(addr1, wd1.ID)
+			if err != nil {
+				t.Fatal(n("GetWithdrawal"), err)
+			}
+			if retrieved.Status != giga.WithdrawalSent || retrieved.PaymentID != 7 || retrieved.TxID != "abc123" {
+				t.Fatal(n("UpdateWithdrawalStatus: wrong withdrawal status"), retrieved)
+			}
+			pending, err = tx.ListPendingWithdrawals(addr1, 10)
+			if err != nil {
+				t.Fatal(n("ListPendingWithdrawals"), err)
+			}
+			if len(pending) != 0 {
+				t.Fatal(n("ListPendingWithdrawals: expected no pending withdrawals"), pending)
+			}
+
+			err = tx.Commit()
+			if err != nil {
+				t.Fatal(n("commit transaction"), err)
+			}
+		})
+
+		t.Run(n("ScheduledPayment"), func(t *testing.T) {
+			tx, err := store.Begin()
+			if err != nil {
+				t.Fatal(n("establish transaction"), err)
+			}
+
+			// Test CreateScheduledPayment
+			payAt := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+			payTo := []giga.PayTo{{Amount: decimal.NewFromInt(5), PayTo: addr2}}
+			sp1, err := tx.CreateScheduledPayment(giga.ScheduledPayment{AccountID: addr1, PayTo: payTo, Memo: "payroll", PayAt: payAt})
+			if err != nil {
+				t.Fatal(n("CreateScheduledPayment"), err)
+			}
+			sp2, err := tx.CreateScheduledPayment(giga.ScheduledPayment{AccountID: addr1, PayTo: payTo, PayAtHeight: 5000000, LockTime: true, PaymentID: 9})
+			if err != nil {
+				t.Fatal(n("CreateScheduledPayment"), err)
+			}
+			if sp1.Status != giga.SchedulePending || sp2.ID <= sp1.ID {
+				t.Fatal(n("CreateScheduledPayment: wrong status or ID"), sp1, sp2)
+			}
+
+			// Test GetScheduledPayment
+			retrieved, err := tx.GetScheduledPayment(addr1, sp1.ID)
+			if err != nil {
+				t.Fatal(n("GetScheduledPayment"), err)
+			}
+			if !retrieved.PayAt.Equal(payAt) || retrieved.PayAtHeight != 0 || retrieved.Memo != "payroll" ||
+				len(retrieved.PayTo) != 1 || retrieved.PayTo[0].PayTo != addr2 || !retrieved.NextAttempt.IsZero() {
+				t.Fatal(n("GetScheduledPayment: wrong scheduled payment details"), retrieved)
+			}
+			retrieved, err = tx.GetScheduledPayment(addr1, sp2.ID)
+			if err != nil {
+				t.Fatal(n("GetScheduledPayment"), err)
+			}
+			if !retrieved.PayAt.IsZero() || retrieved.PayAtHeight != 5000000 || !retrieved.LockTime || retrieved.PaymentID != 9 {
+				t.Fatal(n("GetScheduledPayment: wrong scheduled payment details"), retrieved)
+			}
+			_, err = tx.GetScheduledPayment(addr2, sp1.ID)
+			if !giga.IsNotFoundError(err) {
+				t.Fatal(n("GetScheduledPayment: expected NotFound for another account"), err)
+			}
+
+			// Test UpdateScheduledPayment
+			retry := time.Date(2030, 1, 1, 0, 10, 0, 0, time.UTC)
+			sp1.Attempts = 1
+			sp1.NextAttempt = retry
+			sp1.Error = "insufficient funds"
+			err = tx.UpdateScheduledPayment(sp1)
+			if err != nil {
+				t.Fatal(n("UpdateScheduledPayment"), err)
+			}
+			sp2.Status = giga.ScheduleSent
+			sp2.TxID = "abc123"
+			err = tx.UpdateScheduledPayment(sp2)
+			if err != nil {
+				t.Fatal(n("UpdateScheduledPayment"), err)
+			}
+
+			// Test ListPendingScheduledPayments
+			pending, err := tx.ListPendingScheduledPayments(10)
+			if err != nil {
+				t.Fatal(n("ListPendingScheduledPayments"), err)
+			}
+			if len(pending) != 1 || pending[0].ID != sp1.ID || pending[0].Attempts != 1 || !pending[0].NextAttempt.Equal(retry) || pending[0].Error != "insufficient funds" {
+				t.Fatal(n("ListPendingScheduledPayments: expected the retrying payment"), pending)
+			}
+
+			err = tx.Commit()
+			if err != nil {
+				t.Fatal(n("commit transaction"), err)
+			}
+		})
+
+		t.Run(n("Idempotency"), func(t *testing.T) {
+			tx, err := store.Begin()
+			if err != nil {
+				t.Fatal(n("establish transaction"), err)
+			}
+
+			// Test GetIdempotentResponse for an unused key
+			_, err = tx.GetIdempotentResponse(addr1, "key-1")
+			if !giga.IsNotFoundError(err) {
+				t.Fatal(n("GetIdempotentResponse: expected NotFound"), err)
+			}
+
+			// Test StoreIdempotentResponse
+			res := giga.IdempotentResponse{AccountID: addr1, Key: "key-1", RequestHash: "abc", Response: []byte(`{"txid":"123"}`), Created: time.Now()}
+			err = tx.StoreIdempotentResponse(res)
+			if err != nil {
+				t.Fatal(n("StoreIdempotentResponse"), err)
+			}
+			retrieved, err := tx.GetIdempotentResponse(addr1, "key-1")
+			if err != nil {
+				t.Fatal(n("GetIdempotentResponse"), err)
+			}
+			if retrieved.RequestHash != "abc" || string(retrieved.Response) != `{"txid":"123"}` {
+				t.Fatal(n("GetIdempotentResponse: wrong response"), retrieved)
+			}
+
+			// Keys are scoped per account, and cannot be re-used
+			_, err = tx.GetIdempotentResponse(addr2, "key-1")
+			if !giga.IsNotFoundError(err) {
+				t.Fatal(n("GetIdempotentResponse: expected NotFound for another account"), err)
+			}
+			err = tx.StoreIdempotentResponse(res)
+			if !giga.IsAlreadyExistsError(err) {
+				t.Fatal(n("StoreIdempotentResponse: expected AlreadyExists"), err)
+			}
+			tx.Rollback()
+		})
+
+		t.Run(n("VerifiedHeader"), func(t *testing.T) {
+			tx, err := store.Begin()
+			if err != nil {
+				t.Fatal(n("establish transaction"), err)
+			}
+			headers := []giga.VerifiedHeader{
+				{Height: 300, Hash: "aa", Header: "a0"},
+				{Height: 301, Hash: "bb", Header: "b0"},
+			}
+			err = tx.StoreVerifiedHeaders(headers)
+			if err != nil {
+				tx.Rollback()
+				t.Fatal(n("StoreVerifiedHeaders"), err)
+			}
+			// Reorg back to 300 removes the header at 301.
+			_, err = tx.RevertChangesAboveHeight(300, 1, 301, false)
+			if err != nil {
+				tx.Rollback()
+				t.Fatal(n("RevertChangesAboveHeight"), err)
+			}
+			err = tx.Commit()
+			if err != nil {
+				t.Fatal(n("commit"), err)
+			}
+
+			hdr, err := store.GetVerifiedHeader(300)
+			if err != nil || hdr != headers[0] {
+				t.Fatal(n("GetVerifiedHeader: wrong header"), hdr, err)
+			}
+			_, err = store.GetVerifiedHeader(301)
+			if !giga.IsNotFoundError(err) {
+				t.Fatal(n("GetVerifiedHeader: expected NotFound after revert"), err)
+			}
+		})
+
+		t.Run(n("SkippedBlock"), func(t *testing.T) {
+			tx, err := store.Begin()
+			if err != nil {
+				t.Fatal(n("establish transaction"), err)
+			}
+			now := time.Now().UTC().Truncate(time.Second)
+			blocks := []giga.SkippedBlock{
+				{Height: 400, Hash: "cc", Error: "bad block", Skipped: now},
+				{Height: 401, Hash: "dd", Error: "bad block", Skipped: now},
+			}
+			for _, b := range blocks {
+				err = tx.StoreSkippedBlock(b)
+				if err != nil {
+					tx.Rollback()
+					t.Fatal(n("StoreSkippedBlock"), err)
+				}
+			}
+			// Reorg back to 400 removes the skipped block at 401.
+			_, err = tx.RevertChangesAboveHeight(400, 1, 401, false)
+			if err != nil {
+				tx.Rollback()
+				t.Fatal(n("RevertChangesAboveHeight"), err)
+			}
+			err = tx.Commit()
+			if err != nil {
+				t.Fatal(n("commit"), err)
+			}
+
+			list, err := store.ListSkippedBlocks()
+			if err != nil {
+				t.Fatal(n("ListSkippedBlocks"), err)
+			}
+			if len(list) != 1 || list[0].Hash != "cc" || list[0].Height != 400 || list[0].Error != "bad block" || !list[0].Skipped.Equal(now) {
+				t.Fatal(n("ListSkippedBlocks: wrong blocks"), list)
+			}
+		})
+
 	}
 }